@@ -11,6 +11,27 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		var run func([]string) error
+		switch os.Args[1] {
+		case "migrate":
+			run = runMigrate
+		case "rekey":
+			run = runRekey
+		case "recovery-kit":
+			run = runRecoveryKit
+		case "recover":
+			run = runRecover
+		}
+		if run != nil {
+			if err := run(os.Args[2:]); err != nil {
+				slog.Error(err.Error())
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
 	config, err := server.LoadConfig()
 	if err != nil {
 		slog.Error(err.Error())
@@ -22,6 +43,8 @@ func main() {
 		slog.Group("config",
 			"port", config.Port,
 			"data", config.DatabaseFile,
+			"indexKey", config.IndexKeyFile,
+			"hosts", config.Hosts,
 		),
 	)
 	server, err := server.NewServer(config)
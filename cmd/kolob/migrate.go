@@ -0,0 +1,226 @@
+// ---------------------------------------------------------------------------------------------- //
+// -- Copyright (c) 2024 Braden Hitchcock - MIT License (https://opensource.org/licenses/MIT)  -- //
+// ---------------------------------------------------------------------------------------------- //
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bradenhc/kolob/internal/crypto"
+	"github.com/bradenhc/kolob/internal/server"
+	"github.com/bradenhc/kolob/internal/services"
+	"github.com/bradenhc/kolob/internal/store/sqlite"
+)
+
+// runMigrate implements the migrate subcommand, which applies pending schema migrations to the
+// database or reports on them.
+func runMigrate(args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	data := flags.String("data", "", "The path to the database file where data is stored.")
+	status := flags.Bool("status", false, "Show which migrations have been applied and exit.")
+	dryRun := flags.Bool(
+		"dry-run", false, "Check that the pending migrations apply without changing the database.",
+	)
+	gid := flags.String(
+		"group", "", "The group ID, needed by migrations that rewrite encrypted data.",
+	)
+	ikeyFile := flags.String(
+		"index-key", "", "The path to the file holding the key used to index usernames.",
+	)
+
+	flags.Usage = func() {
+		println := func(format string, a ...any) {
+			fmt.Fprintf(flags.Output(), format, a...)
+			fmt.Fprint(flags.Output(), "\n")
+		}
+
+		println("")
+		println("usage:  %s migrate [options...]", filepath.Base(os.Args[0]))
+		println("")
+		println("Applies pending schema migrations to the Kolob database. Migrations that rewrite")
+		println("encrypted data need the group ID and password; the password is read from the")
+		println("KOLOB_GROUP_PASSWORD environment variable or prompted for. Migrations that")
+		println("recompute blind indexes also need the index key used by the server.")
+		println("")
+		flags.PrintDefaults()
+		println("")
+	}
+
+	flags.Parse(args)
+
+	dbpath, err := databaseFile(*data)
+	if err != nil {
+		return err
+	}
+
+	db, err := sqlite.Connect(dbpath)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+
+	if *status {
+		statuses, err := sqlite.MigrationStatuses(ctx, db)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tREWRITES DATA\tAPPLIED")
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%t\t%s\n", s.Version, s.Name, s.RewritesData(), applied)
+		}
+		return w.Flush()
+	}
+
+	ikey, err := indexKey(ctx, db, *ikeyFile, dbpath)
+	if err != nil {
+		return err
+	}
+
+	// Only ask for the group password when a migration actually has encrypted data to rewrite
+	applied, err := sqlite.Migrate(ctx, db, nil, ikey, *dryRun)
+	if errors.Is(err, sqlite.ErrKeyRequired) {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+
+		var key crypto.Key
+		key, err = groupKey(ctx, db, ikey, *gid)
+		if err != nil {
+			return err
+		}
+
+		var more []sqlite.Migration
+		more, err = sqlite.Migrate(ctx, db, key, ikey, *dryRun)
+		if *dryRun {
+			applied = more
+		} else {
+			applied = append(applied, more...)
+		}
+	}
+
+	verb := "Applied"
+	if *dryRun {
+		verb = "Would apply"
+	}
+	for _, m := range applied {
+		fmt.Printf("%s migration %d (%s)\n", verb, m.Version, m.Name)
+	}
+	if err != nil {
+		return err
+	}
+	if len(applied) == 0 {
+		fmt.Println("Database is up to date")
+	}
+
+	return nil
+}
+
+// databaseFile returns the path to the database, using the same defaults as the server.
+func databaseFile(flagValue string) (string, error) {
+	if flagValue != "" {
+		return flagValue, nil
+	}
+	if val := os.Getenv("KOLOB_DATA"); val != "" {
+		return val, nil
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+
+	return path.Join(cwd, "kolob.db"), nil
+}
+
+// indexKey loads the index key from the same file as the server. Like the server, it only creates
+// the key if the database doesn't hold a group with blind indexes yet.
+func indexKey(
+	ctx context.Context, db *sql.DB, flagValue, dbpath string,
+) (crypto.IndexKey, error) {
+	p := flagValue
+	if p == "" {
+		p = os.Getenv("KOLOB_INDEX_KEY")
+	}
+	if p == "" {
+		p = server.DefaultIndexKeyFile(dbpath)
+	}
+
+	inUse, err := sqlite.IndexKeyInUse(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	return crypto.LoadIndexKey(p, !inUse)
+}
+
+// groupKey unlocks the group data key with the group ID and password. The database may be only
+// partly migrated, so the group is unlocked without going through the group service.
+func groupKey(
+	ctx context.Context, db *sql.DB, ikey crypto.IndexKey, gid string,
+) (crypto.Key, error) {
+	if gid == "" {
+		return nil, fmt.Errorf("the -group flag is required to rewrite encrypted data")
+	}
+
+	pass, err := groupPassword()
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := sqlite.UnlockGroupKey(ctx, db, gid, crypto.Password(pass), ikey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unlock group: %v", err)
+	}
+
+	return key, nil
+}
+
+// groupPassword reads the group password from the environment or prompts for it.
+func groupPassword() (string, error) {
+	if pass := os.Getenv("KOLOB_GROUP_PASSWORD"); pass != "" {
+		return pass, nil
+	}
+
+	return readLine(bufio.NewReader(os.Stdin), "Group password")
+}
+
+// readLine prompts for a line of input and reads it.
+func readLine(r *bufio.Reader, prompt string) (string, error) {
+	fmt.Fprintf(os.Stderr, "%s: ", prompt)
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %v", strings.ToLower(prompt), err)
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func newGroupService(db *sql.DB, ikey crypto.IndexKey) services.GroupService {
+	return services.NewGroupService(
+		sqlite.NewGroupStore(db),
+		sqlite.NewMemberStore(db),
+		sqlite.NewConversationStore(db),
+		sqlite.NewInvitationStore(db),
+		sqlite.NewSessionStore(db),
+		sqlite.NewRekeyStore(db),
+		sqlite.NewTransactor(db),
+		ikey,
+	)
+}
@@ -0,0 +1,196 @@
+// ---------------------------------------------------------------------------------------------- //
+// -- Copyright (c) 2024 Braden Hitchcock - MIT License (https://opensource.org/licenses/MIT)  -- //
+// ---------------------------------------------------------------------------------------------- //
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/bradenhc/kolob/internal/crypto"
+	"github.com/bradenhc/kolob/internal/services"
+	"github.com/bradenhc/kolob/internal/store/sqlite"
+	flatbuffers "github.com/google/flatbuffers/go"
+)
+
+// runRecoveryKit implements the recovery-kit subcommand, which creates a new recovery kit for the
+// group and prints its shares.
+func runRecoveryKit(args []string) error {
+	flags := flag.NewFlagSet("recovery-kit", flag.ExitOnError)
+	data := flags.String("data", "", "The path to the database file where data is stored.")
+	ikeyFile := flags.String(
+		"index-key", "", "The path to the file holding the key used to index usernames.",
+	)
+	shares := flags.Int("shares", 3, "The number of shares to split the recovery key into.")
+	threshold := flags.Int("threshold", 2, "The number of shares needed to recover the group.")
+
+	flags.Usage = func() {
+		println := func(format string, a ...any) {
+			fmt.Fprintf(flags.Output(), format, a...)
+			fmt.Fprint(flags.Output(), "\n")
+		}
+
+		println("")
+		println("usage:  %s recovery-kit [options...]", filepath.Base(os.Args[0]))
+		println("")
+		println("Creates a recovery kit that can set a new group password if the current one is")
+		println("lost. The recovery key is split into shares and printed; give each share to a")
+		println("different person. Any threshold of the shares recover the group with the")
+		println("recover command. Creating a new kit stops the shares of the old one from working.")
+		println("The group password is read from the KOLOB_GROUP_PASSWORD environment variable or")
+		println("prompted for.")
+		println("")
+		flags.PrintDefaults()
+		println("")
+	}
+
+	flags.Parse(args)
+
+	if *shares < 0 || *shares > 255 || *threshold < 0 || *threshold > 255 {
+		return fmt.Errorf("invalid threshold %d of %d shares", *threshold, *shares)
+	}
+
+	dbpath, err := databaseFile(*data)
+	if err != nil {
+		return err
+	}
+
+	db, err := sqlite.Open(dbpath)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	ikey, err := indexKey(context.Background(), db, *ikeyFile, dbpath)
+	if err != nil {
+		return err
+	}
+
+	pass, err := groupPassword()
+	if err != nil {
+		return err
+	}
+
+	builder := flatbuffers.NewBuilder(64)
+	passOffset := builder.CreateString(pass)
+	services.GroupRecoveryKitRequestStart(builder)
+	services.GroupRecoveryKitRequestAddPassword(builder, passOffset)
+	services.GroupRecoveryKitRequestAddShares(builder, byte(*shares))
+	services.GroupRecoveryKitRequestAddThreshold(builder, byte(*threshold))
+	builder.Finish(services.GroupRecoveryKitRequestEnd(builder))
+
+	req := services.GetRootAsGroupRecoveryKitRequest(builder.FinishedBytes(), 0)
+	kit, err := newGroupService(db, ikey).CreateRecoveryKit(context.Background(), req)
+	if err != nil {
+		return fmt.Errorf("failed to create recovery kit: %v", err)
+	}
+
+	fmt.Printf("Recovery kit created; any %d of these shares recover the group:\n\n", *threshold)
+	for i, s := range kit {
+		fmt.Printf("Share %d: %s\n", i+1, s)
+	}
+
+	return nil
+}
+
+// runRecover implements the recover subcommand, which sets a new group password using the shares
+// of the group's recovery kit.
+func runRecover(args []string) error {
+	flags := flag.NewFlagSet("recover", flag.ExitOnError)
+	data := flags.String("data", "", "The path to the database file where data is stored.")
+	ikeyFile := flags.String(
+		"index-key", "", "The path to the file holding the key used to index usernames.",
+	)
+
+	flags.Usage = func() {
+		println := func(format string, a ...any) {
+			fmt.Fprintf(flags.Output(), format, a...)
+			fmt.Fprint(flags.Output(), "\n")
+		}
+
+		println("")
+		println("usage:  %s recover [options...]", filepath.Base(os.Args[0]))
+		println("")
+		println("Sets a new group password using the shares of the group's recovery kit. The shares")
+		println("are prompted for, one per line, until there are enough of them. The new group")
+		println("password is read from the KOLOB_NEW_GROUP_PASSWORD environment variable or")
+		println("prompted for. The server should be stopped first.")
+		println("")
+		flags.PrintDefaults()
+		println("")
+	}
+
+	flags.Parse(args)
+
+	dbpath, err := databaseFile(*data)
+	if err != nil {
+		return err
+	}
+
+	db, err := sqlite.Open(dbpath)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	ikey, err := indexKey(context.Background(), db, *ikeyFile, dbpath)
+	if err != nil {
+		return err
+	}
+
+	// The first share says how many are needed
+	r := bufio.NewReader(os.Stdin)
+	shares := make([]string, 0)
+	for threshold := 1; len(shares) < threshold; {
+		line, err := readLine(r, fmt.Sprintf("Recovery share %d", len(shares)+1))
+		if err != nil {
+			return err
+		}
+
+		s, err := crypto.ParseShare(line)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v, try again\n", err)
+			continue
+		}
+
+		threshold = int(s.Threshold)
+		shares = append(shares, line)
+	}
+
+	pass := os.Getenv("KOLOB_NEW_GROUP_PASSWORD")
+	if pass == "" {
+		pass, err = readLine(r, "New group password")
+		if err != nil {
+			return err
+		}
+	}
+
+	builder := flatbuffers.NewBuilder(256)
+	offsets := make([]flatbuffers.UOffsetT, len(shares))
+	for i, s := range shares {
+		offsets[i] = builder.CreateString(s)
+	}
+	services.GroupRecoverRequestStartSharesVector(builder, len(offsets))
+	for i := len(offsets) - 1; i >= 0; i-- {
+		builder.PrependUOffsetT(offsets[i])
+	}
+	sharesOffset := builder.EndVector(len(offsets))
+	passOffset := builder.CreateString(pass)
+	services.GroupRecoverRequestStart(builder)
+	services.GroupRecoverRequestAddShares(builder, sharesOffset)
+	services.GroupRecoverRequestAddNewPassword(builder, passOffset)
+	builder.Finish(services.GroupRecoverRequestEnd(builder))
+
+	req := services.GetRootAsGroupRecoverRequest(builder.FinishedBytes(), 0)
+	err = newGroupService(db, ikey).Recover(context.Background(), req)
+	if err != nil {
+		return fmt.Errorf("failed to recover group: %v", err)
+	}
+
+	fmt.Println("Group password reset")
+	return nil
+}
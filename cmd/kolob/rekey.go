@@ -0,0 +1,114 @@
+// ---------------------------------------------------------------------------------------------- //
+// -- Copyright (c) 2024 Braden Hitchcock - MIT License (https://opensource.org/licenses/MIT)  -- //
+// ---------------------------------------------------------------------------------------------- //
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/bradenhc/kolob/internal/services"
+	"github.com/bradenhc/kolob/internal/store/sqlite"
+	flatbuffers "github.com/google/flatbuffers/go"
+)
+
+// runRekey implements the rekey subcommand, which replaces the group data key and re-encrypts all
+// of the group data with the new key.
+func runRekey(args []string) error {
+	flags := flag.NewFlagSet("rekey", flag.ExitOnError)
+	data := flags.String("data", "", "The path to the database file where data is stored.")
+	ikeyFile := flags.String(
+		"index-key", "", "The path to the file holding the key used to index usernames.",
+	)
+	shares := flags.Int("shares", 0, "The number of shares to split the new recovery key into.")
+	threshold := flags.Int("threshold", 0, "The number of shares needed to recover the group.")
+
+	flags.Usage = func() {
+		println := func(format string, a ...any) {
+			fmt.Fprintf(flags.Output(), format, a...)
+			fmt.Fprint(flags.Output(), "\n")
+		}
+
+		println("")
+		println("usage:  %s rekey [options...]", filepath.Base(os.Args[0]))
+		println("")
+		println("Replaces the group data key with a new one and re-encrypts all of the group data.")
+		println("The server should be stopped first. If the rotation is interrupted, run the command")
+		println("again to finish it. The group password is read from the KOLOB_GROUP_PASSWORD")
+		println("environment variable or prompted for.")
+		println("")
+		println("Rotating the key replaces the recovery kit, and the shares of the old kit stop")
+		println("working. If the group has a recovery kit, -shares and -threshold are required and")
+		println("the shares of the new kit are printed.")
+		println("")
+		flags.PrintDefaults()
+		println("")
+	}
+
+	flags.Parse(args)
+
+	if *shares < 0 || *shares > 255 || *threshold < 0 || *threshold > 255 {
+		return fmt.Errorf("invalid threshold %d of %d shares", *threshold, *shares)
+	}
+
+	dbpath, err := databaseFile(*data)
+	if err != nil {
+		return err
+	}
+
+	db, err := sqlite.Open(dbpath)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	ikey, err := indexKey(context.Background(), db, *ikeyFile, dbpath)
+	if err != nil {
+		return err
+	}
+
+	pass, err := groupPassword()
+	if err != nil {
+		return err
+	}
+
+	builder := flatbuffers.NewBuilder(64)
+	passOffset := builder.CreateString(pass)
+	services.GroupRekeyRequestStart(builder)
+	services.GroupRekeyRequestAddPassword(builder, passOffset)
+	services.GroupRekeyRequestAddShares(builder, byte(*shares))
+	services.GroupRekeyRequestAddThreshold(builder, byte(*threshold))
+	builder.Finish(services.GroupRekeyRequestEnd(builder))
+
+	req := services.GetRootAsGroupRekeyRequest(builder.FinishedBytes(), 0)
+	gs := newGroupService(db, ikey)
+
+	ctx := context.Background()
+	err = gs.CheckRekeyRecoveryKit(ctx, req)
+	if errors.Is(err, services.ErrRecoveryKitRequired) {
+		return fmt.Errorf("%v; give its size with -shares and -threshold", err)
+	}
+	if err != nil {
+		return err
+	}
+
+	kit, err := gs.Rekey(ctx, req)
+	if err != nil {
+		return fmt.Errorf("failed to rotate group data key: %v", err)
+	}
+
+	fmt.Println("Group data key rotated; members need to log in again")
+	if len(kit) != 0 {
+		fmt.Printf("\nRecovery kit replaced; any %d of these shares recover the group:\n\n",
+			*threshold)
+		for i, s := range kit {
+			fmt.Printf("Share %d: %s\n", i+1, s)
+		}
+	}
+
+	return nil
+}
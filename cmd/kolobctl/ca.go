@@ -0,0 +1,289 @@
+// ---------------------------------------------------------------------------------------------- //
+// -- Copyright (c) 2024 Braden Hitchcock - MIT License (https://opensource.org/licenses/MIT)  -- //
+// ---------------------------------------------------------------------------------------------- //
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"html"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bradenhc/kolob/internal/crypto"
+	"github.com/bradenhc/kolob/internal/model"
+	"github.com/bradenhc/kolob/internal/server"
+)
+
+// runCA implements the ca command, which manages a local certificate authority for servers that
+// can't get a certificate from a public one, such as servers on a church building LAN.
+func runCA(args []string) error {
+	var run func([]string) error
+	if len(args) > 0 {
+		switch args[0] {
+		case "init":
+			run = runCAInit
+		case "issue":
+			run = runCAIssue
+		case "export":
+			run = runCAExport
+		}
+	}
+	if run == nil {
+		usage()
+		return fmt.Errorf("unknown ca command")
+	}
+
+	return run(args[1:])
+}
+
+// runCAInit creates a new certificate authority next to the database, where the server finds it.
+func runCAInit(args []string) error {
+	flags := caFlagSet("init", "Creates a local certificate authority that the server uses to issue "+
+		"its certificate. Install the exported authority on each device once and it will trust the "+
+		"server from then on.")
+	dir := flags.String("dir", "", "The directory holding the database and certificates.")
+	name := flags.String("name", "Kolob Local CA", "The name devices show for the authority.")
+	force := flags.Bool("force", false, "Replace an existing certificate authority.")
+	flags.Parse(args)
+
+	d, err := dataDir(*dir)
+	if err != nil {
+		return err
+	}
+	certPath := filepath.Join(d, crypto.CACertFileName)
+	keyPath := filepath.Join(d, crypto.CAKeyFileName)
+
+	if _, err := os.Stat(certPath); !errors.Is(err, fs.ErrNotExist) && !*force {
+		return fmt.Errorf("a certificate authority already exists at %s; use -force to replace it "+
+			"and reinstall it on every device", certPath)
+	}
+
+	certPEM, keyPEM, err := crypto.NewCertAuthority(*name)
+	if err != nil {
+		return fmt.Errorf("failed to create certificate authority: %v", err)
+	}
+	err = crypto.SaveCertAndKey(certPEM, keyPEM, certPath, keyPath)
+	if err != nil {
+		return fmt.Errorf("failed to save certificate authority: %v", err)
+	}
+
+	ca, err := crypto.LoadCertAuthority(certPath, keyPath)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Created certificate authority %s\n", certPath)
+	fmt.Printf("SHA-256 fingerprint: %s\n", ca.Fingerprint())
+	fmt.Println("")
+	fmt.Printf("Keep %s secret. Restart the server to use a certificate issued by the\n", keyPath)
+	fmt.Println("authority, then run 'kolobctl ca export' to get files to install on devices.")
+
+	return nil
+}
+
+// runCAIssue issues a server certificate from the certificate authority. The server does this on
+// its own when it starts, so this is only needed for certificates used somewhere else, such as a
+// reverse proxy.
+func runCAIssue(args []string) error {
+	flags := caFlagSet("issue", "Issues a server certificate from the local certificate authority.")
+	dir := flags.String("dir", "", "The directory holding the database and certificates.")
+	hosts := flags.String(
+		"hosts", "", "Comma separated DNS names and IP addresses the certificate is valid for.",
+	)
+	out := flags.String("out", "", "The directory to save the certificate to. Defaults to -dir.")
+	flags.Parse(args)
+
+	ca, d, err := loadCA(*dir)
+	if err != nil {
+		return err
+	}
+	if *out != "" {
+		d = *out
+	}
+
+	h := server.DefaultHosts()
+	if *hosts != "" {
+		h = server.ParseHosts(*hosts)
+	}
+
+	certPEM, keyPEM, err := ca.IssueCert(h)
+	if err != nil {
+		return fmt.Errorf("failed to issue certificate: %v", err)
+	}
+
+	certPath := filepath.Join(d, "kolob-tls.crt")
+	err = crypto.SaveCertAndKey(certPEM, keyPEM, certPath, filepath.Join(d, "kolob-tls.key"))
+	if err != nil {
+		return fmt.Errorf("failed to save certificate: %v", err)
+	}
+
+	fmt.Printf("Issued certificate %s for %s\n", certPath, strings.Join(h, ", "))
+	return nil
+}
+
+// caExportFormats are the formats the certificate authority can be exported in, by the extension
+// of the file they are saved to.
+var caExportFormats = map[string]func(*crypto.CertAuthority) ([]byte, error){
+	// PEM works for Linux, macOS, ChromeOS, and Firefox
+	"pem": func(ca *crypto.CertAuthority) ([]byte, error) { return ca.PEM(), nil },
+
+	// DER works for Windows and Android
+	"cer": func(ca *crypto.CertAuthority) ([]byte, error) { return ca.DER(), nil },
+
+	// A configuration profile can be opened directly on iPhones and iPads
+	"mobileconfig": mobileConfig,
+}
+
+// runCAExport saves the certificate of the certificate authority in formats that devices can
+// install. The private key is never exported.
+func runCAExport(args []string) error {
+	flags := caFlagSet("export", "Exports the local certificate authority for installing on "+
+		"devices. Each format is saved as kolob-ca.<format>.")
+	dir := flags.String("dir", "", "The directory holding the database and certificates.")
+	out := flags.String("out", ".", "The directory to save the exported files to.")
+	format := flags.String("format", "", "Export only one format: pem, cer, or mobileconfig.")
+	flags.Parse(args)
+
+	ca, _, err := loadCA(*dir)
+	if err != nil {
+		return err
+	}
+
+	formats := []string{"pem", "cer", "mobileconfig"}
+	if *format != "" {
+		if _, ok := caExportFormats[*format]; !ok {
+			return fmt.Errorf("unknown export format: %s", *format)
+		}
+		formats = []string{*format}
+	}
+
+	for _, f := range formats {
+		data, err := caExportFormats[f](ca)
+		if err != nil {
+			return err
+		}
+
+		p := filepath.Join(*out, "kolob-ca."+f)
+		err = os.WriteFile(p, data, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to save %s: %v", p, err)
+		}
+		fmt.Printf("Exported %s\n", p)
+	}
+
+	fmt.Printf("SHA-256 fingerprint: %s\n", ca.Fingerprint())
+	return nil
+}
+
+func caFlagSet(name, description string) *flag.FlagSet {
+	flags := flag.NewFlagSet("ca "+name, flag.ExitOnError)
+	flags.Usage = func() {
+		println := func(format string, a ...any) {
+			fmt.Fprintf(flags.Output(), format, a...)
+			fmt.Fprint(flags.Output(), "\n")
+		}
+
+		println("")
+		println("usage:  %s ca %s [options...]", filepath.Base(os.Args[0]), name)
+		println("")
+		println("%s", description)
+		println("")
+		flags.PrintDefaults()
+		println("")
+	}
+
+	return flags
+}
+
+// dataDir returns the directory holding the database, using the same defaults as the server.
+func dataDir(flagValue string) (string, error) {
+	if flagValue != "" {
+		return flagValue, nil
+	}
+	if val := os.Getenv("KOLOB_DATA"); val != "" {
+		return filepath.Dir(val), nil
+	}
+
+	return os.Getwd()
+}
+
+// loadCA loads the certificate authority kept next to the database, returning the directory it is
+// in along with it.
+func loadCA(dir string) (*crypto.CertAuthority, string, error) {
+	d, err := dataDir(dir)
+	if err != nil {
+		return nil, "", err
+	}
+
+	certPath := filepath.Join(d, crypto.CACertFileName)
+	if _, err := os.Stat(certPath); errors.Is(err, fs.ErrNotExist) {
+		return nil, "", fmt.Errorf("no certificate authority at %s; run 'kolobctl ca init'", certPath)
+	}
+
+	ca, err := crypto.LoadCertAuthority(certPath, filepath.Join(d, crypto.CAKeyFileName))
+	if err != nil {
+		return nil, "", err
+	}
+
+	return ca, d, nil
+}
+
+// mobileConfig wraps the certificate authority in an Apple configuration profile.
+func mobileConfig(ca *crypto.CertAuthority) ([]byte, error) {
+	profileId, err := model.NewUuid()
+	if err != nil {
+		return nil, err
+	}
+	certId, err := model.NewUuid()
+	if err != nil {
+		return nil, err
+	}
+
+	name := html.EscapeString(ca.Cert.Subject.CommonName)
+	profile := fmt.Sprintf(mobileConfigTemplate,
+		base64.StdEncoding.EncodeToString(ca.DER()),
+		name, certId, certId, name, profileId, profileId,
+	)
+
+	return []byte(profile), nil
+}
+
+const mobileConfigTemplate = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>PayloadContent</key>
+	<array>
+		<dict>
+			<key>PayloadContent</key>
+			<data>%s</data>
+			<key>PayloadDisplayName</key>
+			<string>%s</string>
+			<key>PayloadIdentifier</key>
+			<string>org.kolob.ca.%s</string>
+			<key>PayloadType</key>
+			<string>com.apple.security.root</string>
+			<key>PayloadUUID</key>
+			<string>%s</string>
+			<key>PayloadVersion</key>
+			<integer>1</integer>
+		</dict>
+	</array>
+	<key>PayloadDisplayName</key>
+	<string>%s</string>
+	<key>PayloadIdentifier</key>
+	<string>org.kolob.profile.%s</string>
+	<key>PayloadType</key>
+	<string>Configuration</string>
+	<key>PayloadUUID</key>
+	<string>%s</string>
+	<key>PayloadVersion</key>
+	<integer>1</integer>
+</dict>
+</plist>
+`
@@ -3,8 +3,42 @@
 // ---------------------------------------------------------------------------------------------- //
 package main
 
-import "fmt"
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
 
 func main() {
-	fmt.Println("Hello, world!")
+	var run func([]string) error
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "ca":
+			run = runCA
+		}
+	}
+	if run == nil {
+		usage()
+		os.Exit(2)
+	}
+
+	if err := run(os.Args[2:]); err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	name := filepath.Base(os.Args[0])
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "usage:  %s <command> [options...]\n", name)
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "Administers a Kolob server.\n")
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Fprintf(os.Stderr, "commands:\n")
+	fmt.Fprintf(os.Stderr, "  ca init    Create a local certificate authority\n")
+	fmt.Fprintf(os.Stderr, "  ca issue   Issue a server certificate from the certificate authority\n")
+	fmt.Fprintf(os.Stderr, "  ca export  Export the certificate authority for installing on devices\n")
+	fmt.Fprintf(os.Stderr, "\n")
 }
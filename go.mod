@@ -5,6 +5,7 @@ go 1.22.0
 require (
 	github.com/google/flatbuffers v24.3.25+incompatible
 	golang.org/x/crypto v0.17.0
+	golang.org/x/net v0.19.0
 	modernc.org/sqlite v1.33.1
 )
 
@@ -0,0 +1,145 @@
+// ---------------------------------------------------------------------------------------------- //
+// -- Copyright (c) 2024 Braden Hitchcock - MIT License (https://opensource.org/licenses/MIT)  -- //
+// ---------------------------------------------------------------------------------------------- //
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CACertFileName and CAKeyFileName are the names of the files that hold a certificate authority
+// when it is kept next to the database.
+const (
+	CACertFileName = "kolob-ca.crt"
+	CAKeyFileName  = "kolob-ca.key"
+)
+
+// CAValidity is how long a certificate authority created by NewCertAuthority is valid. Devices only
+// need to trust the authority once, so it lasts much longer than the certificates it issues.
+const CAValidity = 10 * 365 * 24 * time.Hour
+
+// A CertAuthority is a small local root certificate authority that issues server certificates. Once
+// a device trusts the authority, it trusts every certificate the authority issues, so the server
+// certificate can be renewed or given new hosts without touching the devices again.
+type CertAuthority struct {
+	Cert *x509.Certificate
+	Key  *ecdsa.PrivateKey
+}
+
+// NewCertAuthority generates the certificate and private key of a new certificate authority with
+// the provided name. It returns the certificate and key in PEM format, or an error if the generation
+// fails.
+func NewCertAuthority(name string) ([]byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	serialNumber, err := newSerialNumber()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	notBefore := time.Now()
+	template := x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject: pkix.Name{
+			Organization: []string{"Kolob"},
+			CommonName:   name,
+		},
+		NotBefore: notBefore,
+		NotAfter:  notBefore.Add(CAValidity),
+
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+
+	certDER, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+
+	return certPEM, keyPEM, nil
+}
+
+// LoadCertAuthority reads the certificate and private key of a certificate authority from the PEM
+// files at the provided paths.
+func LoadCertAuthority(certPath, keyPath string) (*CertAuthority, error) {
+	pair, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load certificate authority: %v", err)
+	}
+
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate authority: %v", err)
+	}
+	if !cert.IsCA {
+		return nil, fmt.Errorf("certificate %s is not a certificate authority", certPath)
+	}
+	if time.Now().After(cert.NotAfter) {
+		expired := cert.NotAfter.Format(time.DateOnly)
+		return nil, fmt.Errorf("certificate authority expired on %s", expired)
+	}
+
+	key, ok := pair.PrivateKey.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("certificate authority key must be an ECDSA key")
+	}
+
+	return &CertAuthority{cert, key}, nil
+}
+
+// IssueCert generates a server certificate and private key for the provided hosts, which may be DNS
+// names or IP addresses, signed by the certificate authority. It returns the certificate and key in
+// PEM format.
+func (ca *CertAuthority) IssueCert(hosts []string) ([]byte, []byte, error) {
+	if len(hosts) == 0 {
+		return nil, nil, fmt.Errorf("at least one host is required")
+	}
+
+	return generateCert(hosts, ca)
+}
+
+// DER returns the certificate of the certificate authority in DER format, which is what most phones
+// and Windows expect when installing a root certificate.
+func (ca *CertAuthority) DER() []byte {
+	return ca.Cert.Raw
+}
+
+// PEM returns the certificate of the certificate authority in PEM format.
+func (ca *CertAuthority) PEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Cert.Raw})
+}
+
+// Fingerprint returns the SHA-256 fingerprint of the certificate authority as colon separated
+// hexadecimal, the way devices show it when asking whether to trust a certificate.
+func (ca *CertAuthority) Fingerprint() string {
+	sum := sha256.Sum256(ca.Cert.Raw)
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+
+	return strings.Join(parts, ":")
+}
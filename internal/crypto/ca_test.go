@@ -0,0 +1,91 @@
+// ---------------------------------------------------------------------------------------------- //
+// -- Copyright (c) 2024 Braden Hitchcock - MIT License (https://opensource.org/licenses/MIT)  -- //
+// ---------------------------------------------------------------------------------------------- //
+package crypto_test
+
+import (
+	"bytes"
+	"crypto/x509"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/bradenhc/kolob/internal/crypto"
+)
+
+func TestCertAuthority(t *testing.T) {
+	dir := t.TempDir()
+	caCertPath := path.Join(dir, crypto.CACertFileName)
+	caKeyPath := path.Join(dir, crypto.CAKeyFileName)
+
+	certPEM, keyPEM, err := crypto.NewCertAuthority("Test CA")
+	if err != nil {
+		t.Fatalf("failed to create certificate authority: %v", err)
+	}
+	err = crypto.SaveCertAndKey(certPEM, keyPEM, caCertPath, caKeyPath)
+	if err != nil {
+		t.Fatalf("failed to save certificate authority: %v", err)
+	}
+	ca, err := crypto.LoadCertAuthority(caCertPath, caKeyPath)
+	if err != nil {
+		t.Fatalf("failed to load certificate authority: %v", err)
+	}
+	if !bytes.Equal(ca.PEM(), certPEM) {
+		t.Errorf("exported certificate authority does not match the saved one")
+	}
+	if len(ca.Fingerprint()) != 32*3-1 {
+		t.Errorf("wrong fingerprint length: %s", ca.Fingerprint())
+	}
+
+	// A server certificate can't be used as a certificate authority
+	leafCertPath := path.Join(dir, "kolob-tls.crt")
+	leafKeyPath := path.Join(dir, "kolob-tls.key")
+	hosts := []string{"localhost", "kolob.lan", "192.168.1.10"}
+	doTestCertAuthorityEnsure(t, leafCertPath, leafKeyPath, hosts, nil)
+	_, err = crypto.LoadCertAuthority(leafCertPath, leafKeyPath)
+	if err == nil {
+		t.Errorf("loaded a server certificate as a certificate authority")
+	}
+
+	// The self-signed certificate is replaced by one issued by the certificate authority
+	leaf := doTestCertAuthorityEnsure(t, leafCertPath, leafKeyPath, hosts, ca)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Cert)
+	for _, h := range hosts {
+		_, err := leaf.Verify(x509.VerifyOptions{DNSName: h, Roots: roots})
+		if err != nil {
+			t.Errorf("issued certificate is not trusted for %s: %v", h, err)
+		}
+	}
+
+	// The issued certificate is kept as long as it is still good
+	again := doTestCertAuthorityEnsure(t, leafCertPath, leafKeyPath, hosts, ca)
+	if !again.Equal(leaf) {
+		t.Errorf("issued certificate was replaced")
+	}
+
+	// A certificate for no hosts is useless
+	_, _, err = ca.IssueCert(nil)
+	if err == nil {
+		t.Errorf("issued a certificate without hosts")
+	}
+}
+
+func doTestCertAuthorityEnsure(
+	t *testing.T, certPath, keyPath string, hosts []string, ca *crypto.CertAuthority,
+) *x509.Certificate {
+	err := crypto.EnsureCert(certPath, keyPath, hosts, ca)
+	if err != nil {
+		t.Fatalf("failed to ensure certificate: %v", err)
+	}
+
+	r, err := crypto.NewCertReloader(certPath, keyPath)
+	if err != nil {
+		t.Fatalf("failed to load certificate: %v", err)
+	}
+	if _, err := os.Stat(keyPath); err != nil {
+		t.Fatalf("key was not saved: %v", err)
+	}
+
+	return r.Certificate()
+}
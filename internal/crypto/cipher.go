@@ -4,28 +4,27 @@
 package crypto
 
 import (
-	"crypto/aes"
-	"crypto/cipher"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"math/big"
 	"strings"
 	"unicode"
 
 	"golang.org/x/crypto/bcrypt"
-	"golang.org/x/crypto/pbkdf2"
 )
 
 const (
-	// Iterations is the number of iterations used when generating a key with PBKDF2.
+	// Iterations is the number of iterations used when generating a key with PBKDF2 (see
+	// LegacyKdfParams).
 	Iterations = 1000000
 
-	// KeyLength is the length of the key generated by PBKDF2 for encrypting data.
+	// KeyLength is the length of the key derived from a password for encrypting data.
 	KeyLength = 32
 
-	// SaltLength is the length of the salt used by PBKDF2 when generating a key.
+	// SaltLength is the length of the salt used when deriving a key from a password.
 	SaltLength = 32
 
 	// MinPasswordLength is the minimum number of characters that must be contained in a
@@ -40,6 +39,10 @@ const (
 	HashCost = 14
 )
 
+// ErrInvalidPassword is returned by NewPassword for a value that doesn't meet the criteria for a
+// password.
+var ErrInvalidPassword = errors.New("invalid password")
+
 // Password is a user-provided string that has been validated and meets all criteria for a password.
 type Password string
 
@@ -107,16 +110,89 @@ func NewPassword(val string) (Password, error) {
 	}
 
 	if len(fails) > 0 {
-		return "", fmt.Errorf("password must contain %v", strings.Join(fails, ", "))
+		return "", fmt.Errorf("%w: must contain %v", ErrInvalidPassword, strings.Join(fails, ", "))
 	}
 
 	return Password(val), nil
 }
 
+// temporaryAlphabet and codeAlphabet leave out the characters that are easy to mistake for one
+// another when a password or code is read aloud or written down, such as 0 and O or 1 and l. Codes
+// only use uppercase letters so that they can be typed in either case.
+const (
+	temporaryAlphabet = "abcdefghjkmnpqrstuvwxyzABCDEFGHJKMNPQRSTUVWXYZ23456789"
+	codeAlphabet      = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+)
+
+// NewTemporaryPassword generates a random password that meets the password criteria for Kolob, for
+// a Group Moderator to hand to a member who forgot theirs. It is four groups of five characters
+// separated by dashes, so that it is easy to read out and type.
+func NewTemporaryPassword() (Password, error) {
+	for {
+		val, err := randomGroups(temporaryAlphabet, 4, 5)
+		if err != nil {
+			return "", fmt.Errorf("failed to create temporary password: %v", err)
+		}
+
+		// Try again in the rare case that a kind of character didn't come up
+		if p, err := NewPassword(val); err == nil {
+			return p, nil
+		}
+	}
+}
+
+// NewInvitationCode generates a random single use code, such as one that lets a new member join a
+// group. It is two groups of five characters separated by a dash.
+func NewInvitationCode() (string, error) {
+	code, err := randomGroups(codeAlphabet, 2, 5)
+	if err != nil {
+		return "", fmt.Errorf("failed to create invitation code: %v", err)
+	}
+
+	return code, nil
+}
+
+// NormalizeInvitationCode puts a code typed by a person in the form NewInvitationCode creates, so
+// that it matches no matter the case or the spaces and dashes in it.
+func NormalizeInvitationCode(code string) string {
+	var b strings.Builder
+	for _, c := range strings.ToUpper(code) {
+		if c == '-' || unicode.IsSpace(c) {
+			continue
+		}
+		if b.Len() > 0 && b.Len()%6 == 5 {
+			b.WriteByte('-')
+		}
+		b.WriteRune(c)
+	}
+
+	return b.String()
+}
+
+// randomGroups returns groups of random characters from the alphabet separated by dashes.
+func randomGroups(alphabet string, groups, size int) (string, error) {
+	n := big.NewInt(int64(len(alphabet)))
+
+	var b strings.Builder
+	for i := range groups * size {
+		if i > 0 && i%size == 0 {
+			b.WriteByte('-')
+		}
+		c, err := rand.Int(rand.Reader, n)
+		if err != nil {
+			return "", err
+		}
+		b.WriteByte(alphabet[c.Int64()])
+	}
+
+	return b.String(), nil
+}
+
 // HashPassword produces a byte hash of the provided password using the Bcrypt algorithm.
 //
 // See https://www.usenix.org/legacy/event/usenix99/provos/provos.pdf for algorithm specifics.
 func HashPassword(password Password) (PassHash, error) {
+	defer releaseKdf(acquireKdf())
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), HashCost)
 	return bytes, err
 }
@@ -127,6 +203,7 @@ func HashPassword(password Password) (PassHash, error) {
 //
 // See https://www.usenix.org/legacy/event/usenix99/provos/provos.pdf for algorithm specifics.
 func CheckPasswordHash(password Password, hash PassHash) bool {
+	defer releaseKdf(acquireKdf())
 	err := bcrypt.CompareHashAndPassword(hash, []byte(password))
 	return err == nil
 }
@@ -166,12 +243,6 @@ func LoadSalt(val []byte) (Salt, error) {
 	return val, nil
 }
 
-// NewDerivedKey uses the PBKDF2 key derivation algorithm to create a 256-bit key that can be used
-// by the AES algorithm for encrypting and decrypting data.
-func NewDerivedKey(pass Password, salt Salt) Key {
-	return pbkdf2.Key([]byte(pass), salt, Iterations, KeyLength, sha256.New)
-}
-
 // NewRandomKey uses a cryptographically strong random generator to create a 256-bit key that can be
 // used by the AES algorithm for encrypting and decrypting data.
 func NewRandomKey() (Key, error) {
@@ -184,54 +255,102 @@ func NewRandomKey() (Key, error) {
 	return key, nil
 }
 
-// Encrypt uses AES-256 to encrypt the provided plaintext and produce a newly allocated byte slice
-// of ciphertext. The byte slice is only valid if err is nil.
+// Zero overwrites a key or other secret so that it doesn't linger in memory once it is no longer
+// needed.
+func Zero(secret []byte) {
+	clear(secret)
+}
+
+// Encrypt uses the default algorithm to encrypt the provided plaintext and produce a newly
+// allocated byte slice holding the ciphertext in an Envelope. The byte slice is only valid if err is
+// nil.
 func Encrypt(key Key, plaintext []byte) (ciphertext []byte, err error) {
+	return EncryptAD(key, plaintext, nil)
+}
 
-	// Prepare the block cipher
-	block, err := aes.NewCipher(key)
-	if err != nil {
-		return
-	}
-	gcm, err := cipher.NewGCM(block)
+// EncryptAD is like Encrypt but also authenticates the additional data (AD), which binds the
+// ciphertext to it. The same additional data must be given to DecryptAD to decrypt the ciphertext.
+// The additional data itself is not encrypted or included in the ciphertext.
+func EncryptAD(key Key, plaintext, ad []byte) (ciphertext []byte, err error) {
+	return EncryptWith(DefaultAlgorithm, key, plaintext, ad)
+}
+
+// EncryptWith is like EncryptAD but uses the given algorithm.
+func EncryptWith(alg Algorithm, key Key, plaintext, ad []byte) (ciphertext []byte, err error) {
+
+	// Prepare the cipher
+	aead, err := alg.newAEAD(key)
 	if err != nil {
 		return
 	}
 
 	// Create a new nonce and fill it with cryptographically strong random values.
-	nonce := make([]byte, gcm.NonceSize())
+	nonce := make([]byte, aead.NonceSize())
 	_, err = rand.Read(nonce)
 	if err != nil {
 		return
 	}
 
-	// Encrypt the plaintext data in the same byte sequence as the nonce. This will make it easy for
-	// us to extract the nonce from the ciphertext when we decrypt it later.
-	ciphertext = gcm.Seal(nonce, nonce, plaintext, nil)
+	// Encrypt the plaintext data after the header and nonce so that Decrypt can tell how to decrypt
+	// it later. The header is authenticated along with the data.
+	e := Envelope{Version: EnvelopeVersion, Algorithm: alg, KeyId: NewKeyId(key), Nonce: nonce}
+	ciphertext = aead.Seal(e.Bytes(), nonce, plaintext, e.additionalData(ad))
 	return
 }
 
-// Decrypt uses AES-256 to decrypt the provided ciphertext and produce a newly allocated byte slice
-// of the plaintext contents.
+// Decrypt decrypts the ciphertext in the provided Envelope and produces a newly allocated byte slice
+// of the plaintext contents. Data encrypted before envelopes existed, which is a bare AES-256-GCM
+// nonce followed by the ciphertext, is also accepted.
 func Decrypt(key Key, ciphertext []byte) (plaintext []byte, err error) {
+	return DecryptAD(key, ciphertext, nil)
+}
 
-	// Prepare the block cipher
-	block, err := aes.NewCipher(key)
+// DecryptAD is like Decrypt but for ciphertext that was bound to additional data by EncryptAD.
+// Decryption fails unless the additional data is the same. Data encrypted before envelopes existed
+// never has additional data, so it is only accepted when ad is nil.
+func DecryptAD(key Key, ciphertext, ad []byte) (plaintext []byte, err error) {
+	e, err := ParseEnvelope(ciphertext)
 	if err != nil {
-		return
+		if ad != nil {
+			return nil, err
+		}
+		return decryptLegacy(key, ciphertext)
+	}
+
+	plaintext, err = e.open(key, ad)
+	if err != nil && ad == nil {
+		// Data from before envelopes existed starts with a random nonce, so it can look like an
+		// envelope by chance
+		if legacy, lerr := decryptLegacy(key, ciphertext); lerr == nil {
+			return legacy, nil
+		}
 	}
-	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return plaintext, nil
+}
+
+// decryptLegacy decrypts data that was encrypted with AES-256-GCM before envelopes existed.
+func decryptLegacy(key Key, ciphertext []byte) (plaintext []byte, err error) {
+
+	// Prepare the block cipher
+	aead, err := AES256GCM.newAEAD(key)
 	if err != nil {
 		return
 	}
 
 	// The ciphertext contains both the nonce and the encrypted data. We need to split the slice
 	// so that we can pass both to Open().
-	delim := gcm.NonceSize()
+	delim := aead.NonceSize()
+	if len(ciphertext) < delim {
+		return nil, ErrInvalidEnvelope
+	}
 	nonce, ciphertext := ciphertext[:delim], ciphertext[delim:]
 
 	// Decrypt
-	plaintext, err = gcm.Open(nil, nonce, ciphertext, nil)
+	plaintext, err = aead.Open(nil, nonce, ciphertext, nil)
 	return
 }
 
@@ -6,6 +6,7 @@ package crypto_test
 import (
 	"bytes"
 	"crypto/rand"
+	"errors"
 	"fmt"
 	"strings"
 	"testing"
@@ -22,7 +23,7 @@ func TestNewPasswordValid(t *testing.T) {
 
 func TestNewPasswordTooShort(t *testing.T) {
 	_, err := crypto.NewPassword("sH0rt!")
-	if err == nil {
+	if !errors.Is(err, crypto.ErrInvalidPassword) {
 		t.Error("Missing expected error")
 	}
 	if !strings.Contains(err.Error(), "at least") {
@@ -70,6 +71,42 @@ func TestNewPasswordMissingSpecial(t *testing.T) {
 	}
 }
 
+func TestNewTemporaryPassword(t *testing.T) {
+	a, err := crypto.NewTemporaryPassword()
+	if err != nil {
+		t.Fatalf("failed to create temporary password: %v", err)
+	}
+	if _, err := crypto.NewPassword(string(a)); err != nil {
+		t.Errorf("temporary password does not meet the password criteria: %v", err)
+	}
+	if len(a) != 23 || strings.Count(string(a), "-") != 3 {
+		t.Errorf("temporary password is not four groups of five: %s", a)
+	}
+
+	b, err := crypto.NewTemporaryPassword()
+	if err != nil {
+		t.Fatalf("failed to create temporary password: %v", err)
+	}
+	if a == b {
+		t.Errorf("temporary passwords should be random")
+	}
+}
+
+func TestNewInvitationCode(t *testing.T) {
+	code, err := crypto.NewInvitationCode()
+	if err != nil {
+		t.Fatalf("failed to create invitation code: %v", err)
+	}
+	if len(code) != 11 || code[5] != '-' {
+		t.Errorf("invitation code is not two groups of five: %s", code)
+	}
+
+	typed := " " + strings.ToLower(strings.ReplaceAll(code, "-", " ")) + "\n"
+	if n := crypto.NormalizeInvitationCode(typed); n != code {
+		t.Errorf("typed invitation code normalized incorrectly: %q != %q", n, code)
+	}
+}
+
 func TestNewSalt(t *testing.T) {
 	salt, err := crypto.NewSalt()
 	if err != nil {
@@ -118,11 +155,16 @@ func TestNewKey(t *testing.T) {
 		t.Errorf("Test setup failed to create password: %v", err)
 	}
 
-	key := crypto.NewDerivedKey(pass, salt)
-	if len(key) != crypto.KeyLength {
-		t.Errorf("len(key) == %v, expected %v", len(key), crypto.KeyLength)
+	for _, params := range []crypto.KdfParams{crypto.DefaultKdfParams, crypto.LegacyKdfParams} {
+		key, err := crypto.NewDerivedKey(pass, salt, params)
+		if err != nil {
+			t.Fatalf("failed to derive key with %v: %v", params, err)
+		}
+		if len(key) != crypto.KeyLength {
+			t.Errorf("len(key) == %v, expected %v", len(key), crypto.KeyLength)
+		}
+		fmt.Printf("Key: %v\n", key)
 	}
-	fmt.Printf("Key: %v\n", key)
 }
 
 func TestRandomKey(t *testing.T) {
@@ -0,0 +1,169 @@
+// ---------------------------------------------------------------------------------------------- //
+// -- Copyright (c) 2024 Braden Hitchcock - MIT License (https://opensource.org/licenses/MIT)  -- //
+// ---------------------------------------------------------------------------------------------- //
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// EnvelopeVersion is the version of the envelope format produced by Encrypt.
+const EnvelopeVersion byte = 1
+
+// KeyIdLength is the number of bytes in a KeyId.
+const KeyIdLength = 8
+
+// envelopeHeaderLength is the number of bytes before the nonce in an envelope: the version, the
+// algorithm, and the key ID.
+const envelopeHeaderLength = 2 + KeyIdLength
+
+// An Algorithm identifies the authenticated cipher used to encrypt the data in an envelope.
+type Algorithm byte
+
+const (
+	AES256GCM         Algorithm = 1
+	XChaCha20Poly1305 Algorithm = 2
+)
+
+// DefaultAlgorithm is the algorithm used by Encrypt.
+const DefaultAlgorithm = AES256GCM
+
+var (
+	ErrInvalidEnvelope = errors.New("invalid ciphertext envelope")
+	ErrWrongKey        = errors.New("data was encrypted with a different key")
+)
+
+// A KeyId identifies the key used to encrypt an envelope without revealing anything about the key.
+type KeyId [KeyIdLength]byte
+
+// NewKeyId returns the ID of the key, which is the start of an HMAC-SHA256 of a fixed label keyed by
+// the key.
+func NewKeyId(key Key) KeyId {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("kolob key id"))
+
+	var id KeyId
+	copy(id[:], mac.Sum(nil))
+	return id
+}
+
+// String returns a string containing a hexadecimal representation of the KeyId receiver.
+func (id KeyId) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// An Envelope is the self-describing form of encrypted data. It is laid out as:
+//
+//	version (1 byte) || algorithm (1 byte) || key ID (8 bytes) || nonce || ciphertext
+//
+// The size of the nonce depends on the algorithm. The header before the nonce is authenticated
+// along with the ciphertext and any additional data, so it can't be changed without decryption
+// failing.
+type Envelope struct {
+	Version    byte
+	Algorithm  Algorithm
+	KeyId      KeyId
+	Nonce      []byte
+	Ciphertext []byte
+}
+
+// ParseEnvelope splits encrypted data into the parts of its envelope. It fails if the data was not
+// produced by a version of Encrypt that it knows about.
+func ParseEnvelope(data []byte) (Envelope, error) {
+	var e Envelope
+	if len(data) < envelopeHeaderLength {
+		return e, ErrInvalidEnvelope
+	}
+
+	e.Version = data[0]
+	if e.Version != EnvelopeVersion {
+		return e, fmt.Errorf("%w: unknown version %d", ErrInvalidEnvelope, e.Version)
+	}
+
+	e.Algorithm = Algorithm(data[1])
+	size, err := e.Algorithm.nonceSize()
+	if err != nil {
+		return e, err
+	}
+	if len(data) < envelopeHeaderLength+size {
+		return e, ErrInvalidEnvelope
+	}
+
+	copy(e.KeyId[:], data[2:envelopeHeaderLength])
+	e.Nonce = data[envelopeHeaderLength : envelopeHeaderLength+size]
+	e.Ciphertext = data[envelopeHeaderLength+size:]
+
+	return e, nil
+}
+
+// Bytes returns the envelope laid out in its binary form.
+func (e Envelope) Bytes() []byte {
+	data := make([]byte, 0, e.headerLength()+len(e.Ciphertext))
+	data = append(data, e.header()...)
+	data = append(data, e.Nonce...)
+	return append(data, e.Ciphertext...)
+}
+
+func (e Envelope) header() []byte {
+	h := make([]byte, 0, envelopeHeaderLength)
+	h = append(h, e.Version, byte(e.Algorithm))
+	return append(h, e.KeyId[:]...)
+}
+
+func (e Envelope) headerLength() int {
+	return envelopeHeaderLength + len(e.Nonce)
+}
+
+// additionalData returns what is authenticated along with the ciphertext: the header followed by
+// the additional data given by the caller, if any.
+func (e Envelope) additionalData(ad []byte) []byte {
+	return append(e.header(), ad...)
+}
+
+// open authenticates and decrypts the ciphertext in the envelope with the key.
+func (e Envelope) open(key Key, ad []byte) ([]byte, error) {
+	if e.KeyId != NewKeyId(key) {
+		return nil, ErrWrongKey
+	}
+
+	aead, err := e.Algorithm.newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return aead.Open(nil, e.Nonce, e.Ciphertext, e.additionalData(ad))
+}
+
+func (a Algorithm) nonceSize() (int, error) {
+	switch a {
+	case AES256GCM:
+		return 12, nil
+	case XChaCha20Poly1305:
+		return chacha20poly1305.NonceSizeX, nil
+	default:
+		return 0, fmt.Errorf("%w: unknown algorithm %d", ErrInvalidEnvelope, a)
+	}
+}
+
+func (a Algorithm) newAEAD(key Key) (cipher.AEAD, error) {
+	switch a {
+	case AES256GCM:
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		return cipher.NewGCM(block)
+	case XChaCha20Poly1305:
+		return chacha20poly1305.NewX(key)
+	default:
+		return nil, fmt.Errorf("%w: unknown algorithm %d", ErrInvalidEnvelope, a)
+	}
+}
@@ -0,0 +1,150 @@
+// ---------------------------------------------------------------------------------------------- //
+// -- Copyright (c) 2024 Braden Hitchcock - MIT License (https://opensource.org/licenses/MIT)  -- //
+// ---------------------------------------------------------------------------------------------- //
+package crypto_test
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"testing"
+
+	"github.com/bradenhc/kolob/internal/crypto"
+)
+
+func TestEnvelopeAlgorithms(t *testing.T) {
+	key, err := crypto.NewRandomKey()
+	if err != nil {
+		t.Fatalf("failed to create random key: %v", err)
+	}
+	plaintext := []byte("some data to encrypt")
+
+	for _, alg := range []crypto.Algorithm{crypto.AES256GCM, crypto.XChaCha20Poly1305} {
+		data, err := crypto.EncryptWith(alg, key, plaintext, nil)
+		if err != nil {
+			t.Fatalf("failed to encrypt with algorithm %d: %v", alg, err)
+		}
+
+		e, err := crypto.ParseEnvelope(data)
+		if err != nil {
+			t.Fatalf("failed to parse envelope: %v", err)
+		}
+		if e.Version != crypto.EnvelopeVersion {
+			t.Errorf("%d != %d", e.Version, crypto.EnvelopeVersion)
+		}
+		if e.Algorithm != alg {
+			t.Errorf("%d != %d", e.Algorithm, alg)
+		}
+		if e.KeyId != crypto.NewKeyId(key) {
+			t.Errorf("%v != %v", e.KeyId, crypto.NewKeyId(key))
+		}
+		if !bytes.Equal(e.Bytes(), data) {
+			t.Errorf("envelope bytes differ from the encrypted data")
+		}
+
+		decrypted, err := crypto.Decrypt(key, data)
+		if err != nil {
+			t.Fatalf("failed to decrypt with algorithm %d: %v", alg, err)
+		}
+		if !bytes.Equal(decrypted, plaintext) {
+			t.Errorf("%s != %s", decrypted, plaintext)
+		}
+	}
+}
+
+func TestEnvelopeWrongKey(t *testing.T) {
+	key1, _ := crypto.NewRandomKey()
+	key2, _ := crypto.NewRandomKey()
+
+	data, err := crypto.Encrypt(key1, []byte("some data to encrypt"))
+	if err != nil {
+		t.Fatalf("failed to encrypt: %v", err)
+	}
+
+	_, err = crypto.Decrypt(key2, data)
+	if !errors.Is(err, crypto.ErrWrongKey) {
+		t.Errorf("expected decrypting with the wrong key to fail with ErrWrongKey: %v", err)
+	}
+}
+
+func TestEnvelopeTamperedHeader(t *testing.T) {
+	key, _ := crypto.NewRandomKey()
+
+	data, err := crypto.EncryptWith(
+		crypto.XChaCha20Poly1305, key, []byte("some data to encrypt"), nil,
+	)
+	if err != nil {
+		t.Fatalf("failed to encrypt: %v", err)
+	}
+
+	// Claiming a different algorithm must not go unnoticed
+	data[1] = byte(crypto.AES256GCM)
+	_, err = crypto.Decrypt(key, data)
+	if err == nil {
+		t.Errorf("expected decrypting a tampered envelope to fail")
+	}
+}
+
+func TestEnvelopeLegacy(t *testing.T) {
+	key, _ := crypto.NewRandomKey()
+	plaintext := []byte("some data encrypted before envelopes")
+
+	// Encrypt the way it was done before envelopes: a bare nonce followed by the ciphertext
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatalf("failed to create block cipher: %v", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatalf("failed to create GCM: %v", err)
+	}
+
+	for i := 0; i < 16; i++ {
+		nonce := make([]byte, gcm.NonceSize())
+		rand.Read(nonce)
+
+		// Make sure some of the nonces look like the start of an envelope
+		if i%2 == 0 {
+			nonce[0] = crypto.EnvelopeVersion
+			nonce[1] = byte(crypto.AES256GCM)
+		}
+
+		data := gcm.Seal(nonce, nonce, plaintext, nil)
+		decrypted, err := crypto.Decrypt(key, data)
+		if err != nil {
+			t.Fatalf("failed to decrypt legacy data: %v", err)
+		}
+		if !bytes.Equal(decrypted, plaintext) {
+			t.Errorf("%s != %s", decrypted, plaintext)
+		}
+	}
+}
+
+func TestEnvelopeAdditionalData(t *testing.T) {
+	key, _ := crypto.NewRandomKey()
+	plaintext := []byte("some data to encrypt")
+
+	data, err := crypto.EncryptAD(key, plaintext, []byte("row 1"))
+	if err != nil {
+		t.Fatalf("failed to encrypt: %v", err)
+	}
+
+	decrypted, err := crypto.DecryptAD(key, data, []byte("row 1"))
+	if err != nil {
+		t.Fatalf("failed to decrypt with the same additional data: %v", err)
+	}
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Errorf("%s != %s", decrypted, plaintext)
+	}
+
+	_, err = crypto.DecryptAD(key, data, []byte("row 2"))
+	if err == nil {
+		t.Errorf("expected decrypting with different additional data to fail")
+	}
+	_, err = crypto.Decrypt(key, data)
+	if err == nil {
+		t.Errorf("expected decrypting without the additional data to fail")
+	}
+}
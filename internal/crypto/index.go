@@ -0,0 +1,61 @@
+// ---------------------------------------------------------------------------------------------- //
+// -- Copyright (c) 2024 Braden Hitchcock - MIT License (https://opensource.org/licenses/MIT)  -- //
+// ---------------------------------------------------------------------------------------------- //
+package crypto
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// IndexKeyLength is the number of bytes in an index key.
+const IndexKeyLength = 32
+
+// An IndexKey is the secret used to compute blind indexes. It is kept by the server outside of the
+// database, so that someone who only has the database can't compute the index of a guessed value.
+type IndexKey []byte
+
+// NewIndexKey creates a new random index key.
+func NewIndexKey() (IndexKey, error) {
+	key := make([]byte, IndexKeyLength)
+	_, err := rand.Read(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create index key: %v", err)
+	}
+
+	return key, nil
+}
+
+// LoadIndexKey reads the hex encoded index key stored in the file at the provided path. If the file
+// doesn't exist and create is set, a new index key is created and saved there with read/write
+// permissions for the owner only. A key must not be created for a database that already holds blind
+// indexes, since none of them would match.
+func LoadIndexKey(path string, create bool) (IndexKey, error) {
+	return loadKeyFile(path, "index key", IndexKeyLength, create)
+}
+
+// BlindIndex produces a keyed hash of the provided data using HMAC-SHA256. Unlike HashData, the
+// index can't be checked against guessed data without the index key.
+func BlindIndex(key IndexKey, data []byte) DataHash {
+	var h DataHash
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	copy(h[:], mac.Sum(nil))
+	return h
+}
+
+// CheckBlindIndex compares a slice of byte data with a blind index. If BlindIndex would produce the
+// provided index from the data with the index key, then the function returns true. Otherwise the
+// function returns false.
+func CheckBlindIndex(key IndexKey, data []byte, index DataHash) bool {
+	h := BlindIndex(key, data)
+	return hmac.Equal(h[:], index[:])
+}
+
+// String returns a string containing a hexadecimal representation of the IndexKey receiver.
+func (k IndexKey) String() string {
+	return hex.EncodeToString(k)
+}
@@ -0,0 +1,77 @@
+// ---------------------------------------------------------------------------------------------- //
+// -- Copyright (c) 2024 Braden Hitchcock - MIT License (https://opensource.org/licenses/MIT)  -- //
+// ---------------------------------------------------------------------------------------------- //
+package crypto_test
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/bradenhc/kolob/internal/crypto"
+)
+
+func TestBlindIndex(t *testing.T) {
+	key1, _ := crypto.NewIndexKey()
+	key2, _ := crypto.NewIndexKey()
+	data := []byte("someuser")
+
+	index := crypto.BlindIndex(key1, data)
+	if !crypto.CheckBlindIndex(key1, data, index) {
+		t.Errorf("check blind index should pass")
+	}
+	if crypto.CheckBlindIndex(key2, data, index) {
+		t.Errorf("check blind index should fail with a different key")
+	}
+	if crypto.CheckBlindIndex(key1, []byte("otheruser"), index) {
+		t.Errorf("check blind index should fail with different data")
+	}
+	if index == crypto.HashData(data) {
+		t.Errorf("blind index should not be a plain hash of the data")
+	}
+}
+
+func TestLoadIndexKey(t *testing.T) {
+	p := path.Join(t.TempDir(), "kolob.key")
+
+	// The key isn't created unless asked to
+	_, err := crypto.LoadIndexKey(p, false)
+	if err == nil {
+		t.Fatalf("loaded an index key that doesn't exist")
+	}
+	if _, err := os.Stat(p); err == nil {
+		t.Errorf("index key was created without being asked to")
+	}
+
+	// The key is created the first time it is loaded
+	key1, err := crypto.LoadIndexKey(p, true)
+	if err != nil {
+		t.Fatalf("failed to create index key: %v", err)
+	}
+	info, err := os.Stat(p)
+	if err != nil {
+		t.Fatalf("index key was not saved: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("index key file has the wrong permissions: %v", info.Mode().Perm())
+	}
+
+	// And the same key is loaded after that
+	key2, err := crypto.LoadIndexKey(p, true)
+	if err != nil {
+		t.Fatalf("failed to load index key: %v", err)
+	}
+	if !bytes.Equal(key1, key2) {
+		t.Errorf("loaded index key is different: %v != %v", key2, key1)
+	}
+
+	err = os.WriteFile(p, []byte("not a key"), 0600)
+	if err != nil {
+		t.Fatalf("failed to write invalid index key: %v", err)
+	}
+	_, err = crypto.LoadIndexKey(p, true)
+	if err == nil {
+		t.Errorf("loaded an invalid index key")
+	}
+}
@@ -0,0 +1,121 @@
+// ---------------------------------------------------------------------------------------------- //
+// -- Copyright (c) 2024 Braden Hitchcock - MIT License (https://opensource.org/licenses/MIT)  -- //
+// ---------------------------------------------------------------------------------------------- //
+package crypto
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// A KdfAlgorithm names the algorithm used to derive a key from a password.
+type KdfAlgorithm string
+
+const (
+	PBKDF2SHA256 KdfAlgorithm = "pbkdf2-sha256"
+	Argon2id     KdfAlgorithm = "argon2id"
+)
+
+// KdfParams records the algorithm and parameters used to derive a key from a password, so that the
+// same key can be derived again later even after the defaults have changed.
+type KdfParams struct {
+	Algorithm KdfAlgorithm
+
+	// Iterations is the number of iterations of PBKDF2 or the number of passes over memory made by
+	// Argon2id.
+	Iterations uint32
+
+	// Memory is the amount of memory used by Argon2id, in KiB.
+	Memory uint32
+
+	// Parallelism is the number of threads used by Argon2id.
+	Parallelism uint8
+}
+
+var (
+	// DefaultKdfParams are the parameters used to derive new keys. They follow the second
+	// recommended option for Argon2id in RFC 9106.
+	DefaultKdfParams = KdfParams{Algorithm: Argon2id, Iterations: 3, Memory: 64 * 1024, Parallelism: 4}
+
+	// LegacyKdfParams are the parameters that were used to derive keys before they were recorded.
+	LegacyKdfParams = KdfParams{Algorithm: PBKDF2SHA256, Iterations: Iterations}
+)
+
+// NewDerivedKey uses the key derivation algorithm and parameters to create a 256-bit key that can be
+// used by the AES algorithm for encrypting and decrypting data.
+//
+// Only a limited number of keys are derived at once; see SetKdfConcurrency.
+func NewDerivedKey(pass Password, salt Salt, params KdfParams) (Key, error) {
+	defer releaseKdf(acquireKdf())
+
+	switch params.Algorithm {
+	case PBKDF2SHA256:
+		return pbkdf2.Key([]byte(pass), salt, int(params.Iterations), KeyLength, sha256.New), nil
+	case Argon2id:
+		return argon2.IDKey(
+			[]byte(pass), salt, params.Iterations, params.Memory, params.Parallelism, KeyLength,
+		), nil
+	default:
+		return nil, fmt.Errorf("unknown key derivation algorithm: %s", params.Algorithm)
+	}
+}
+
+// String encodes the parameters in a form that can be stored and read back with ParseKdfParams,
+// such as "argon2id$t=3$m=65536$p=4" or "pbkdf2-sha256$i=1000000".
+func (p KdfParams) String() string {
+	switch p.Algorithm {
+	case Argon2id:
+		return fmt.Sprintf("%s$t=%d$m=%d$p=%d", p.Algorithm, p.Iterations, p.Memory, p.Parallelism)
+	default:
+		return fmt.Sprintf("%s$i=%d", p.Algorithm, p.Iterations)
+	}
+}
+
+// ParseKdfParams reads parameters encoded by KdfParams.String.
+func ParseKdfParams(s string) (KdfParams, error) {
+	fields := strings.Split(s, "$")
+	p := KdfParams{Algorithm: KdfAlgorithm(fields[0])}
+
+	var names string
+	switch p.Algorithm {
+	case PBKDF2SHA256:
+		names = "i"
+	case Argon2id:
+		names = "tmp"
+	default:
+		return p, fmt.Errorf("unknown key derivation algorithm: %s", p.Algorithm)
+	}
+
+	if len(fields)-1 != len(names) {
+		return p, fmt.Errorf("invalid %s parameters: %s", p.Algorithm, s)
+	}
+	for i, f := range fields[1:] {
+		name, val, ok := strings.Cut(f, "=")
+		if !ok || name != names[i:i+1] {
+			return p, fmt.Errorf("invalid %s parameters: %s", p.Algorithm, s)
+		}
+		n, err := strconv.ParseUint(val, 10, 32)
+		if err != nil || n == 0 {
+			return p, fmt.Errorf("invalid %s parameter %s: %s", p.Algorithm, name, val)
+		}
+
+		switch name {
+		case "i", "t":
+			p.Iterations = uint32(n)
+		case "m":
+			p.Memory = uint32(n)
+		case "p":
+			if n > 255 {
+				return p, fmt.Errorf("invalid %s parameter %s: %s", p.Algorithm, name, val)
+			}
+			p.Parallelism = uint8(n)
+		}
+	}
+
+	return p, nil
+}
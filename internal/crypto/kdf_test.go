@@ -0,0 +1,67 @@
+// ---------------------------------------------------------------------------------------------- //
+// -- Copyright (c) 2024 Braden Hitchcock - MIT License (https://opensource.org/licenses/MIT)  -- //
+// ---------------------------------------------------------------------------------------------- //
+package crypto_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bradenhc/kolob/internal/crypto"
+)
+
+func TestKdfParamsString(t *testing.T) {
+	for _, params := range []crypto.KdfParams{crypto.DefaultKdfParams, crypto.LegacyKdfParams} {
+		parsed, err := crypto.ParseKdfParams(params.String())
+		if err != nil {
+			t.Fatalf("failed to parse %v: %v", params, err)
+		}
+		if parsed != params {
+			t.Errorf("%+v != %+v", parsed, params)
+		}
+	}
+
+	if crypto.LegacyKdfParams.String() != "pbkdf2-sha256$i=1000000" {
+		t.Errorf("legacy parameters changed: %v", crypto.LegacyKdfParams)
+	}
+}
+
+func TestKdfParamsInvalid(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"scrypt$n=32768",
+		"pbkdf2-sha256",
+		"pbkdf2-sha256$t=3",
+		"argon2id$t=3$m=65536",
+		"argon2id$t=3$m=65536$p=1000",
+		"argon2id$t=0$m=65536$p=4",
+	} {
+		_, err := crypto.ParseKdfParams(s)
+		if err == nil {
+			t.Errorf("expected parsing %q to fail", s)
+		}
+	}
+}
+
+func TestKdfDifferentParams(t *testing.T) {
+	salt, _ := crypto.NewSalt()
+	pass, _ := crypto.NewPassword("This1s@validPassw0rd")
+
+	params := crypto.KdfParams{
+		Algorithm: crypto.Argon2id, Iterations: 1, Memory: 8 * 1024, Parallelism: 1,
+	}
+	key1, err := crypto.NewDerivedKey(pass, salt, params)
+	if err != nil {
+		t.Fatalf("failed to derive key: %v", err)
+	}
+
+	params.Iterations = 2
+	key2, err := crypto.NewDerivedKey(pass, salt, params)
+	if err != nil {
+		t.Fatalf("failed to derive key: %v", err)
+	}
+
+	if bytes.Equal(key1, key2) {
+		t.Errorf("keys derived with different parameters should be different")
+	}
+}
@@ -0,0 +1,60 @@
+// ---------------------------------------------------------------------------------------------- //
+// -- Copyright (c) 2024 Braden Hitchcock - MIT License (https://opensource.org/licenses/MIT)  -- //
+// ---------------------------------------------------------------------------------------------- //
+package crypto
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// LoadSessionKey reads the hex encoded session key stored in the file at the provided path, creating
+// it the same way as LoadIndexKey if it doesn't exist. The session key encrypts the data keys held
+// by logged in sessions, so that the stored sessions are useless without it.
+func LoadSessionKey(path string) (Key, error) {
+	return loadKeyFile(path, "session key", KeyLength, true)
+}
+
+// loadKeyFile reads a hex encoded key of the provided length from the file at the path. If the file
+// doesn't exist and create is set, a new random key is saved there with read/write permissions for
+// the owner only.
+func loadKeyFile(path, name string, length int, create bool) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) && !create {
+		return nil, fmt.Errorf("%s not found at %s", name, path)
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		slog.Warn("Creating a new "+name, "path", path)
+		key := make([]byte, length)
+		_, err := rand.Read(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s: %v", name, err)
+		}
+
+		err = os.WriteFile(path, []byte(hex.EncodeToString(key)+"\n"), 0600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to save %s: %v", name, err)
+		}
+
+		return key, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", name, err)
+	}
+
+	key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %v", name, err)
+	}
+	if len(key) != length {
+		return nil, fmt.Errorf("%s must be %d bytes, got %d", name, length, len(key))
+	}
+
+	return key, nil
+}
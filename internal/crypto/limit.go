@@ -0,0 +1,40 @@
+// ---------------------------------------------------------------------------------------------- //
+// -- Copyright (c) 2024 Braden Hitchcock - MIT License (https://opensource.org/licenses/MIT)  -- //
+// ---------------------------------------------------------------------------------------------- //
+package crypto
+
+import (
+	"runtime"
+	"sync"
+)
+
+// Deriving keys and hashing passwords is deliberately expensive, so only a limited number of those
+// operations run at once; the rest wait their turn. This keeps a flood of login attempts from using
+// all of the CPU and, with Argon2id, all of the memory of the server.
+var (
+	kdfSlots   = make(chan struct{}, runtime.NumCPU())
+	kdfSlotsMx sync.Mutex
+)
+
+// SetKdfConcurrency sets how many key derivations and password hashes can run at once. It is meant
+// to be called once when the program starts; operations already waiting keep the previous limit.
+func SetKdfConcurrency(n int) {
+	kdfSlotsMx.Lock()
+	defer kdfSlotsMx.Unlock()
+	kdfSlots = make(chan struct{}, max(n, 1))
+}
+
+// acquireKdf waits for a turn to run an expensive operation. The returned slots must be given to
+// releaseKdf when the operation is done.
+func acquireKdf() chan struct{} {
+	kdfSlotsMx.Lock()
+	slots := kdfSlots
+	kdfSlotsMx.Unlock()
+
+	slots <- struct{}{}
+	return slots
+}
+
+func releaseKdf(slots chan struct{}) {
+	<-slots
+}
@@ -0,0 +1,200 @@
+// ---------------------------------------------------------------------------------------------- //
+// -- Copyright (c) 2024 Braden Hitchcock - MIT License (https://opensource.org/licenses/MIT)  -- //
+// ---------------------------------------------------------------------------------------------- //
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidShare is returned when a share can't be parsed, usually because it was mistyped.
+var ErrInvalidShare = errors.New("invalid recovery share")
+
+// A Share is one piece of a key split with Shamir's secret sharing scheme. Any Threshold shares of
+// the same key can be combined to get the key back; fewer reveal nothing about it.
+type Share struct {
+	Threshold uint8
+	X         uint8
+	Y         []byte
+}
+
+// sharePrefix starts the text form of every share.
+const sharePrefix = "KOLOB"
+
+// shareChecksumLength is the number of bytes of SHA-256 added to the text form of a share so that
+// typos are caught before shares are combined.
+const shareChecksumLength = 4
+
+// shareEncoding is uppercase and unpadded so that shares only use characters that are easy to read
+// aloud and that fit the alphanumeric mode of QR codes.
+var shareEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// CheckThreshold returns an error if a key can't be split into n shares, any k of which can be
+// combined to get the key back.
+func CheckThreshold(n, k int) error {
+	if k < 2 || k > n || n > 255 {
+		return fmt.Errorf("invalid threshold %d of %d shares", k, n)
+	}
+
+	return nil
+}
+
+// SplitKey splits the key into n shares, any k of which can be combined to get the key back.
+func SplitKey(key Key, n, k int) ([]Share, error) {
+	if err := CheckThreshold(n, k); err != nil {
+		return nil, err
+	}
+
+	shares := make([]Share, n)
+	for i := range shares {
+		shares[i] = Share{uint8(k), uint8(i + 1), make([]byte, len(key))}
+	}
+
+	// Each byte of the key is the constant term of its own random polynomial of degree k-1, and
+	// each share holds the value of every polynomial at the share's x coordinate
+	coeffs := make([]byte, k)
+	for b := range key {
+		coeffs[0] = key[b]
+		_, err := rand.Read(coeffs[1:])
+		if err != nil {
+			return nil, fmt.Errorf("failed to create polynomial: %v", err)
+		}
+
+		for i := range shares {
+			shares[i].Y[b] = gfEval(coeffs, shares[i].X)
+		}
+	}
+
+	return shares, nil
+}
+
+// CombineShares returns the key that the shares were split from. At least as many shares as the
+// threshold are needed. Combining the wrong shares gives the wrong key rather than an error, so the
+// key should be checked before it is used.
+func CombineShares(shares []Share) (Key, error) {
+	if len(shares) == 0 {
+		return nil, fmt.Errorf("no recovery shares")
+	}
+
+	k := int(shares[0].Threshold)
+	size := len(shares[0].Y)
+	seen := make(map[uint8]bool)
+	for _, s := range shares {
+		if int(s.Threshold) != k || len(s.Y) != size {
+			return nil, fmt.Errorf("recovery shares are from different recovery kits")
+		}
+		if s.X == 0 || seen[s.X] {
+			return nil, fmt.Errorf("recovery share %d is repeated or invalid", s.X)
+		}
+		seen[s.X] = true
+	}
+	if len(shares) < k {
+		return nil, fmt.Errorf("%d recovery shares are needed, got %d", k, len(shares))
+	}
+	shares = shares[:k]
+
+	// Lagrange interpolation of each polynomial at x = 0
+	key := make([]byte, size)
+	for i, si := range shares {
+		basis := byte(1)
+		for j, sj := range shares {
+			if i != j {
+				basis = gfMul(basis, gfDiv(sj.X, sj.X^si.X))
+			}
+		}
+		for b := range key {
+			key[b] ^= gfMul(si.Y[b], basis)
+		}
+	}
+
+	return key, nil
+}
+
+// String returns the share as printable text, which can be given to ParseShare to get it back.
+func (s Share) String() string {
+	data := append([]byte{s.Threshold, s.X}, s.Y...)
+	sum := sha256.Sum256(data)
+	text := shareEncoding.EncodeToString(append(data, sum[:shareChecksumLength]...))
+
+	// Group the text so it is easier to copy by hand
+	var b strings.Builder
+	b.WriteString(sharePrefix)
+	for i := 0; i < len(text); i += 4 {
+		b.WriteByte('-')
+		b.WriteString(text[i:min(i+4, len(text))])
+	}
+
+	return b.String()
+}
+
+// ParseShare reads a share in the form produced by Share.String. Case and whitespace are ignored.
+func ParseShare(text string) (Share, error) {
+	text = strings.ToUpper(strings.Join(strings.Fields(text), ""))
+	text, ok := strings.CutPrefix(text, sharePrefix)
+	if !ok {
+		return Share{}, ErrInvalidShare
+	}
+
+	data, err := shareEncoding.DecodeString(strings.ReplaceAll(text, "-", ""))
+	if err != nil || len(data) < 3+shareChecksumLength {
+		return Share{}, ErrInvalidShare
+	}
+
+	data, sum := data[:len(data)-shareChecksumLength], data[len(data)-shareChecksumLength:]
+	expected := sha256.Sum256(data)
+	if !bytes.Equal(sum, expected[:shareChecksumLength]) {
+		return Share{}, ErrInvalidShare
+	}
+
+	return Share{data[0], data[1], data[2:]}, nil
+}
+
+// gfExp and gfLog are the exponent and logarithm tables of GF(2^8) with the generator 3, using the
+// same reducing polynomial as AES.
+var gfExp, gfLog = func() ([510]byte, [256]byte) {
+	var exp [510]byte
+	var log [256]byte
+	x := byte(1)
+	for i := 0; i < 255; i++ {
+		exp[i] = x
+		exp[i+255] = x
+		log[x] = byte(i)
+
+		// Multiply by 3 and reduce
+		hi := x & 0x80
+		x ^= x << 1
+		if hi != 0 {
+			x ^= 0x1b
+		}
+	}
+	return exp, log
+}()
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+int(gfLog[b])]
+}
+
+func gfDiv(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return gfExp[int(gfLog[a])+255-int(gfLog[b])]
+}
+
+// gfEval evaluates the polynomial with the coefficients, lowest degree first, at x.
+func gfEval(coeffs []byte, x byte) byte {
+	var y byte
+	for i := len(coeffs) - 1; i >= 0; i-- {
+		y = gfMul(y, x) ^ coeffs[i]
+	}
+	return y
+}
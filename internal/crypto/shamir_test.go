@@ -0,0 +1,97 @@
+// ---------------------------------------------------------------------------------------------- //
+// -- Copyright (c) 2024 Braden Hitchcock - MIT License (https://opensource.org/licenses/MIT)  -- //
+// ---------------------------------------------------------------------------------------------- //
+package crypto_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/bradenhc/kolob/internal/crypto"
+)
+
+func TestSplitKey(t *testing.T) {
+	key, _ := crypto.NewRandomKey()
+
+	shares, err := crypto.SplitKey(key, 5, 3)
+	if err != nil {
+		t.Fatalf("failed to split key: %v", err)
+	}
+	if len(shares) != 5 {
+		t.Fatalf("wrong number of shares: %d", len(shares))
+	}
+
+	// Any three shares give the key back
+	for _, picked := range [][]int{{0, 1, 2}, {4, 2, 0}, {1, 3, 4}, {0, 1, 2, 3, 4}} {
+		subset := make([]crypto.Share, 0, len(picked))
+		for _, i := range picked {
+			subset = append(subset, shares[i])
+		}
+
+		combined, err := crypto.CombineShares(subset)
+		if err != nil {
+			t.Fatalf("failed to combine shares %v: %v", picked, err)
+		}
+		if !bytes.Equal(combined, key) {
+			t.Errorf("shares %v combined to the wrong key", picked)
+		}
+	}
+
+	// Two are not enough
+	_, err = crypto.CombineShares(shares[:2])
+	if err == nil {
+		t.Errorf("combined fewer shares than the threshold")
+	}
+
+	// And the same share can't be counted twice
+	_, err = crypto.CombineShares([]crypto.Share{shares[0], shares[0], shares[1]})
+	if err == nil {
+		t.Errorf("combined a repeated share")
+	}
+}
+
+func TestSplitKeyInvalid(t *testing.T) {
+	key, _ := crypto.NewRandomKey()
+	for _, nk := range [][2]int{{3, 1}, {3, 4}, {256, 3}} {
+		_, err := crypto.SplitKey(key, nk[0], nk[1])
+		if err == nil {
+			t.Errorf("split key with threshold %d of %d shares", nk[1], nk[0])
+		}
+	}
+}
+
+func TestParseShare(t *testing.T) {
+	key, _ := crypto.NewRandomKey()
+	shares, err := crypto.SplitKey(key, 3, 2)
+	if err != nil {
+		t.Fatalf("failed to split key: %v", err)
+	}
+
+	text := shares[1].String()
+	if !strings.HasPrefix(text, "KOLOB-") {
+		t.Errorf("share text has the wrong prefix: %s", text)
+	}
+
+	// Case and whitespace don't matter
+	s, err := crypto.ParseShare(" " + strings.ToLower(strings.ReplaceAll(text, "-", "- ")) + "\n")
+	if err != nil {
+		t.Fatalf("failed to parse share: %v", err)
+	}
+	if s.Threshold != shares[1].Threshold || s.X != shares[1].X || !bytes.Equal(s.Y, shares[1].Y) {
+		t.Errorf("parsed share is different: %+v != %+v", s, shares[1])
+	}
+
+	// Typos are caught by the checksum
+	typo := []byte(text)
+	if typo[10] == 'A' {
+		typo[10] = 'B'
+	} else {
+		typo[10] = 'A'
+	}
+	_, err = crypto.ParseShare(string(typo))
+	if !errors.Is(err, crypto.ErrInvalidShare) {
+		t.Errorf("expected mistyped share to be invalid: %v", err)
+	}
+}
@@ -7,26 +7,52 @@ import (
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
+	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
 	"math/big"
+	"net"
 	"os"
+	"sync"
 	"time"
 )
 
-// GenerateSelfSignedCert generates a self-signed ECDSA certificate and private key.
-// It returns the certificate and key in PEM format, or an error if the generation fails.
-func GenerateSelfSignedCert() ([]byte, []byte, error) {
+// CertExpiryWarning is how long before a certificate expires that warnings about it are logged.
+const CertExpiryWarning = 30 * 24 * time.Hour
+
+// certCheckInterval is how often a CertReloader checks whether its files have changed.
+const certCheckInterval = 10 * time.Second
+
+// certWarnInterval is how often a CertReloader repeats a warning about an expiring certificate.
+const certWarnInterval = 24 * time.Hour
+
+// CertValidity is how long the certificates generated for the server are valid.
+const CertValidity = 365 * 24 * time.Hour
+
+// GenerateSelfSignedCert generates a self-signed ECDSA certificate and private key for the provided
+// hosts, which may be DNS names or IP addresses. It returns the certificate and key in PEM format,
+// or an error if the generation fails.
+func GenerateSelfSignedCert(hosts []string) ([]byte, []byte, error) {
+	return generateCert(hosts, nil)
+}
+
+// generateCert generates a server certificate for the hosts, signed by the certificate authority
+// or self-signed if it is nil.
+func generateCert(hosts []string, ca *CertAuthority) ([]byte, []byte, error) {
 	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
 	if err != nil {
 		return nil, nil, err
 	}
 
 	notBefore := time.Now()
-	notAfter := notBefore.Add(365 * 24 * time.Hour)
+	notAfter := notBefore.Add(CertValidity)
 
-	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	serialNumber, err := newSerialNumber()
 	if err != nil {
 		return nil, nil, err
 	}
@@ -44,7 +70,24 @@ func GenerateSelfSignedCert() ([]byte, []byte, error) {
 		BasicConstraintsValid: true,
 	}
 
-	certDER, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	// Browsers only check the subject alternative names, never the common name
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, h)
+		}
+	}
+	if len(hosts) != 0 {
+		template.Subject.CommonName = hosts[0]
+	}
+
+	parent, signer := &template, any(key)
+	if ca != nil {
+		parent, signer = ca.Cert, ca.Key
+	}
+
+	certDER, err := x509.CreateCertificate(rand.Reader, &template, parent, &key.PublicKey, signer)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -60,6 +103,10 @@ func GenerateSelfSignedCert() ([]byte, []byte, error) {
 	return certPEM, keyPEMBlock, nil
 }
 
+func newSerialNumber() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
+
 // SaveCertAndKey saves the provided certificate and key to the specified file paths.
 // The certificate is saved with read permissions for everyone, while the key is saved with
 // read/write permissions for the owner only. Returns an error if saving the files fails.
@@ -76,3 +123,179 @@ func SaveCertAndKey(certPEM, keyPEM []byte, certPath, keyPath string) error {
 
 	return nil
 }
+
+// EnsureCert makes sure that a certificate and key for the hosts are saved at the provided paths,
+// so that the same certificate is used every time the server starts. The certificate is issued by
+// the certificate authority, or self-signed if it is nil.
+//
+// A new pair is generated if the certificate doesn't exist yet, has expired, or doesn't cover all
+// of the hosts. With a certificate authority, a certificate it didn't issue is replaced too, and so
+// is one that is about to expire, since devices that trust the authority trust the new certificate
+// as well. Without one, any other certificate is kept, including one issued by a certificate
+// authority that has since been removed.
+func EnsureCert(certPath, keyPath string, hosts []string, ca *CertAuthority) error {
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err == nil {
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			return fmt.Errorf("failed to parse certificate: %v", err)
+		}
+
+		reason := certRenewalReason(leaf, hosts, ca)
+		if reason == "" {
+			return nil
+		}
+		slog.Warn("Generating a new certificate", "path", certPath, "reason", reason)
+	} else if _, serr := os.Stat(certPath); !errors.Is(serr, fs.ErrNotExist) {
+		return fmt.Errorf("failed to load certificate: %v", err)
+	} else {
+		slog.Info("Generating certificate", "path", certPath, "hosts", hosts)
+	}
+
+	certPEM, keyPEM, err := generateCert(hosts, ca)
+	if err != nil {
+		return fmt.Errorf("failed to generate certificate: %v", err)
+	}
+
+	err = SaveCertAndKey(certPEM, keyPEM, certPath, keyPath)
+	if err != nil {
+		return fmt.Errorf("failed to save certificate: %v", err)
+	}
+
+	return nil
+}
+
+// certRenewalReason explains why the certificate needs to be replaced, or returns an empty string
+// if it doesn't.
+func certRenewalReason(leaf *x509.Certificate, hosts []string, ca *CertAuthority) string {
+	if time.Now().After(leaf.NotAfter) {
+		return "expired"
+	}
+	for _, h := range hosts {
+		if leaf.VerifyHostname(h) != nil {
+			return "host " + h + " not covered"
+		}
+	}
+	if ca == nil {
+		return ""
+	}
+	if leaf.CheckSignatureFrom(ca.Cert) != nil {
+		return "not issued by the certificate authority"
+	}
+	if time.Until(leaf.NotAfter) < CertExpiryWarning {
+		return "expires soon"
+	}
+	return ""
+}
+
+// A CertReloader serves a certificate and key loaded from files, and loads them again when the files
+// change so that a renewed certificate is picked up without restarting the server. It also logs a
+// warning when the certificate is about to expire.
+type CertReloader struct {
+	certPath string
+	keyPath  string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+	checked time.Time
+	warned  time.Time
+}
+
+// NewCertReloader loads the certificate and key from the provided paths.
+func NewCertReloader(certPath, keyPath string) (*CertReloader, error) {
+	r := &CertReloader{certPath: certPath, keyPath: keyPath}
+	err := r.Reload()
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// GetCertificate returns the current certificate. It is meant to be used as the GetCertificate
+// function of a tls.Config.
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	check := time.Since(r.checked) >= certCheckInterval
+	r.mu.Unlock()
+
+	if check {
+		err := r.Reload()
+		if err != nil {
+			slog.Warn("Failed to reload TLS certificate; using the previous one", "err", err)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cert, nil
+}
+
+// Reload loads the certificate and key again if either file has changed since they were last
+// loaded. If they can't be loaded, the previous certificate is kept.
+func (r *CertReloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.checked = time.Now()
+
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return err
+	}
+
+	if r.cert == nil || !modTime.Equal(r.modTime) {
+		cert, err := tls.LoadX509KeyPair(r.certPath, r.keyPath)
+		if err != nil {
+			return fmt.Errorf("failed to load TLS certificate: %v", err)
+		}
+		cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			return fmt.Errorf("failed to parse TLS certificate: %v", err)
+		}
+
+		if r.cert != nil {
+			slog.Info("Reloaded TLS certificate", "path", r.certPath)
+		}
+		r.cert = &cert
+		r.modTime = modTime
+		r.warned = time.Time{}
+	}
+
+	if time.Since(r.warned) >= certWarnInterval {
+		notAfter := r.cert.Leaf.NotAfter
+		if time.Now().After(notAfter) {
+			slog.Error("TLS certificate has expired", "path", r.certPath, "notAfter", notAfter)
+			r.warned = time.Now()
+		} else if time.Until(notAfter) < CertExpiryWarning {
+			slog.Warn("TLS certificate expires soon", "path", r.certPath, "notAfter", notAfter)
+			r.warned = time.Now()
+		}
+	}
+
+	return nil
+}
+
+// Certificate returns the certificate that is currently loaded.
+func (r *CertReloader) Certificate() *x509.Certificate {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cert.Leaf
+}
+
+// latestModTime returns the time the certificate or key file was last modified, whichever is later.
+func (r *CertReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, p := range []string{r.certPath, r.keyPath} {
+		info, err := os.Stat(p)
+		if err != nil {
+			return latest, fmt.Errorf("failed to check TLS certificate: %v", err)
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+
+	return latest, nil
+}
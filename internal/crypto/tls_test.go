@@ -0,0 +1,150 @@
+// ---------------------------------------------------------------------------------------------- //
+// -- Copyright (c) 2024 Braden Hitchcock - MIT License (https://opensource.org/licenses/MIT)  -- //
+// ---------------------------------------------------------------------------------------------- //
+package crypto_test
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/bradenhc/kolob/internal/crypto"
+)
+
+func TestEnsureCert(t *testing.T) {
+	dir := t.TempDir()
+	certPath := path.Join(dir, "kolob-tls.crt")
+	keyPath := path.Join(dir, "kolob-tls.key")
+	hosts := []string{"localhost", "127.0.0.1"}
+
+	err := crypto.EnsureCert(certPath, keyPath, hosts, nil)
+	if err != nil {
+		t.Fatalf("failed to create self-signed certificate: %v", err)
+	}
+	first, err := os.ReadFile(certPath)
+	if err != nil {
+		t.Fatalf("self-signed certificate was not saved: %v", err)
+	}
+	info, err := os.Stat(keyPath)
+	if err != nil {
+		t.Fatalf("self-signed key was not saved: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("self-signed key has the wrong permissions: %v", info.Mode().Perm())
+	}
+
+	// The same certificate is used the next time
+	err = crypto.EnsureCert(certPath, keyPath, hosts, nil)
+	if err != nil {
+		t.Fatalf("failed to load self-signed certificate: %v", err)
+	}
+	second, err := os.ReadFile(certPath)
+	if err != nil {
+		t.Fatalf("failed to read self-signed certificate: %v", err)
+	}
+	if !bytes.Equal(first, second) {
+		t.Errorf("self-signed certificate was replaced")
+	}
+
+	// The certificate is replaced when a host is added
+	hosts = append(hosts, "kolob.lan")
+	err = crypto.EnsureCert(certPath, keyPath, hosts, nil)
+	if err != nil {
+		t.Fatalf("failed to replace self-signed certificate: %v", err)
+	}
+	r, err := crypto.NewCertReloader(certPath, keyPath)
+	if err != nil {
+		t.Fatalf("failed to load self-signed certificate: %v", err)
+	}
+	for _, h := range hosts {
+		if err := r.Certificate().VerifyHostname(h); err != nil {
+			t.Errorf("self-signed certificate does not cover %s: %v", h, err)
+		}
+	}
+
+	// A certificate without its key is not replaced
+	err = os.Remove(keyPath)
+	if err != nil {
+		t.Fatalf("failed to remove key: %v", err)
+	}
+	err = crypto.EnsureCert(certPath, keyPath, hosts, nil)
+	if err == nil {
+		t.Errorf("replaced a certificate whose key is missing")
+	}
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certPath := path.Join(dir, "kolob-tls.crt")
+	keyPath := path.Join(dir, "kolob-tls.key")
+
+	doTestCertReloaderWrite(t, certPath, keyPath, time.Now().Add(-time.Minute))
+	r, err := crypto.NewCertReloader(certPath, keyPath)
+	if err != nil {
+		t.Fatalf("failed to load certificate: %v", err)
+	}
+	first := r.Certificate()
+
+	cert, err := r.GetCertificate(nil)
+	if err != nil {
+		t.Fatalf("failed to get certificate: %v", err)
+	}
+	if !cert.Leaf.Equal(first) {
+		t.Errorf("got a different certificate than the one loaded")
+	}
+	if time.Until(first.NotAfter) < 364*24*time.Hour {
+		t.Errorf("self-signed certificate should be valid for a year: %v", first.NotAfter)
+	}
+
+	// Nothing changes until the files do
+	err = r.Reload()
+	if err != nil {
+		t.Fatalf("failed to reload certificate: %v", err)
+	}
+	if !r.Certificate().Equal(first) {
+		t.Errorf("certificate changed without its files changing")
+	}
+
+	// Replace the certificate
+	doTestCertReloaderWrite(t, certPath, keyPath, time.Now())
+	err = r.Reload()
+	if err != nil {
+		t.Fatalf("failed to reload certificate: %v", err)
+	}
+	second := r.Certificate()
+	if second.Equal(first) {
+		t.Errorf("certificate was not reloaded after its files changed")
+	}
+
+	// A broken certificate is not loaded
+	err = os.WriteFile(certPath, []byte("not a certificate"), 0644)
+	if err != nil {
+		t.Fatalf("failed to write broken certificate: %v", err)
+	}
+	err = r.Reload()
+	if err == nil {
+		t.Errorf("loaded a broken certificate")
+	}
+	if !r.Certificate().Equal(second) {
+		t.Errorf("previous certificate was not kept after a failed reload")
+	}
+}
+
+func doTestCertReloaderWrite(t *testing.T, certPath, keyPath string, modTime time.Time) {
+	certPEM, keyPEM, err := crypto.GenerateSelfSignedCert([]string{"localhost"})
+	if err != nil {
+		t.Fatalf("failed to generate certificate: %v", err)
+	}
+	err = crypto.SaveCertAndKey(certPEM, keyPEM, certPath, keyPath)
+	if err != nil {
+		t.Fatalf("failed to save certificate: %v", err)
+	}
+	for _, p := range []string{certPath, keyPath} {
+		err = os.Chtimes(p, modTime, modTime)
+		if err != nil {
+			t.Fatalf("failed to set modification time: %v", err)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+// ---------------------------------------------------------------------------------------------- //
+// -- Copyright (c) 2024 Braden Hitchcock - MIT License (https://opensource.org/licenses/MIT)  -- //
+// ---------------------------------------------------------------------------------------------- //
+package events
+
+import (
+	"log/slog"
+	"sync"
+)
+
+// The types of events published by the services when data in the group changes.
+const (
+	MemberCreated       = "member.created"
+	MemberUpdated       = "member.updated"
+	MemberRemoved       = "member.removed"
+	ConversationCreated = "conversation.created"
+	ConversationUpdated = "conversation.updated"
+	ConversationRemoved = "conversation.removed"
+	MessageCreated      = "message.created"
+	MessageUpdated      = "message.updated"
+	MessageRemoved      = "message.removed"
+	ReactionCreated     = "reaction.created"
+	ReactionRemoved     = "reaction.removed"
+)
+
+// SubscriptionBufferSize is the number of events that can be queued for a subscriber before new
+// events are dropped for that subscriber.
+const SubscriptionBufferSize = 64
+
+// An Event notifies clients about something that happened on the server. The data contained in the
+// event depends on its type and must be serializable to JSON.
+//
+// Events about something that happened in a conversation name the conversation so that they are
+// only delivered to clients of members that can see it. A conversation that was removed has no
+// participants left to check, so events about its removal also name the members it had. Neither is
+// sent.
+type Event struct {
+	Type         string   `json:"type"`
+	Id           string   `json:"id,omitempty"`
+	Data         any      `json:"data,omitempty"`
+	Conversation string   `json:"-"`
+	Participants []string `json:"-"`
+}
+
+// Removed is the data sent with events that announce the removal of an entity.
+type Removed struct {
+	Id string `json:"id"`
+}
+
+// A Publisher accepts events and delivers them to interested parties.
+type Publisher interface {
+	Publish(e Event)
+}
+
+// A Broker is a Publisher that fans events out to all of its current subscribers. It is safe to
+// use from multiple goroutines.
+type Broker struct {
+	subs   map[chan Event]struct{}
+	subsmx sync.Mutex
+}
+
+func NewBroker() *Broker {
+	return &Broker{
+		subs: make(map[chan Event]struct{}),
+	}
+}
+
+// Publish sends the event to every subscriber. Publishing never blocks: if a subscriber is not
+// keeping up with the events sent to it, the event is dropped for that subscriber.
+func (b *Broker) Publish(e Event) {
+	b.subsmx.Lock()
+	defer b.subsmx.Unlock()
+
+	for c := range b.subs {
+		select {
+		case c <- e:
+		default:
+			slog.Warn("Dropping event for slow subscriber", "type", e.Type)
+		}
+	}
+}
+
+// Subscribe registers a new subscriber with the broker. The returned function must be called once
+// the subscriber is no longer interested in events; it closes the returned channel.
+func (b *Broker) Subscribe() (<-chan Event, func()) {
+	c := make(chan Event, SubscriptionBufferSize)
+
+	b.subsmx.Lock()
+	defer b.subsmx.Unlock()
+	b.subs[c] = struct{}{}
+
+	var once sync.Once
+	return c, func() {
+		once.Do(func() {
+			b.subsmx.Lock()
+			defer b.subsmx.Unlock()
+			delete(b.subs, c)
+			close(c)
+		})
+	}
+}
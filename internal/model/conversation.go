@@ -4,6 +4,7 @@
 package model
 
 import (
+	"encoding/json"
 	"fmt"
 	"slices"
 	"time"
@@ -11,7 +12,9 @@ import (
 	flatbuffers "github.com/google/flatbuffers/go"
 )
 
-func NewConversation(name, desc string, mods []Uuid) (*Conversation, error) {
+// NewConversation creates a new conversation. The moderators of the conversation are added to its
+// participants if they are not already listed.
+func NewConversation(name, desc string, mods, participants []Uuid) (*Conversation, error) {
 	uuid, err := NewUuid()
 	if err != nil {
 		return nil, fmt.Errorf("failed to create conversation: %v", err)
@@ -34,6 +37,15 @@ func NewConversation(name, desc string, mods []Uuid) (*Conversation, error) {
 	}
 	modsOffset := builder.EndVector(len(modsElsOffsets))
 
+	parts := make([][]byte, 0, len(participants))
+	for _, p := range participants {
+		parts = append(parts, []byte(p))
+	}
+	for _, m := range mods {
+		parts = append(parts, []byte(m))
+	}
+	partsOffset := createParticipantsVector(builder, parts)
+
 	ConversationStart(builder)
 	ConversationAddId(builder, idOffsets)
 	ConversationAddName(builder, nameOffset)
@@ -41,6 +53,7 @@ func NewConversation(name, desc string, mods []Uuid) (*Conversation, error) {
 	ConversationAddMods(builder, modsOffset)
 	ConversationAddCreated(builder, now)
 	ConversationAddUpdated(builder, now)
+	ConversationAddParticipants(builder, partsOffset)
 	convOffset := ConversationEnd(builder)
 
 	builder.Finish(convOffset)
@@ -48,7 +61,11 @@ func NewConversation(name, desc string, mods []Uuid) (*Conversation, error) {
 	return GetRootAsConversation(builder.FinishedBytes(), 0), nil
 }
 
-func CloneConversationWithUpdates(prev *Conversation, name, desc []byte, mods [][]byte) *Conversation {
+// CloneConversationWithUpdates copies the previous conversation, replacing any of the fields that
+// are not nil. The moderators of the new conversation are always kept as participants.
+func CloneConversationWithUpdates(
+	prev *Conversation, name, desc []byte, mods, participants [][]byte,
+) *Conversation {
 	now := time.Now().UnixMilli()
 
 	builder := flatbuffers.NewBuilder(1024)
@@ -69,23 +86,32 @@ func CloneConversationWithUpdates(prev *Conversation, name, desc []byte, mods []
 		descOffset = builder.CreateByteString(prev.Desc())
 	}
 
-	modsElsOffsets := make([]flatbuffers.UOffsetT, 0)
-	if mods != nil {
-		for _, m := range mods {
-			modsElsOffsets = append(modsElsOffsets, builder.CreateString(string(m)))
-		}
-	} else {
+	if mods == nil {
+		mods = make([][]byte, 0, prev.ModsLength())
 		for i := range prev.ModsLength() {
-			modsElsOffsets = append(modsElsOffsets, builder.CreateByteString(prev.Mods(i)))
+			mods = append(mods, prev.Mods(i))
 		}
 	}
 
+	modsElsOffsets := make([]flatbuffers.UOffsetT, 0, len(mods))
+	for _, m := range mods {
+		modsElsOffsets = append(modsElsOffsets, builder.CreateByteString(m))
+	}
+
 	ConversationStartModsVector(builder, len(modsElsOffsets))
 	for _, m := range modsElsOffsets {
 		builder.PrependUOffsetT(m)
 	}
 	modsOffset := builder.EndVector(len(modsElsOffsets))
 
+	if participants == nil {
+		participants = make([][]byte, 0, prev.ParticipantsLength())
+		for i := range prev.ParticipantsLength() {
+			participants = append(participants, prev.Participants(i))
+		}
+	}
+	partsOffset := createParticipantsVector(builder, slices.Concat(participants, mods))
+
 	ConversationStart(builder)
 	ConversationAddId(builder, idOffsets)
 	ConversationAddName(builder, nameOffset)
@@ -93,6 +119,7 @@ func CloneConversationWithUpdates(prev *Conversation, name, desc []byte, mods []
 	ConversationAddMods(builder, modsOffset)
 	ConversationAddCreated(builder, prev.Created())
 	ConversationAddUpdated(builder, now)
+	ConversationAddParticipants(builder, partsOffset)
 	convOffset := ConversationEnd(builder)
 
 	builder.Finish(convOffset)
@@ -100,6 +127,36 @@ func CloneConversationWithUpdates(prev *Conversation, name, desc []byte, mods []
 	return GetRootAsConversation(builder.FinishedBytes(), 0)
 }
 
+// IsParticipant reports whether the member is one of the participants in the conversation.
+func (c *Conversation) IsParticipant(member Uuid) bool {
+	for i := range c.ParticipantsLength() {
+		if Uuid(c.Participants(i)) == member {
+			return true
+		}
+	}
+	return false
+}
+
+// createParticipantsVector adds the participant ids to the builder, skipping any duplicates.
+func createParticipantsVector(builder *flatbuffers.Builder, ids [][]byte) flatbuffers.UOffsetT {
+	seen := make(map[string]bool, len(ids))
+	offsets := make([]flatbuffers.UOffsetT, 0, len(ids))
+	for _, id := range ids {
+		if seen[string(id)] {
+			continue
+		}
+		seen[string(id)] = true
+		offsets = append(offsets, builder.CreateByteString(id))
+	}
+
+	// Prepend the offsets in reverse so the vector keeps the order the ids were given in
+	ConversationStartParticipantsVector(builder, len(offsets))
+	for i := len(offsets) - 1; i >= 0; i-- {
+		builder.PrependUOffsetT(offsets[i])
+	}
+	return builder.EndVector(len(offsets))
+}
+
 func ConversationEqual(a, b *Conversation) bool {
 	if a == b {
 		return true
@@ -110,7 +167,8 @@ func ConversationEqual(a, b *Conversation) bool {
 		slices.Equal(a.Desc(), b.Desc()) &&
 		a.Created() == b.Created() &&
 		a.Updated() == b.Updated() &&
-		a.ModsLength() == b.ModsLength() {
+		a.ModsLength() == b.ModsLength() &&
+		a.ParticipantsLength() == b.ParticipantsLength() {
 		// Make sure all the mods are equal. Order is not important.
 		amods := make(map[string]bool, a.ModsLength())
 		for i := range a.ModsLength() {
@@ -123,8 +181,40 @@ func ConversationEqual(a, b *Conversation) bool {
 			}
 		}
 
+		// The same goes for the participants
+		for i := range b.ParticipantsLength() {
+			if !a.IsParticipant(Uuid(b.Participants(i))) {
+				return false
+			}
+		}
+
 		return true
 	}
 
 	return false
 }
+
+func (c *Conversation) MarshalJSON() ([]byte, error) {
+	mods := make([]string, 0, c.ModsLength())
+	for i := range c.ModsLength() {
+		mods = append(mods, string(c.Mods(i)))
+	}
+
+	participants := make([]string, 0, c.ParticipantsLength())
+	for i := range c.ParticipantsLength() {
+		participants = append(participants, string(c.Participants(i)))
+	}
+
+	return json.Marshal(struct {
+		Id           string   `json:"id"`
+		Name         string   `json:"name"`
+		Desc         string   `json:"desc"`
+		Mods         []string `json:"mods"`
+		Participants []string `json:"participants"`
+		Created      int64    `json:"created"`
+		Updated      int64    `json:"updated"`
+	}{
+		string(c.Id()), string(c.Name()), string(c.Desc()), mods, participants, c.Created(),
+		c.Updated(),
+	})
+}
@@ -4,6 +4,7 @@
 package model
 
 import (
+	"encoding/json"
 	"fmt"
 	"slices"
 	"time"
@@ -97,3 +98,17 @@ func GroupEqual(a, b *Group) bool {
 	}
 	return true
 }
+
+func (g *Group) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Id      string `json:"id"`
+		Gid     string `json:"gid"`
+		Name    string `json:"name"`
+		Desc    string `json:"desc"`
+		Created int64  `json:"created"`
+		Updated int64  `json:"updated"`
+	}{
+		string(g.Id()), string(g.Gid()), string(g.Name()), string(g.Desc()), g.Created(),
+		g.Updated(),
+	})
+}
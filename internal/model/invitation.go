@@ -0,0 +1,69 @@
+// ---------------------------------------------------------------------------------------------- //
+// -- Copyright (c) 2024 Braden Hitchcock - MIT License (https://opensource.org/licenses/MIT)  -- //
+// ---------------------------------------------------------------------------------------------- //
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	flatbuffers "github.com/google/flatbuffers/go"
+)
+
+func NewInvitation(
+	creator Uuid, role Role, conversations []Uuid, ttl time.Duration,
+) (*Invitation, error) {
+	uuid, err := NewUuid()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create invitation: %v", err)
+	}
+
+	now := time.Now()
+
+	builder := flatbuffers.NewBuilder(256)
+	idOffset := builder.CreateString(string(uuid))
+	creatorOffset := builder.CreateString(string(creator))
+
+	convOffsets := make([]flatbuffers.UOffsetT, 0, len(conversations))
+	for _, c := range conversations {
+		convOffsets = append(convOffsets, builder.CreateString(string(c)))
+	}
+	InvitationStartConversationsVector(builder, len(convOffsets))
+	for i := len(convOffsets) - 1; i >= 0; i-- {
+		builder.PrependUOffsetT(convOffsets[i])
+	}
+	convsOffset := builder.EndVector(len(convOffsets))
+
+	InvitationStart(builder)
+	InvitationAddId(builder, idOffset)
+	InvitationAddCreator(builder, creatorOffset)
+	InvitationAddRole(builder, role)
+	InvitationAddConversations(builder, convsOffset)
+	InvitationAddCreated(builder, now.UnixMilli())
+	InvitationAddExpires(builder, now.Add(ttl).UnixMilli())
+
+	invitationOffset := InvitationEnd(builder)
+	builder.Finish(invitationOffset)
+
+	return GetRootAsInvitation(builder.FinishedBytes(), 0), nil
+}
+
+func (i *Invitation) MarshalJSON() ([]byte, error) {
+	conversations := make([]string, 0, i.ConversationsLength())
+	for j := range i.ConversationsLength() {
+		conversations = append(conversations, string(i.Conversations(j)))
+	}
+
+	return json.Marshal(struct {
+		Id            string   `json:"id"`
+		Creator       string   `json:"creator"`
+		Role          string   `json:"role"`
+		Conversations []string `json:"conversations"`
+		Created       int64    `json:"created"`
+		Expires       int64    `json:"expires"`
+	}{
+		string(i.Id()), string(i.Creator()), i.Role().String(), conversations, i.Created(),
+		i.Expires(),
+	})
+}
@@ -0,0 +1,46 @@
+// ---------------------------------------------------------------------------------------------- //
+// -- Copyright (c) 2024 Braden Hitchcock - MIT License (https://opensource.org/licenses/MIT)  -- //
+// ---------------------------------------------------------------------------------------------- //
+package model
+
+import (
+	"encoding/json"
+
+	flatbuffers "github.com/google/flatbuffers/go"
+)
+
+func NewLockout(
+	kind, subject string, member Uuid, failures int32, failed, until int64,
+) *Lockout {
+	builder := flatbuffers.NewBuilder(256)
+	kindOffset := builder.CreateString(kind)
+	subjectOffset := builder.CreateString(subject)
+	memberOffset := builder.CreateString(string(member))
+
+	LockoutStart(builder)
+	LockoutAddKind(builder, kindOffset)
+	LockoutAddSubject(builder, subjectOffset)
+	LockoutAddMember(builder, memberOffset)
+	LockoutAddFailures(builder, failures)
+	LockoutAddFailed(builder, failed)
+	LockoutAddUntil(builder, until)
+
+	lockoutOffset := LockoutEnd(builder)
+	builder.Finish(lockoutOffset)
+
+	return GetRootAsLockout(builder.FinishedBytes(), 0)
+}
+
+func (l *Lockout) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Kind     string `json:"kind"`
+		Subject  string `json:"subject"`
+		Member   string `json:"member"`
+		Failures int32  `json:"failures"`
+		Failed   int64  `json:"failed"`
+		Until    int64  `json:"until"`
+	}{
+		string(l.Kind()), string(l.Subject()), string(l.Member()), l.Failures(), l.Failed(),
+		l.Until(),
+	})
+}
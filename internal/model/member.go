@@ -4,6 +4,7 @@
 package model
 
 import (
+	"encoding/json"
 	"fmt"
 	"slices"
 	"time"
@@ -11,7 +12,7 @@ import (
 	flatbuffers "github.com/google/flatbuffers/go"
 )
 
-func NewMember(username, name string) (*Member, error) {
+func NewMember(username, name string, role Role) (*Member, error) {
 	uuid, err := NewUuid()
 	if err != nil {
 		return nil, fmt.Errorf("failed to create new member: %v", err)
@@ -31,6 +32,7 @@ func NewMember(username, name string) (*Member, error) {
 	MemberAddName(builder, mn)
 	MemberAddCreated(builder, now.UnixMilli())
 	MemberAddUpdated(builder, now.UnixMilli())
+	MemberAddRole(builder, role)
 
 	m := MemberEnd(builder)
 
@@ -65,6 +67,33 @@ func CloneMemberWithUpdates(prev *Member, uname, name []byte) *Member {
 	MemberAddName(builder, mn)
 	MemberAddCreated(builder, prev.Created())
 	MemberAddUpdated(builder, updated)
+	MemberAddRole(builder, prev.Role())
+	MemberAddReset(builder, prev.Reset())
+
+	m := MemberEnd(builder)
+	builder.Finish(m)
+
+	return GetRootAsMember(builder.FinishedBytes(), 0)
+}
+
+// CloneMemberWithReset copies the member, marking whether they must change their password before
+// doing anything else.
+func CloneMemberWithReset(prev *Member, reset bool) *Member {
+	builder := flatbuffers.NewBuilder(64)
+	mi := builder.CreateByteString(prev.Id())
+	mu := builder.CreateByteString(prev.Uname())
+	mn := builder.CreateByteString(prev.Name())
+
+	updated := time.Now().UnixMilli()
+
+	MemberStart(builder)
+	MemberAddId(builder, mi)
+	MemberAddUname(builder, mu)
+	MemberAddName(builder, mn)
+	MemberAddCreated(builder, prev.Created())
+	MemberAddUpdated(builder, updated)
+	MemberAddRole(builder, prev.Role())
+	MemberAddReset(builder, reset)
 
 	m := MemberEnd(builder)
 	builder.Finish(m)
@@ -79,9 +108,26 @@ func MemberEqual(a, b *Member) bool {
 			!slices.Equal(a.Uname(), b.Uname()) ||
 			!slices.Equal(a.Name(), b.Name()) ||
 			a.Created() != b.Created() ||
-			a.Updated() != b.Updated() {
+			a.Updated() != b.Updated() ||
+			a.Role() != b.Role() ||
+			a.Reset() != b.Reset() {
 			return false
 		}
 	}
 	return true
 }
+
+func (m *Member) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Id      string `json:"id"`
+		Uname   string `json:"uname"`
+		Name    string `json:"name"`
+		Created int64  `json:"created"`
+		Updated int64  `json:"updated"`
+		Role    string `json:"role"`
+		Reset   bool   `json:"reset"`
+	}{
+		string(m.Id()), string(m.Uname()), string(m.Name()), m.Created(), m.Updated(),
+		m.Role().String(), m.Reset(),
+	})
+}
@@ -4,6 +4,7 @@
 package model
 
 import (
+	"encoding/json"
 	"fmt"
 	"slices"
 	"time"
@@ -11,7 +12,9 @@ import (
 	flatbuffers "github.com/google/flatbuffers/go"
 )
 
-func NewMessage(author, convo Uuid, content string) (*Message, error) {
+// NewMessage creates a new message in a conversation. When parent is not empty the message is created
+// as a reply in the thread of the parent message.
+func NewMessage(author, convo, parent Uuid, content string) (*Message, error) {
 	uuid, err := NewUuid()
 	if err != nil {
 		return nil, fmt.Errorf("failed to create new message: %v", err)
@@ -24,6 +27,10 @@ func NewMessage(author, convo Uuid, content string) (*Message, error) {
 	authorOffset := builder.CreateString(string(author))
 	convoOffset := builder.CreateString(string(convo))
 	contentOffset := builder.CreateString(content)
+	var parentOffset flatbuffers.UOffsetT
+	if parent != "" {
+		parentOffset = builder.CreateString(string(parent))
+	}
 
 	MessageStart(builder)
 	MessageAddId(builder, idOffset)
@@ -32,6 +39,9 @@ func NewMessage(author, convo Uuid, content string) (*Message, error) {
 	MessageAddContent(builder, contentOffset)
 	MessageAddCreated(builder, now)
 	MessageAddUpdated(builder, now)
+	if parent != "" {
+		MessageAddParent(builder, parentOffset)
+	}
 
 	msgOffset := MessageEnd(builder)
 	builder.Finish(msgOffset)
@@ -48,6 +58,10 @@ func CloneMessageWithUpdates(prev *Message, content []byte) *Message {
 	authorOffset := builder.CreateByteString(prev.Author())
 	convoOffset := builder.CreateByteString(prev.Conversation())
 	contentOffset := builder.CreateByteString(content)
+	var parentOffset flatbuffers.UOffsetT
+	if prev.Parent() != nil {
+		parentOffset = builder.CreateByteString(prev.Parent())
+	}
 
 	MessageStart(builder)
 	MessageAddId(builder, idOffset)
@@ -56,6 +70,9 @@ func CloneMessageWithUpdates(prev *Message, content []byte) *Message {
 	MessageAddContent(builder, contentOffset)
 	MessageAddCreated(builder, prev.Created())
 	MessageAddUpdated(builder, now)
+	if prev.Parent() != nil {
+		MessageAddParent(builder, parentOffset)
+	}
 
 	msgOffset := MessageEnd(builder)
 	builder.Finish(msgOffset)
@@ -76,6 +93,7 @@ func MessageEqual(a, b *Message) bool {
 		slices.Equal(a.Author(), b.Author()) &&
 		slices.Equal(a.Conversation(), b.Conversation()) &&
 		slices.Equal(a.Content(), b.Content()) &&
+		slices.Equal(a.Parent(), b.Parent()) &&
 		a.Created() == b.Created() &&
 		a.Updated() == b.Updated() {
 		return true
@@ -83,3 +101,18 @@ func MessageEqual(a, b *Message) bool {
 
 	return false
 }
+
+func (m *Message) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Id           string `json:"id"`
+		Author       string `json:"author"`
+		Conversation string `json:"conversation"`
+		Content      string `json:"content"`
+		Parent       string `json:"parent,omitempty"`
+		Created      int64  `json:"created"`
+		Updated      int64  `json:"updated"`
+	}{
+		string(m.Id()), string(m.Author()), string(m.Conversation()), string(m.Content()),
+		string(m.Parent()), m.Created(), m.Updated(),
+	})
+}
@@ -4,8 +4,36 @@ package model
 
 import (
 	flatbuffers "github.com/google/flatbuffers/go"
+	"strconv"
 )
 
+type Role int8
+
+const (
+	RoleUser                  Role = 0
+	RoleConversationModerator Role = 1
+	RoleGroupModerator        Role = 2
+)
+
+var EnumNamesRole = map[Role]string{
+	RoleUser:                  "User",
+	RoleConversationModerator: "ConversationModerator",
+	RoleGroupModerator:        "GroupModerator",
+}
+
+var EnumValuesRole = map[string]Role{
+	"User":                  RoleUser,
+	"ConversationModerator": RoleConversationModerator,
+	"GroupModerator":        RoleGroupModerator,
+}
+
+func (v Role) String() string {
+	if s, ok := EnumNamesRole[v]; ok {
+		return s
+	}
+	return "Role(" + strconv.FormatInt(int64(v), 10) + ")"
+}
+
 type Group struct {
 	_tab flatbuffers.Table
 }
@@ -204,8 +232,32 @@ func (rcv *Member) MutateUpdated(n int64) bool {
 	return rcv._tab.MutateInt64Slot(12, n)
 }
 
+func (rcv *Member) Role() Role {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(14))
+	if o != 0 {
+		return Role(rcv._tab.GetInt8(o + rcv._tab.Pos))
+	}
+	return 0
+}
+
+func (rcv *Member) MutateRole(n Role) bool {
+	return rcv._tab.MutateInt8Slot(14, int8(n))
+}
+
+func (rcv *Member) Reset() bool {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(16))
+	if o != 0 {
+		return rcv._tab.GetBool(o + rcv._tab.Pos)
+	}
+	return false
+}
+
+func (rcv *Member) MutateReset(n bool) bool {
+	return rcv._tab.MutateBoolSlot(16, n)
+}
+
 func MemberStart(builder *flatbuffers.Builder) {
-	builder.StartObject(5)
+	builder.StartObject(7)
 }
 func MemberAddId(builder *flatbuffers.Builder, id flatbuffers.UOffsetT) {
 	builder.PrependUOffsetTSlot(0, flatbuffers.UOffsetT(id), 0)
@@ -222,6 +274,12 @@ func MemberAddCreated(builder *flatbuffers.Builder, created int64) {
 func MemberAddUpdated(builder *flatbuffers.Builder, updated int64) {
 	builder.PrependInt64Slot(4, updated, 0)
 }
+func MemberAddRole(builder *flatbuffers.Builder, role Role) {
+	builder.PrependInt8Slot(5, int8(role), 0)
+}
+func MemberAddReset(builder *flatbuffers.Builder, reset bool) {
+	builder.PrependBoolSlot(6, reset, false)
+}
 func MemberEnd(builder *flatbuffers.Builder) flatbuffers.UOffsetT {
 	return builder.EndObject()
 }
@@ -325,8 +383,25 @@ func (rcv *Conversation) MutateUpdated(n int64) bool {
 	return rcv._tab.MutateInt64Slot(14, n)
 }
 
+func (rcv *Conversation) Participants(j int) []byte {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(16))
+	if o != 0 {
+		a := rcv._tab.Vector(o)
+		return rcv._tab.ByteVector(a + flatbuffers.UOffsetT(j*4))
+	}
+	return nil
+}
+
+func (rcv *Conversation) ParticipantsLength() int {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(16))
+	if o != 0 {
+		return rcv._tab.VectorLen(o)
+	}
+	return 0
+}
+
 func ConversationStart(builder *flatbuffers.Builder) {
-	builder.StartObject(6)
+	builder.StartObject(7)
 }
 func ConversationAddId(builder *flatbuffers.Builder, id flatbuffers.UOffsetT) {
 	builder.PrependUOffsetTSlot(0, flatbuffers.UOffsetT(id), 0)
@@ -349,6 +424,12 @@ func ConversationAddCreated(builder *flatbuffers.Builder, created int64) {
 func ConversationAddUpdated(builder *flatbuffers.Builder, updated int64) {
 	builder.PrependInt64Slot(5, updated, 0)
 }
+func ConversationAddParticipants(builder *flatbuffers.Builder, participants flatbuffers.UOffsetT) {
+	builder.PrependUOffsetTSlot(6, flatbuffers.UOffsetT(participants), 0)
+}
+func ConversationStartParticipantsVector(builder *flatbuffers.Builder, numElems int) flatbuffers.UOffsetT {
+	return builder.StartVector(4, numElems, 4)
+}
 func ConversationEnd(builder *flatbuffers.Builder) flatbuffers.UOffsetT {
 	return builder.EndObject()
 }
@@ -443,8 +524,16 @@ func (rcv *Message) MutateUpdated(n int64) bool {
 	return rcv._tab.MutateInt64Slot(14, n)
 }
 
+func (rcv *Message) Parent() []byte {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(16))
+	if o != 0 {
+		return rcv._tab.ByteVector(o + rcv._tab.Pos)
+	}
+	return nil
+}
+
 func MessageStart(builder *flatbuffers.Builder) {
-	builder.StartObject(6)
+	builder.StartObject(7)
 }
 func MessageAddId(builder *flatbuffers.Builder, id flatbuffers.UOffsetT) {
 	builder.PrependUOffsetTSlot(0, flatbuffers.UOffsetT(id), 0)
@@ -464,6 +553,463 @@ func MessageAddCreated(builder *flatbuffers.Builder, created int64) {
 func MessageAddUpdated(builder *flatbuffers.Builder, updated int64) {
 	builder.PrependInt64Slot(5, updated, 0)
 }
+func MessageAddParent(builder *flatbuffers.Builder, parent flatbuffers.UOffsetT) {
+	builder.PrependUOffsetTSlot(6, flatbuffers.UOffsetT(parent), 0)
+}
 func MessageEnd(builder *flatbuffers.Builder) flatbuffers.UOffsetT {
 	return builder.EndObject()
 }
+type Reaction struct {
+	_tab flatbuffers.Table
+}
+
+func GetRootAsReaction(buf []byte, offset flatbuffers.UOffsetT) *Reaction {
+	n := flatbuffers.GetUOffsetT(buf[offset:])
+	x := &Reaction{}
+	x.Init(buf, n+offset)
+	return x
+}
+
+func FinishReactionBuffer(builder *flatbuffers.Builder, offset flatbuffers.UOffsetT) {
+	builder.Finish(offset)
+}
+
+func GetSizePrefixedRootAsReaction(buf []byte, offset flatbuffers.UOffsetT) *Reaction {
+	n := flatbuffers.GetUOffsetT(buf[offset+flatbuffers.SizeUint32:])
+	x := &Reaction{}
+	x.Init(buf, n+offset+flatbuffers.SizeUint32)
+	return x
+}
+
+func FinishSizePrefixedReactionBuffer(builder *flatbuffers.Builder, offset flatbuffers.UOffsetT) {
+	builder.FinishSizePrefixed(offset)
+}
+
+func (rcv *Reaction) Init(buf []byte, i flatbuffers.UOffsetT) {
+	rcv._tab.Bytes = buf
+	rcv._tab.Pos = i
+}
+
+func (rcv *Reaction) Table() flatbuffers.Table {
+	return rcv._tab
+}
+
+func (rcv *Reaction) Message() []byte {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(4))
+	if o != 0 {
+		return rcv._tab.ByteVector(o + rcv._tab.Pos)
+	}
+	return nil
+}
+
+func (rcv *Reaction) Author() []byte {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(6))
+	if o != 0 {
+		return rcv._tab.ByteVector(o + rcv._tab.Pos)
+	}
+	return nil
+}
+
+func (rcv *Reaction) Emoji() []byte {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(8))
+	if o != 0 {
+		return rcv._tab.ByteVector(o + rcv._tab.Pos)
+	}
+	return nil
+}
+
+func (rcv *Reaction) Created() int64 {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(10))
+	if o != 0 {
+		return rcv._tab.GetInt64(o + rcv._tab.Pos)
+	}
+	return 0
+}
+
+func (rcv *Reaction) MutateCreated(n int64) bool {
+	return rcv._tab.MutateInt64Slot(10, n)
+}
+
+func ReactionStart(builder *flatbuffers.Builder) {
+	builder.StartObject(4)
+}
+func ReactionAddMessage(builder *flatbuffers.Builder, message flatbuffers.UOffsetT) {
+	builder.PrependUOffsetTSlot(0, flatbuffers.UOffsetT(message), 0)
+}
+func ReactionAddAuthor(builder *flatbuffers.Builder, author flatbuffers.UOffsetT) {
+	builder.PrependUOffsetTSlot(1, flatbuffers.UOffsetT(author), 0)
+}
+func ReactionAddEmoji(builder *flatbuffers.Builder, emoji flatbuffers.UOffsetT) {
+	builder.PrependUOffsetTSlot(2, flatbuffers.UOffsetT(emoji), 0)
+}
+func ReactionAddCreated(builder *flatbuffers.Builder, created int64) {
+	builder.PrependInt64Slot(3, created, 0)
+}
+func ReactionEnd(builder *flatbuffers.Builder) flatbuffers.UOffsetT {
+	return builder.EndObject()
+}
+type Session struct {
+	_tab flatbuffers.Table
+}
+
+func GetRootAsSession(buf []byte, offset flatbuffers.UOffsetT) *Session {
+	n := flatbuffers.GetUOffsetT(buf[offset:])
+	x := &Session{}
+	x.Init(buf, n+offset)
+	return x
+}
+
+func FinishSessionBuffer(builder *flatbuffers.Builder, offset flatbuffers.UOffsetT) {
+	builder.Finish(offset)
+}
+
+func GetSizePrefixedRootAsSession(buf []byte, offset flatbuffers.UOffsetT) *Session {
+	n := flatbuffers.GetUOffsetT(buf[offset+flatbuffers.SizeUint32:])
+	x := &Session{}
+	x.Init(buf, n+offset+flatbuffers.SizeUint32)
+	return x
+}
+
+func FinishSizePrefixedSessionBuffer(builder *flatbuffers.Builder, offset flatbuffers.UOffsetT) {
+	builder.FinishSizePrefixed(offset)
+}
+
+func (rcv *Session) Init(buf []byte, i flatbuffers.UOffsetT) {
+	rcv._tab.Bytes = buf
+	rcv._tab.Pos = i
+}
+
+func (rcv *Session) Table() flatbuffers.Table {
+	return rcv._tab
+}
+
+func (rcv *Session) Id() []byte {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(4))
+	if o != 0 {
+		return rcv._tab.ByteVector(o + rcv._tab.Pos)
+	}
+	return nil
+}
+
+func (rcv *Session) Member() []byte {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(6))
+	if o != 0 {
+		return rcv._tab.ByteVector(o + rcv._tab.Pos)
+	}
+	return nil
+}
+
+func (rcv *Session) Agent() []byte {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(8))
+	if o != 0 {
+		return rcv._tab.ByteVector(o + rcv._tab.Pos)
+	}
+	return nil
+}
+
+func (rcv *Session) Addr() []byte {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(10))
+	if o != 0 {
+		return rcv._tab.ByteVector(o + rcv._tab.Pos)
+	}
+	return nil
+}
+
+func (rcv *Session) Created() int64 {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(12))
+	if o != 0 {
+		return rcv._tab.GetInt64(o + rcv._tab.Pos)
+	}
+	return 0
+}
+
+func (rcv *Session) MutateCreated(n int64) bool {
+	return rcv._tab.MutateInt64Slot(12, n)
+}
+
+func (rcv *Session) Seen() int64 {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(14))
+	if o != 0 {
+		return rcv._tab.GetInt64(o + rcv._tab.Pos)
+	}
+	return 0
+}
+
+func (rcv *Session) MutateSeen(n int64) bool {
+	return rcv._tab.MutateInt64Slot(14, n)
+}
+
+func SessionStart(builder *flatbuffers.Builder) {
+	builder.StartObject(6)
+}
+func SessionAddId(builder *flatbuffers.Builder, id flatbuffers.UOffsetT) {
+	builder.PrependUOffsetTSlot(0, flatbuffers.UOffsetT(id), 0)
+}
+func SessionAddMember(builder *flatbuffers.Builder, member flatbuffers.UOffsetT) {
+	builder.PrependUOffsetTSlot(1, flatbuffers.UOffsetT(member), 0)
+}
+func SessionAddAgent(builder *flatbuffers.Builder, agent flatbuffers.UOffsetT) {
+	builder.PrependUOffsetTSlot(2, flatbuffers.UOffsetT(agent), 0)
+}
+func SessionAddAddr(builder *flatbuffers.Builder, addr flatbuffers.UOffsetT) {
+	builder.PrependUOffsetTSlot(3, flatbuffers.UOffsetT(addr), 0)
+}
+func SessionAddCreated(builder *flatbuffers.Builder, created int64) {
+	builder.PrependInt64Slot(4, created, 0)
+}
+func SessionAddSeen(builder *flatbuffers.Builder, seen int64) {
+	builder.PrependInt64Slot(5, seen, 0)
+}
+func SessionEnd(builder *flatbuffers.Builder) flatbuffers.UOffsetT {
+	return builder.EndObject()
+}
+type Lockout struct {
+	_tab flatbuffers.Table
+}
+
+func GetRootAsLockout(buf []byte, offset flatbuffers.UOffsetT) *Lockout {
+	n := flatbuffers.GetUOffsetT(buf[offset:])
+	x := &Lockout{}
+	x.Init(buf, n+offset)
+	return x
+}
+
+func FinishLockoutBuffer(builder *flatbuffers.Builder, offset flatbuffers.UOffsetT) {
+	builder.Finish(offset)
+}
+
+func GetSizePrefixedRootAsLockout(buf []byte, offset flatbuffers.UOffsetT) *Lockout {
+	n := flatbuffers.GetUOffsetT(buf[offset+flatbuffers.SizeUint32:])
+	x := &Lockout{}
+	x.Init(buf, n+offset+flatbuffers.SizeUint32)
+	return x
+}
+
+func FinishSizePrefixedLockoutBuffer(builder *flatbuffers.Builder, offset flatbuffers.UOffsetT) {
+	builder.FinishSizePrefixed(offset)
+}
+
+func (rcv *Lockout) Init(buf []byte, i flatbuffers.UOffsetT) {
+	rcv._tab.Bytes = buf
+	rcv._tab.Pos = i
+}
+
+func (rcv *Lockout) Table() flatbuffers.Table {
+	return rcv._tab
+}
+
+func (rcv *Lockout) Kind() []byte {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(4))
+	if o != 0 {
+		return rcv._tab.ByteVector(o + rcv._tab.Pos)
+	}
+	return nil
+}
+
+func (rcv *Lockout) Subject() []byte {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(6))
+	if o != 0 {
+		return rcv._tab.ByteVector(o + rcv._tab.Pos)
+	}
+	return nil
+}
+
+func (rcv *Lockout) Member() []byte {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(8))
+	if o != 0 {
+		return rcv._tab.ByteVector(o + rcv._tab.Pos)
+	}
+	return nil
+}
+
+func (rcv *Lockout) Failures() int32 {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(10))
+	if o != 0 {
+		return rcv._tab.GetInt32(o + rcv._tab.Pos)
+	}
+	return 0
+}
+
+func (rcv *Lockout) MutateFailures(n int32) bool {
+	return rcv._tab.MutateInt32Slot(10, n)
+}
+
+func (rcv *Lockout) Failed() int64 {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(12))
+	if o != 0 {
+		return rcv._tab.GetInt64(o + rcv._tab.Pos)
+	}
+	return 0
+}
+
+func (rcv *Lockout) MutateFailed(n int64) bool {
+	return rcv._tab.MutateInt64Slot(12, n)
+}
+
+func (rcv *Lockout) Until() int64 {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(14))
+	if o != 0 {
+		return rcv._tab.GetInt64(o + rcv._tab.Pos)
+	}
+	return 0
+}
+
+func (rcv *Lockout) MutateUntil(n int64) bool {
+	return rcv._tab.MutateInt64Slot(14, n)
+}
+
+func LockoutStart(builder *flatbuffers.Builder) {
+	builder.StartObject(6)
+}
+func LockoutAddKind(builder *flatbuffers.Builder, kind flatbuffers.UOffsetT) {
+	builder.PrependUOffsetTSlot(0, flatbuffers.UOffsetT(kind), 0)
+}
+func LockoutAddSubject(builder *flatbuffers.Builder, subject flatbuffers.UOffsetT) {
+	builder.PrependUOffsetTSlot(1, flatbuffers.UOffsetT(subject), 0)
+}
+func LockoutAddMember(builder *flatbuffers.Builder, member flatbuffers.UOffsetT) {
+	builder.PrependUOffsetTSlot(2, flatbuffers.UOffsetT(member), 0)
+}
+func LockoutAddFailures(builder *flatbuffers.Builder, failures int32) {
+	builder.PrependInt32Slot(3, failures, 0)
+}
+func LockoutAddFailed(builder *flatbuffers.Builder, failed int64) {
+	builder.PrependInt64Slot(4, failed, 0)
+}
+func LockoutAddUntil(builder *flatbuffers.Builder, until int64) {
+	builder.PrependInt64Slot(5, until, 0)
+}
+func LockoutEnd(builder *flatbuffers.Builder) flatbuffers.UOffsetT {
+	return builder.EndObject()
+}
+type Invitation struct {
+	_tab flatbuffers.Table
+}
+
+func GetRootAsInvitation(buf []byte, offset flatbuffers.UOffsetT) *Invitation {
+	n := flatbuffers.GetUOffsetT(buf[offset:])
+	x := &Invitation{}
+	x.Init(buf, n+offset)
+	return x
+}
+
+func FinishInvitationBuffer(builder *flatbuffers.Builder, offset flatbuffers.UOffsetT) {
+	builder.Finish(offset)
+}
+
+func GetSizePrefixedRootAsInvitation(buf []byte, offset flatbuffers.UOffsetT) *Invitation {
+	n := flatbuffers.GetUOffsetT(buf[offset+flatbuffers.SizeUint32:])
+	x := &Invitation{}
+	x.Init(buf, n+offset+flatbuffers.SizeUint32)
+	return x
+}
+
+func FinishSizePrefixedInvitationBuffer(builder *flatbuffers.Builder, offset flatbuffers.UOffsetT) {
+	builder.FinishSizePrefixed(offset)
+}
+
+func (rcv *Invitation) Init(buf []byte, i flatbuffers.UOffsetT) {
+	rcv._tab.Bytes = buf
+	rcv._tab.Pos = i
+}
+
+func (rcv *Invitation) Table() flatbuffers.Table {
+	return rcv._tab
+}
+
+func (rcv *Invitation) Id() []byte {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(4))
+	if o != 0 {
+		return rcv._tab.ByteVector(o + rcv._tab.Pos)
+	}
+	return nil
+}
+
+func (rcv *Invitation) Creator() []byte {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(6))
+	if o != 0 {
+		return rcv._tab.ByteVector(o + rcv._tab.Pos)
+	}
+	return nil
+}
+
+func (rcv *Invitation) Role() Role {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(8))
+	if o != 0 {
+		return Role(rcv._tab.GetInt8(o + rcv._tab.Pos))
+	}
+	return 0
+}
+
+func (rcv *Invitation) MutateRole(n Role) bool {
+	return rcv._tab.MutateInt8Slot(8, int8(n))
+}
+
+func (rcv *Invitation) Conversations(j int) []byte {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(10))
+	if o != 0 {
+		a := rcv._tab.Vector(o)
+		return rcv._tab.ByteVector(a + flatbuffers.UOffsetT(j*4))
+	}
+	return nil
+}
+
+func (rcv *Invitation) ConversationsLength() int {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(10))
+	if o != 0 {
+		return rcv._tab.VectorLen(o)
+	}
+	return 0
+}
+
+func (rcv *Invitation) Created() int64 {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(12))
+	if o != 0 {
+		return rcv._tab.GetInt64(o + rcv._tab.Pos)
+	}
+	return 0
+}
+
+func (rcv *Invitation) MutateCreated(n int64) bool {
+	return rcv._tab.MutateInt64Slot(12, n)
+}
+
+func (rcv *Invitation) Expires() int64 {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(14))
+	if o != 0 {
+		return rcv._tab.GetInt64(o + rcv._tab.Pos)
+	}
+	return 0
+}
+
+func (rcv *Invitation) MutateExpires(n int64) bool {
+	return rcv._tab.MutateInt64Slot(14, n)
+}
+
+func InvitationStart(builder *flatbuffers.Builder) {
+	builder.StartObject(6)
+}
+func InvitationAddId(builder *flatbuffers.Builder, id flatbuffers.UOffsetT) {
+	builder.PrependUOffsetTSlot(0, flatbuffers.UOffsetT(id), 0)
+}
+func InvitationAddCreator(builder *flatbuffers.Builder, creator flatbuffers.UOffsetT) {
+	builder.PrependUOffsetTSlot(1, flatbuffers.UOffsetT(creator), 0)
+}
+func InvitationAddRole(builder *flatbuffers.Builder, role Role) {
+	builder.PrependInt8Slot(2, int8(role), 0)
+}
+func InvitationAddConversations(builder *flatbuffers.Builder, conversations flatbuffers.UOffsetT) {
+	builder.PrependUOffsetTSlot(3, flatbuffers.UOffsetT(conversations), 0)
+}
+func InvitationStartConversationsVector(builder *flatbuffers.Builder, numElems int) flatbuffers.UOffsetT {
+	return builder.StartVector(4, numElems, 4)
+}
+func InvitationAddCreated(builder *flatbuffers.Builder, created int64) {
+	builder.PrependInt64Slot(4, created, 0)
+}
+func InvitationAddExpires(builder *flatbuffers.Builder, expires int64) {
+	builder.PrependInt64Slot(5, expires, 0)
+}
+func InvitationEnd(builder *flatbuffers.Builder) flatbuffers.UOffsetT {
+	return builder.EndObject()
+}
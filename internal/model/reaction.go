@@ -0,0 +1,62 @@
+// ---------------------------------------------------------------------------------------------- //
+// -- Copyright (c) 2024 Braden Hitchcock - MIT License (https://opensource.org/licenses/MIT)  -- //
+// ---------------------------------------------------------------------------------------------- //
+package model
+
+import (
+	"encoding/json"
+	"slices"
+	"time"
+
+	flatbuffers "github.com/google/flatbuffers/go"
+)
+
+func NewReaction(message, author Uuid, emoji string) *Reaction {
+	now := time.Now().UnixMilli()
+
+	builder := flatbuffers.NewBuilder(256)
+	messageOffset := builder.CreateString(string(message))
+	authorOffset := builder.CreateString(string(author))
+	emojiOffset := builder.CreateString(emoji)
+
+	ReactionStart(builder)
+	ReactionAddMessage(builder, messageOffset)
+	ReactionAddAuthor(builder, authorOffset)
+	ReactionAddEmoji(builder, emojiOffset)
+	ReactionAddCreated(builder, now)
+
+	reactionOffset := ReactionEnd(builder)
+	builder.Finish(reactionOffset)
+
+	return GetRootAsReaction(builder.FinishedBytes(), 0)
+}
+
+func ReactionEqual(a, b *Reaction) bool {
+	if a == b {
+		return true
+	}
+
+	if a == nil || b == nil {
+		return false
+	}
+
+	if slices.Equal(a.Message(), b.Message()) &&
+		slices.Equal(a.Author(), b.Author()) &&
+		slices.Equal(a.Emoji(), b.Emoji()) &&
+		a.Created() == b.Created() {
+		return true
+	}
+
+	return false
+}
+
+func (r *Reaction) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Message string `json:"message"`
+		Author  string `json:"author"`
+		Emoji   string `json:"emoji"`
+		Created int64  `json:"created"`
+	}{
+		string(r.Message()), string(r.Author()), string(r.Emoji()), r.Created(),
+	})
+}
@@ -0,0 +1,45 @@
+// ---------------------------------------------------------------------------------------------- //
+// -- Copyright (c) 2024 Braden Hitchcock - MIT License (https://opensource.org/licenses/MIT)  -- //
+// ---------------------------------------------------------------------------------------------- //
+package model
+
+import (
+	"encoding/json"
+
+	flatbuffers "github.com/google/flatbuffers/go"
+)
+
+func NewSession(id, member Uuid, agent, addr string, created, seen int64) *Session {
+	builder := flatbuffers.NewBuilder(256)
+	idOffset := builder.CreateString(string(id))
+	memberOffset := builder.CreateString(string(member))
+	agentOffset := builder.CreateString(agent)
+	addrOffset := builder.CreateString(addr)
+
+	SessionStart(builder)
+	SessionAddId(builder, idOffset)
+	SessionAddMember(builder, memberOffset)
+	SessionAddAgent(builder, agentOffset)
+	SessionAddAddr(builder, addrOffset)
+	SessionAddCreated(builder, created)
+	SessionAddSeen(builder, seen)
+
+	sessionOffset := SessionEnd(builder)
+	builder.Finish(sessionOffset)
+
+	return GetRootAsSession(builder.FinishedBytes(), 0)
+}
+
+func (s *Session) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Id      string `json:"id"`
+		Member  string `json:"member"`
+		Agent   string `json:"agent"`
+		Addr    string `json:"addr"`
+		Created int64  `json:"created"`
+		Seen    int64  `json:"seen"`
+	}{
+		string(s.Id()), string(s.Member()), string(s.Agent()), string(s.Addr()), s.Created(),
+		s.Seen(),
+	})
+}
@@ -0,0 +1,216 @@
+// ---------------------------------------------------------------------------------------------- //
+// -- Copyright (c) 2024 Braden Hitchcock - MIT License (https://opensource.org/licenses/MIT)  -- //
+// ---------------------------------------------------------------------------------------------- //
+package policy
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/bradenhc/kolob/internal/model"
+)
+
+// A Permission allows a member to perform one of the use cases listed in the member use case
+// diagram (see docs/kolob-member-use-cases.plantuml.txt). The value of a permission is the ID of
+// the use case.
+type Permission string
+
+const (
+	EditOwnProfile                 Permission = "UC.ST.01"
+	ChangeOwnPassword              Permission = "UC.ST.02"
+	PostOwnMessage                 Permission = "UC.ST.03"
+	ReadOwnMessage                 Permission = "UC.ST.04"
+	EditOwnMessage                 Permission = "UC.ST.05"
+	DeleteOwnMessage               Permission = "UC.ST.06"
+	ReadOtherMessages              Permission = "UC.ST.07"
+	ReactToMessages                Permission = "UC.ST.08"
+	CreateMessageThreads           Permission = "UC.ST.09"
+	EditConversationInfo           Permission = "UC.CM.01"
+	ModifyConversationMembership   Permission = "UC.CM.02"
+	ModifyConversationMessageRules Permission = "UC.CM.03"
+	DeleteOtherMessages            Permission = "UC.CM.04"
+	ModifyConversationMods         Permission = "UC.CM.05"
+	CreateConversation             Permission = "UC.GM.01"
+	DeleteConversation             Permission = "UC.GM.02"
+	AddMember                      Permission = "UC.GM.03"
+	RemoveMember                   Permission = "UC.GM.04"
+	EditGroupInfo                  Permission = "UC.GM.05"
+	ChangeGroupPassword            Permission = "UC.GM.06"
+	ResetMemberPasswords           Permission = "UC.GM.07"
+
+	// Authenticated is satisfied by every member of the group. It is used for operations that are
+	// not restricted to a use case, such as listing the members of the group.
+	Authenticated Permission = "authenticated"
+
+	// Nobody is never granted to any role. It is used for operations that cannot be performed on
+	// resources owned by another member, such as editing another member's message.
+	Nobody Permission = "nobody"
+)
+
+// grants lists the permissions given directly to each role. Roles also inherit all permissions
+// given to the roles below them (see Granted).
+var grants = map[model.Role][]Permission{
+	model.RoleUser: {
+		Authenticated,
+		EditOwnProfile,
+		ChangeOwnPassword,
+		PostOwnMessage,
+		ReadOwnMessage,
+		EditOwnMessage,
+		DeleteOwnMessage,
+		ReadOtherMessages,
+		ReactToMessages,
+		CreateMessageThreads,
+	},
+	model.RoleConversationModerator: {
+		EditConversationInfo,
+		ModifyConversationMembership,
+		ModifyConversationMessageRules,
+		DeleteOtherMessages,
+		ModifyConversationMods,
+	},
+	model.RoleGroupModerator: {
+		CreateConversation,
+		DeleteConversation,
+		AddMember,
+		RemoveMember,
+		EditGroupInfo,
+		ChangeGroupPassword,
+		ResetMemberPasswords,
+	},
+}
+
+// Granted reports whether the role has been given the permission, either directly or through one
+// of the roles it inherits from.
+func Granted(r model.Role, p Permission) bool {
+	for role := model.RoleUser; role <= r; role++ {
+		for _, g := range grants[role] {
+			if g == p {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// An Operation identifies an action a member can ask the server to perform.
+type Operation string
+
+const (
+	GroupGet                       Operation = "group.get"
+	GroupUpdate                    Operation = "group.update"
+	GroupChangePassword            Operation = "group.auth"
+	GroupRekey                     Operation = "group.rekey"
+	MemberCreate                   Operation = "member.create"
+	MemberList                     Operation = "member.list"
+	MemberGet                      Operation = "member.get"
+	MemberUpdate                   Operation = "member.update"
+	MemberRemove                   Operation = "member.remove"
+	MemberChangePassword           Operation = "member.auth"
+	MemberResetPassword            Operation = "member.reset"
+	ConversationCreate             Operation = "conversation.create"
+	ConversationList               Operation = "conversation.list"
+	ConversationGet                Operation = "conversation.get"
+	ConversationUpdate             Operation = "conversation.update"
+	ConversationRemove             Operation = "conversation.remove"
+	ConversationAddMods            Operation = "conversation.mods.add"
+	ConversationRemoveMods         Operation = "conversation.mods.remove"
+	ConversationAddParticipants    Operation = "conversation.participants.add"
+	ConversationRemoveParticipants Operation = "conversation.participants.remove"
+	MessageCreate                  Operation = "message.add"
+	MessageList                    Operation = "message.list"
+	MessageGet                     Operation = "message.get"
+	MessageUpdate                  Operation = "message.update"
+	MessageRemove                  Operation = "message.remove"
+	MessageThread                  Operation = "message.thread"
+	ReactionCreate                 Operation = "reaction.add"
+	ReactionList                   Operation = "reaction.list"
+	ReactionRemove                 Operation = "reaction.remove"
+	InvitationCreate               Operation = "invitation.create"
+	InvitationList                 Operation = "invitation.list"
+	InvitationRemove               Operation = "invitation.remove"
+	LockoutList                    Operation = "lockout.list"
+	LockoutRemove                  Operation = "lockout.remove"
+	SessionList                    Operation = "session.list"
+	SessionRemove                  Operation = "session.remove"
+	StreamOpen                     Operation = "stream"
+)
+
+// A requirement lists the permission needed to perform an operation on a resource owned by the
+// member performing it and the permission needed to perform it on anyone else's resource.
+type requirement struct {
+	own   Permission
+	other Permission
+}
+
+var requirements = map[Operation]requirement{
+	GroupGet:                       {Authenticated, Authenticated},
+	GroupUpdate:                    {EditGroupInfo, EditGroupInfo},
+	GroupChangePassword:            {ChangeGroupPassword, ChangeGroupPassword},
+	GroupRekey:                     {ChangeGroupPassword, ChangeGroupPassword},
+	MemberCreate:                   {AddMember, AddMember},
+	MemberList:                     {Authenticated, Authenticated},
+	MemberGet:                      {Authenticated, Authenticated},
+	MemberUpdate:                   {EditOwnProfile, Nobody},
+	MemberRemove:                   {RemoveMember, RemoveMember},
+	MemberChangePassword:           {ChangeOwnPassword, Nobody},
+	MemberResetPassword:            {Nobody, ResetMemberPasswords},
+	ConversationCreate:             {CreateConversation, CreateConversation},
+	ConversationList:               {Authenticated, Authenticated},
+	ConversationGet:                {Authenticated, Authenticated},
+	ConversationUpdate:             {EditConversationInfo, EditConversationInfo},
+	ConversationRemove:             {DeleteConversation, DeleteConversation},
+	ConversationAddMods:            {ModifyConversationMods, ModifyConversationMods},
+	ConversationRemoveMods:         {ModifyConversationMods, ModifyConversationMods},
+	ConversationAddParticipants:    {ModifyConversationMembership, ModifyConversationMembership},
+	ConversationRemoveParticipants: {ModifyConversationMembership, ModifyConversationMembership},
+	MessageCreate:                  {PostOwnMessage, Nobody},
+	MessageList:                    {ReadOtherMessages, ReadOtherMessages},
+	MessageGet:                     {ReadOwnMessage, ReadOtherMessages},
+	MessageUpdate:                  {EditOwnMessage, Nobody},
+	MessageRemove:                  {DeleteOwnMessage, DeleteOtherMessages},
+	MessageThread:                  {ReadOtherMessages, ReadOtherMessages},
+	ReactionCreate:                 {ReactToMessages, Nobody},
+	ReactionList:                   {ReadOtherMessages, ReadOtherMessages},
+	ReactionRemove:                 {ReactToMessages, Nobody},
+	InvitationCreate:               {AddMember, AddMember},
+	InvitationList:                 {AddMember, AddMember},
+	InvitationRemove:               {AddMember, AddMember},
+	LockoutList:                    {ResetMemberPasswords, ResetMemberPasswords},
+	LockoutRemove:                  {ResetMemberPasswords, ResetMemberPasswords},
+	SessionList:                    {Authenticated, Authenticated},
+	SessionRemove:                  {Authenticated, Authenticated},
+	StreamOpen:                     {Authenticated, Authenticated},
+}
+
+var (
+	ErrForbidden        = errors.New("forbidden")
+	ErrUnknownOperation = errors.New("unknown operation")
+)
+
+// Required returns the permission a member needs to perform the operation. The owner flag
+// indicates whether the resource the operation acts on belongs to the member.
+func Required(op Operation, owner bool) (Permission, error) {
+	req, ok := requirements[op]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownOperation, op)
+	}
+	if owner {
+		return req.own, nil
+	}
+	return req.other, nil
+}
+
+// Check returns nil if a member with the role is allowed to perform the operation. The owner flag
+// indicates whether the resource the operation acts on belongs to the member. If the member is not
+// allowed to perform the operation, an error wrapping ErrForbidden is returned.
+func Check(op Operation, r model.Role, owner bool) error {
+	p, err := Required(op, owner)
+	if err != nil {
+		return err
+	}
+	if !Granted(r, p) {
+		return fmt.Errorf("%w: %s requires %s", ErrForbidden, op, p)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+// ---------------------------------------------------------------------------------------------- //
+// -- Copyright (c) 2024 Braden Hitchcock - MIT License (https://opensource.org/licenses/MIT)  -- //
+// ---------------------------------------------------------------------------------------------- //
+package policy_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bradenhc/kolob/internal/model"
+	"github.com/bradenhc/kolob/internal/policy"
+)
+
+func TestGrantedInheritance(t *testing.T) {
+	if !policy.Granted(model.RoleGroupModerator, policy.PostOwnMessage) {
+		t.Errorf("group moderator should inherit user permissions")
+	}
+	if !policy.Granted(model.RoleGroupModerator, policy.DeleteOtherMessages) {
+		t.Errorf("group moderator should inherit conversation moderator permissions")
+	}
+	if policy.Granted(model.RoleUser, policy.DeleteOtherMessages) {
+		t.Errorf("user should not have conversation moderator permissions")
+	}
+	if policy.Granted(model.RoleConversationModerator, policy.CreateConversation) {
+		t.Errorf("conversation moderator should not have group moderator permissions")
+	}
+	if policy.Granted(model.RoleGroupModerator, policy.Nobody) {
+		t.Errorf("no role should be granted the nobody permission")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	cases := []struct {
+		op      policy.Operation
+		role    model.Role
+		owner   bool
+		allowed bool
+	}{
+		{policy.MessageRemove, model.RoleUser, true, true},
+		{policy.MessageRemove, model.RoleUser, false, false},
+		{policy.MessageRemove, model.RoleConversationModerator, false, true},
+		{policy.MessageUpdate, model.RoleGroupModerator, false, false},
+		{policy.MessageCreate, model.RoleUser, false, false},
+		{policy.ConversationAddMods, model.RoleUser, false, false},
+		{policy.ConversationAddMods, model.RoleConversationModerator, false, true},
+		{policy.ConversationCreate, model.RoleConversationModerator, false, false},
+		{policy.ConversationCreate, model.RoleGroupModerator, false, true},
+		{policy.MemberUpdate, model.RoleUser, true, true},
+		{policy.MemberUpdate, model.RoleGroupModerator, false, false},
+		{policy.MemberCreate, model.RoleGroupModerator, false, true},
+		{policy.MemberResetPassword, model.RoleGroupModerator, false, true},
+		{policy.MemberResetPassword, model.RoleGroupModerator, true, false},
+		{policy.MemberResetPassword, model.RoleConversationModerator, false, false},
+		{policy.GroupGet, model.RoleUser, false, true},
+		{policy.SessionList, model.RoleUser, false, true},
+		{policy.LockoutList, model.RoleConversationModerator, false, false},
+		{policy.InvitationCreate, model.RoleUser, false, false},
+		{policy.InvitationCreate, model.RoleGroupModerator, false, true},
+		{policy.LockoutRemove, model.RoleGroupModerator, false, true},
+	}
+
+	for _, c := range cases {
+		err := policy.Check(c.op, c.role, c.owner)
+		if c.allowed && err != nil {
+			t.Errorf("%s by %s (owner=%v) should be allowed: %v", c.op, c.role, c.owner, err)
+		}
+		if !c.allowed && !errors.Is(err, policy.ErrForbidden) {
+			t.Errorf("%s by %s (owner=%v) should be forbidden: %v", c.op, c.role, c.owner, err)
+		}
+	}
+}
+
+func TestCheckUnknownOperation(t *testing.T) {
+	err := policy.Check("not.an.operation", model.RoleGroupModerator, true)
+	if !errors.Is(err, policy.ErrUnknownOperation) {
+		t.Errorf("expected unknown operation error, got %v", err)
+	}
+}
@@ -0,0 +1,259 @@
+// ---------------------------------------------------------------------------------------------- //
+// -- Copyright (c) 2024 Braden Hitchcock - MIT License (https://opensource.org/licenses/MIT)  -- //
+// ---------------------------------------------------------------------------------------------- //
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/bradenhc/kolob/internal/crypto"
+	"github.com/bradenhc/kolob/internal/model"
+	"github.com/bradenhc/kolob/internal/policy"
+	"github.com/bradenhc/kolob/internal/services"
+	"github.com/bradenhc/kolob/internal/session"
+	flatbuffers "github.com/google/flatbuffers/go"
+)
+
+var (
+	ErrTargetNotFound         = errors.New("target of operation not found")
+	ErrPasswordChangeRequired = errors.New("password change required")
+)
+
+// A Target identifies the resource an operation acts on. The Authorizer uses it to determine
+// whether the member performing the operation owns the resource and whether they moderate the
+// conversation the resource belongs to. If a message is given, its conversation is looked up and
+// used in place of the conversation field. The author of the message is used as the owner unless a
+// member is also given, as is the case for resources that members attach to a message.
+type Target struct {
+	Member       model.Uuid
+	Conversation model.Uuid
+	Message      model.Uuid
+}
+
+// An Authorizer decides whether the member that owns the session in a request context may perform
+// an operation according to the access control policy.
+type Authorizer struct {
+	members       services.MemberService
+	conversations services.ConversationService
+	messages      services.MessageService
+}
+
+func NewAuthorizer(
+	ms services.MemberService, cs services.ConversationService, gs services.MessageService,
+) *Authorizer {
+	return &Authorizer{ms, cs, gs}
+}
+
+// Authorize returns nil if the member in the session attached to the context is allowed to perform
+// the operation on the target. If they are not, the returned error wraps policy.ErrForbidden.
+func (a *Authorizer) Authorize(ctx context.Context, op policy.Operation, t Target) error {
+	key, err := session.FromContext(ctx)
+	if err != nil {
+		return err
+	}
+	caller, err := session.MemberFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	// The member may have been removed since the session was created, in which case they are no
+	// longer allowed to do anything.
+	m, err := a.getMember(ctx, key, caller)
+	if err != nil {
+		return fmt.Errorf("%w: member %s not found", policy.ErrForbidden, caller)
+	}
+	role := m.Role()
+
+	// Members whose password was reset can only change it until they do
+	if m.Reset() && op != policy.MemberChangePassword {
+		return fmt.Errorf("%w: %w", policy.ErrForbidden, ErrPasswordChangeRequired)
+	}
+
+	if t.Message != "" {
+		msg, err := a.getMessage(ctx, key, t.Message)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrTargetNotFound, err)
+		}
+		if t.Member == "" {
+			t.Member = model.Uuid(msg.Author())
+		}
+		t.Conversation = model.Uuid(msg.Conversation())
+	}
+
+	if t.Conversation != "" {
+		c, err := a.getConversation(ctx, key, t.Conversation)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrTargetNotFound, err)
+		}
+		// Conversations are private to their participants. Group moderators can still see and act
+		// on them so that they can manage every conversation in the group, as the services and
+		// event streams allow as well.
+		if role < model.RoleGroupModerator && !c.IsParticipant(caller) {
+			return fmt.Errorf("%w: member does not participate in conversation", policy.ErrForbidden)
+		}
+		if role < model.RoleConversationModerator {
+			for i := range c.ModsLength() {
+				if model.Uuid(c.Mods(i)) == caller {
+					role = model.RoleConversationModerator
+					break
+				}
+			}
+		}
+	}
+
+	owner := t.Member != "" && t.Member == caller
+
+	return policy.Check(op, role, owner)
+}
+
+// Require creates middleware that only calls the next handler if the member making the request is
+// allowed to perform the operation on the target found in the request.
+func (a *Authorizer) Require(op policy.Operation, target targetFunc) Middleware {
+	return authorization{a, op, target}
+}
+
+func (a *Authorizer) getMember(
+	ctx context.Context, key crypto.Key, id model.Uuid,
+) (*model.Member, error) {
+	builder := flatbuffers.NewBuilder(64)
+	idOffset := builder.CreateString(string(id))
+	services.MemberGetRequestStart(builder)
+	services.MemberGetRequestAddId(builder, idOffset)
+	builder.Finish(services.MemberGetRequestEnd(builder))
+
+	req := services.GetRootAsMemberGetRequest(builder.FinishedBytes(), 0)
+	return a.members.Get(ctx, req, key)
+}
+
+func (a *Authorizer) getConversation(
+	ctx context.Context, key crypto.Key, id model.Uuid,
+) (*model.Conversation, error) {
+	builder := flatbuffers.NewBuilder(64)
+	idOffset := builder.CreateString(string(id))
+	services.ConversationGetRequestStart(builder)
+	services.ConversationGetRequestAddId(builder, idOffset)
+	builder.Finish(services.ConversationGetRequestEnd(builder))
+
+	req := services.GetRootAsConversationGetRequest(builder.FinishedBytes(), 0)
+	return a.conversations.Get(ctx, req, key)
+}
+
+func (a *Authorizer) getMessage(
+	ctx context.Context, key crypto.Key, id model.Uuid,
+) (*model.Message, error) {
+	builder := flatbuffers.NewBuilder(64)
+	idOffset := builder.CreateString(string(id))
+	services.MessageGetRequestStart(builder)
+	services.MessageGetRequestAddId(builder, idOffset)
+	builder.Finish(services.MessageGetRequestEnd(builder))
+
+	req := services.GetRootAsMessageGetRequest(builder.FinishedBytes(), 0)
+	return a.messages.Get(ctx, req, key)
+}
+
+type authorization struct {
+	authorizer *Authorizer
+	op         policy.Operation
+	target     targetFunc
+}
+
+func (z authorization) Middleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		t, err := z.target(w, r)
+		if err != nil {
+			WriteJsonErr(w, http.StatusBadRequest, err)
+			return
+		}
+
+		err = z.authorizer.Authorize(r.Context(), z.op, t)
+		switch {
+		case err == nil:
+			next(w, r)
+		case errors.Is(err, policy.ErrForbidden):
+			WriteJsonErr(w, http.StatusForbidden, err)
+		case errors.Is(err, ErrTargetNotFound):
+			WriteJsonErr(w, http.StatusNotFound, err)
+		default:
+			WriteJsonErr(w, http.StatusInternalServerError, err)
+		}
+	}
+}
+
+// A targetFunc finds the target of an operation in a request.
+type targetFunc func(w http.ResponseWriter, r *http.Request) (Target, error)
+
+func noTarget(w http.ResponseWriter, r *http.Request) (Target, error) {
+	return Target{}, nil
+}
+
+func memberPathTarget(w http.ResponseWriter, r *http.Request) (Target, error) {
+	return Target{Member: model.Uuid(r.PathValue("id"))}, nil
+}
+
+func conversationPathTarget(w http.ResponseWriter, r *http.Request) (Target, error) {
+	return Target{Conversation: model.Uuid(r.PathValue("id"))}, nil
+}
+
+func messagePathTarget(w http.ResponseWriter, r *http.Request) (Target, error) {
+	return Target{Message: model.Uuid(r.PathValue("id"))}, nil
+}
+
+// messageBodyTarget finds the author and conversation of a new message in the body of the request.
+func messageBodyTarget(w http.ResponseWriter, r *http.Request) (Target, error) {
+	body, err := peekRequestBody(w, r)
+	if err != nil {
+		return Target{}, err
+	}
+
+	req := services.GetRootAsMessageAddRequest(body, 0)
+	return Target{
+		Member:       model.Uuid(req.Author()),
+		Conversation: model.Uuid(req.Conversation()),
+	}, nil
+}
+
+// reactionAddTarget finds the member reacting to the message in the request path in the body of a
+// request to add a reaction.
+func reactionAddTarget(w http.ResponseWriter, r *http.Request) (Target, error) {
+	body, err := peekRequestBody(w, r)
+	if err != nil {
+		return Target{}, err
+	}
+
+	req := services.GetRootAsReactionAddRequest(body, 0)
+	return Target{
+		Member:  model.Uuid(req.Author()),
+		Message: model.Uuid(r.PathValue("id")),
+	}, nil
+}
+
+// reactionRemoveTarget finds the member whose reaction to the message in the request path is
+// removed in the body of a request to remove a reaction.
+func reactionRemoveTarget(w http.ResponseWriter, r *http.Request) (Target, error) {
+	body, err := peekRequestBody(w, r)
+	if err != nil {
+		return Target{}, err
+	}
+
+	req := services.GetRootAsReactionRemoveRequest(body, 0)
+	return Target{
+		Member:  model.Uuid(req.Author()),
+		Message: model.Uuid(r.PathValue("id")),
+	}, nil
+}
+
+// peekRequestBody reads the body of the request and restores it so the handler can read it again.
+func peekRequestBody(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	body, err := ReadRequestBody(w, r)
+	if err != nil {
+		return nil, err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	return body, nil
+}
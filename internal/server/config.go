@@ -9,14 +9,47 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"runtime"
 	"strconv"
+	"strings"
 	"time"
 )
 
 type Config struct {
-	Port            int
-	DatabaseFile    string
+	Port         int
+	DatabaseFile string
+
+	// IndexKeyFile holds the key that blind indexes usernames and the group ID. It is kept next to
+	// the database unless set.
+	IndexKeyFile    string
 	ShutdownTimeout time.Duration
+
+	// SessionKeyFile holds the key that encrypts the data keys of logged in sessions. SessionStore
+	// is where sessions are kept: "sqlite" keeps them in the database so they survive a restart,
+	// and "memory" ends them all when the server stops. A session ends once it has been idle for
+	// SessionIdleTimeout or has existed for SessionMaxAge.
+	SessionKeyFile     string
+	SessionStore       string
+	SessionIdleTimeout time.Duration
+	SessionMaxAge      time.Duration
+
+	// KdfConcurrency is how many password hashes and key derivations can run at once. Each one uses
+	// a full CPU and, with Argon2id, a lot of memory, so logins beyond this wait their turn.
+	KdfConcurrency int
+
+	// CertFile and KeyFile are the TLS certificate and key the server uses. If neither is set, a
+	// self-signed certificate is generated and saved next to the database.
+	CertFile string
+	KeyFile  string
+
+	// CACertFile and CAKeyFile are a local certificate authority that issues the certificate the
+	// server uses when CertFile and KeyFile aren't set. If neither is set, the authority created by
+	// kolobctl next to the database is used if there is one.
+	CACertFile string
+	CAKeyFile  string
+
+	// Hosts are the DNS names and IP addresses that generated certificates are valid for.
+	Hosts []string
 }
 
 func LoadConfig() (Config, error) {
@@ -30,9 +63,20 @@ func LoadConfig() (Config, error) {
 		Port:            24000,
 		DatabaseFile:    path.Join(cwd, "kolob.db"),
 		ShutdownTimeout: 10 * time.Second,
+		Hosts:           DefaultHosts(),
+
+		SessionKeyFile:     path.Join(cwd, "kolob-session.key"),
+		SessionStore:       "sqlite",
+		SessionIdleTimeout: 15 * time.Minute,
+		SessionMaxAge:      7 * 24 * time.Hour,
+
+		KdfConcurrency: runtime.NumCPU(),
 	}
 	s.loadEnvironment()
 	s.loadArgs()
+	if s.IndexKeyFile == "" {
+		s.IndexKeyFile = DefaultIndexKeyFile(s.DatabaseFile)
+	}
 	return s, nil
 }
 
@@ -49,6 +93,62 @@ func (s *Config) loadEnvironment() error {
 		s.DatabaseFile = val
 	}
 
+	if val := os.Getenv("KOLOB_INDEX_KEY"); val != "" {
+		s.IndexKeyFile = val
+	}
+
+	if val := os.Getenv("KOLOB_TLS_CERT"); val != "" {
+		s.CertFile = val
+	}
+
+	if val := os.Getenv("KOLOB_TLS_KEY"); val != "" {
+		s.KeyFile = val
+	}
+
+	if val := os.Getenv("KOLOB_CA_CERT"); val != "" {
+		s.CACertFile = val
+	}
+
+	if val := os.Getenv("KOLOB_CA_KEY"); val != "" {
+		s.CAKeyFile = val
+	}
+
+	if val := os.Getenv("KOLOB_HOSTS"); val != "" {
+		s.Hosts = ParseHosts(val)
+	}
+
+	if val := os.Getenv("KOLOB_SESSION_KEY"); val != "" {
+		s.SessionKeyFile = val
+	}
+
+	if val := os.Getenv("KOLOB_SESSION_STORE"); val != "" {
+		s.SessionStore = val
+	}
+
+	if val := os.Getenv("KOLOB_SESSION_IDLE_TIMEOUT"); val != "" {
+		d, err := time.ParseDuration(val)
+		if err != nil {
+			return fmt.Errorf("failed to parse KOLOB_SESSION_IDLE_TIMEOUT: %v", err)
+		}
+		s.SessionIdleTimeout = d
+	}
+
+	if val := os.Getenv("KOLOB_SESSION_MAX_AGE"); val != "" {
+		d, err := time.ParseDuration(val)
+		if err != nil {
+			return fmt.Errorf("failed to parse KOLOB_SESSION_MAX_AGE: %v", err)
+		}
+		s.SessionMaxAge = d
+	}
+
+	if val := os.Getenv("KOLOB_KDF_CONCURRENCY"); val != "" {
+		n, err := strconv.Atoi(val)
+		if err != nil {
+			return fmt.Errorf("failed to parse KOLOB_KDF_CONCURRENCY: %v", err)
+		}
+		s.KdfConcurrency = n
+	}
+
 	if val := os.Getenv("KOLOB_SHUTDOWN_TIMEOUT"); val != "" {
 		d, err := time.ParseDuration(val)
 		if err != nil {
@@ -63,6 +163,25 @@ func (s *Config) loadEnvironment() error {
 func (s *Config) loadArgs() error {
 	port := flag.Int("port", 0, "The port to run the HTTP server on.")
 	data := flag.String("data", "", "The path to the database file where data is stored.")
+	ikey := flag.String(
+		"index-key", "", "The path to the file holding the key used to index usernames.",
+	)
+	cert := flag.String("tls-cert", "", "The path to the TLS certificate file.")
+	key := flag.String("tls-key", "", "The path to the TLS private key file.")
+	caCert := flag.String("ca-cert", "", "The path to the certificate authority certificate file.")
+	caKey := flag.String("ca-key", "", "The path to the certificate authority private key file.")
+	hosts := flag.String(
+		"hosts", "", "Comma separated DNS names and IP addresses the certificate is valid for.",
+	)
+	skey := flag.String(
+		"session-key", "", "The path to the file holding the key that encrypts sessions.",
+	)
+	sstore := flag.String("session-store", "", "Where sessions are kept: sqlite or memory.")
+	idle := flag.Duration("session-idle-timeout", 0, "How long an idle session lasts.")
+	maxAge := flag.Duration("session-max-age", 0, "How long a session lasts at most.")
+	kdf := flag.Int(
+		"kdf-concurrency", 0, "How many password hashes and key derivations can run at once.",
+	)
 
 	flag.Usage = func() {
 		println := func(format string, a ...any) {
@@ -87,5 +206,69 @@ func (s *Config) loadArgs() error {
 	if *data != "" {
 		s.DatabaseFile = *data
 	}
+	if *ikey != "" {
+		s.IndexKeyFile = *ikey
+	}
+	if *cert != "" {
+		s.CertFile = *cert
+	}
+	if *key != "" {
+		s.KeyFile = *key
+	}
+	if *caCert != "" {
+		s.CACertFile = *caCert
+	}
+	if *caKey != "" {
+		s.CAKeyFile = *caKey
+	}
+	if *hosts != "" {
+		s.Hosts = ParseHosts(*hosts)
+	}
+	if *skey != "" {
+		s.SessionKeyFile = *skey
+	}
+	if *sstore != "" {
+		s.SessionStore = *sstore
+	}
+	if *idle != 0 {
+		s.SessionIdleTimeout = *idle
+	}
+	if *maxAge != 0 {
+		s.SessionMaxAge = *maxAge
+	}
+	if *kdf != 0 {
+		s.KdfConcurrency = *kdf
+	}
 	return nil
 }
+
+// DefaultIndexKeyFile returns where the index key is kept when no file is configured: next to the
+// database, so that the two are found together.
+func DefaultIndexKeyFile(dbpath string) string {
+	return filepath.Join(filepath.Dir(dbpath), "kolob.key")
+}
+
+// DefaultHosts returns the hosts that generated certificates are valid for when none are configured:
+// the loopback addresses and the name of this machine, both bare and under .local so that devices
+// on the LAN can reach it through mDNS.
+func DefaultHosts() []string {
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	if name, err := os.Hostname(); err == nil && name != "" && name != "localhost" {
+		name = strings.ToLower(strings.TrimSuffix(name, ".local"))
+		hosts = append(hosts, name, name+".local")
+	}
+
+	return hosts
+}
+
+// ParseHosts splits a comma separated list of hosts, ignoring whitespace and empty entries.
+func ParseHosts(val string) []string {
+	hosts := make([]string, 0)
+	for _, h := range strings.Split(val, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			hosts = append(hosts, h)
+		}
+	}
+
+	return hosts
+}
@@ -0,0 +1,249 @@
+// ---------------------------------------------------------------------------------------------- //
+// -- Copyright (c) 2024 Braden Hitchcock - MIT License (https://opensource.org/licenses/MIT)  -- //
+// ---------------------------------------------------------------------------------------------- //
+package server
+
+import (
+	"net/http"
+
+	"github.com/bradenhc/kolob/internal/services"
+	"github.com/bradenhc/kolob/internal/session"
+	flatbuffers "github.com/google/flatbuffers/go"
+)
+
+type ConversationHandler struct {
+	conversations services.ConversationService
+}
+
+func NewConversationHandler(cs services.ConversationService) ConversationHandler {
+	return ConversationHandler{cs}
+}
+
+func (h *ConversationHandler) CreateConversation(w http.ResponseWriter, r *http.Request) {
+	key, err := session.FromContext(r.Context())
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	body, err := ReadRequestBody(w, r)
+	if err != nil {
+		WriteJsonErr(w, http.StatusBadRequest, err)
+		return
+	}
+
+	req := services.GetRootAsConversationAddRequest(body, 0)
+	c, err := h.conversations.Add(r.Context(), req, key)
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	WriteJson(w, http.StatusOK, c)
+}
+
+func (h *ConversationHandler) ListConversations(w http.ResponseWriter, r *http.Request) {
+	key, err := session.FromContext(r.Context())
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	member, err := session.MemberFromContext(r.Context())
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	cs, err := h.conversations.ListAll(r.Context(), member, key)
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	WriteJson(w, http.StatusOK, cs)
+}
+
+func (h *ConversationHandler) GetConversation(w http.ResponseWriter, r *http.Request) {
+	key, err := session.FromContext(r.Context())
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	builder := flatbuffers.NewBuilder(64)
+	idOffset := builder.CreateString(r.PathValue("id"))
+	services.ConversationGetRequestStart(builder)
+	services.ConversationGetRequestAddId(builder, idOffset)
+	builder.Finish(services.ConversationGetRequestEnd(builder))
+
+	req := services.GetRootAsConversationGetRequest(builder.FinishedBytes(), 0)
+	c, err := h.conversations.Get(r.Context(), req, key)
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	WriteJson(w, http.StatusOK, c)
+}
+
+func (h *ConversationHandler) UpdateConversation(w http.ResponseWriter, r *http.Request) {
+	key, err := session.FromContext(r.Context())
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	body, err := ReadRequestBody(w, r)
+	if err != nil {
+		WriteJsonErr(w, http.StatusBadRequest, err)
+		return
+	}
+
+	req := services.GetRootAsConversationUpdateRequest(body, 0)
+	if err := CheckRequestId(r, req.Id()); err != nil {
+		WriteJsonErr(w, http.StatusBadRequest, err)
+		return
+	}
+
+	c, err := h.conversations.Update(r.Context(), req, key)
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	WriteJson(w, http.StatusOK, c)
+}
+
+func (h *ConversationHandler) RemoveConversation(w http.ResponseWriter, r *http.Request) {
+	builder := flatbuffers.NewBuilder(64)
+	idOffset := builder.CreateString(r.PathValue("id"))
+	services.ConversationRemoveRequestStart(builder)
+	services.ConversationRemoveRequestAddId(builder, idOffset)
+	builder.Finish(services.ConversationRemoveRequestEnd(builder))
+
+	req := services.GetRootAsConversationRemoveRequest(builder.FinishedBytes(), 0)
+	err := h.conversations.Remove(r.Context(), req)
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *ConversationHandler) AddConversationMods(w http.ResponseWriter, r *http.Request) {
+	key, err := session.FromContext(r.Context())
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	body, err := ReadRequestBody(w, r)
+	if err != nil {
+		WriteJsonErr(w, http.StatusBadRequest, err)
+		return
+	}
+
+	req := services.GetRootAsConversationModsAddRequest(body, 0)
+	if err := CheckRequestId(r, req.Id()); err != nil {
+		WriteJsonErr(w, http.StatusBadRequest, err)
+		return
+	}
+
+	err = h.conversations.AddMods(r.Context(), req, key)
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *ConversationHandler) RemoveConversationMods(w http.ResponseWriter, r *http.Request) {
+	key, err := session.FromContext(r.Context())
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	body, err := ReadRequestBody(w, r)
+	if err != nil {
+		WriteJsonErr(w, http.StatusBadRequest, err)
+		return
+	}
+
+	req := services.GetRootAsConversationModsRemoveRequest(body, 0)
+	if err := CheckRequestId(r, req.Id()); err != nil {
+		WriteJsonErr(w, http.StatusBadRequest, err)
+		return
+	}
+
+	err = h.conversations.RemoveMods(r.Context(), req, key)
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *ConversationHandler) AddConversationParticipants(
+	w http.ResponseWriter, r *http.Request,
+) {
+	key, err := session.FromContext(r.Context())
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	body, err := ReadRequestBody(w, r)
+	if err != nil {
+		WriteJsonErr(w, http.StatusBadRequest, err)
+		return
+	}
+
+	req := services.GetRootAsConversationParticipantsAddRequest(body, 0)
+	if err := CheckRequestId(r, req.Id()); err != nil {
+		WriteJsonErr(w, http.StatusBadRequest, err)
+		return
+	}
+
+	err = h.conversations.AddParticipants(r.Context(), req, key)
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *ConversationHandler) RemoveConversationParticipants(
+	w http.ResponseWriter, r *http.Request,
+) {
+	key, err := session.FromContext(r.Context())
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	body, err := ReadRequestBody(w, r)
+	if err != nil {
+		WriteJsonErr(w, http.StatusBadRequest, err)
+		return
+	}
+
+	req := services.GetRootAsConversationParticipantsRemoveRequest(body, 0)
+	if err := CheckRequestId(r, req.Id()); err != nil {
+		WriteJsonErr(w, http.StatusBadRequest, err)
+		return
+	}
+
+	err = h.conversations.RemoveParticipants(r.Context(), req, key)
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -4,8 +4,10 @@
 package server
 
 import (
+	"errors"
 	"net/http"
 
+	"github.com/bradenhc/kolob/internal/crypto"
 	"github.com/bradenhc/kolob/internal/services"
 	"github.com/bradenhc/kolob/internal/session"
 )
@@ -19,8 +21,12 @@ func NewGroupHandler(gs services.GroupService) GroupHandler {
 }
 
 func (h *GroupHandler) InitGroup(w http.ResponseWriter, r *http.Request) {
-	body := make([]byte, 1024)
-	r.Body.Read(body)
+	body, err := ReadRequestBody(w, r)
+	if err != nil {
+		WriteJsonErr(w, http.StatusBadRequest, err)
+		return
+	}
+
 	req := services.GetRootAsGroupInitRequest(body, 0)
 	g, err := h.groups.Create(r.Context(), req)
 	if err != nil {
@@ -32,9 +38,115 @@ func (h *GroupHandler) InitGroup(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *GroupHandler) GetGroupInfo(w http.ResponseWriter, r *http.Request) {
-	_, err := session.FromContext(r.Context())
+	key, err := session.FromContext(r.Context())
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	g, err := h.groups.Get(r.Context(), key)
 	if err != nil {
 		WriteJsonErr(w, http.StatusInternalServerError, err)
 		return
 	}
+
+	WriteJson(w, http.StatusOK, g)
+}
+
+func (h *GroupHandler) UpdateGroup(w http.ResponseWriter, r *http.Request) {
+	key, err := session.FromContext(r.Context())
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	body, err := ReadRequestBody(w, r)
+	if err != nil {
+		WriteJsonErr(w, http.StatusBadRequest, err)
+		return
+	}
+
+	req := services.GetRootAsGroupUpdateRequest(body, 0)
+	g, err := h.groups.Update(r.Context(), req, key)
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	WriteJson(w, http.StatusOK, g)
+}
+
+func (h *GroupHandler) ChangeGroupPassword(w http.ResponseWriter, r *http.Request) {
+	key, err := session.FromContext(r.Context())
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	body, err := ReadRequestBody(w, r)
+	if err != nil {
+		WriteJsonErr(w, http.StatusBadRequest, err)
+		return
+	}
+
+	req := services.GetRootAsGroupChangePasswordRequest(body, 0)
+	err = h.groups.ChangePassword(r.Context(), req, key)
+	if errors.Is(err, crypto.ErrInvalidPassword) {
+		WriteJsonErr(w, http.StatusBadRequest, err)
+		return
+	}
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *GroupHandler) RekeyGroup(w http.ResponseWriter, r *http.Request) {
+	body, err := ReadRequestBody(w, r)
+	if err != nil {
+		WriteJsonErr(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// Check the password before anyone is signed out, so that a mistyped one changes nothing
+	req := services.GetRootAsGroupRekeyRequest(body, 0)
+	ok, err := h.groups.CheckPassword(r.Context(), crypto.Password(req.Password()))
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !ok {
+		WriteJsonErr(w, http.StatusUnauthorized, ErrIncorrectCredentials)
+		return
+	}
+
+	err = h.groups.CheckRekeyRecoveryKit(r.Context(), req)
+	if err != nil {
+		WriteJsonErr(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// Every session is ended by the rotation, so members need to log in again once it finishes
+	kit, err := h.groups.Rekey(r.Context(), req)
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// The shares of the old recovery kit stop working, so the new ones are only ever shown here
+	if len(kit) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	shares := make([]string, len(kit))
+	for i, s := range kit {
+		shares[i] = s.String()
+	}
+
+	WriteJson(w, http.StatusOK, struct {
+		Shares []string `json:"shares"`
+	}{shares})
 }
@@ -0,0 +1,143 @@
+// ---------------------------------------------------------------------------------------------- //
+// -- Copyright (c) 2024 Braden Hitchcock - MIT License (https://opensource.org/licenses/MIT)  -- //
+// ---------------------------------------------------------------------------------------------- //
+package server
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/bradenhc/kolob/internal/crypto"
+	"github.com/bradenhc/kolob/internal/model"
+	"github.com/bradenhc/kolob/internal/services"
+	"github.com/bradenhc/kolob/internal/session"
+	"github.com/bradenhc/kolob/internal/store"
+	flatbuffers "github.com/google/flatbuffers/go"
+)
+
+type InvitationHandler struct {
+	invitations services.InvitationService
+	lockouts    services.LockoutService
+}
+
+func NewInvitationHandler(
+	is services.InvitationService, ls services.LockoutService,
+) InvitationHandler {
+	return InvitationHandler{is, ls}
+}
+
+// CreateInvitation creates an invitation from the member making the request and responds with it
+// along with its code, which is never shown again.
+func (h *InvitationHandler) CreateInvitation(w http.ResponseWriter, r *http.Request) {
+	key, err := session.FromContext(r.Context())
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+	creator, err := session.MemberFromContext(r.Context())
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	body, err := ReadRequestBody(w, r)
+	if err != nil {
+		WriteJsonErr(w, http.StatusBadRequest, err)
+		return
+	}
+
+	req := services.GetRootAsInvitationCreateRequest(body, 0)
+	inv, code, err := h.invitations.Create(r.Context(), req, key, creator)
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	WriteJson(w, http.StatusOK, struct {
+		Invitation *model.Invitation `json:"invitation"`
+		Code       string            `json:"code"`
+	}{inv, code})
+}
+
+func (h *InvitationHandler) ListInvitations(w http.ResponseWriter, r *http.Request) {
+	key, err := session.FromContext(r.Context())
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	invs, err := h.invitations.List(r.Context(), key)
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	WriteJson(w, http.StatusOK, invs)
+}
+
+func (h *InvitationHandler) RemoveInvitation(w http.ResponseWriter, r *http.Request) {
+	builder := flatbuffers.NewBuilder(64)
+	idOffset := builder.CreateString(r.PathValue("id"))
+	services.InvitationRemoveRequestStart(builder)
+	services.InvitationRemoveRequestAddId(builder, idOffset)
+	builder.Finish(services.InvitationRemoveRequestEnd(builder))
+
+	req := services.GetRootAsInvitationRemoveRequest(builder.FinishedBytes(), 0)
+	err := h.invitations.Remove(r.Context(), req)
+	if errors.Is(err, store.ErrInvitationNotFound) {
+		WriteJsonErr(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RedeemInvitation adds a member with the username, name, and password in the request using up the
+// invitation code in the request. It doesn't require a session. Wrong codes count as failed logins
+// for the address of the client, so that codes can't be guessed.
+func (h *InvitationHandler) RedeemInvitation(w http.ResponseWriter, r *http.Request) {
+	body, err := ReadRequestBody(w, r)
+	if err != nil {
+		WriteJsonErr(w, http.StatusBadRequest, err)
+		return
+	}
+
+	req := services.GetRootAsInvitationRedeemRequest(body, 0)
+	addr := clientAddr(r)
+
+	wait, err := h.lockouts.Check(r.Context(), addr, nil)
+	if errors.Is(err, services.ErrLockedOut) {
+		writeLockedOut(w, err, wait)
+		return
+	}
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer h.lockouts.Done(addr, nil)
+
+	m, err := h.invitations.Redeem(r.Context(), req)
+	if errors.Is(err, services.ErrInvalidInvitation) {
+		err := h.lockouts.Fail(r.Context(), addr, nil)
+		if err != nil {
+			slog.Warn("Failed to count failed invitation", "err", err)
+		}
+		WriteJsonErr(w, http.StatusNotFound, services.ErrInvalidInvitation)
+		return
+	}
+	if errors.Is(err, crypto.ErrInvalidPassword) {
+		WriteJsonErr(w, http.StatusBadRequest, err)
+		return
+	}
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	WriteJson(w, http.StatusOK, m)
+}
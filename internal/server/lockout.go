@@ -0,0 +1,48 @@
+// ---------------------------------------------------------------------------------------------- //
+// -- Copyright (c) 2024 Braden Hitchcock - MIT License (https://opensource.org/licenses/MIT)  -- //
+// ---------------------------------------------------------------------------------------------- //
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/bradenhc/kolob/internal/services"
+	"github.com/bradenhc/kolob/internal/store"
+)
+
+type LockoutHandler struct {
+	lockouts services.LockoutService
+}
+
+func NewLockoutHandler(ls services.LockoutService) LockoutHandler {
+	return LockoutHandler{ls}
+}
+
+// ListLockouts lists the client addresses and usernames with recent failed logins, including the
+// ones that are locked out.
+func (h *LockoutHandler) ListLockouts(w http.ResponseWriter, r *http.Request) {
+	ls, err := h.lockouts.List(r.Context())
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	WriteJson(w, http.StatusOK, ls)
+}
+
+// ClearLockout forgets the failed logins of a client address or username so it can log in again.
+func (h *LockoutHandler) ClearLockout(w http.ResponseWriter, r *http.Request) {
+	kind := store.LockoutKind(r.PathValue("kind"))
+	err := h.lockouts.Clear(r.Context(), kind, r.PathValue("subject"))
+	if errors.Is(err, services.ErrUnknownLockoutKind) {
+		WriteJsonErr(w, http.StatusBadRequest, err)
+		return
+	}
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
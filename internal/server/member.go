@@ -0,0 +1,192 @@
+// ---------------------------------------------------------------------------------------------- //
+// -- Copyright (c) 2024 Braden Hitchcock - MIT License (https://opensource.org/licenses/MIT)  -- //
+// ---------------------------------------------------------------------------------------------- //
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/bradenhc/kolob/internal/crypto"
+	"github.com/bradenhc/kolob/internal/services"
+	"github.com/bradenhc/kolob/internal/session"
+	flatbuffers "github.com/google/flatbuffers/go"
+)
+
+type MemberHandler struct {
+	members services.MemberService
+}
+
+func NewMemberHandler(ms services.MemberService) MemberHandler {
+	return MemberHandler{ms}
+}
+
+func (h *MemberHandler) CreateMember(w http.ResponseWriter, r *http.Request) {
+	key, err := session.FromContext(r.Context())
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	body, err := ReadRequestBody(w, r)
+	if err != nil {
+		WriteJsonErr(w, http.StatusBadRequest, err)
+		return
+	}
+
+	req := services.GetRootAsMemberCreateRequest(body, 0)
+	m, err := h.members.Create(r.Context(), req, key)
+	if errors.Is(err, crypto.ErrInvalidPassword) {
+		WriteJsonErr(w, http.StatusBadRequest, err)
+		return
+	}
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	WriteJson(w, http.StatusOK, m)
+}
+
+func (h *MemberHandler) ListMembers(w http.ResponseWriter, r *http.Request) {
+	key, err := session.FromContext(r.Context())
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	ms, err := h.members.ListMembers(r.Context(), key)
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	WriteJson(w, http.StatusOK, ms)
+}
+
+func (h *MemberHandler) GetMember(w http.ResponseWriter, r *http.Request) {
+	key, err := session.FromContext(r.Context())
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	builder := flatbuffers.NewBuilder(64)
+	idOffset := builder.CreateString(r.PathValue("id"))
+	services.MemberGetRequestStart(builder)
+	services.MemberGetRequestAddId(builder, idOffset)
+	builder.Finish(services.MemberGetRequestEnd(builder))
+
+	req := services.GetRootAsMemberGetRequest(builder.FinishedBytes(), 0)
+	m, err := h.members.Get(r.Context(), req, key)
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	WriteJson(w, http.StatusOK, m)
+}
+
+func (h *MemberHandler) UpdateMember(w http.ResponseWriter, r *http.Request) {
+	key, err := session.FromContext(r.Context())
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	body, err := ReadRequestBody(w, r)
+	if err != nil {
+		WriteJsonErr(w, http.StatusBadRequest, err)
+		return
+	}
+
+	req := services.GetRootAsMemberUpdateRequest(body, 0)
+	if err := CheckRequestId(r, req.Id()); err != nil {
+		WriteJsonErr(w, http.StatusBadRequest, err)
+		return
+	}
+
+	m, err := h.members.UpdateMember(r.Context(), req, key)
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	WriteJson(w, http.StatusOK, m)
+}
+
+func (h *MemberHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	builder := flatbuffers.NewBuilder(64)
+	idOffset := builder.CreateString(r.PathValue("id"))
+	services.MemberRemoveRequestStart(builder)
+	services.MemberRemoveRequestAddId(builder, idOffset)
+	builder.Finish(services.MemberRemoveRequestEnd(builder))
+
+	req := services.GetRootAsMemberRemoveRequest(builder.FinishedBytes(), 0)
+	err := h.members.RemoveMember(r.Context(), req)
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ResetMemberPassword replaces the password of the member in the path with a temporary one and
+// responds with it. The member has to change it the next time they log in.
+func (h *MemberHandler) ResetMemberPassword(w http.ResponseWriter, r *http.Request) {
+	key, err := session.FromContext(r.Context())
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	builder := flatbuffers.NewBuilder(64)
+	idOffset := builder.CreateString(r.PathValue("id"))
+	services.MemberResetPasswordRequestStart(builder)
+	services.MemberResetPasswordRequestAddId(builder, idOffset)
+	builder.Finish(services.MemberResetPasswordRequestEnd(builder))
+
+	req := services.GetRootAsMemberResetPasswordRequest(builder.FinishedBytes(), 0)
+	pass, err := h.members.ResetPassword(r.Context(), req, key)
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	WriteJson(w, http.StatusOK, struct {
+		Password string `json:"password"`
+	}{string(pass)})
+}
+
+func (h *MemberHandler) ChangeMemberPassword(w http.ResponseWriter, r *http.Request) {
+	key, err := session.FromContext(r.Context())
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	body, err := ReadRequestBody(w, r)
+	if err != nil {
+		WriteJsonErr(w, http.StatusBadRequest, err)
+		return
+	}
+
+	req := services.GetRootAsMemberChangePasswordRequest(body, 0)
+	if err := CheckRequestId(r, req.Id()); err != nil {
+		WriteJsonErr(w, http.StatusBadRequest, err)
+		return
+	}
+
+	err = h.members.ChangePassword(r.Context(), req, key)
+	if errors.Is(err, crypto.ErrInvalidPassword) {
+		WriteJsonErr(w, http.StatusBadRequest, err)
+		return
+	}
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
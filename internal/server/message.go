@@ -0,0 +1,200 @@
+// ---------------------------------------------------------------------------------------------- //
+// -- Copyright (c) 2024 Braden Hitchcock - MIT License (https://opensource.org/licenses/MIT)  -- //
+// ---------------------------------------------------------------------------------------------- //
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/bradenhc/kolob/internal/services"
+	"github.com/bradenhc/kolob/internal/session"
+	flatbuffers "github.com/google/flatbuffers/go"
+)
+
+type MessageHandler struct {
+	messages services.MessageService
+}
+
+func NewMessageHandler(ms services.MessageService) MessageHandler {
+	return MessageHandler{ms}
+}
+
+func (h *MessageHandler) AddMessage(w http.ResponseWriter, r *http.Request) {
+	key, err := session.FromContext(r.Context())
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	body, err := ReadRequestBody(w, r)
+	if err != nil {
+		WriteJsonErr(w, http.StatusBadRequest, err)
+		return
+	}
+
+	req := services.GetRootAsMessageAddRequest(body, 0)
+	m, err := h.messages.Add(r.Context(), req, key)
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	WriteJson(w, http.StatusOK, m)
+}
+
+// ListMessages lists the messages in the conversation identified by the request path. The results
+// can be narrowed down using the "author", "after", "before", and "pattern" query parameters.
+func (h *MessageHandler) ListMessages(w http.ResponseWriter, r *http.Request) {
+	key, err := session.FromContext(r.Context())
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	q := r.URL.Query()
+	var after, before int64
+	if v := q.Get("after"); v != "" {
+		after, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			WriteJsonErr(w, http.StatusBadRequest, fmt.Errorf("invalid after parameter: %v", err))
+			return
+		}
+	}
+	if v := q.Get("before"); v != "" {
+		before, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			WriteJsonErr(w, http.StatusBadRequest, fmt.Errorf("invalid before parameter: %v", err))
+			return
+		}
+	}
+
+	builder := flatbuffers.NewBuilder(128)
+	convoOffset := builder.CreateString(r.PathValue("id"))
+	var authorOffset, patternOffset flatbuffers.UOffsetT
+	if q.Has("author") {
+		authorOffset = builder.CreateString(q.Get("author"))
+	}
+	if q.Has("pattern") {
+		patternOffset = builder.CreateString(q.Get("pattern"))
+	}
+	services.MessageListRequestStart(builder)
+	services.MessageListRequestAddConversation(builder, convoOffset)
+	services.MessageListRequestAddAuthor(builder, authorOffset)
+	services.MessageListRequestAddCreatedAfter(builder, after)
+	services.MessageListRequestAddCreatedBefore(builder, before)
+	services.MessageListRequestAddPattern(builder, patternOffset)
+	builder.Finish(services.MessageListRequestEnd(builder))
+
+	member, err := session.MemberFromContext(r.Context())
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	req := services.GetRootAsMessageListRequest(builder.FinishedBytes(), 0)
+	ms, err := h.messages.List(r.Context(), member, req, key)
+	if errors.Is(err, services.ErrNotParticipant) {
+		WriteJsonErr(w, http.StatusForbidden, err)
+		return
+	}
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	WriteJson(w, http.StatusOK, ms)
+}
+
+func (h *MessageHandler) GetMessage(w http.ResponseWriter, r *http.Request) {
+	key, err := session.FromContext(r.Context())
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	builder := flatbuffers.NewBuilder(64)
+	idOffset := builder.CreateString(r.PathValue("id"))
+	services.MessageGetRequestStart(builder)
+	services.MessageGetRequestAddId(builder, idOffset)
+	builder.Finish(services.MessageGetRequestEnd(builder))
+
+	req := services.GetRootAsMessageGetRequest(builder.FinishedBytes(), 0)
+	m, err := h.messages.Get(r.Context(), req, key)
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	WriteJson(w, http.StatusOK, m)
+}
+
+func (h *MessageHandler) UpdateMessage(w http.ResponseWriter, r *http.Request) {
+	key, err := session.FromContext(r.Context())
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	body, err := ReadRequestBody(w, r)
+	if err != nil {
+		WriteJsonErr(w, http.StatusBadRequest, err)
+		return
+	}
+
+	req := services.GetRootAsMessageUpdateRequest(body, 0)
+	if err := CheckRequestId(r, req.Id()); err != nil {
+		WriteJsonErr(w, http.StatusBadRequest, err)
+		return
+	}
+
+	m, err := h.messages.Update(r.Context(), req, key)
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	WriteJson(w, http.StatusOK, m)
+}
+
+func (h *MessageHandler) RemoveMessage(w http.ResponseWriter, r *http.Request) {
+	builder := flatbuffers.NewBuilder(64)
+	idOffset := builder.CreateString(r.PathValue("id"))
+	services.MessageRemoveRequestStart(builder)
+	services.MessageRemoveRequestAddId(builder, idOffset)
+	builder.Finish(services.MessageRemoveRequestEnd(builder))
+
+	req := services.GetRootAsMessageRemoveRequest(builder.FinishedBytes(), 0)
+	err := h.messages.Remove(r.Context(), req)
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *MessageHandler) ListThread(w http.ResponseWriter, r *http.Request) {
+	key, err := session.FromContext(r.Context())
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	builder := flatbuffers.NewBuilder(64)
+	idOffset := builder.CreateString(r.PathValue("id"))
+	services.MessageThreadRequestStart(builder)
+	services.MessageThreadRequestAddId(builder, idOffset)
+	builder.Finish(services.MessageThreadRequestEnd(builder))
+
+	req := services.GetRootAsMessageThreadRequest(builder.FinishedBytes(), 0)
+	t, err := h.messages.ListThread(r.Context(), req, key)
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	WriteJson(w, http.StatusOK, t)
+}
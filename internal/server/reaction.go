@@ -0,0 +1,99 @@
+// ---------------------------------------------------------------------------------------------- //
+// -- Copyright (c) 2024 Braden Hitchcock - MIT License (https://opensource.org/licenses/MIT)  -- //
+// ---------------------------------------------------------------------------------------------- //
+package server
+
+import (
+	"net/http"
+
+	"github.com/bradenhc/kolob/internal/services"
+	"github.com/bradenhc/kolob/internal/session"
+	flatbuffers "github.com/google/flatbuffers/go"
+)
+
+type ReactionHandler struct {
+	reactions services.ReactionService
+}
+
+func NewReactionHandler(rs services.ReactionService) ReactionHandler {
+	return ReactionHandler{rs}
+}
+
+func (h *ReactionHandler) AddReaction(w http.ResponseWriter, r *http.Request) {
+	key, err := session.FromContext(r.Context())
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	body, err := ReadRequestBody(w, r)
+	if err != nil {
+		WriteJsonErr(w, http.StatusBadRequest, err)
+		return
+	}
+
+	req := services.GetRootAsReactionAddRequest(body, 0)
+	if err := CheckRequestId(r, req.Message()); err != nil {
+		WriteJsonErr(w, http.StatusBadRequest, err)
+		return
+	}
+
+	reaction, err := h.reactions.Add(r.Context(), req, key)
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	WriteJson(w, http.StatusOK, reaction)
+}
+
+func (h *ReactionHandler) ListReactions(w http.ResponseWriter, r *http.Request) {
+	key, err := session.FromContext(r.Context())
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	builder := flatbuffers.NewBuilder(64)
+	idOffset := builder.CreateString(r.PathValue("id"))
+	services.ReactionListRequestStart(builder)
+	services.ReactionListRequestAddMessage(builder, idOffset)
+	builder.Finish(services.ReactionListRequestEnd(builder))
+
+	req := services.GetRootAsReactionListRequest(builder.FinishedBytes(), 0)
+	reactions, err := h.reactions.List(r.Context(), req, key)
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	WriteJson(w, http.StatusOK, reactions)
+}
+
+func (h *ReactionHandler) RemoveReaction(w http.ResponseWriter, r *http.Request) {
+	key, err := session.FromContext(r.Context())
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	body, err := ReadRequestBody(w, r)
+	if err != nil {
+		WriteJsonErr(w, http.StatusBadRequest, err)
+		return
+	}
+
+	req := services.GetRootAsReactionRemoveRequest(body, 0)
+	if err := CheckRequestId(r, req.Message()); err != nil {
+		WriteJsonErr(w, http.StatusBadRequest, err)
+		return
+	}
+
+	err = h.reactions.Remove(r.Context(), req, key)
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,46 @@
+// ---------------------------------------------------------------------------------------------- //
+// -- Copyright (c) 2024 Braden Hitchcock - MIT License (https://opensource.org/licenses/MIT)  -- //
+// ---------------------------------------------------------------------------------------------- //
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	flatbuffers "github.com/google/flatbuffers/go"
+)
+
+// MaxRequestBodySize is the maximum number of bytes the server will read from a request body.
+const MaxRequestBodySize = 1 << 20
+
+var (
+	ErrEmptyRequestBody     = errors.New("request body is empty")
+	ErrRequestIdMismatch    = errors.New("request id does not match resource path")
+	ErrIncorrectCredentials = errors.New("incorrect credentials")
+)
+
+// ReadRequestBody reads the entire body of the request so that it can be interpreted as a
+// flatbuffer. An error is returned if the body is too large or too small to contain a flatbuffer.
+func ReadRequestBody(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MaxRequestBodySize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %v", err)
+	}
+
+	if len(body) < flatbuffers.SizeUOffsetT {
+		return nil, ErrEmptyRequestBody
+	}
+
+	return body, nil
+}
+
+// CheckRequestId makes sure the id contained in a request body matches the {id} wildcard in the
+// request path.
+func CheckRequestId(r *http.Request, id []byte) error {
+	if string(id) != r.PathValue("id") {
+		return ErrRequestIdMismatch
+	}
+	return nil
+}
@@ -14,51 +14,135 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 	"time"
 
 	"github.com/bradenhc/kolob/internal/appfs"
 	"github.com/bradenhc/kolob/internal/crypto"
+	"github.com/bradenhc/kolob/internal/events"
+	"github.com/bradenhc/kolob/internal/policy"
 	"github.com/bradenhc/kolob/internal/services"
 	"github.com/bradenhc/kolob/internal/session"
+	"github.com/bradenhc/kolob/internal/store"
+	"github.com/bradenhc/kolob/internal/store/memory"
 	"github.com/bradenhc/kolob/internal/store/sqlite"
 )
 
 type ContextKey string
 
 type Server struct {
-	sessions     *session.Manager
-	db           *sql.DB
-	groupHandler GroupHandler
-	httpServer   *http.Server
+	sessions            *session.Manager
+	lockouts            services.LockoutService
+	invitations         services.InvitationService
+	db                  *sql.DB
+	groupHandler        GroupHandler
+	memberHandler       MemberHandler
+	conversationHandler ConversationHandler
+	messageHandler      MessageHandler
+	reactionHandler     ReactionHandler
+	sessionHandler      SessionHandler
+	streamHandler       StreamHandler
+	httpServer          *http.Server
 }
 
 func NewServer(c Config) (*Server, error) {
-	// First, we need to create a self-signed TLS configuration to use with our server. This will
-	// guarantee that we force all connections to be encrypted and can use HTTP/2. The user can
-	// override the self-signed certificate with their own if they choose using the provided
-	// configuration object.
-	slog.Info("Generating self-signed TLS configuration")
-	tlsConfig, err := createSelfSignedTlsConfig()
+	// First, we need to create a TLS configuration to use with our server. This will guarantee
+	// that we force all connections to be encrypted and can use HTTP/2. The user can override the
+	// self-signed certificate with their own if they choose using the provided configuration
+	// object.
+	slog.Info("Loading TLS configuration")
+	tlsConfig, err := createTlsConfig(c)
 	if err != nil {
 		return nil, err
 	}
 
+	slog.Info("Loading session key")
+	sessionKey, err := crypto.LoadSessionKey(c.SessionKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	crypto.SetKdfConcurrency(c.KdfConcurrency)
+
 	slog.Info("Openning database")
 	db, err := sqlite.Open(c.DatabaseFile)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %v", err)
 	}
 
-	slog.Info("Creating database stores")
-	groupStore, err := sqlite.NewGroupStore(db)
+	// A new index key would leave a group that already exists unable to log in, so one is only
+	// created while the database doesn't hold a group yet
+	slog.Info("Loading index key")
+	inUse, err := sqlite.IndexKeyInUse(context.Background(), db)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create group store: %v", err)
+		db.Close()
+		return nil, err
+	}
+	indexKey, err := crypto.LoadIndexKey(c.IndexKeyFile, !inUse)
+	if err != nil {
+		db.Close()
+		return nil, err
 	}
-	groupService := services.NewGroupService(groupStore)
-	groupHandler := NewGroupHandler(groupService)
 
-	sessions := session.NewManager()
+	slog.Info("Creating database stores")
+	groupStore := sqlite.NewGroupStore(db)
+	memberStore := sqlite.NewMemberStore(db)
+	conversationStore := sqlite.NewConversationStore(db)
+	messageStore := sqlite.NewMessageStore(db)
+	reactionStore := sqlite.NewReactionStore(db)
+	rekeyStore := sqlite.NewRekeyStore(db)
+	lockoutStore := sqlite.NewLockoutStore(db)
+	invitationStore := sqlite.NewInvitationStore(db)
+
+	broker := events.NewBroker()
+	transactor := sqlite.NewTransactor(db)
+
+	var sessionStore store.SessionStore
+	switch c.SessionStore {
+	case "sqlite":
+		sessionStore = sqlite.NewSessionStore(db)
+	case "memory":
+		sessionStore = memory.NewSessionStore()
+	default:
+		db.Close()
+		return nil, fmt.Errorf("unknown session store: %s", c.SessionStore)
+	}
+	sessions := session.NewManager(
+		sessionStore, sessionKey, c.SessionIdleTimeout, c.SessionMaxAge,
+	)
+
+	groupService := services.NewGroupService(
+		groupStore, memberStore, conversationStore, invitationStore, sessionStore, rekeyStore,
+		transactor, indexKey,
+	)
+	conversationService := services.NewConversationService(
+		conversationStore, memberStore, transactor, broker,
+	)
+	messageService := services.NewMessageService(
+		messageStore, conversationStore, memberStore, reactionStore, transactor, broker,
+	)
+	reactionService := services.NewReactionService(reactionStore, messageStore, transactor, broker)
+
+	memberService := services.NewMemberService(memberStore, sessionStore, broker, indexKey)
+	lockoutService := services.NewLockoutService(lockoutStore, memberStore, indexKey)
+	invitationService := services.NewInvitationService(
+		invitationStore, memberStore, conversationStore, rekeyStore, transactor, broker, indexKey,
+	)
+
+	groupHandler := NewGroupHandler(groupService)
+	memberHandler := NewMemberHandler(memberService)
+	conversationHandler := NewConversationHandler(conversationService)
+	messageHandler := NewMessageHandler(messageService)
+	reactionHandler := NewReactionHandler(reactionService)
+	sessionHandler := NewSessionHandler(groupService, memberService, lockoutService, sessions)
+	lockoutHandler := NewLockoutHandler(lockoutService)
+	invitationHandler := NewInvitationHandler(invitationService, lockoutService)
+	authorizer := NewAuthorizer(memberService, conversationService, messageService)
+	streamHandler := NewStreamHandler(
+		broker, authorizer, sessions, memberService, conversationService, messageService,
+		reactionService,
+	)
 
 	middlware := NewMiddlewareChain(sessions)
 
@@ -70,9 +154,184 @@ func NewServer(c Config) (*Server, error) {
 	webui, _ := fs.Sub(fsys, "webui")
 	mux.Handle("GET /", http.FileServer(http.FS(webui)))
 
-	// Setup the routes for the API
+	// Setup the routes for the API. Every route that acts on group data requires a session and is
+	// checked against the access control policy before the handler is called.
+	secure := func(op policy.Operation, t targetFunc, f http.HandlerFunc) http.HandlerFunc {
+		chain := NewMiddlewareChain(sessions, authorizer.Require(op, t))
+		return chain.Finish(f)
+	}
+
 	mux.HandleFunc("POST /api/v1/group", groupHandler.InitGroup)
-	mux.HandleFunc("GET /api/v1/group", middlware.Finish(groupHandler.GetGroupInfo))
+	mux.HandleFunc("GET /api/v1/group", secure(policy.GroupGet, noTarget, groupHandler.GetGroupInfo))
+	mux.HandleFunc("PUT /api/v1/group", secure(policy.GroupUpdate, noTarget, groupHandler.UpdateGroup))
+	mux.HandleFunc(
+		"PUT /api/v1/group/auth",
+		secure(policy.GroupChangePassword, noTarget, groupHandler.ChangeGroupPassword),
+	)
+	mux.HandleFunc(
+		"POST /api/v1/group/rekey", secure(policy.GroupRekey, noTarget, groupHandler.RekeyGroup),
+	)
+
+	mux.HandleFunc("POST /api/v1/session", sessionHandler.Login)
+	mux.HandleFunc("DELETE /api/v1/session", middlware.Finish(sessionHandler.Logout))
+
+	// Members only ever see and end their own sessions, so the handlers check ownership themselves
+	mux.HandleFunc(
+		"GET /api/v1/sessions", secure(policy.SessionList, noTarget, sessionHandler.ListSessions),
+	)
+	mux.HandleFunc(
+		"DELETE /api/v1/sessions",
+		secure(policy.SessionRemove, noTarget, sessionHandler.RevokeOtherSessions),
+	)
+	mux.HandleFunc(
+		"DELETE /api/v1/sessions/{id}",
+		secure(policy.SessionRemove, noTarget, sessionHandler.RevokeSession),
+	)
+
+	// Invitations are redeemed by people who aren't members yet, so redeeming one needs no session
+	mux.HandleFunc("POST /api/v1/invitations/redeem", invitationHandler.RedeemInvitation)
+	mux.HandleFunc(
+		"POST /api/v1/invitations",
+		secure(policy.InvitationCreate, noTarget, invitationHandler.CreateInvitation),
+	)
+	mux.HandleFunc(
+		"GET /api/v1/invitations",
+		secure(policy.InvitationList, noTarget, invitationHandler.ListInvitations),
+	)
+	mux.HandleFunc(
+		"DELETE /api/v1/invitations/{id}",
+		secure(policy.InvitationRemove, noTarget, invitationHandler.RemoveInvitation),
+	)
+
+	mux.HandleFunc(
+		"GET /api/v1/lockouts", secure(policy.LockoutList, noTarget, lockoutHandler.ListLockouts),
+	)
+	mux.HandleFunc(
+		"DELETE /api/v1/lockouts/{kind}/{subject}",
+		secure(policy.LockoutRemove, noTarget, lockoutHandler.ClearLockout),
+	)
+
+	mux.HandleFunc("GET /api/v1/stream", secure(policy.StreamOpen, noTarget, streamHandler.Stream))
+
+	mux.HandleFunc(
+		"POST /api/v1/members", secure(policy.MemberCreate, noTarget, memberHandler.CreateMember),
+	)
+	mux.HandleFunc(
+		"GET /api/v1/members", secure(policy.MemberList, noTarget, memberHandler.ListMembers),
+	)
+	mux.HandleFunc(
+		"GET /api/v1/members/{id}",
+		secure(policy.MemberGet, memberPathTarget, memberHandler.GetMember),
+	)
+	mux.HandleFunc(
+		"PUT /api/v1/members/{id}",
+		secure(policy.MemberUpdate, memberPathTarget, memberHandler.UpdateMember),
+	)
+	mux.HandleFunc(
+		"DELETE /api/v1/members/{id}",
+		secure(policy.MemberRemove, memberPathTarget, memberHandler.RemoveMember),
+	)
+	mux.HandleFunc(
+		"PUT /api/v1/members/{id}/auth",
+		secure(policy.MemberChangePassword, memberPathTarget, memberHandler.ChangeMemberPassword),
+	)
+	mux.HandleFunc(
+		"POST /api/v1/members/{id}/reset",
+		secure(policy.MemberResetPassword, memberPathTarget, memberHandler.ResetMemberPassword),
+	)
+
+	mux.HandleFunc(
+		"POST /api/v1/conversations",
+		secure(policy.ConversationCreate, noTarget, conversationHandler.CreateConversation),
+	)
+	mux.HandleFunc(
+		"GET /api/v1/conversations",
+		secure(policy.ConversationList, noTarget, conversationHandler.ListConversations),
+	)
+	mux.HandleFunc(
+		"GET /api/v1/conversations/{id}",
+		secure(policy.ConversationGet, conversationPathTarget, conversationHandler.GetConversation),
+	)
+	mux.HandleFunc(
+		"PUT /api/v1/conversations/{id}",
+		secure(
+			policy.ConversationUpdate, conversationPathTarget,
+			conversationHandler.UpdateConversation,
+		),
+	)
+	mux.HandleFunc(
+		"DELETE /api/v1/conversations/{id}",
+		secure(
+			policy.ConversationRemove, conversationPathTarget,
+			conversationHandler.RemoveConversation,
+		),
+	)
+	mux.HandleFunc(
+		"POST /api/v1/conversations/{id}/mods",
+		secure(
+			policy.ConversationAddMods, conversationPathTarget,
+			conversationHandler.AddConversationMods,
+		),
+	)
+	mux.HandleFunc(
+		"DELETE /api/v1/conversations/{id}/mods",
+		secure(
+			policy.ConversationRemoveMods, conversationPathTarget,
+			conversationHandler.RemoveConversationMods,
+		),
+	)
+	mux.HandleFunc(
+		"POST /api/v1/conversations/{id}/participants",
+		secure(
+			policy.ConversationAddParticipants, conversationPathTarget,
+			conversationHandler.AddConversationParticipants,
+		),
+	)
+	mux.HandleFunc(
+		"DELETE /api/v1/conversations/{id}/participants",
+		secure(
+			policy.ConversationRemoveParticipants, conversationPathTarget,
+			conversationHandler.RemoveConversationParticipants,
+		),
+	)
+	mux.HandleFunc(
+		"GET /api/v1/conversations/{id}/messages",
+		secure(policy.MessageList, conversationPathTarget, messageHandler.ListMessages),
+	)
+
+	mux.HandleFunc(
+		"POST /api/v1/messages",
+		secure(policy.MessageCreate, messageBodyTarget, messageHandler.AddMessage),
+	)
+	mux.HandleFunc(
+		"GET /api/v1/messages/{id}",
+		secure(policy.MessageGet, messagePathTarget, messageHandler.GetMessage),
+	)
+	mux.HandleFunc(
+		"PUT /api/v1/messages/{id}",
+		secure(policy.MessageUpdate, messagePathTarget, messageHandler.UpdateMessage),
+	)
+	mux.HandleFunc(
+		"DELETE /api/v1/messages/{id}",
+		secure(policy.MessageRemove, messagePathTarget, messageHandler.RemoveMessage),
+	)
+	mux.HandleFunc(
+		"POST /api/v1/messages/{id}/reactions",
+		secure(policy.ReactionCreate, reactionAddTarget, reactionHandler.AddReaction),
+	)
+	mux.HandleFunc(
+		"GET /api/v1/messages/{id}/reactions",
+		secure(policy.ReactionList, messagePathTarget, reactionHandler.ListReactions),
+	)
+	mux.HandleFunc(
+		"DELETE /api/v1/messages/{id}/reactions",
+		secure(policy.ReactionRemove, reactionRemoveTarget, reactionHandler.RemoveReaction),
+	)
+
+	mux.HandleFunc(
+		"GET /api/v1/threads/{id}",
+		secure(policy.MessageThread, messagePathTarget, messageHandler.ListThread),
+	)
 
 	slog.Info("Creating HTTP server")
 	httpServer := http.Server{
@@ -82,13 +341,30 @@ func NewServer(c Config) (*Server, error) {
 	}
 
 	server := &Server{
-		sessions, db, groupHandler, &httpServer,
+		sessions, lockoutService, invitationService, db, groupHandler, memberHandler,
+		conversationHandler, messageHandler, reactionHandler, sessionHandler, streamHandler,
+		&httpServer,
 	}
 
 	return server, nil
 }
 
+// sessionSweepInterval is how often expired sessions are removed from the session store, and the
+// others are how often forgotten failed logins and expired invitations are removed from the
+// database.
+const (
+	sessionSweepInterval    = time.Minute
+	lockoutSweepInterval    = 10 * time.Minute
+	invitationSweepInterval = 10 * time.Minute
+)
+
 func (s *Server) Start() {
+	sweepCtx, stopSweeper := context.WithCancel(context.Background())
+	defer stopSweeper()
+	s.sessions.StartSweeper(sweepCtx, sessionSweepInterval)
+	s.lockouts.StartSweeper(sweepCtx, lockoutSweepInterval)
+	s.invitations.StartSweeper(sweepCtx, invitationSweepInterval)
+
 	go func() {
 		if err := s.httpServer.ListenAndServeTLS("", ""); !errors.Is(err, http.ErrServerClosed) {
 			slog.Error("HTTP server error", "err", err.Error())
@@ -111,19 +387,57 @@ func (s *Server) Start() {
 	slog.Info("Kolob server shut down successfully")
 }
 
-func createSelfSignedTlsConfig() (*tls.Config, error) {
-	crt, key, err := crypto.GenerateSelfSignedCert()
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate self-signed certificate: %v", err)
+// createTlsConfig serves the certificate and key from the configuration. If there aren't any, a
+// certificate for the configured hosts is saved next to the database, issued by the local
+// certificate authority if there is one and self-signed otherwise. The certificate is reloaded
+// whenever its files change.
+func createTlsConfig(c Config) (*tls.Config, error) {
+	certFile, keyFile := c.CertFile, c.KeyFile
+	if certFile == "" && keyFile == "" {
+		dir := filepath.Dir(c.DatabaseFile)
+		certFile = filepath.Join(dir, "kolob-tls.crt")
+		keyFile = filepath.Join(dir, "kolob-tls.key")
+
+		ca, err := loadCertAuthority(c)
+		if err != nil {
+			return nil, err
+		}
+
+		err = crypto.EnsureCert(certFile, keyFile, c.Hosts, ca)
+		if err != nil {
+			return nil, err
+		}
+	} else if certFile == "" || keyFile == "" {
+		return nil, fmt.Errorf("both a TLS certificate and key file are required")
 	}
 
-	cert, err := tls.X509KeyPair(crt, key)
+	certs, err := crypto.NewCertReloader(certFile, keyFile)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load X509 key pair: %v", err)
+		return nil, err
 	}
 
 	return &tls.Config{
-		Certificates: []tls.Certificate{cert},
-		ServerName:   "localhost",
+		GetCertificate: certs.GetCertificate,
+		ServerName:     "localhost",
 	}, nil
 }
+
+// loadCertAuthority loads the certificate authority from the configuration, or the one saved next
+// to the database by kolobctl. It returns nil if there isn't one.
+func loadCertAuthority(c Config) (*crypto.CertAuthority, error) {
+	certFile, keyFile := c.CACertFile, c.CAKeyFile
+	if certFile == "" && keyFile == "" {
+		dir := filepath.Dir(c.DatabaseFile)
+		certFile = filepath.Join(dir, crypto.CACertFileName)
+		keyFile = filepath.Join(dir, crypto.CAKeyFileName)
+
+		if _, err := os.Stat(certFile); errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+	} else if certFile == "" || keyFile == "" {
+		return nil, fmt.Errorf("both a certificate authority certificate and key file are required")
+	}
+
+	slog.Info("Issuing certificates from local certificate authority", "path", certFile)
+	return crypto.LoadCertAuthority(certFile, keyFile)
+}
@@ -0,0 +1,258 @@
+// ---------------------------------------------------------------------------------------------- //
+// -- Copyright (c) 2024 Braden Hitchcock - MIT License (https://opensource.org/licenses/MIT)  -- //
+// ---------------------------------------------------------------------------------------------- //
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bradenhc/kolob/internal/crypto"
+	"github.com/bradenhc/kolob/internal/model"
+	"github.com/bradenhc/kolob/internal/services"
+	"github.com/bradenhc/kolob/internal/session"
+	flatbuffers "github.com/google/flatbuffers/go"
+)
+
+type SessionHandler struct {
+	groups   services.GroupService
+	members  services.MemberService
+	lockouts services.LockoutService
+	sessions *session.Manager
+}
+
+func NewSessionHandler(
+	gs services.GroupService, ms services.MemberService, ls services.LockoutService,
+	sm *session.Manager,
+) SessionHandler {
+	return SessionHandler{gs, ms, ls, sm}
+}
+
+// Login authenticates the member credentials in the request. If the request also has group
+// credentials, the group is unlocked with them; otherwise the group is unlocked with the member's
+// own keyslot. If authentication succeeds, a new session is created that holds the group data key
+// and the member's ID, and the session cookie is attached to the response.
+//
+// Clients and usernames that fail to log in too often have to wait before trying again, and are
+// told how long with the Retry-After header.
+func (h *SessionHandler) Login(w http.ResponseWriter, r *http.Request) {
+	body, err := ReadRequestBody(w, r)
+	if err != nil {
+		WriteJsonErr(w, http.StatusBadRequest, err)
+		return
+	}
+
+	req := services.GetRootAsSessionCreateRequest(body, 0)
+	addr := clientAddr(r)
+
+	wait, err := h.lockouts.Check(r.Context(), addr, req.Username())
+	if errors.Is(err, services.ErrLockedOut) {
+		writeLockedOut(w, err, wait)
+		return
+	}
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer h.lockouts.Done(addr, req.Username())
+
+	builder := flatbuffers.NewBuilder(128)
+	unameOffset := builder.CreateByteString(req.Username())
+	upassOffset := builder.CreateByteString(req.Password())
+	services.MemberAuthenticateRequestStart(builder)
+	services.MemberAuthenticateRequestAddUsername(builder, unameOffset)
+	services.MemberAuthenticateRequestAddPassword(builder, upassOffset)
+	builder.Finish(services.MemberAuthenticateRequestEnd(builder))
+
+	mreq := services.GetRootAsMemberAuthenticateRequest(builder.FinishedBytes(), 0)
+
+	var key crypto.Key
+	var m *model.Member
+	if len(req.GroupPassword()) == 0 {
+		key, m, err = h.members.Unlock(r.Context(), mreq)
+		if err != nil {
+			slog.Info("Member authentication failed", "err", err.Error())
+			h.loginFailed(w, r, addr, req.Username())
+			return
+		}
+	} else {
+		builder := flatbuffers.NewBuilder(128)
+		gidOffset := builder.CreateByteString(req.GroupId())
+		gpassOffset := builder.CreateByteString(req.GroupPassword())
+		services.GroupAuthenticateRequestStart(builder)
+		services.GroupAuthenticateRequestAddGroupId(builder, gidOffset)
+		services.GroupAuthenticateRequestAddPassword(builder, gpassOffset)
+		builder.Finish(services.GroupAuthenticateRequestEnd(builder))
+
+		greq := services.GetRootAsGroupAuthenticateRequest(builder.FinishedBytes(), 0)
+		key, err = h.groups.Authenticate(r.Context(), greq)
+		if err != nil {
+			slog.Info("Group authentication failed", "err", err.Error())
+			h.loginFailed(w, r, addr, req.Username())
+			return
+		}
+
+		m, err = h.members.Authenticate(r.Context(), mreq, key)
+		if err != nil {
+			slog.Info("Member authentication failed", "err", err.Error())
+			h.loginFailed(w, r, addr, req.Username())
+			return
+		}
+	}
+
+	err = h.lockouts.Succeed(r.Context(), req.Username())
+	if err != nil {
+		slog.Warn("Failed to clear failed logins", "err", err)
+	}
+
+	token, err := h.sessions.Add(r.Context(), key, model.Uuid(m.Id()), clientAgent(r), addr)
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	session.SetCookie(w, token)
+	WriteJson(w, http.StatusOK, m)
+}
+
+// writeLockedOut responds that the client has to wait before trying again, rounded up to the second.
+func writeLockedOut(w http.ResponseWriter, err error, wait time.Duration) {
+	secs := int((wait + time.Second - 1) / time.Second)
+	w.Header().Set("Retry-After", strconv.Itoa(secs))
+	WriteJsonErr(w, http.StatusTooManyRequests, fmt.Errorf("%v: try again in %ds", err, secs))
+}
+
+// loginFailed counts a failed login for the client and the username and responds that the
+// credentials were incorrect.
+func (h *SessionHandler) loginFailed(
+	w http.ResponseWriter, r *http.Request, addr string, uname []byte,
+) {
+	err := h.lockouts.Fail(r.Context(), addr, uname)
+	if err != nil {
+		slog.Warn("Failed to count failed login", "err", err)
+	}
+
+	WriteJsonErr(w, http.StatusUnauthorized, ErrIncorrectCredentials)
+}
+
+// Logout removes the session attached to the request and clears the session cookie.
+func (h *SessionHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	id, err := session.IdFromContext(r.Context())
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	err = h.sessions.Remove(r.Context(), id)
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	session.ClearCookie(w)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListSessions lists the sessions of the member making the request, along with the ID of the
+// session the request was made with.
+func (h *SessionHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	id, member, err := sessionFromContext(r)
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	ss, err := h.sessions.List(r.Context(), member)
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	WriteJson(w, http.StatusOK, struct {
+		Current  model.Uuid       `json:"current"`
+		Sessions []*model.Session `json:"sessions"`
+	}{id, ss})
+}
+
+// RevokeSession ends one of the sessions of the member making the request. Ending the session the
+// request was made with also clears the session cookie.
+func (h *SessionHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	id, member, err := sessionFromContext(r)
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	target := model.Uuid(r.PathValue("id"))
+	err = h.sessions.Revoke(r.Context(), member, target)
+	if err == session.ErrSessionNotFound {
+		WriteJsonErr(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if target == id {
+		session.ClearCookie(w)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RevokeOtherSessions ends every session of the member making the request except the one the
+// request was made with.
+func (h *SessionHandler) RevokeOtherSessions(w http.ResponseWriter, r *http.Request) {
+	id, member, err := sessionFromContext(r)
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	err = h.sessions.RevokeOthers(r.Context(), member, id)
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func sessionFromContext(r *http.Request) (model.Uuid, model.Uuid, error) {
+	id, err := session.IdFromContext(r.Context())
+	if err != nil {
+		return "", "", err
+	}
+	member, err := session.MemberFromContext(r.Context())
+	if err != nil {
+		return "", "", err
+	}
+
+	return id, member, nil
+}
+
+// maxAgentLength limits how much of the user agent of a client is kept with its session.
+const maxAgentLength = 256
+
+func clientAgent(r *http.Request) string {
+	agent := r.UserAgent()
+	if len(agent) > maxAgentLength {
+		agent = agent[:maxAgentLength]
+	}
+	return agent
+}
+
+// clientAddr returns the IP address of the client that made the request. Headers set by proxies are
+// ignored since the server is meant to be reached directly.
+func clientAddr(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
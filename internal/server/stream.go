@@ -0,0 +1,412 @@
+// ---------------------------------------------------------------------------------------------- //
+// -- Copyright (c) 2024 Braden Hitchcock - MIT License (https://opensource.org/licenses/MIT)  -- //
+// ---------------------------------------------------------------------------------------------- //
+package server
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/bradenhc/kolob/internal/crypto"
+	"github.com/bradenhc/kolob/internal/events"
+	"github.com/bradenhc/kolob/internal/model"
+	"github.com/bradenhc/kolob/internal/policy"
+	"github.com/bradenhc/kolob/internal/services"
+	"github.com/bradenhc/kolob/internal/session"
+	flatbuffers "github.com/google/flatbuffers/go"
+	"golang.org/x/net/websocket"
+)
+
+// The types of events the server sends in reply to a request event sent by a client.
+const (
+	StreamResponse = "response"
+	StreamError    = "error"
+)
+
+// streamCheckInterval is how often an idle stream checks that its session has not ended, so that
+// the streams of revoked sessions are closed even when nothing is sent over them.
+const streamCheckInterval = time.Minute
+
+// A streamRequest is an event sent by a client over the stream asking the server to perform an
+// action. The data is a flatbuffer containing the service request for the action. The id is chosen
+// by the client and is echoed back in the response event so the two can be matched.
+type streamRequest struct {
+	Type string `json:"type"`
+	Id   string `json:"id"`
+	Data []byte `json:"data"`
+}
+
+// A streamOperation performs a service request sent by a client over the stream. The target
+// function finds the target of the operation in the request data so it can be authorized.
+type streamOperation struct {
+	target  func(data []byte) Target
+	perform func(ctx context.Context, key crypto.Key, data []byte) (any, error)
+}
+
+type StreamHandler struct {
+	broker        *events.Broker
+	authorizer    *Authorizer
+	sessions      *session.Manager
+	conversations services.ConversationService
+	operations    map[policy.Operation]streamOperation
+}
+
+func NewStreamHandler(
+	b *events.Broker,
+	a *Authorizer,
+	sm *session.Manager,
+	members services.MemberService,
+	conversations services.ConversationService,
+	messages services.MessageService,
+	reactions services.ReactionService,
+) StreamHandler {
+	// Each target is found by reading the request data as the request type of its operation, so
+	// that the target always comes from the field the operation acts on.
+	none := func(data []byte) Target {
+		return Target{}
+	}
+
+	operations := map[policy.Operation]streamOperation{
+		policy.MemberList: {
+			none,
+			func(ctx context.Context, key crypto.Key, data []byte) (any, error) {
+				return members.ListMembers(ctx, key)
+			},
+		},
+		policy.ConversationList: {
+			none,
+			func(ctx context.Context, key crypto.Key, data []byte) (any, error) {
+				member, err := session.MemberFromContext(ctx)
+				if err != nil {
+					return nil, err
+				}
+				return conversations.ListAll(ctx, member, key)
+			},
+		},
+		policy.ConversationGet: {
+			func(data []byte) Target {
+				req := services.GetRootAsConversationGetRequest(data, 0)
+				return Target{Conversation: model.Uuid(req.Id())}
+			},
+			func(ctx context.Context, key crypto.Key, data []byte) (any, error) {
+				req := services.GetRootAsConversationGetRequest(data, 0)
+				return conversations.Get(ctx, req, key)
+			},
+		},
+		policy.ConversationAddParticipants: {
+			func(data []byte) Target {
+				req := services.GetRootAsConversationParticipantsAddRequest(data, 0)
+				return Target{Conversation: model.Uuid(req.Id())}
+			},
+			func(ctx context.Context, key crypto.Key, data []byte) (any, error) {
+				req := services.GetRootAsConversationParticipantsAddRequest(data, 0)
+				return nil, conversations.AddParticipants(ctx, req, key)
+			},
+		},
+		policy.ConversationRemoveParticipants: {
+			func(data []byte) Target {
+				req := services.GetRootAsConversationParticipantsRemoveRequest(data, 0)
+				return Target{Conversation: model.Uuid(req.Id())}
+			},
+			func(ctx context.Context, key crypto.Key, data []byte) (any, error) {
+				req := services.GetRootAsConversationParticipantsRemoveRequest(data, 0)
+				return nil, conversations.RemoveParticipants(ctx, req, key)
+			},
+		},
+		policy.MessageCreate: {
+			func(data []byte) Target {
+				req := services.GetRootAsMessageAddRequest(data, 0)
+				return Target{
+					Member:       model.Uuid(req.Author()),
+					Conversation: model.Uuid(req.Conversation()),
+				}
+			},
+			func(ctx context.Context, key crypto.Key, data []byte) (any, error) {
+				return messages.Add(ctx, services.GetRootAsMessageAddRequest(data, 0), key)
+			},
+		},
+		policy.MessageGet: {
+			func(data []byte) Target {
+				req := services.GetRootAsMessageGetRequest(data, 0)
+				return Target{Message: model.Uuid(req.Id())}
+			},
+			func(ctx context.Context, key crypto.Key, data []byte) (any, error) {
+				return messages.Get(ctx, services.GetRootAsMessageGetRequest(data, 0), key)
+			},
+		},
+		policy.MessageUpdate: {
+			func(data []byte) Target {
+				req := services.GetRootAsMessageUpdateRequest(data, 0)
+				return Target{Message: model.Uuid(req.Id())}
+			},
+			func(ctx context.Context, key crypto.Key, data []byte) (any, error) {
+				return messages.Update(ctx, services.GetRootAsMessageUpdateRequest(data, 0), key)
+			},
+		},
+		policy.MessageRemove: {
+			func(data []byte) Target {
+				req := services.GetRootAsMessageRemoveRequest(data, 0)
+				return Target{Message: model.Uuid(req.Id())}
+			},
+			func(ctx context.Context, key crypto.Key, data []byte) (any, error) {
+				return nil, messages.Remove(ctx, services.GetRootAsMessageRemoveRequest(data, 0))
+			},
+		},
+		policy.MessageList: {
+			func(data []byte) Target {
+				req := services.GetRootAsMessageListRequest(data, 0)
+				return Target{Conversation: model.Uuid(req.Conversation())}
+			},
+			func(ctx context.Context, key crypto.Key, data []byte) (any, error) {
+				member, err := session.MemberFromContext(ctx)
+				if err != nil {
+					return nil, err
+				}
+				req := services.GetRootAsMessageListRequest(data, 0)
+				return messages.List(ctx, member, req, key)
+			},
+		},
+		policy.MessageThread: {
+			func(data []byte) Target {
+				req := services.GetRootAsMessageThreadRequest(data, 0)
+				return Target{Message: model.Uuid(req.Id())}
+			},
+			func(ctx context.Context, key crypto.Key, data []byte) (any, error) {
+				req := services.GetRootAsMessageThreadRequest(data, 0)
+				return messages.ListThread(ctx, req, key)
+			},
+		},
+		policy.ReactionCreate: {
+			func(data []byte) Target {
+				req := services.GetRootAsReactionAddRequest(data, 0)
+				return Target{Member: model.Uuid(req.Author()), Message: model.Uuid(req.Message())}
+			},
+			func(ctx context.Context, key crypto.Key, data []byte) (any, error) {
+				return reactions.Add(ctx, services.GetRootAsReactionAddRequest(data, 0), key)
+			},
+		},
+		policy.ReactionList: {
+			func(data []byte) Target {
+				req := services.GetRootAsReactionListRequest(data, 0)
+				return Target{Message: model.Uuid(req.Message())}
+			},
+			func(ctx context.Context, key crypto.Key, data []byte) (any, error) {
+				return reactions.List(ctx, services.GetRootAsReactionListRequest(data, 0), key)
+			},
+		},
+		policy.ReactionRemove: {
+			func(data []byte) Target {
+				req := services.GetRootAsReactionRemoveRequest(data, 0)
+				return Target{Member: model.Uuid(req.Author()), Message: model.Uuid(req.Message())}
+			},
+			func(ctx context.Context, key crypto.Key, data []byte) (any, error) {
+				req := services.GetRootAsReactionRemoveRequest(data, 0)
+				return nil, reactions.Remove(ctx, req, key)
+			},
+		},
+	}
+
+	return StreamHandler{b, a, sm, conversations, operations}
+}
+
+// Stream upgrades the connection to a websocket. Every event published by the services is
+// forwarded to the client, and request events sent by the client are performed on its behalf.
+//
+// The stream outlives the request that opened it, so the session is checked again before anything
+// is sent or performed, and the stream is closed once the session has been revoked or has expired.
+func (h *StreamHandler) Stream(w http.ResponseWriter, r *http.Request) {
+	key, err := session.FromContext(r.Context())
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+	id, err := session.IdFromContext(r.Context())
+	if err != nil {
+		WriteJsonErr(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	s := websocket.Server{
+		Handshake: checkStreamOrigin,
+		Handler: func(ws *websocket.Conn) {
+			h.serve(r.Context(), ws, key, id)
+		},
+	}
+	s.ServeHTTP(w, r)
+}
+
+func (h *StreamHandler) serve(
+	ctx context.Context, ws *websocket.Conn, key crypto.Key, id model.Uuid,
+) {
+	defer ws.Close()
+
+	sub, unsubscribe := h.broker.Subscribe()
+	defer unsubscribe()
+
+	// Requests are read and performed on a separate goroutine. Replies are handed back to this
+	// goroutine so that only one goroutine ever writes to the connection.
+	replies := make(chan events.Event)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			var req streamRequest
+			if err := websocket.JSON.Receive(ws, &req); err != nil {
+				return
+			}
+			if !h.live(ctx, id) {
+				return
+			}
+
+			select {
+			case replies <- h.perform(ctx, key, req):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	check := time.NewTicker(streamCheckInterval)
+	defer check.Stop()
+
+	for {
+		var e events.Event
+		select {
+		case e = <-sub:
+			if !h.delivers(ctx, key, e) {
+				continue
+			}
+		case e = <-replies:
+		case <-check.C:
+			if !h.live(ctx, id) {
+				return
+			}
+			continue
+		case <-done:
+			return
+		case <-ctx.Done():
+			return
+		}
+
+		if !h.live(ctx, id) {
+			return
+		}
+
+		if err := websocket.JSON.Send(ws, e); err != nil {
+			slog.Info("Closing event stream", "err", err.Error())
+			return
+		}
+	}
+}
+
+// live reports whether the session that opened the stream is still going.
+func (h *StreamHandler) live(ctx context.Context, id model.Uuid) bool {
+	if err := h.sessions.Check(ctx, id); err != nil {
+		slog.Info("Closing event stream", "err", err.Error())
+		return false
+	}
+
+	return true
+}
+
+// delivers reports whether an event published by the services should be sent to the member that
+// owns the stream. Events about a conversation are only sent to the members that can see it.
+func (h *StreamHandler) delivers(ctx context.Context, key crypto.Key, e events.Event) bool {
+	if e.Conversation == "" {
+		return true
+	}
+
+	member, err := session.MemberFromContext(ctx)
+	if err != nil {
+		return false
+	}
+
+	if slices.Contains(e.Participants, string(member)) {
+		return true
+	}
+
+	ok, err := h.conversations.CanSee(ctx, model.Uuid(e.Conversation), member, key)
+	if err != nil {
+		slog.Warn("Failed to check event recipient", "type", e.Type, "err", err.Error())
+		return false
+	}
+
+	return ok
+}
+
+func (h *StreamHandler) perform(
+	ctx context.Context, key crypto.Key, req streamRequest,
+) (reply events.Event) {
+	reply.Id = req.Id
+
+	// A client can send us any bytes it wants as a flatbuffer, and reading a malformed flatbuffer
+	// panics. Make sure a bad request only fails that request.
+	defer func() {
+		if v := recover(); v != nil {
+			reply.Type = StreamError
+			reply.Data = eresponse{fmt.Sprintf("malformed %s request", req.Type)}
+		}
+	}()
+
+	op, ok := h.operations[policy.Operation(req.Type)]
+	if !ok {
+		reply.Type = StreamError
+		reply.Data = eresponse{fmt.Sprintf("unknown request type: %s", req.Type)}
+		return
+	}
+
+	if len(req.Data) < flatbuffers.SizeUOffsetT {
+		req.Data = emptyTable
+	}
+
+	err := h.authorizer.Authorize(ctx, policy.Operation(req.Type), op.target(req.Data))
+	if err != nil {
+		reply.Type = StreamError
+		reply.Data = eresponse{err.Error()}
+		return
+	}
+
+	v, err := op.perform(ctx, key, req.Data)
+	if err != nil {
+		reply.Type = StreamError
+		reply.Data = eresponse{err.Error()}
+		return
+	}
+
+	reply.Type = StreamResponse
+	reply.Data = v
+	return
+}
+
+// emptyTable is a flatbuffer containing a table with no fields. It is used in place of missing
+// request data.
+var emptyTable = func() []byte {
+	builder := flatbuffers.NewBuilder(16)
+	builder.StartObject(0)
+	builder.Finish(builder.EndObject())
+	return builder.FinishedBytes()
+}()
+
+// checkStreamOrigin only allows browsers to open a stream from pages served by this server.
+func checkStreamOrigin(config *websocket.Config, r *http.Request) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return fmt.Errorf("invalid origin: %v", err)
+	}
+	if u.Host != r.Host {
+		return fmt.Errorf("cross-origin stream not allowed: %s", origin)
+	}
+
+	config.Origin = u
+	return nil
+}
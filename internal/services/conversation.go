@@ -5,19 +5,32 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/bradenhc/kolob/internal/crypto"
+	"github.com/bradenhc/kolob/internal/events"
 	"github.com/bradenhc/kolob/internal/model"
 	"github.com/bradenhc/kolob/internal/store"
 )
 
+// ErrNotParticipant is returned when a member asks for a conversation they can't see.
+var ErrNotParticipant = errors.New("member does not participate in conversation")
+
 type ConversationService struct {
-	store store.ConversationStore
+	store   store.ConversationStore
+	members store.MemberStore
+	tx      store.Transactor
+	events  events.Publisher
 }
 
-func NewConversationService(store store.ConversationStore) ConversationService {
-	return ConversationService{store}
+func NewConversationService(
+	store store.ConversationStore,
+	members store.MemberStore,
+	tx store.Transactor,
+	events events.Publisher,
+) ConversationService {
+	return ConversationService{store, members, tx, events}
 }
 
 func (s *ConversationService) Add(
@@ -28,7 +41,13 @@ func (s *ConversationService) Add(
 	for i := range req.ModeratorsLength() {
 		mods = append(mods, model.Uuid(req.Moderators(i)))
 	}
-	c, err := model.NewConversation(string(req.Name()), string(req.Description()), mods)
+	participants := make([]model.Uuid, 0, req.ParticipantsLength())
+	for i := range req.ParticipantsLength() {
+		participants = append(participants, model.Uuid(req.Participants(i)))
+	}
+	c, err := model.NewConversation(
+		string(req.Name()), string(req.Description()), mods, participants,
+	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create conversation object: %v", err)
 	}
@@ -45,6 +64,10 @@ func (s *ConversationService) Add(
 		return nil, fmt.Errorf("failed to store conversation entity: %v", err)
 	}
 
+	s.events.Publish(events.Event{
+		Type: events.ConversationCreated, Data: c, Conversation: string(c.Id()),
+	})
+
 	return c, nil
 }
 
@@ -67,39 +90,84 @@ func (s *ConversationService) Get(
 func (s *ConversationService) Update(
 	ctx context.Context, req *ConversationUpdateRequest, key crypto.Key,
 ) (*model.Conversation, error) {
-	entity, err := s.store.GetConversationEntity(ctx, model.Uuid(req.Id()))
+	convo, err := s.modify(ctx, model.Uuid(req.Id()), key,
+		func(prev *model.Conversation) (*model.Conversation, error) {
+			return model.CloneConversationWithUpdates(
+				prev, req.Name(), req.Description(), nil, nil,
+			), nil
+		},
+	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get conversation from store: %v", err)
+		return nil, err
 	}
 
-	convo, err := entity.Update(key, req.Name(), req.Description(), nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to update conversation entity: %v", err)
-	}
-
-	err = s.store.UpdateConversationEntity(ctx, entity)
-	if err != nil {
-		return nil, fmt.Errorf("faled to store updated conversation entity: %v", err)
-	}
+	s.events.Publish(events.Event{
+		Type: events.ConversationUpdated, Data: convo, Conversation: string(convo.Id()),
+	})
 
 	return convo, nil
 }
 
+// Remove removes the conversation. The last remaining conversation of the group cannot be removed,
+// so the group always has somewhere to talk. Only the members that can see it are told.
 func (s *ConversationService) Remove(
 	ctx context.Context, req *ConversationRemoveRequest,
 ) error {
-	err := s.store.RemoveConversationEntity(ctx, model.Uuid(req.Id()))
+	var participants []string
+	err := s.tx.InTransaction(ctx, func(ctx context.Context) error {
+		count, err := s.store.CountConversationEntities(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to count conversations: %v", err)
+		}
+		if count <= 1 {
+			return fmt.Errorf("cannot remove the last remaining conversation")
+		}
+
+		ms, err := s.store.ListConversationParticipants(ctx, model.Uuid(req.Id()))
+		if err != nil {
+			return fmt.Errorf("failed to list conversation participants: %v", err)
+		}
+		for _, m := range ms {
+			participants = append(participants, string(m))
+		}
+
+		err = s.store.RemoveConversationEntity(ctx, model.Uuid(req.Id()))
+		if err != nil {
+			return fmt.Errorf("failed to remove conversation from database: %v", err)
+		}
+
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("failed to remove member from database: %v", err)
+		return err
 	}
 
+	s.events.Publish(events.Event{
+		Type:         events.ConversationRemoved,
+		Data:         events.Removed{Id: string(req.Id())},
+		Conversation: string(req.Id()),
+		Participants: participants,
+	})
+
 	return nil
 }
 
+// ListAll lists all of the conversations the member can see, which for Group Moderators is every
+// conversation in the group.
 func (s *ConversationService) ListAll(
-	ctx context.Context, key crypto.Key,
+	ctx context.Context, member model.Uuid, key crypto.Key,
 ) ([]*model.Conversation, error) {
-	edatas, err := s.store.ListConversationEntities(ctx)
+	gm, err := isGroupModerator(ctx, s.members, member, key)
+	if err != nil {
+		return nil, err
+	}
+
+	var edatas []store.ConversationEntity
+	if gm {
+		edatas, err = s.store.ListConversationEntities(ctx)
+	} else {
+		edatas, err = s.store.ListParticipatingConversationEntities(ctx, member)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get conversation entity list: %v", err)
 	}
@@ -116,95 +184,239 @@ func (s *ConversationService) ListAll(
 	return cs, nil
 }
 
-func (s *ConversationService) AddMods(
-	ctx context.Context, req *ConversationModsAddRequest, key crypto.Key,
-) error {
-	entity, err := s.store.GetConversationEntity(ctx, model.Uuid(req.Id()))
-	if err != nil {
-		return fmt.Errorf("failed to get conversation from store: %v", err)
-	}
+// CanSee reports whether the member can see the conversation, following canSeeConversation.
+func (s *ConversationService) CanSee(
+	ctx context.Context, id, member model.Uuid, key crypto.Key,
+) (bool, error) {
+	return canSeeConversation(ctx, s.store, s.members, id, member, key)
+}
 
-	prev, err := entity.Decrypt(key)
+// canSeeConversation reports whether the member can see the conversation, its messages, and the
+// events about it. Conversations are private to their participants, except that Group Moderators
+// can see all of them so that they can manage every conversation in the group. The conversation
+// doesn't need to exist anymore, so that its removal can be checked as well.
+func canSeeConversation(
+	ctx context.Context,
+	conversations store.ConversationStore,
+	members store.MemberStore,
+	id, member model.Uuid,
+	key crypto.Key,
+) (bool, error) {
+	ok, err := conversations.IsConversationParticipant(ctx, id, member)
 	if err != nil {
-		return fmt.Errorf("failed to descrypt conversation: %v", err)
+		return false, fmt.Errorf("failed to find conversation participant in store: %v", err)
 	}
-
-	// First, copy the existing moderator entries into the new moderator list. While doing so, keep
-	// track of which moderator ids are already in the list so that we don't add duplicates.
-	prevMods := make(map[string]bool, prev.ModsLength())
-	newMods := make([][]byte, 0, prev.ModsLength())
-	for i := range prev.ModsLength() {
-		prevMods[string(prev.Mods(i))] = true
-		newMods = append(newMods, prev.Mods(i))
+	if ok {
+		return true, nil
 	}
 
-	// Add new moderators to the list if their id isn't already there.
-	for i := range req.ModeratorsLength() {
-		_, ok := prevMods[string(req.Moderators(i))]
-		if !ok {
-			newMods = append(newMods, req.Moderators(i))
-		}
-	}
+	return isGroupModerator(ctx, members, member, key)
+}
 
-	c := model.CloneConversationWithUpdates(prev, nil, nil, newMods)
-	entity, err = store.NewConversationEntity(c, key)
+// isGroupModerator reports whether the member is a Group Moderator or above.
+func isGroupModerator(
+	ctx context.Context, members store.MemberStore, member model.Uuid, key crypto.Key,
+) (bool, error) {
+	e, err := members.GetMemberEntity(ctx, member)
 	if err != nil {
-		return fmt.Errorf("failed to create updated conversation entity: %v", err)
+		return false, fmt.Errorf("failed to get member from store: %v", err)
+	}
+	m, err := e.Decrypt(key)
+	if err != nil {
+		return false, fmt.Errorf("failed to decrypt member: %v", err)
 	}
 
-	err = s.store.UpdateConversationEntity(ctx, entity)
+	return m.Role() >= model.RoleGroupModerator, nil
+}
+
+func (s *ConversationService) AddMods(
+	ctx context.Context, req *ConversationModsAddRequest, key crypto.Key,
+) error {
+	c, err := s.modify(ctx, model.Uuid(req.Id()), key,
+		func(prev *model.Conversation) (*model.Conversation, error) {
+			// First, copy the existing moderator entries into the new moderator list. While doing
+			// so, keep track of which moderator ids are already in the list so that we don't add
+			// duplicates.
+			prevMods := make(map[string]bool, prev.ModsLength())
+			newMods := make([][]byte, 0, prev.ModsLength())
+			for i := range prev.ModsLength() {
+				prevMods[string(prev.Mods(i))] = true
+				newMods = append(newMods, prev.Mods(i))
+			}
+
+			// Add new moderators to the list if their id isn't already there.
+			for i := range req.ModeratorsLength() {
+				_, ok := prevMods[string(req.Moderators(i))]
+				if !ok {
+					newMods = append(newMods, req.Moderators(i))
+				}
+			}
+
+			return model.CloneConversationWithUpdates(prev, nil, nil, newMods, nil), nil
+		},
+	)
 	if err != nil {
-		return fmt.Errorf("failed to store updated conversation: %v", err)
+		return err
 	}
 
+	s.events.Publish(events.Event{
+		Type: events.ConversationUpdated, Data: c, Conversation: string(c.Id()),
+	})
+
 	return nil
 }
 
 func (s *ConversationService) RemoveMods(
 	ctx context.Context, req *ConversationModsRemoveRequest, key crypto.Key,
 ) error {
-	entity, err := s.store.GetConversationEntity(ctx, model.Uuid(req.Id()))
+	c, err := s.modify(ctx, model.Uuid(req.Id()), key,
+		func(prev *model.Conversation) (*model.Conversation, error) {
+			// First, convert the list of mods to remove to a map so that we can easily test for ids
+			modsToRemove := make(map[string]bool, req.ModeratorsLength())
+			for i := range req.ModeratorsLength() {
+				modsToRemove[string(req.Moderators(i))] = true
+			}
+
+			// Iterate over the existing mods. If the id exists in the mapping of mods to remove,
+			// then remove it.
+			newMods := make([][]byte, 0, prev.ModsLength())
+			for i := range prev.ModsLength() {
+				_, found := modsToRemove[string(prev.Mods(i))]
+				if !found {
+					newMods = append(newMods, prev.Mods(i))
+				}
+			}
+
+			if len(newMods) == 0 {
+				return nil, fmt.Errorf(
+					"removing requested moderators would result in no moderators",
+				)
+			}
+
+			return model.CloneConversationWithUpdates(prev, nil, nil, newMods, nil), nil
+		},
+	)
 	if err != nil {
-		return fmt.Errorf("failed to get conversation from store: %v", err)
+		return err
 	}
 
-	prev, err := entity.Decrypt(key)
+	s.events.Publish(events.Event{
+		Type: events.ConversationUpdated, Data: c, Conversation: string(c.Id()),
+	})
+
+	return nil
+}
+
+func (s *ConversationService) AddParticipants(
+	ctx context.Context, req *ConversationParticipantsAddRequest, key crypto.Key,
+) error {
+	c, err := s.modify(ctx, model.Uuid(req.Id()), key,
+		func(prev *model.Conversation) (*model.Conversation, error) {
+			// Duplicate participants are dropped when the updated conversation is created
+			participants := make([][]byte, 0, prev.ParticipantsLength()+req.ParticipantsLength())
+			for i := range prev.ParticipantsLength() {
+				participants = append(participants, prev.Participants(i))
+			}
+			for i := range req.ParticipantsLength() {
+				participants = append(participants, req.Participants(i))
+			}
+
+			return model.CloneConversationWithUpdates(prev, nil, nil, nil, participants), nil
+		},
+	)
 	if err != nil {
-		return fmt.Errorf("failed to decrypt conversation: %v", err)
+		return err
 	}
 
-	// First, convert the list of mods to remove to a map so that we can easily test for ids
-	modsToRemove := make(map[string]bool, req.ModeratorsLength())
-	for i := range req.ModeratorsLength() {
-		modsToRemove[string(req.Moderators(i))] = true
+	s.events.Publish(events.Event{
+		Type: events.ConversationUpdated, Data: c, Conversation: string(c.Id()),
+	})
+
+	return nil
+}
+
+func (s *ConversationService) RemoveParticipants(
+	ctx context.Context, req *ConversationParticipantsRemoveRequest, key crypto.Key,
+) error {
+	c, err := s.modify(ctx, model.Uuid(req.Id()), key,
+		func(prev *model.Conversation) (*model.Conversation, error) {
+			toRemove := make(map[string]bool, req.ParticipantsLength())
+			for i := range req.ParticipantsLength() {
+				toRemove[string(req.Participants(i))] = true
+			}
+
+			// Moderators must always participate in the conversation they moderate, so they have
+			// to be removed as moderators before they can be removed as participants.
+			for i := range prev.ModsLength() {
+				if toRemove[string(prev.Mods(i))] {
+					return nil, fmt.Errorf(
+						"cannot remove moderator %s from conversation", prev.Mods(i),
+					)
+				}
+			}
+
+			participants := make([][]byte, 0, prev.ParticipantsLength())
+			for i := range prev.ParticipantsLength() {
+				if !toRemove[string(prev.Participants(i))] {
+					participants = append(participants, prev.Participants(i))
+				}
+			}
+
+			return model.CloneConversationWithUpdates(prev, nil, nil, nil, participants), nil
+		},
+	)
+	if err != nil {
+		return err
 	}
 
-	// Iterate over the existing mods. If the id exists in the mapping of mods to remove, then
-	// remove it.
-	newMods := make([][]byte, 0, prev.ModsLength())
-	for i := range prev.ModsLength() {
-		_, found := modsToRemove[string(prev.Mods(i))]
-		if !found {
-			newMods = append(newMods, prev.Mods(i))
+	s.events.Publish(events.Event{
+		Type: events.ConversationUpdated, Data: c, Conversation: string(c.Id()),
+	})
+
+	return nil
+}
+
+// modify reads the conversation, passes it to f, and stores the conversation f returns. All of
+// this happens in a single transaction so that concurrent changes to the conversation are not
+// lost.
+func (s *ConversationService) modify(
+	ctx context.Context,
+	id model.Uuid,
+	key crypto.Key,
+	f func(prev *model.Conversation) (*model.Conversation, error),
+) (*model.Conversation, error) {
+	var c *model.Conversation
+	err := s.tx.InTransaction(ctx, func(ctx context.Context) error {
+		entity, err := s.store.GetConversationEntity(ctx, id)
+		if err != nil {
+			return fmt.Errorf("failed to get conversation from store: %v", err)
 		}
-	}
 
-	if len(newMods) == 0 {
-		return fmt.Errorf("removing requested moderators would result in no moderators")
-	}
+		prev, err := entity.Decrypt(key)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt conversation: %v", err)
+		}
 
-	// Create the updated entity with the new moderator list
-	c := model.CloneConversationWithUpdates(prev, nil, nil, newMods)
-	entity, err = store.NewConversationEntity(c, key)
-	if err != nil {
-		return fmt.Errorf("failed to create update conversation entity: %v", err)
-	}
+		c, err = f(prev)
+		if err != nil {
+			return err
+		}
+
+		entity, err = store.NewConversationEntity(c, key)
+		if err != nil {
+			return fmt.Errorf("failed to create updated conversation entity: %v", err)
+		}
 
-	// Store the updated entity
-	err = s.store.UpdateConversationEntity(ctx, entity)
+		err = s.store.UpdateConversationEntity(ctx, entity)
+		if err != nil {
+			return fmt.Errorf("failed to store updated conversation: %v", err)
+		}
+
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("failed to store updated conversation: %v", err)
+		return nil, err
 	}
 
-	return nil
+	return c, nil
 }
@@ -11,6 +11,7 @@ import (
 	"testing"
 
 	"github.com/bradenhc/kolob/internal/crypto"
+	"github.com/bradenhc/kolob/internal/events"
 	"github.com/bradenhc/kolob/internal/model"
 	"github.com/bradenhc/kolob/internal/services"
 	"github.com/bradenhc/kolob/internal/store"
@@ -34,19 +35,25 @@ func TestConversationService(t *testing.T) {
 
 	// Create the group this conversation and the mediator member will belong to
 	//
+	tx := sqlite.NewTransactor(db)
 	gstore := doTestGroupCreateStore(t, db)
-	gs := services.NewGroupService(gstore)
-	doTestGroupCreate(t, ctx, gs)
+	mstore := doTestMemberCreateStore(t, db)
+	cstore := doTestConversationCreateStore(t, db)
+	ikey := doTestIndexKey(t)
+	gs := services.NewGroupService(
+		gstore, mstore, cstore, sqlite.NewInvitationStore(db), sqlite.NewSessionStore(db),
+		sqlite.NewRekeyStore(db), tx, ikey,
+	)
+	group := doTestGroupCreate(t, ctx, gs)
 	key := doTestGroupAuth(t, ctx, gs)
 
 	// Add a member to use later as a mediator
-	mstore := doTestMemberCreateStore(t, db)
-	ms := services.NewMemberService(mstore)
+	ms := services.NewMemberService(mstore, sqlite.NewSessionStore(db), events.NewBroker(), ikey)
 	m1 := doTestMemberAdd(t, ctx, ms, key, "user1")
 
-	// Create the conversation store and service
-	cstore := doTestConversationCreateStore(t, db)
-	cs := services.NewConversationService(cstore)
+	// Create the conversation service
+	broker := events.NewBroker()
+	cs := services.NewConversationService(cstore, mstore, tx, broker)
 
 	// Create a conversation
 	a := doTestConversationAdd(t, ctx, cs, key, m1)
@@ -58,7 +65,7 @@ func TestConversationService(t *testing.T) {
 	c := doTestConversationListAll(t, ctx, cs, key, m1, b)
 
 	// Remove conversations
-	doTestConversationRemove(t, ctx, cs, key, b)
+	doTestConversationRemove(t, ctx, cs, broker, key, m1, b)
 
 	// Add mods to conversation
 	m2 := doTestMemberAdd(t, ctx, ms, key, "user2")
@@ -67,15 +74,21 @@ func TestConversationService(t *testing.T) {
 
 	// Remove mods from conversation
 	doTestConversationModsRemove(t, ctx, cs, key, c, m2)
+
+	// Add and remove participants from the conversation
+	m4 := doTestMemberAdd(t, ctx, ms, key, "user4")
+	doTestConversationParticipantsAdd(t, ctx, cs, key, c, m4)
+	doTestConversationParticipantsRemove(t, ctx, cs, key, c, m3, m4)
+
+	// Group Moderators can see every conversation, including the ones they don't participate in
+	doTestConversationGroupModerator(t, ctx, cs, cstore, key, c, group.Creator)
+
+	// The last remaining conversation cannot be removed
+	doTestConversationRemoveLast(t, ctx, cs, cstore)
 }
 
 func doTestConversationCreateStore(t *testing.T, db *sql.DB) store.ConversationStore {
-	cstore, err := sqlite.NewConversationStore(db)
-	if err != nil {
-		t.Fatalf("failed to setup conversation store: %v", err)
-	}
-
-	return cstore
+	return sqlite.NewConversationStore(db)
 }
 
 func doTestConversationAdd(
@@ -192,7 +205,7 @@ func doTestConversationListAll(
 	c := doTestConversationAdd(t, ctx, cs, key, m)
 	d := doTestConversationAdd(t, ctx, cs, key, m)
 
-	l, err := cs.ListAll(ctx, key)
+	l, err := cs.ListAll(ctx, model.Uuid(m.Id()), key)
 	if err != nil {
 		t.Fatalf("failed to list all conversations: %v", err)
 	}
@@ -218,9 +231,14 @@ func doTestConversationRemove(
 	t *testing.T,
 	ctx context.Context,
 	cs services.ConversationService,
+	broker *events.Broker,
 	key crypto.Key,
+	m *model.Member,
 	b *model.Conversation,
 ) {
+	sub, unsubscribe := broker.Subscribe()
+	defer unsubscribe()
+
 	builder := flatbuffers.NewBuilder(32)
 	idOffset := builder.CreateByteString(b.Id())
 
@@ -235,7 +253,7 @@ func doTestConversationRemove(
 		t.Fatalf("failed to remove conversation: %v", err)
 	}
 
-	l, err := cs.ListAll(ctx, key)
+	l, err := cs.ListAll(ctx, model.Uuid(m.Id()), key)
 	if err != nil {
 		t.Fatalf("failed to list conversations after removing one: %v", err)
 	}
@@ -243,6 +261,89 @@ func doTestConversationRemove(
 	if len(l) != 2 {
 		t.Errorf("too many conversations in list after remove: %d != %d", len(l), 2)
 	}
+
+	// The removal is only announced to the members that participated in the conversation
+	e := <-sub
+	if e.Type != events.ConversationRemoved || e.Conversation != string(b.Id()) {
+		t.Fatalf("expected removal of conversation %s, got %s %s", b.Id(), e.Type, e.Conversation)
+	}
+	if !slices.Equal(e.Participants, []string{string(m.Id())}) {
+		t.Errorf("removal announced to the wrong members: %v", e.Participants)
+	}
+}
+
+func doTestConversationGroupModerator(
+	t *testing.T,
+	ctx context.Context,
+	cs services.ConversationService,
+	cstore store.ConversationStore,
+	key crypto.Key,
+	c *model.Conversation,
+	gm *model.Member,
+) {
+	if c.IsParticipant(model.Uuid(gm.Id())) {
+		t.Fatalf("group moderator already participates in the conversation")
+	}
+
+	ok, err := cs.CanSee(ctx, model.Uuid(c.Id()), model.Uuid(gm.Id()), key)
+	if err != nil {
+		t.Fatalf("failed to check whether the conversation can be seen: %v", err)
+	}
+	if !ok {
+		t.Errorf("group moderator can't see a conversation they don't participate in")
+	}
+
+	l, err := cs.ListAll(ctx, model.Uuid(gm.Id()), key)
+	if err != nil {
+		t.Fatalf("failed to list conversations for group moderator: %v", err)
+	}
+	count, err := cstore.CountConversationEntities(ctx)
+	if err != nil {
+		t.Fatalf("failed to count conversations: %v", err)
+	}
+	if len(l) != count {
+		t.Errorf("group moderator didn't list every conversation: %d != %d", len(l), count)
+	}
+}
+
+func doTestConversationRemoveLast(
+	t *testing.T, ctx context.Context, cs services.ConversationService, cstore store.ConversationStore,
+) {
+	es, err := cstore.ListConversationEntities(ctx)
+	if err != nil {
+		t.Fatalf("failed to list conversations: %v", err)
+	}
+
+	remove := func(id model.Uuid) error {
+		builder := flatbuffers.NewBuilder(32)
+		idOffset := builder.CreateString(string(id))
+
+		services.ConversationRemoveRequestStart(builder)
+		services.ConversationRemoveRequestAddId(builder, idOffset)
+		reqOffset := services.ConversationRemoveRequestEnd(builder)
+		builder.Finish(reqOffset)
+
+		req := services.GetRootAsConversationRemoveRequest(builder.FinishedBytes(), 0)
+		return cs.Remove(ctx, req)
+	}
+
+	for _, e := range es[1:] {
+		if err := remove(e.Id); err != nil {
+			t.Fatalf("failed to remove conversation: %v", err)
+		}
+	}
+
+	if err := remove(es[0].Id); err == nil {
+		t.Errorf("expected removing the last conversation to fail")
+	}
+
+	count, err := cstore.CountConversationEntities(ctx)
+	if err != nil {
+		t.Fatalf("failed to count conversations: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("wrong number of conversations remaining: %d != %d", count, 1)
+	}
 }
 
 func doTestConversationModsAdd(
@@ -356,3 +457,93 @@ func doTestConversationModsRemove(
 		}
 	}
 }
+
+func doTestConversationParticipantsAdd(
+	t *testing.T,
+	ctx context.Context,
+	cs services.ConversationService,
+	key crypto.Key,
+	c *model.Conversation,
+	m *model.Member,
+) {
+	l, err := cs.ListAll(ctx, model.Uuid(m.Id()), key)
+	if err != nil {
+		t.Fatalf("failed to list conversations before adding participant: %v", err)
+	}
+	if len(l) != 0 {
+		t.Errorf("member listed conversations before participating: %d != %d", len(l), 0)
+	}
+
+	builder := flatbuffers.NewBuilder(64)
+	cIdOffset := builder.CreateByteString(c.Id())
+	mIdOffset := builder.CreateByteString(m.Id())
+
+	services.ConversationParticipantsAddRequestStartParticipantsVector(builder, 1)
+	builder.PrependUOffsetT(mIdOffset)
+	partsOffset := builder.EndVector(1)
+
+	services.ConversationParticipantsAddRequestStart(builder)
+	services.ConversationParticipantsAddRequestAddId(builder, cIdOffset)
+	services.ConversationParticipantsAddRequestAddParticipants(builder, partsOffset)
+	builder.Finish(services.ConversationParticipantsAddRequestEnd(builder))
+
+	req := services.GetRootAsConversationParticipantsAddRequest(builder.FinishedBytes(), 0)
+	err = cs.AddParticipants(ctx, req, key)
+	if err != nil {
+		t.Fatalf("failed to add participants to conversation: %v", err)
+	}
+
+	l, err = cs.ListAll(ctx, model.Uuid(m.Id()), key)
+	if err != nil {
+		t.Fatalf("failed to list conversations after adding participant: %v", err)
+	}
+	if len(l) != 1 {
+		t.Fatalf("incorrect number of conversations for participant: %d != %d", len(l), 1)
+	}
+	if !l[0].IsParticipant(model.Uuid(m.Id())) {
+		t.Errorf("conversation is missing participant %s", m.Id())
+	}
+}
+
+func doTestConversationParticipantsRemove(
+	t *testing.T,
+	ctx context.Context,
+	cs services.ConversationService,
+	key crypto.Key,
+	c *model.Conversation,
+	mod, m *model.Member,
+) {
+	remove := func(id []byte) error {
+		builder := flatbuffers.NewBuilder(64)
+		cIdOffset := builder.CreateByteString(c.Id())
+		mIdOffset := builder.CreateByteString(id)
+
+		services.ConversationParticipantsRemoveRequestStartParticipantsVector(builder, 1)
+		builder.PrependUOffsetT(mIdOffset)
+		partsOffset := builder.EndVector(1)
+
+		services.ConversationParticipantsRemoveRequestStart(builder)
+		services.ConversationParticipantsRemoveRequestAddId(builder, cIdOffset)
+		services.ConversationParticipantsRemoveRequestAddParticipants(builder, partsOffset)
+		builder.Finish(services.ConversationParticipantsRemoveRequestEnd(builder))
+
+		req := services.GetRootAsConversationParticipantsRemoveRequest(builder.FinishedBytes(), 0)
+		return cs.RemoveParticipants(ctx, req, key)
+	}
+
+	if err := remove(mod.Id()); err == nil {
+		t.Errorf("removed a moderator from the conversation participants")
+	}
+
+	if err := remove(m.Id()); err != nil {
+		t.Fatalf("failed to remove participant from conversation: %v", err)
+	}
+
+	ok, err := cs.CanSee(ctx, model.Uuid(c.Id()), model.Uuid(m.Id()), key)
+	if err != nil {
+		t.Fatalf("failed to check conversation participant: %v", err)
+	}
+	if ok {
+		t.Errorf("member still participates in conversation after remove")
+	}
+}
@@ -5,6 +5,7 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"time"
@@ -14,15 +15,52 @@ import (
 	"github.com/bradenhc/kolob/internal/store"
 )
 
+// GeneralConversationName is the name of the conversation created along with the group.
+const GeneralConversationName = "General"
+
+// RekeyBatchSize is the number of entities re-encrypted in each transaction of a data key rotation.
+const RekeyBatchSize = 100
+
+// ErrRecoveryKitRequired is returned when the data key of a group with a recovery kit is rotated
+// without the shares and threshold of the kit that replaces it.
+var ErrRecoveryKitRequired = errors.New("the group has a recovery kit, so a new one is required")
+
 type GroupService struct {
-	store store.GroupStore
+	store         store.GroupStore
+	members       store.MemberStore
+	conversations store.ConversationStore
+	invitations   store.InvitationStore
+	sessions      store.SessionStore
+	rekeys        store.RekeyStore
+	tx            store.Transactor
+	ikey          crypto.IndexKey
 }
 
-func NewGroupService(store store.GroupStore) GroupService {
-	return GroupService{store}
+func NewGroupService(
+	store store.GroupStore,
+	members store.MemberStore,
+	conversations store.ConversationStore,
+	invitations store.InvitationStore,
+	sessions store.SessionStore,
+	rekeys store.RekeyStore,
+	tx store.Transactor,
+	ikey crypto.IndexKey,
+) GroupService {
+	return GroupService{store, members, conversations, invitations, sessions, rekeys, tx, ikey}
 }
 
-func (svc GroupService) Create(ctx context.Context, req *GroupInitRequest) (*model.Group, error) {
+// An InitializedGroup is the group created by GroupService.Create together with the member that
+// created it.
+type InitializedGroup struct {
+	Group   *model.Group  `json:"group"`
+	Creator *model.Member `json:"creator"`
+}
+
+// Create initializes the group. The member creating the group is added as its first Group
+// Moderator, and the group starts out with a General conversation moderated by the creator.
+func (svc GroupService) Create(
+	ctx context.Context, req *GroupInitRequest,
+) (*InitializedGroup, error) {
 	// Make sure there isn't already a set of group information in the database
 	exists, err := svc.store.IsGroupDataSet(ctx)
 	if err != nil {
@@ -32,6 +70,15 @@ func (svc GroupService) Create(ctx context.Context, req *GroupInitRequest) (*mod
 		return nil, fmt.Errorf("group already exists")
 	}
 
+	// Verify the credentials of the group creator before doing any expensive work
+	if len(req.CreatorUsername()) == 0 {
+		return nil, fmt.Errorf("group creator username is required")
+	}
+	cpass, err := crypto.NewPassword(string(req.CreatorPassword()))
+	if err != nil {
+		return nil, fmt.Errorf("creator password validation failed: %w", err)
+	}
+
 	// Generate a new random key that will encrypt all data for the group (data key)
 	slog.Info("Generating data encryption key for group")
 	dkey, err := crypto.NewRandomKey()
@@ -45,14 +92,62 @@ func (svc GroupService) Create(ctx context.Context, req *GroupInitRequest) (*mod
 		return nil, fmt.Errorf("failed to initialize group: %v", err)
 	}
 
-	entity, err := store.NewGroupEntity(group, crypto.Password(req.Password()), dkey)
+	entity, err := store.NewGroupEntity(group, crypto.Password(req.Password()), dkey, svc.ikey)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create group store entity: %v", err)
 	}
 
-	svc.store.AddGroupEntity(ctx, entity)
+	// Create the member that is creating the group
+	creator, err := model.NewMember(
+		string(req.CreatorUsername()), string(req.CreatorName()), model.RoleGroupModerator,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create group creator: %v", err)
+	}
 
-	return group, nil
+	centity, err := store.NewMemberEntity(creator, cpass, dkey, svc.ikey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create group creator entity: %v", err)
+	}
+
+	// Every group starts out with a General conversation. The group, its creator, and the
+	// conversation are stored together so that a group never exists without them.
+	mods := []model.Uuid{model.Uuid(creator.Id())}
+	general, err := model.NewConversation(
+		GeneralConversationName, "Conversation for the whole group", mods, nil,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create general conversation: %v", err)
+	}
+
+	gentity, err := store.NewConversationEntity(general, dkey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create general conversation entity: %v", err)
+	}
+
+	err = svc.tx.InTransaction(ctx, func(ctx context.Context) error {
+		err := svc.store.AddGroupEntity(ctx, entity)
+		if err != nil {
+			return fmt.Errorf("failed to store group entity: %v", err)
+		}
+
+		err = svc.members.AddMemberEntity(ctx, centity)
+		if err != nil {
+			return fmt.Errorf("failed to store group creator entity: %v", err)
+		}
+
+		err = svc.conversations.AddConversationEntity(ctx, gentity)
+		if err != nil {
+			return fmt.Errorf("failed to store general conversation entity: %v", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &InitializedGroup{group, creator}, nil
 }
 
 func (g GroupService) Get(ctx context.Context, dkey crypto.Key) (*model.Group, error) {
@@ -61,7 +156,7 @@ func (g GroupService) Get(ctx context.Context, dkey crypto.Key) (*model.Group, e
 		return nil, fmt.Errorf("store error: %v", err)
 	}
 
-	data, err := crypto.Decrypt(dkey, e.EncryptedData)
+	data, err := crypto.DecryptAD(dkey, e.EncryptedData, e.AssociatedData())
 	if err != nil {
 		return nil, fmt.Errorf("decrypt error: %v", err)
 	}
@@ -77,8 +172,18 @@ func (g GroupService) Authenticate(
 		return nil, fmt.Errorf("store error: %v", err)
 	}
 
+	// The group data key can't be handed out while it is being replaced, since data written with it
+	// might never be re-encrypted
+	err = g.checkNoRekey(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Groups that haven't been migrated to blind indexes yet still store a plain hash of the group
+	// ID, which the migrate command needs to unlock
 	pass := crypto.Password(req.Password())
-	if !crypto.CheckDataHash(req.GroupId(), m.GroupHash) {
+	gid := req.GroupId()
+	if !crypto.CheckBlindIndex(g.ikey, gid, m.GroupHash) && !crypto.CheckDataHash(gid, m.GroupHash) {
 		return nil, fmt.Errorf("incorrect credentials")
 	}
 	if !crypto.CheckPasswordHash(pass, m.PassHash) {
@@ -86,15 +191,64 @@ func (g GroupService) Authenticate(
 
 	}
 
-	pkey := crypto.NewDerivedKey(pass, m.PassSalt)
+	pkey, err := crypto.NewDerivedKey(pass, m.PassSalt, m.KdfParams)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive password key: %v", err)
+	}
 	dkey, err := crypto.Decrypt(pkey, m.EncryptedKey)
 	if err != nil {
 		return nil, fmt.Errorf("failed to decrypt group data key: %v", err)
 	}
 
+	// Now that we have the password, move the data key over to the current key derivation defaults.
+	// The group can still be unlocked with the old parameters if this fails, so it isn't fatal.
+	if m.KdfParams != crypto.DefaultKdfParams {
+		err := g.upgradeKdf(ctx, m, pass, dkey)
+		if err != nil {
+			slog.Warn("Failed to upgrade group key derivation", "err", err)
+		}
+	}
+
 	return dkey, nil
 }
 
+// upgradeKdf encrypts the data key with a key derived from the password using the current default
+// key derivation parameters and a new salt.
+func (g GroupService) upgradeKdf(
+	ctx context.Context, e store.GroupEntity, pass crypto.Password, dkey crypto.Key,
+) error {
+	slog.Info(
+		"Upgrading group key derivation",
+		"from", e.KdfParams.String(), "to", crypto.DefaultKdfParams.String(),
+	)
+
+	psalt, err := crypto.NewSalt()
+	if err != nil {
+		return fmt.Errorf("failed to generate salt: %v", err)
+	}
+
+	pkey, err := crypto.NewDerivedKey(pass, psalt, crypto.DefaultKdfParams)
+	if err != nil {
+		return fmt.Errorf("failed to derive password key: %v", err)
+	}
+
+	ekey, err := crypto.Encrypt(pkey, dkey)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt data key with password key: %v", err)
+	}
+
+	e.PassSalt = psalt
+	e.KdfParams = crypto.DefaultKdfParams
+	e.EncryptedKey = ekey
+
+	err = g.store.UpdateGroupEntity(ctx, e)
+	if err != nil {
+		return fmt.Errorf("failed to update group: %v", err)
+	}
+
+	return nil
+}
+
 func (g GroupService) Update(
 	ctx context.Context, req *GroupUpdateRequest, dkey crypto.Key,
 ) (*model.Group, error) {
@@ -103,7 +257,7 @@ func (g GroupService) Update(
 		return nil, fmt.Errorf("could not get group entity from store: %v", err)
 	}
 
-	group, err := e.Update(dkey, req.GroupId(), req.Name(), req.Description())
+	group, err := e.Update(dkey, g.ikey, req.GroupId(), req.Name(), req.Description())
 	if err != nil {
 		return nil, fmt.Errorf("could not update group: %v", err)
 	}
@@ -128,19 +282,33 @@ func (g GroupService) ChangePassword(
 		return fmt.Errorf("old password validation failed")
 	}
 
-	data, err := crypto.Decrypt(dkey, e.EncryptedData)
+	// The new key of an unfinished data key rotation is encrypted with the old password
+	err = g.checkNoRekey(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to decrypt group data: %v", err)
+		return err
 	}
 
-	psalt, err := crypto.NewSalt()
+	npass, err := crypto.NewPassword(string(req.NewPassword()))
 	if err != nil {
-		return fmt.Errorf("failed to generate salt for new password: %v", err)
+		return err
 	}
 
-	npass, err := crypto.NewPassword(string(req.NewPassword()))
+	return g.setPassword(ctx, e, npass, dkey)
+}
+
+// setPassword replaces the group password and stores the data key encrypted with a key derived from
+// the new password.
+func (g GroupService) setPassword(
+	ctx context.Context, e store.GroupEntity, npass crypto.Password, dkey crypto.Key,
+) error {
+	data, err := crypto.DecryptAD(dkey, e.EncryptedData, e.AssociatedData())
+	if err != nil {
+		return fmt.Errorf("failed to decrypt group data: %v", err)
+	}
+
+	psalt, err := crypto.NewSalt()
 	if err != nil {
-		return fmt.Errorf("invalid password: %v", err)
+		return fmt.Errorf("failed to generate salt for new password: %v", err)
 	}
 
 	phash, err := crypto.HashPassword(npass)
@@ -150,7 +318,12 @@ func (g GroupService) ChangePassword(
 	e.PassSalt = psalt
 	e.PassHash = phash
 
-	pkey := crypto.NewDerivedKey(npass, psalt)
+	pkey, err := crypto.NewDerivedKey(npass, psalt, crypto.DefaultKdfParams)
+	if err != nil {
+		return fmt.Errorf("failed to derive new password key: %v", err)
+	}
+	e.KdfParams = crypto.DefaultKdfParams
+
 	ekey, err := crypto.Encrypt(pkey, dkey)
 	if err != nil {
 		return fmt.Errorf("failed to encrypt data key with new password key: %v", err)
@@ -162,7 +335,7 @@ func (g GroupService) ChangePassword(
 	group := model.GetRootAsGroup(data, 0)
 	group.MutateUpdated(updated)
 
-	e.EncryptedData, err = crypto.Encrypt(dkey, data)
+	e.EncryptedData, err = crypto.EncryptAD(dkey, data, e.AssociatedData())
 	if err != nil {
 		return fmt.Errorf("failed to encrypt update group data: %v", err)
 	}
@@ -174,3 +347,421 @@ func (g GroupService) ChangePassword(
 
 	return nil
 }
+
+// CreateRecoveryKit creates a new recovery key for the group and splits it into shares, any
+// threshold of which can be given to Recover to set a new group password. Only the data key
+// encrypted with the recovery key is stored, never the recovery key or its shares. Creating a new
+// recovery kit replaces the old one, whose shares stop working.
+func (g GroupService) CreateRecoveryKit(
+	ctx context.Context, req *GroupRecoveryKitRequest,
+) ([]crypto.Share, error) {
+	e, err := g.store.GetGroupEntity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("store error: %v", err)
+	}
+
+	pass := crypto.Password(req.Password())
+	if !crypto.CheckPasswordHash(pass, e.PassHash) {
+		return nil, fmt.Errorf("incorrect credentials")
+	}
+
+	// The kit would hold the old key after the rotation finishes
+	err = g.checkNoRekey(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pkey, err := crypto.NewDerivedKey(pass, e.PassSalt, e.KdfParams)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive password key: %v", err)
+	}
+	dkey, err := crypto.Decrypt(pkey, e.EncryptedKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt group data key: %v", err)
+	}
+
+	rkey, err := crypto.NewRandomKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create recovery key: %v", err)
+	}
+
+	shares, err := crypto.SplitKey(rkey, int(req.Shares()), int(req.Threshold()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to split recovery key: %v", err)
+	}
+
+	err = e.WrapRecoveryKey(dkey, rkey)
+	if err != nil {
+		return nil, err
+	}
+
+	err = g.store.UpdateGroupEntity(ctx, e)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store recovery kit: %v", err)
+	}
+
+	return shares, nil
+}
+
+// Recover sets a new group password for a group whose password was lost, using enough of the
+// shares of the group's recovery kit to recover the data key.
+func (g GroupService) Recover(ctx context.Context, req *GroupRecoverRequest) error {
+	e, err := g.store.GetGroupEntity(ctx)
+	if err != nil {
+		return fmt.Errorf("store error: %v", err)
+	}
+
+	// The new key of an unfinished data key rotation is encrypted with the lost password
+	err = g.checkNoRekey(ctx)
+	if err != nil {
+		return err
+	}
+
+	shares := make([]crypto.Share, req.SharesLength())
+	for i := range shares {
+		shares[i], err = crypto.ParseShare(string(req.Shares(i)))
+		if err != nil {
+			return fmt.Errorf("failed to read recovery share %d: %v", i+1, err)
+		}
+	}
+
+	rkey, err := crypto.CombineShares(shares)
+	if err != nil {
+		return fmt.Errorf("failed to combine recovery shares: %v", err)
+	}
+
+	dkey, err := e.RecoverDataKey(rkey)
+	if err != nil {
+		return err
+	}
+
+	npass, err := crypto.NewPassword(string(req.NewPassword()))
+	if err != nil {
+		return err
+	}
+
+	slog.Info("Setting new group password from recovery kit")
+	return g.setPassword(ctx, e, npass, dkey)
+}
+
+// CheckPassword reports whether the password is the group password, such as before ending every
+// session for a data key rotation that would fail anyway.
+func (g GroupService) CheckPassword(ctx context.Context, pass crypto.Password) (bool, error) {
+	e, err := g.store.GetGroupEntity(ctx)
+	if err != nil {
+		return false, fmt.Errorf("store error: %v", err)
+	}
+
+	return crypto.CheckPasswordHash(pass, e.PassHash), nil
+}
+
+// CheckRekeyRecoveryKit returns an error if the data key rotation asked for would leave the group
+// without a working recovery kit, or asks for a recovery kit that can't be created.
+func (g GroupService) CheckRekeyRecoveryKit(ctx context.Context, req *GroupRekeyRequest) error {
+	e, err := g.store.GetGroupEntity(ctx)
+	if err != nil {
+		return fmt.Errorf("store error: %v", err)
+	}
+
+	return checkRekeyRecoveryKit(e, req)
+}
+
+func checkRekeyRecoveryKit(e store.GroupEntity, req *GroupRekeyRequest) error {
+	if req.Shares() == 0 && req.Threshold() == 0 {
+		if e.HasRecoveryKit() {
+			return ErrRecoveryKitRequired
+		}
+		return nil
+	}
+
+	return crypto.CheckThreshold(int(req.Shares()), int(req.Threshold()))
+}
+
+// Rekey replaces the group data key with a new random key. Every entity encrypted with the old key
+// is re-encrypted with the new one in batches, each in its own transaction, and the new key is then
+// stored encrypted with the group password in place of the old one.
+//
+// The rotation is recorded before any data is re-encrypted, so if it is interrupted it can be
+// finished by calling Rekey again with the same password. Until it finishes, the group can't be
+// authenticated against, so no one can write data with the old key in the meantime. Every session
+// holds the old key, so they are all ended once the rotation is recorded and logins are blocked.
+//
+// Before a new rotation is recorded, all of the data is checked to decrypt with the old key, so that
+// data that can't be re-encrypted doesn't leave the group locked in a rotation that can't finish.
+//
+// The recovery kit only keeps the data key encrypted with the recovery key, and the recovery key is
+// never stored, so the kit can't be moved to the new data key. A rotation instead replaces the kit
+// with a new one split into the shares asked for, which are returned, and the shares of the old kit
+// stop working. A group with a recovery kit can't be rotated without asking for a new kit.
+func (g GroupService) Rekey(ctx context.Context, req *GroupRekeyRequest) ([]crypto.Share, error) {
+	e, err := g.store.GetGroupEntity(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("store error: %v", err)
+	}
+
+	pass := crypto.Password(req.Password())
+	if !crypto.CheckPasswordHash(pass, e.PassHash) {
+		return nil, fmt.Errorf("incorrect credentials")
+	}
+
+	err = checkRekeyRecoveryKit(e, req)
+	if err != nil {
+		return nil, err
+	}
+
+	pkey, err := crypto.NewDerivedKey(pass, e.PassSalt, e.KdfParams)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive password key: %v", err)
+	}
+	okey, err := crypto.Decrypt(pkey, e.EncryptedKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt group data key: %v", err)
+	}
+
+	var rkey crypto.Key
+	var shares []crypto.Share
+	if req.Shares() != 0 {
+		rkey, err = crypto.NewRandomKey()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create recovery key: %v", err)
+		}
+
+		shares, err = crypto.SplitKey(rkey, int(req.Shares()), int(req.Threshold()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to split recovery key: %v", err)
+		}
+	}
+
+	ok, err := g.rekeys.IsRekeyInProgress(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for data key rotation: %v", err)
+	}
+	if !ok {
+		if _, err := e.Decrypt(okey); err != nil {
+			return nil, fmt.Errorf("failed to decrypt group data: %v", err)
+		}
+		for _, kind := range store.EncryptedEntityKinds {
+			if err := g.checkDecrypts(ctx, kind, okey); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	nkey, err := g.startRekey(ctx, pkey)
+	if err != nil {
+		return nil, err
+	}
+
+	// Ending the sessions only now keeps anyone from logging in with the old key after they are
+	// ended, and leaves them alone if the rotation can't be started
+	err = g.sessions.RemoveAllSessionEntities(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to end sessions: %v", err)
+	}
+
+	for _, kind := range store.EncryptedEntityKinds {
+		err := g.rekeyEntities(ctx, kind, okey, nkey)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Finally, swap the keys and forget about the rotation
+	slog.Info("Replacing group data key")
+	err = g.tx.InTransaction(ctx, func(ctx context.Context) error {
+		data, err := crypto.DecryptAD(okey, e.EncryptedData, e.AssociatedData())
+		if err != nil {
+			return fmt.Errorf("failed to decrypt group data: %v", err)
+		}
+
+		e.EncryptedData, err = crypto.EncryptAD(nkey, data, e.AssociatedData())
+		if err != nil {
+			return fmt.Errorf("failed to encrypt group data: %v", err)
+		}
+
+		e.EncryptedKey, err = crypto.Encrypt(pkey, nkey)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt new data key with password key: %v", err)
+		}
+
+		if rkey != nil {
+			err = e.WrapRecoveryKey(nkey, rkey)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = g.store.UpdateGroupEntity(ctx, e)
+		if err != nil {
+			return fmt.Errorf("failed to update group: %v", err)
+		}
+
+		err = g.rekeys.RemoveRekeyEntity(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to finish data key rotation: %v", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return shares, nil
+}
+
+// startRekey returns the new key of the data key rotation in progress, or starts a new rotation if
+// there isn't one.
+func (g GroupService) startRekey(ctx context.Context, pkey crypto.Key) (crypto.Key, error) {
+	var nkey crypto.Key
+	err := g.tx.InTransaction(ctx, func(ctx context.Context) error {
+		ok, err := g.rekeys.IsRekeyInProgress(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to check for data key rotation: %v", err)
+		}
+
+		if ok {
+			slog.Info("Resuming data key rotation")
+			re, err := g.rekeys.GetRekeyEntity(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to get data key rotation: %v", err)
+			}
+
+			nkey, err = crypto.Decrypt(pkey, re.EncryptedKey)
+			if err != nil {
+				return fmt.Errorf("failed to decrypt new data key: %v", err)
+			}
+
+			return nil
+		}
+
+		slog.Info("Generating new data encryption key for group")
+		nkey, err = crypto.NewRandomKey()
+		if err != nil {
+			return fmt.Errorf("failed to create new data encryption key: %v", err)
+		}
+
+		ekey, err := crypto.Encrypt(pkey, nkey)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt new data key with password key: %v", err)
+		}
+
+		err = g.rekeys.PutRekeyEntity(ctx, store.RekeyEntity{
+			EncryptedKey: ekey, StartedAt: time.Now().UnixMilli(),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to store data key rotation: %v", err)
+		}
+
+		// Member keyslots hold the old key and can't be filled with the new one without the
+		// passwords of the members, so members log in with the group password again to get a new
+		// keyslot. This also keeps members from unlocking the old key while the rotation runs.
+		err = g.members.RemoveMemberKeyslots(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to remove member keyslots: %v", err)
+		}
+
+		// Invitations hold the old key too and can't be given the new one without their codes, so
+		// they are revoked
+		err = g.invitations.RemoveInvitationEntities(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to remove invitations: %v", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return nkey, nil
+}
+
+// checkDecrypts makes sure all of the entities of one kind can be decrypted with the key.
+func (g GroupService) checkDecrypts(ctx context.Context, kind store.EntityKind, key crypto.Key) error {
+	var after int64
+	for {
+		batch, err := g.rekeys.ListEncryptedData(ctx, kind, after, RekeyBatchSize)
+		if err != nil {
+			return fmt.Errorf("failed to list %s data: %v", kind, err)
+		}
+
+		for _, d := range batch {
+			if _, err := crypto.DecryptAD(key, d.Data, d.AssociatedData); err != nil {
+				return fmt.Errorf(
+					"failed to decrypt %s data in row %d; the data key was not rotated: %v",
+					kind, d.Row, err,
+				)
+			}
+		}
+
+		if len(batch) < RekeyBatchSize {
+			return nil
+		}
+		after = batch[len(batch)-1].Row
+	}
+}
+
+// rekeyEntities re-encrypts all of the entities of one kind with the new key. Data that is already
+// encrypted with the new key was re-encrypted before the rotation was interrupted and is left alone.
+func (g GroupService) rekeyEntities(
+	ctx context.Context, kind store.EntityKind, okey, nkey crypto.Key,
+) error {
+	slog.Info("Re-encrypting group data", "kind", kind)
+
+	nid := crypto.NewKeyId(nkey)
+	var after int64
+	for {
+		var batch []store.EncryptedData
+		err := g.tx.InTransaction(ctx, func(ctx context.Context) error {
+			var err error
+			batch, err = g.rekeys.ListEncryptedData(ctx, kind, after, RekeyBatchSize)
+			if err != nil {
+				return fmt.Errorf("failed to list %s data: %v", kind, err)
+			}
+
+			for _, d := range batch {
+				if e, err := crypto.ParseEnvelope(d.Data); err == nil && e.KeyId == nid {
+					continue
+				}
+
+				data, err := crypto.DecryptAD(okey, d.Data, d.AssociatedData)
+				if err != nil {
+					return fmt.Errorf("failed to decrypt %s data: %v", kind, err)
+				}
+
+				edata, err := crypto.EncryptAD(nkey, data, d.AssociatedData)
+				if err != nil {
+					return fmt.Errorf("failed to encrypt %s data: %v", kind, err)
+				}
+
+				err = g.rekeys.UpdateEncryptedData(ctx, kind, d.Row, edata)
+				if err != nil {
+					return fmt.Errorf("failed to store %s data: %v", kind, err)
+				}
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if len(batch) < RekeyBatchSize {
+			return nil
+		}
+		after = batch[len(batch)-1].Row
+	}
+}
+
+func (g GroupService) checkNoRekey(ctx context.Context) error {
+	ok, err := g.rekeys.IsRekeyInProgress(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check for data key rotation: %v", err)
+	}
+	if ok {
+		return fmt.Errorf("group data key rotation in progress; run the rotation again to finish it")
+	}
+
+	return nil
+}
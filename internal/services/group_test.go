@@ -4,8 +4,10 @@
 package services_test
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
+	"errors"
 	"path"
 	"slices"
 	"testing"
@@ -13,6 +15,7 @@ import (
 	"github.com/bradenhc/kolob/internal/crypto"
 	"github.com/bradenhc/kolob/internal/model"
 	"github.com/bradenhc/kolob/internal/services"
+	"github.com/bradenhc/kolob/internal/store"
 	"github.com/bradenhc/kolob/internal/store/sqlite"
 	flatbuffers "github.com/google/flatbuffers/go"
 )
@@ -29,60 +32,137 @@ func TestGroupService(t *testing.T) {
 	}
 	defer db.Close()
 
-	// Setup the store and create the service
+	// Setup the stores and create the service
 	store := doTestGroupCreateStore(t, db)
-	gs := services.NewGroupService(store)
+	mstore := doTestMemberCreateStore(t, db)
+	cstore := doTestConversationCreateStore(t, db)
+	sstore := sqlite.NewSessionStore(db)
+	ikey := doTestIndexKey(t)
+	gs := services.NewGroupService(
+		store, mstore, cstore, sqlite.NewInvitationStore(db), sstore, sqlite.NewRekeyStore(db),
+		sqlite.NewTransactor(db), ikey,
+	)
 
 	// Create a group and test
 	ctx := context.Background()
+	doTestGroupCreateWithoutCreator(t, ctx, gs)
 	a := doTestGroupCreate(t, ctx, gs)
 
 	// Authenticate to get the symmetric key
 	dkey := doTestGroupAuth(t, ctx, gs)
 
+	// The group ID can't be checked without the index key
+	doTestGroupAuthIndexKey(t, ctx, db)
+
+	// Groups using older key derivation parameters are upgraded when they are unlocked
+	doTestGroupKdfUpgrade(t, ctx, gs, store, dkey)
+
+	// The group should have been created with its creator and a General conversation
+	doTestGroupCreator(t, ctx, mstore, ikey, dkey, a.Creator)
+	doTestGroupGeneralConversation(t, ctx, cstore, dkey, a.Creator)
+
 	// Access encrypted group information
-	b := doTestGroupGetInfo(t, ctx, gs, dkey, a)
+	b := doTestGroupGetInfo(t, ctx, gs, dkey, a.Group)
 
 	// Update group information
 	c := doTestGroupUpdate(t, ctx, gs, dkey, b)
 
 	// Change the group password and make sure we can still authenticate and access the group
 	doTestGroupChangePassword(t, ctx, gs, dkey, c)
+
+	// Create a recovery kit, which should be replaced when the data key is rotated
+	oshares := doTestGroupRecoveryKit(t, ctx, gs)
+
+	// Rotate the data key, both after an interrupted rotation and from scratch. Sessions are only
+	// ended once a rotation is actually started.
+	sid := doTestGroupAddSession(t, ctx, sstore, a.Creator, dkey)
+	doTestGroupCheckPassword(t, ctx, gs)
+	doTestGroupRekeyRecoveryKitRequired(t, ctx, gs)
+	doTestGroupRekeyUndecryptable(t, ctx, gs, sqlite.NewRekeyStore(db))
+	if _, err := sstore.GetSessionEntity(ctx, sid); err != nil {
+		t.Errorf("refused rekey ended a session: %v", err)
+	}
+	doTestGroupRekeyResume(t, ctx, gs, store, sqlite.NewRekeyStore(db), mstore, ikey, a.Creator)
+	shares := doTestGroupRekey(t, ctx, gs, mstore, cstore, ikey, a.Creator)
+	if _, err := sstore.GetSessionEntity(ctx, sid); err == nil {
+		t.Errorf("session outlived the rekey")
+	}
+
+	// Forget the password and set a new one with the recovery kit, which only the new kit can do
+	doTestGroupRecoverReplaced(t, ctx, gs, oshares)
+	doTestGroupRecover(t, ctx, gs, shares)
 }
 
-func doTestGroupCreateStore(t *testing.T, db *sql.DB) sqlite.GroupStore {
-	// Create our group store
-	//
-	store, err := sqlite.NewGroupStore(db)
+func doTestIndexKey(t *testing.T) crypto.IndexKey {
+	ikey, err := crypto.NewIndexKey()
 	if err != nil {
-		t.Fatalf("failed to create group store: %v", err)
+		t.Fatalf("failed to create index key: %v", err)
 	}
 
-	return store
+	return ikey
+}
+
+func doTestGroupCreateStore(t *testing.T, db *sql.DB) sqlite.GroupStore {
+	// Create our group store
+	//
+	return sqlite.NewGroupStore(db)
 }
 
-func doTestGroupCreate(t *testing.T, ctx context.Context, gs services.GroupService) *model.Group {
+func doTestGroupCreateWithoutCreator(
+	t *testing.T, ctx context.Context, gs services.GroupService,
+) {
 	builder := flatbuffers.NewBuilder(64)
 	offsetGid := builder.CreateString("TestGroup123")
 	offsetName := builder.CreateString("Test Group")
+	offsetPass := builder.CreateString("Password12345678!")
+	services.GroupInitRequestStart(builder)
+	services.GroupInitRequestAddGroupId(builder, offsetGid)
+	services.GroupInitRequestAddName(builder, offsetName)
+	services.GroupInitRequestAddPassword(builder, offsetPass)
+
+	offsetReq := services.GroupInitRequestEnd(builder)
+	builder.Finish(offsetReq)
+
+	reqCreate := services.GetRootAsGroupInitRequest(builder.FinishedBytes(), 0)
+
+	_, err := gs.Create(ctx, reqCreate)
+	if err == nil {
+		t.Fatalf("expected group creation without a creator to fail")
+	}
+}
+
+func doTestGroupCreate(
+	t *testing.T, ctx context.Context, gs services.GroupService,
+) *services.InitializedGroup {
+	builder := flatbuffers.NewBuilder(128)
+	offsetGid := builder.CreateString("TestGroup123")
+	offsetName := builder.CreateString("Test Group")
 	offsetDesc := builder.CreateString("A test group")
 	offsetPass := builder.CreateString("Password12345678!")
+	offsetCuname := builder.CreateString("creator")
+	offsetCname := builder.CreateString("Group Creator")
+	offsetCpass := builder.CreateString("CreatorPassword123!")
 	services.GroupInitRequestStart(builder)
 	services.GroupInitRequestAddGroupId(builder, offsetGid)
 	services.GroupInitRequestAddName(builder, offsetName)
 	services.GroupInitRequestAddDescription(builder, offsetDesc)
 	services.GroupInitRequestAddPassword(builder, offsetPass)
+	services.GroupInitRequestAddCreatorUsername(builder, offsetCuname)
+	services.GroupInitRequestAddCreatorName(builder, offsetCname)
+	services.GroupInitRequestAddCreatorPassword(builder, offsetCpass)
 
 	offsetReq := services.GroupInitRequestEnd(builder)
 	builder.Finish(offsetReq)
 
 	reqCreate := services.GetRootAsGroupInitRequest(builder.FinishedBytes(), 0)
 
-	group, err := gs.Create(ctx, reqCreate)
+	init, err := gs.Create(ctx, reqCreate)
 	if err != nil {
 		t.Fatalf("failed to create group: %v", err)
 	}
 
+	group, creator := init.Group, init.Creator
+
 	if !slices.Equal(group.Gid(), reqCreate.GroupId()) {
 		t.Errorf("%s != %s", string(group.Gid()), string(reqCreate.GroupId()))
 	}
@@ -92,8 +172,71 @@ func doTestGroupCreate(t *testing.T, ctx context.Context, gs services.GroupServi
 	if !slices.Equal(group.Desc(), reqCreate.Description()) {
 		t.Errorf("%s != %s", string(group.Desc()), string(reqCreate.Description()))
 	}
+	if !slices.Equal(creator.Uname(), reqCreate.CreatorUsername()) {
+		t.Errorf("%s != %s", string(creator.Uname()), string(reqCreate.CreatorUsername()))
+	}
+	if !slices.Equal(creator.Name(), reqCreate.CreatorName()) {
+		t.Errorf("%s != %s", string(creator.Name()), string(reqCreate.CreatorName()))
+	}
+
+	return init
+}
+
+func doTestGroupCreator(
+	t *testing.T,
+	ctx context.Context,
+	mstore store.MemberStore,
+	ikey crypto.IndexKey,
+	dkey crypto.Key,
+	creator *model.Member,
+) {
+	e, err := mstore.GetMemberEntityByUname(ctx, crypto.BlindIndex(ikey, creator.Uname()))
+	if err != nil {
+		t.Fatalf("failed to get group creator: %v", err)
+	}
+
+	m, err := e.Decrypt(dkey)
+	if err != nil {
+		t.Fatalf("failed to decrypt group creator: %v", err)
+	}
+	if !model.MemberEqual(m, creator) {
+		t.Errorf("stored creator is not what was expected: %+v != %+v", m, creator)
+	}
+	if m.Role() != model.RoleGroupModerator {
+		t.Errorf("%v != %v", m.Role(), model.RoleGroupModerator)
+	}
+}
+
+func doTestGroupGeneralConversation(
+	t *testing.T,
+	ctx context.Context,
+	cstore store.ConversationStore,
+	dkey crypto.Key,
+	creator *model.Member,
+) {
+	es, err := cstore.ListConversationEntities(ctx)
+	if err != nil {
+		t.Fatalf("failed to list conversations: %v", err)
+	}
+	if len(es) != 1 {
+		t.Fatalf("wrong number of conversations after group create: %d != %d", len(es), 1)
+	}
+
+	c, err := es[0].Decrypt(dkey)
+	if err != nil {
+		t.Fatalf("failed to decrypt general conversation: %v", err)
+	}
+	if string(c.Name()) != services.GeneralConversationName {
+		t.Errorf("%s != %s", string(c.Name()), services.GeneralConversationName)
+	}
 
-	return group
+	id := model.Uuid(creator.Id())
+	if c.ModsLength() != 1 || model.Uuid(c.Mods(0)) != id {
+		t.Errorf("group creator is not the moderator of the general conversation")
+	}
+	if !c.IsParticipant(id) {
+		t.Errorf("group creator does not participate in the general conversation")
+	}
 }
 
 func doTestGroupAuth(t *testing.T, ctx context.Context, gs services.GroupService) crypto.Key {
@@ -115,6 +258,33 @@ func doTestGroupAuth(t *testing.T, ctx context.Context, gs services.GroupService
 	return dkey
 }
 
+func doTestGroupAuthIndexKey(t *testing.T, ctx context.Context, db *sql.DB) {
+	gs := services.NewGroupService(
+		sqlite.NewGroupStore(db),
+		sqlite.NewMemberStore(db),
+		sqlite.NewConversationStore(db),
+		sqlite.NewInvitationStore(db),
+		sqlite.NewSessionStore(db),
+		sqlite.NewRekeyStore(db),
+		sqlite.NewTransactor(db),
+		doTestIndexKey(t),
+	)
+
+	builder := flatbuffers.NewBuilder(64)
+	offsetGid := builder.CreateString("TestGroup123")
+	offsetPass := builder.CreateString("Password12345678!")
+	services.GroupAuthenticateRequestStart(builder)
+	services.GroupAuthenticateRequestAddGroupId(builder, offsetGid)
+	services.GroupAuthenticateRequestAddPassword(builder, offsetPass)
+	builder.Finish(services.GroupAuthenticateRequestEnd(builder))
+
+	req := services.GetRootAsGroupAuthenticateRequest(builder.FinishedBytes(), 0)
+	_, err := gs.Authenticate(ctx, req)
+	if err == nil {
+		t.Errorf("group authenticated with a different index key")
+	}
+}
+
 func doTestGroupGetInfo(
 	t *testing.T, ctx context.Context, gs services.GroupService, dkey crypto.Key, a *model.Group,
 ) *model.Group {
@@ -212,3 +382,444 @@ func doTestGroupChangePassword(
 		t.Errorf("updated times should be different after password upadte")
 	}
 }
+
+func doTestGroupAuthUpdated(
+	t *testing.T, ctx context.Context, gs services.GroupService,
+) (crypto.Key, error) {
+	builder := flatbuffers.NewBuilder(64)
+	offsetGid := builder.CreateString("TestGroup456")
+	offsetPass := builder.CreateString("UpdatedPassword123456!")
+	services.GroupAuthenticateRequestStart(builder)
+	services.GroupAuthenticateRequestAddGroupId(builder, offsetGid)
+	services.GroupAuthenticateRequestAddPassword(builder, offsetPass)
+	builder.Finish(services.GroupAuthenticateRequestEnd(builder))
+
+	reqAuth := services.GetRootAsGroupAuthenticateRequest(builder.FinishedBytes(), 0)
+	return gs.Authenticate(ctx, reqAuth)
+}
+
+func doTestGroupRecoveryKit(
+	t *testing.T, ctx context.Context, gs services.GroupService,
+) []crypto.Share {
+	builder := flatbuffers.NewBuilder(64)
+	offsetPass := builder.CreateString("UpdatedPassword123456!")
+	services.GroupRecoveryKitRequestStart(builder)
+	services.GroupRecoveryKitRequestAddPassword(builder, offsetPass)
+	services.GroupRecoveryKitRequestAddShares(builder, 3)
+	services.GroupRecoveryKitRequestAddThreshold(builder, 2)
+	builder.Finish(services.GroupRecoveryKitRequestEnd(builder))
+
+	req := services.GetRootAsGroupRecoveryKitRequest(builder.FinishedBytes(), 0)
+	shares, err := gs.CreateRecoveryKit(ctx, req)
+	if err != nil {
+		t.Fatalf("failed to create recovery kit: %v", err)
+	}
+	if len(shares) != 3 {
+		t.Fatalf("expected three recovery shares, got %d", len(shares))
+	}
+
+	return shares
+}
+
+func doTestGroupRecoverRequest(shares []crypto.Share, npass string) *services.GroupRecoverRequest {
+	builder := flatbuffers.NewBuilder(256)
+	offsets := make([]flatbuffers.UOffsetT, len(shares))
+	for i, s := range shares {
+		offsets[i] = builder.CreateString(s.String())
+	}
+	services.GroupRecoverRequestStartSharesVector(builder, len(offsets))
+	for i := len(offsets) - 1; i >= 0; i-- {
+		builder.PrependUOffsetT(offsets[i])
+	}
+	offsetShares := builder.EndVector(len(offsets))
+	offsetPass := builder.CreateString(npass)
+	services.GroupRecoverRequestStart(builder)
+	services.GroupRecoverRequestAddShares(builder, offsetShares)
+	services.GroupRecoverRequestAddNewPassword(builder, offsetPass)
+	builder.Finish(services.GroupRecoverRequestEnd(builder))
+
+	return services.GetRootAsGroupRecoverRequest(builder.FinishedBytes(), 0)
+}
+
+func doTestGroupRecover(
+	t *testing.T, ctx context.Context, gs services.GroupService, shares []crypto.Share,
+) {
+	okey, err := doTestGroupAuthUpdated(t, ctx, gs)
+	if err != nil {
+		t.Fatalf("failed to auth group: %v", err)
+	}
+
+	npass := "RecoveredPassword123456!"
+
+	// One share isn't enough
+	err = gs.Recover(ctx, doTestGroupRecoverRequest(shares[:1], npass))
+	if err == nil {
+		t.Fatalf("recovered group with too few shares")
+	}
+
+	// Shares from another kit don't recover the group
+	other, _ := crypto.NewRandomKey()
+	wrong, err := crypto.SplitKey(other, 3, 2)
+	if err != nil {
+		t.Fatalf("failed to split key: %v", err)
+	}
+	err = gs.Recover(ctx, doTestGroupRecoverRequest(wrong[1:], npass))
+	if err == nil {
+		t.Fatalf("recovered group with the shares of another kit")
+	}
+
+	// Any two of the right shares do
+	err = gs.Recover(ctx, doTestGroupRecoverRequest([]crypto.Share{shares[2], shares[0]}, npass))
+	if err != nil {
+		t.Fatalf("failed to recover group: %v", err)
+	}
+
+	_, err = doTestGroupAuthUpdated(t, ctx, gs)
+	if err == nil {
+		t.Errorf("group authenticated with the password replaced by recovery")
+	}
+
+	builder := flatbuffers.NewBuilder(64)
+	offsetGid := builder.CreateString("TestGroup456")
+	offsetPass := builder.CreateString(npass)
+	services.GroupAuthenticateRequestStart(builder)
+	services.GroupAuthenticateRequestAddGroupId(builder, offsetGid)
+	services.GroupAuthenticateRequestAddPassword(builder, offsetPass)
+	builder.Finish(services.GroupAuthenticateRequestEnd(builder))
+
+	reqAuth := services.GetRootAsGroupAuthenticateRequest(builder.FinishedBytes(), 0)
+	nkey, err := gs.Authenticate(ctx, reqAuth)
+	if err != nil {
+		t.Fatalf("failed to auth group with recovered password: %v", err)
+	}
+	if !bytes.Equal(nkey, okey) {
+		t.Errorf("recovery changed the data key")
+	}
+}
+
+func doTestGroupRecoverReplaced(
+	t *testing.T, ctx context.Context, gs services.GroupService, shares []crypto.Share,
+) {
+	err := gs.Recover(ctx, doTestGroupRecoverRequest(shares[:2], "RecoveredPassword123456!"))
+	if err == nil {
+		t.Fatalf("recovered group with the shares of a kit replaced by rekey")
+	}
+
+	if _, err := doTestGroupAuthUpdated(t, ctx, gs); err != nil {
+		t.Errorf("failed to auth group after refused recovery: %v", err)
+	}
+}
+
+func doTestGroupRekeyBuildRequest(shares, threshold byte) *services.GroupRekeyRequest {
+	builder := flatbuffers.NewBuilder(64)
+	offsetPass := builder.CreateString("UpdatedPassword123456!")
+	services.GroupRekeyRequestStart(builder)
+	services.GroupRekeyRequestAddPassword(builder, offsetPass)
+	services.GroupRekeyRequestAddShares(builder, shares)
+	services.GroupRekeyRequestAddThreshold(builder, threshold)
+	builder.Finish(services.GroupRekeyRequestEnd(builder))
+
+	return services.GetRootAsGroupRekeyRequest(builder.FinishedBytes(), 0)
+}
+
+func doTestGroupAddSession(
+	t *testing.T, ctx context.Context, sstore store.SessionStore, member *model.Member, key crypto.Key,
+) model.Uuid {
+	id, err := model.NewUuid()
+	if err != nil {
+		t.Fatalf("failed to create session ID: %v", err)
+	}
+	skey, err := crypto.NewRandomKey()
+	if err != nil {
+		t.Fatalf("failed to create session key: %v", err)
+	}
+
+	e, err := store.NewSessionEntity(
+		model.NewSession(id, model.Uuid(member.Id()), "test-agent", "127.0.0.1", 1, 1), key, skey,
+	)
+	if err != nil {
+		t.Fatalf("failed to create session entity: %v", err)
+	}
+	err = sstore.AddSessionEntity(ctx, e)
+	if err != nil {
+		t.Fatalf("failed to add session entity: %v", err)
+	}
+
+	return id
+}
+
+func doTestGroupRekeyRequest(
+	t *testing.T, ctx context.Context, gs services.GroupService,
+) []crypto.Share {
+	shares, err := gs.Rekey(ctx, doTestGroupRekeyBuildRequest(3, 2))
+	if err != nil {
+		t.Fatalf("failed to rekey group: %v", err)
+	}
+	if len(shares) != 3 {
+		t.Fatalf("expected 3 recovery shares, got %d", len(shares))
+	}
+
+	return shares
+}
+
+func doTestGroupRekeyRecoveryKitRequired(
+	t *testing.T, ctx context.Context, gs services.GroupService,
+) {
+	okey, err := doTestGroupAuthUpdated(t, ctx, gs)
+	if err != nil {
+		t.Fatalf("failed to auth group: %v", err)
+	}
+
+	// The group has a recovery kit, so rotating without asking for a new one is refused
+	req := doTestGroupRekeyBuildRequest(0, 0)
+	err = gs.CheckRekeyRecoveryKit(ctx, req)
+	if !errors.Is(err, services.ErrRecoveryKitRequired) {
+		t.Errorf("expected rekey check to require a recovery kit: %v", err)
+	}
+	_, err = gs.Rekey(ctx, req)
+	if !errors.Is(err, services.ErrRecoveryKitRequired) {
+		t.Fatalf("expected rekey to require a recovery kit: %v", err)
+	}
+
+	// So is asking for a kit that can't be made
+	_, err = gs.Rekey(ctx, doTestGroupRekeyBuildRequest(2, 3))
+	if err == nil {
+		t.Fatalf("expected rekey to fail with a threshold above the number of shares")
+	}
+
+	nkey, err := doTestGroupAuthUpdated(t, ctx, gs)
+	if err != nil {
+		t.Fatalf("failed to auth group after refused rekey: %v", err)
+	}
+	if !slices.Equal(okey, nkey) {
+		t.Errorf("refused rekey replaced the data key")
+	}
+}
+
+func doTestGroupCheckPassword(t *testing.T, ctx context.Context, gs services.GroupService) {
+	ok, err := gs.CheckPassword(ctx, "UpdatedPassword123456!")
+	if err != nil || !ok {
+		t.Errorf("group password was not accepted: %v", err)
+	}
+
+	ok, err = gs.CheckPassword(ctx, "Password12345678!")
+	if err != nil || ok {
+		t.Errorf("old group password was accepted: %v", err)
+	}
+}
+
+func doTestGroupRekeyUndecryptable(
+	t *testing.T, ctx context.Context, gs services.GroupService, rstore store.RekeyStore,
+) {
+	// Replace the data of a member with data encrypted with some other key
+	ds, err := rstore.ListEncryptedData(ctx, store.MemberEntityKind, 0, 1)
+	if err != nil || len(ds) != 1 {
+		t.Fatalf("failed to list member data: %v", err)
+	}
+	other, err := crypto.NewRandomKey()
+	if err != nil {
+		t.Fatalf("failed to create random key: %v", err)
+	}
+	bad, err := crypto.EncryptAD(other, []byte("garbage"), ds[0].AssociatedData)
+	if err != nil {
+		t.Fatalf("failed to encrypt member data: %v", err)
+	}
+	err = rstore.UpdateEncryptedData(ctx, store.MemberEntityKind, ds[0].Row, bad)
+	if err != nil {
+		t.Fatalf("failed to update member data: %v", err)
+	}
+
+	_, err = gs.Rekey(ctx, doTestGroupRekeyBuildRequest(3, 2))
+	if err == nil {
+		t.Fatalf("expected rekey to fail with data that can't be decrypted")
+	}
+
+	// The rotation should never have started, so the group can still be unlocked
+	if _, err := doTestGroupAuthUpdated(t, ctx, gs); err != nil {
+		t.Errorf("failed to auth group after refused rekey: %v", err)
+	}
+
+	err = rstore.UpdateEncryptedData(ctx, store.MemberEntityKind, ds[0].Row, ds[0].Data)
+	if err != nil {
+		t.Fatalf("failed to restore member data: %v", err)
+	}
+}
+
+func doTestGroupRekeyResume(
+	t *testing.T,
+	ctx context.Context,
+	gs services.GroupService,
+	gstore store.GroupStore,
+	rstore store.RekeyStore,
+	mstore store.MemberStore,
+	ikey crypto.IndexKey,
+	creator *model.Member,
+) {
+	okey, err := doTestGroupAuthUpdated(t, ctx, gs)
+	if err != nil {
+		t.Fatalf("failed to auth group: %v", err)
+	}
+
+	// Leave a rotation behind as if it had been interrupted after re-encrypting the first member
+	nkey, err := crypto.NewRandomKey()
+	if err != nil {
+		t.Fatalf("failed to create random key: %v", err)
+	}
+	ge, err := gstore.GetGroupEntity(ctx)
+	if err != nil {
+		t.Fatalf("failed to get group entity: %v", err)
+	}
+	pkey, err := crypto.NewDerivedKey(
+		crypto.Password("UpdatedPassword123456!"), ge.PassSalt, ge.KdfParams,
+	)
+	if err != nil {
+		t.Fatalf("failed to derive password key: %v", err)
+	}
+	ekey, err := crypto.Encrypt(pkey, nkey)
+	if err != nil {
+		t.Fatalf("failed to encrypt new key: %v", err)
+	}
+	err = rstore.PutRekeyEntity(ctx, store.RekeyEntity{EncryptedKey: ekey, StartedAt: 1})
+	if err != nil {
+		t.Fatalf("failed to store rekey entity: %v", err)
+	}
+
+	ds, err := rstore.ListEncryptedData(ctx, store.MemberEntityKind, 0, 1)
+	if err != nil || len(ds) != 1 {
+		t.Fatalf("failed to list member data: %v", err)
+	}
+	data, err := crypto.DecryptAD(okey, ds[0].Data, ds[0].AssociatedData)
+	if err != nil {
+		t.Fatalf("failed to decrypt member data: %v", err)
+	}
+	edata, err := crypto.EncryptAD(nkey, data, ds[0].AssociatedData)
+	if err != nil {
+		t.Fatalf("failed to encrypt member data: %v", err)
+	}
+	err = rstore.UpdateEncryptedData(ctx, store.MemberEntityKind, ds[0].Row, edata)
+	if err != nil {
+		t.Fatalf("failed to update member data: %v", err)
+	}
+
+	// The group can't be unlocked until the rotation is finished
+	_, err = doTestGroupAuthUpdated(t, ctx, gs)
+	if err == nil {
+		t.Fatalf("expected auth to fail during data key rotation")
+	}
+
+	doTestGroupRekeyRequest(t, ctx, gs)
+
+	// The rotation should have finished with the key it was started with
+	key, err := doTestGroupAuthUpdated(t, ctx, gs)
+	if err != nil {
+		t.Fatalf("failed to auth group after rekey: %v", err)
+	}
+	if !slices.Equal(key, nkey) {
+		t.Errorf("resumed rekey did not use the new key of the interrupted rotation")
+	}
+
+	doTestGroupCreator(t, ctx, mstore, ikey, nkey, creator)
+	if _, err := gs.Get(ctx, nkey); err != nil {
+		t.Errorf("failed to get group info after rekey: %v", err)
+	}
+}
+
+func doTestGroupRekey(
+	t *testing.T,
+	ctx context.Context,
+	gs services.GroupService,
+	mstore store.MemberStore,
+	cstore store.ConversationStore,
+	ikey crypto.IndexKey,
+	creator *model.Member,
+) []crypto.Share {
+	okey, err := doTestGroupAuthUpdated(t, ctx, gs)
+	if err != nil {
+		t.Fatalf("failed to auth group: %v", err)
+	}
+
+	shares := doTestGroupRekeyRequest(t, ctx, gs)
+
+	nkey, err := doTestGroupAuthUpdated(t, ctx, gs)
+	if err != nil {
+		t.Fatalf("failed to auth group after rekey: %v", err)
+	}
+	if slices.Equal(okey, nkey) {
+		t.Fatalf("group data key was not replaced")
+	}
+
+	// All of the data should be readable with the new key and only the new key
+	doTestGroupCreator(t, ctx, mstore, ikey, nkey, creator)
+	doTestGroupGeneralConversation(t, ctx, cstore, nkey, creator)
+	if _, err := gs.Get(ctx, nkey); err != nil {
+		t.Errorf("failed to get group info after rekey: %v", err)
+	}
+	if _, err := gs.Get(ctx, okey); err == nil {
+		t.Errorf("group info can still be read with the old key")
+	}
+
+	es, err := cstore.ListConversationEntities(ctx)
+	if err != nil {
+		t.Fatalf("failed to list conversations: %v", err)
+	}
+	for _, e := range es {
+		if _, err := e.Decrypt(okey); err == nil {
+			t.Errorf("conversation can still be read with the old key")
+		}
+	}
+
+	return shares
+}
+
+func doTestGroupKdfUpgrade(
+	t *testing.T,
+	ctx context.Context,
+	gs services.GroupService,
+	gstore store.GroupStore,
+	dkey crypto.Key,
+) {
+	// Wrap the data key the way it was done before key derivation parameters were recorded
+	e, err := gstore.GetGroupEntity(ctx)
+	if err != nil {
+		t.Fatalf("failed to get group entity: %v", err)
+	}
+	if e.KdfParams != crypto.DefaultKdfParams {
+		t.Errorf("new group does not use default key derivation: %v", e.KdfParams)
+	}
+
+	pkey, err := crypto.NewDerivedKey("Password12345678!", e.PassSalt, crypto.LegacyKdfParams)
+	if err != nil {
+		t.Fatalf("failed to derive password key: %v", err)
+	}
+	e.EncryptedKey, err = crypto.Encrypt(pkey, dkey)
+	if err != nil {
+		t.Fatalf("failed to encrypt data key: %v", err)
+	}
+	e.KdfParams = crypto.LegacyKdfParams
+	err = gstore.UpdateGroupEntity(ctx, e)
+	if err != nil {
+		t.Fatalf("failed to update group entity: %v", err)
+	}
+
+	// Unlocking the group should give the same key and upgrade the key derivation
+	key := doTestGroupAuth(t, ctx, gs)
+	if !slices.Equal(key, dkey) {
+		t.Fatalf("unlocking a legacy group gave the wrong key")
+	}
+
+	upgraded, err := gstore.GetGroupEntity(ctx)
+	if err != nil {
+		t.Fatalf("failed to get group entity: %v", err)
+	}
+	if upgraded.KdfParams != crypto.DefaultKdfParams {
+		t.Errorf("%v != %v", upgraded.KdfParams, crypto.DefaultKdfParams)
+	}
+	if slices.Equal(upgraded.PassSalt, e.PassSalt) {
+		t.Errorf("salt was not replaced when key derivation was upgraded")
+	}
+
+	key = doTestGroupAuth(t, ctx, gs)
+	if !slices.Equal(key, dkey) {
+		t.Errorf("unlocking an upgraded group gave the wrong key")
+	}
+}
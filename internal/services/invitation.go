@@ -0,0 +1,295 @@
+// ---------------------------------------------------------------------------------------------- //
+// -- Copyright (c) 2024 Braden Hitchcock - MIT License (https://opensource.org/licenses/MIT)  -- //
+// ---------------------------------------------------------------------------------------------- //
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/bradenhc/kolob/internal/crypto"
+	"github.com/bradenhc/kolob/internal/events"
+	"github.com/bradenhc/kolob/internal/model"
+	"github.com/bradenhc/kolob/internal/store"
+)
+
+// ErrInvalidInvitation is returned when redeeming a code that doesn't belong to an invitation that
+// can still be redeemed. Why is deliberately left out, so that codes can't be probed.
+var ErrInvalidInvitation = errors.New("invalid or expired invitation code")
+
+const (
+	// DefaultInvitationTTL is how long an invitation lasts unless another time is asked for.
+	DefaultInvitationTTL = 72 * time.Hour
+
+	// MaxInvitationTTL is the longest an invitation can last. Codes are short enough to read out,
+	// so they shouldn't be left around to be guessed for long.
+	MaxInvitationTTL = 30 * 24 * time.Hour
+)
+
+// An InvitationService lets a Group Moderator invite someone to join the group with a single use
+// code, so that the new member picks their own username and password when they redeem it.
+type InvitationService struct {
+	store         store.InvitationStore
+	members       store.MemberStore
+	conversations store.ConversationStore
+	rekeys        store.RekeyStore
+	tx            store.Transactor
+	events        events.Publisher
+	ikey          crypto.IndexKey
+}
+
+func NewInvitationService(
+	store store.InvitationStore, members store.MemberStore, conversations store.ConversationStore,
+	rekeys store.RekeyStore, tx store.Transactor, events events.Publisher, ikey crypto.IndexKey,
+) InvitationService {
+	return InvitationService{store, members, conversations, rekeys, tx, events, ikey}
+}
+
+// Create creates an invitation from the creator and returns it along with its code. The code is
+// only ever returned here.
+func (s *InvitationService) Create(
+	ctx context.Context, req *InvitationCreateRequest, key crypto.Key, creator model.Uuid,
+) (*model.Invitation, string, error) {
+	// Only the roles that apply to the whole group can be given to a member
+	role := model.Role(req.Role())
+	if role != model.RoleUser && role != model.RoleGroupModerator {
+		return nil, "", fmt.Errorf("invalid member role: %v", role)
+	}
+
+	ttl := time.Duration(req.Ttl()) * time.Second
+	if ttl == 0 {
+		ttl = DefaultInvitationTTL
+	}
+	if ttl < 0 || ttl > MaxInvitationTTL {
+		return nil, "", fmt.Errorf("invitations must last between 1 second and %v", MaxInvitationTTL)
+	}
+
+	// The new member can only be added to conversations that exist
+	conversations := make([]model.Uuid, 0, req.ConversationsLength())
+	for i := range req.ConversationsLength() {
+		id := model.Uuid(req.Conversations(i))
+		entity, err := s.conversations.GetConversationEntity(ctx, id)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to get conversation %s: %v", id, err)
+		}
+		_, err = entity.Decrypt(key)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to decrypt conversation %s: %v", id, err)
+		}
+		conversations = append(conversations, id)
+	}
+
+	code, err := crypto.NewInvitationCode()
+	if err != nil {
+		return nil, "", err
+	}
+
+	inv, err := model.NewInvitation(creator, role, conversations, ttl)
+	if err != nil {
+		return nil, "", err
+	}
+
+	entity, err := store.NewInvitationEntity(inv, code, key, s.ikey)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create new invitation entity: %v", err)
+	}
+
+	err = s.store.AddInvitationEntity(ctx, entity)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to store invitation: %v", err)
+	}
+
+	return inv, code, nil
+}
+
+// List returns the invitations that haven't been redeemed and haven't expired, oldest first.
+// Invitations created before the data key was rotated can't be redeemed anymore, so they are
+// removed instead.
+func (s *InvitationService) List(ctx context.Context, key crypto.Key) ([]*model.Invitation, error) {
+	es, err := s.store.ListInvitationEntities(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invitation list: %v", err)
+	}
+
+	now := time.Now().UnixMilli()
+	invs := make([]*model.Invitation, 0, len(es))
+	for _, e := range es {
+		if e.ExpiresAt <= now {
+			continue
+		}
+
+		inv, err := e.Decrypt(key)
+		if err != nil {
+			slog.Info("Removing invitation created with a previous data key", "id", e.Id)
+			err = s.store.RemoveInvitationEntity(ctx, e.Id)
+			if err != nil && !errors.Is(err, store.ErrInvitationNotFound) {
+				return nil, fmt.Errorf("failed to remove invitation: %v", err)
+			}
+			continue
+		}
+		invs = append(invs, inv)
+	}
+
+	return invs, nil
+}
+
+// Remove revokes an invitation so that its code can't be redeemed.
+func (s *InvitationService) Remove(ctx context.Context, req *InvitationRemoveRequest) error {
+	err := s.store.RemoveInvitationEntity(ctx, model.Uuid(req.Id()))
+	if errors.Is(err, store.ErrInvitationNotFound) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("failed to remove invitation: %v", err)
+	}
+
+	return nil
+}
+
+// Redeem uses up the invitation with the code in the request to add a member with the username,
+// name, and password in the request. The member gets the role of the invitation and joins those of
+// its conversations that still exist. If the member can't be added, such as when the username is
+// taken, the invitation can still be redeemed.
+func (s *InvitationService) Redeem(
+	ctx context.Context, req *InvitationRedeemRequest,
+) (*model.Member, error) {
+	code := crypto.NormalizeInvitationCode(string(req.Code()))
+	entity, err := s.store.GetInvitationEntityByCode(ctx, crypto.BlindIndex(s.ikey, []byte(code)))
+	if errors.Is(err, store.ErrInvitationNotFound) {
+		return nil, ErrInvalidInvitation
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invitation: %v", err)
+	}
+
+	if entity.ExpiresAt <= time.Now().UnixMilli() {
+		return nil, ErrInvalidInvitation
+	}
+
+	key, err := entity.UnwrapKey(code)
+	if err != nil {
+		return nil, ErrInvalidInvitation
+	}
+	inv, err := entity.Decrypt(key)
+	if err != nil {
+		// The data key was rotated after the invitation was created
+		return nil, ErrInvalidInvitation
+	}
+
+	// Create the member before starting the transaction, since hashing the password is slow
+	upass, err := crypto.NewPassword(string(req.Password()))
+	if err != nil {
+		return nil, fmt.Errorf("password validation failed: %w", err)
+	}
+	m, err := model.NewMember(string(req.Username()), string(req.Name()), inv.Role())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new member: %v", err)
+	}
+	member, err := store.NewMemberEntity(m, upass, key, s.ikey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new member entity: %v", err)
+	}
+
+	var joined []*model.Conversation
+	err = s.tx.InTransaction(ctx, func(ctx context.Context) error {
+		joined = nil
+
+		// Invitations are revoked when a data key rotation starts, but the member must not be
+		// written with the old key while one runs either way
+		ok, err := s.rekeys.IsRekeyInProgress(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to check for data key rotation: %v", err)
+		}
+		if ok {
+			return ErrInvalidInvitation
+		}
+
+		// Only one of several attempts to redeem the same code can remove the invitation
+		err = s.store.RemoveInvitationEntity(ctx, entity.Id)
+		if errors.Is(err, store.ErrInvitationNotFound) {
+			return ErrInvalidInvitation
+		}
+		if err != nil {
+			return fmt.Errorf("failed to remove redeemed invitation: %v", err)
+		}
+
+		err = s.members.AddMemberEntity(ctx, member)
+		if err != nil {
+			return fmt.Errorf("failed to store member: %v", err)
+		}
+
+		for i := range inv.ConversationsLength() {
+			c, err := s.join(ctx, model.Uuid(inv.Conversations(i)), m, key)
+			if err != nil {
+				return err
+			}
+			if c != nil {
+				joined = append(joined, c)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.events.Publish(events.Event{Type: events.MemberCreated, Data: m})
+	for _, c := range joined {
+		s.events.Publish(events.Event{
+			Type: events.ConversationUpdated, Data: c, Conversation: string(c.Id()),
+		})
+	}
+
+	return m, nil
+}
+
+// Sweep removes the invitations that have expired and returns how many there were.
+func (s *InvitationService) Sweep(ctx context.Context) (int, error) {
+	return s.store.RemoveExpiredInvitationEntities(ctx, time.Now().UnixMilli())
+}
+
+// StartSweeper sweeps expired invitations from the store every interval until the context is done.
+func (s *InvitationService) StartSweeper(ctx context.Context, interval time.Duration) {
+	startSweeper(ctx, interval, "expired invitations", s.Sweep)
+}
+
+// join adds the new member to a conversation of the invitation and returns the updated
+// conversation, or nil if the conversation can't be found, such as when it was removed after the
+// invitation was created.
+func (s *InvitationService) join(
+	ctx context.Context, id model.Uuid, m *model.Member, key crypto.Key,
+) (*model.Conversation, error) {
+	entity, err := s.conversations.GetConversationEntity(ctx, id)
+	if err != nil {
+		slog.Warn("Invited member can't join conversation", "id", id, "err", err)
+		return nil, nil
+	}
+
+	prev, err := entity.Decrypt(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt conversation: %v", err)
+	}
+
+	participants := make([][]byte, 0, prev.ParticipantsLength()+1)
+	for i := range prev.ParticipantsLength() {
+		participants = append(participants, prev.Participants(i))
+	}
+	participants = append(participants, m.Id())
+	c := model.CloneConversationWithUpdates(prev, nil, nil, nil, participants)
+
+	entity, err = store.NewConversationEntity(c, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create updated conversation entity: %v", err)
+	}
+
+	err = s.conversations.UpdateConversationEntity(ctx, entity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store updated conversation: %v", err)
+	}
+
+	return c, nil
+}
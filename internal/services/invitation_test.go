@@ -0,0 +1,331 @@
+// ---------------------------------------------------------------------------------------------- //
+// -- Copyright (c) 2024 Braden Hitchcock - MIT License (https://opensource.org/licenses/MIT)  -- //
+// ---------------------------------------------------------------------------------------------- //
+package services_test
+
+import (
+	"context"
+	"errors"
+	"path"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/bradenhc/kolob/internal/crypto"
+	"github.com/bradenhc/kolob/internal/events"
+	"github.com/bradenhc/kolob/internal/model"
+	"github.com/bradenhc/kolob/internal/services"
+	"github.com/bradenhc/kolob/internal/store"
+	"github.com/bradenhc/kolob/internal/store/sqlite"
+	flatbuffers "github.com/google/flatbuffers/go"
+)
+
+func TestInvitationService(t *testing.T) {
+	// Setup the test
+	t.Parallel()
+	tempdir := t.TempDir()
+	dbpath := path.Join(tempdir, "kolob.db")
+
+	db, err := sqlite.Open(dbpath)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+
+	// Create a group with a conversation to invite someone to
+	mstore := doTestMemberCreateStore(t, db)
+	cstore := doTestConversationCreateStore(t, db)
+	ikey := doTestIndexKey(t)
+	tx := sqlite.NewTransactor(db)
+	gs := services.NewGroupService(
+		doTestGroupCreateStore(t, db), mstore, cstore, sqlite.NewInvitationStore(db),
+		sqlite.NewSessionStore(db), sqlite.NewRekeyStore(db), tx, ikey,
+	)
+	creator := doTestGroupCreate(t, ctx, gs).Creator
+	key := doTestGroupAuth(t, ctx, gs)
+
+	broker := events.NewBroker()
+	cs := services.NewConversationService(cstore, mstore, tx, broker)
+	c := doTestConversationAdd(t, ctx, cs, key, creator)
+
+	istore := sqlite.NewInvitationStore(db)
+	is := services.NewInvitationService(
+		istore, mstore, cstore, sqlite.NewRekeyStore(db), tx, broker, ikey,
+	)
+	inv, code := doTestInvitationCreate(t, ctx, is, key, creator, c)
+
+	// A weak password is refused without using up the invitation
+	_, err = is.Redeem(ctx, doTestInvitationRedeemRequestWithPassword(code, "invited", "weak"))
+	if !errors.Is(err, crypto.ErrInvalidPassword) {
+		t.Errorf("expected weak password to be invalid, got %v", err)
+	}
+
+	// Codes can be typed in any case and with any spacing
+	typed := strings.ToLower(strings.ReplaceAll(code, "-", " "))
+	m := doTestInvitationRedeem(t, ctx, is, typed, "invited")
+	if m.Role() != model.RoleUser {
+		t.Errorf("invited member role incorrect: %v != %v", m.Role(), model.RoleUser)
+	}
+	doTestInvitationJoined(t, ctx, cs, key, c, m)
+
+	// Codes can only be used once
+	_, err = is.Redeem(ctx, doTestInvitationRedeemRequest(code, "invited2"))
+	if !errors.Is(err, services.ErrInvalidInvitation) {
+		t.Errorf("expected redeemed invitation to be invalid, got %v", err)
+	}
+	_, err = is.Redeem(ctx, doTestInvitationRedeemRequest("AAAAA-AAAAA", "invited2"))
+	if !errors.Is(err, services.ErrInvalidInvitation) {
+		t.Errorf("expected unknown code to be invalid, got %v", err)
+	}
+
+	invs, err := is.List(ctx, key)
+	if err != nil {
+		t.Fatalf("failed to list invitations: %v", err)
+	}
+	if len(invs) != 0 {
+		t.Errorf("redeemed invitation %s is still listed", inv.Id())
+	}
+
+	doTestInvitationTakenUsername(t, ctx, is, key, creator)
+	doTestInvitationRemove(t, ctx, is, key, creator)
+
+	// Rotating the data key revokes the invitations holding the old key
+	doTestInvitationRekey(t, ctx, is, gs, mstore, key, creator)
+}
+
+func doTestInvitationRekey(
+	t *testing.T,
+	ctx context.Context,
+	is services.InvitationService,
+	gs services.GroupService,
+	mstore store.MemberStore,
+	key crypto.Key,
+	creator *model.Member,
+) {
+	req := doTestInvitationCreateRequest(model.RoleUser, nil, 0)
+	_, code, err := is.Create(ctx, req, key, model.Uuid(creator.Id()))
+	if err != nil {
+		t.Fatalf("failed to create invitation: %v", err)
+	}
+
+	builder := flatbuffers.NewBuilder(64)
+	passOffset := builder.CreateString("Password12345678!")
+	services.GroupRekeyRequestStart(builder)
+	services.GroupRekeyRequestAddPassword(builder, passOffset)
+	builder.Finish(services.GroupRekeyRequestEnd(builder))
+
+	_, err = gs.Rekey(ctx, services.GetRootAsGroupRekeyRequest(builder.FinishedBytes(), 0))
+	if err != nil {
+		t.Fatalf("failed to rekey group: %v", err)
+	}
+
+	_, err = is.Redeem(ctx, doTestInvitationRedeemRequest(code, "rekeyed"))
+	if !errors.Is(err, services.ErrInvalidInvitation) {
+		t.Errorf("expected invitation from before the rotation to be invalid, got %v", err)
+	}
+
+	// Every member should be readable with the new key
+	nkey := doTestGroupAuth(t, ctx, gs)
+	es, err := mstore.ListMemberEntities(ctx)
+	if err != nil {
+		t.Fatalf("failed to list members: %v", err)
+	}
+	for _, e := range es {
+		if _, err := e.Decrypt(nkey); err != nil {
+			t.Errorf("member %s can't be read with the new key: %v", e.Id, err)
+		}
+	}
+}
+
+func doTestInvitationCreateRequest(
+	role model.Role, conversations []model.Uuid, ttl int64,
+) *services.InvitationCreateRequest {
+	builder := flatbuffers.NewBuilder(128)
+	offsets := make([]flatbuffers.UOffsetT, 0, len(conversations))
+	for _, c := range conversations {
+		offsets = append(offsets, builder.CreateString(string(c)))
+	}
+	services.InvitationCreateRequestStartConversationsVector(builder, len(offsets))
+	for _, o := range offsets {
+		builder.PrependUOffsetT(o)
+	}
+	convsOffset := builder.EndVector(len(offsets))
+
+	services.InvitationCreateRequestStart(builder)
+	services.InvitationCreateRequestAddRole(builder, int8(role))
+	services.InvitationCreateRequestAddConversations(builder, convsOffset)
+	services.InvitationCreateRequestAddTtl(builder, ttl)
+	builder.Finish(services.InvitationCreateRequestEnd(builder))
+
+	return services.GetRootAsInvitationCreateRequest(builder.FinishedBytes(), 0)
+}
+
+func doTestInvitationCreate(
+	t *testing.T,
+	ctx context.Context,
+	is services.InvitationService,
+	key crypto.Key,
+	creator *model.Member,
+	c *model.Conversation,
+) (*model.Invitation, string) {
+	// Invitations can't last too long or add members to conversations that don't exist
+	req := doTestInvitationCreateRequest(model.RoleUser, nil, 365*24*60*60)
+	_, _, err := is.Create(ctx, req, key, model.Uuid(creator.Id()))
+	if err == nil {
+		t.Errorf("created an invitation that lasts a year")
+	}
+	req = doTestInvitationCreateRequest(model.RoleUser, []model.Uuid{"nope"}, 0)
+	_, _, err = is.Create(ctx, req, key, model.Uuid(creator.Id()))
+	if err == nil {
+		t.Errorf("created an invitation to a conversation that doesn't exist")
+	}
+
+	req = doTestInvitationCreateRequest(model.RoleUser, []model.Uuid{model.Uuid(c.Id())}, 0)
+	inv, code, err := is.Create(ctx, req, key, model.Uuid(creator.Id()))
+	if err != nil {
+		t.Fatalf("failed to create invitation: %v", err)
+	}
+
+	if !slices.Equal(inv.Creator(), creator.Id()) {
+		t.Errorf("invitation creator incorrect: %s != %s", inv.Creator(), creator.Id())
+	}
+	if inv.ConversationsLength() != 1 || !slices.Equal(inv.Conversations(0), c.Id()) {
+		t.Errorf("invitation conversations incorrect")
+	}
+	ttl := inv.Expires() - inv.Created()
+	if ttl != services.DefaultInvitationTTL.Milliseconds() {
+		t.Errorf("invitation should last %v, lasts %dms", services.DefaultInvitationTTL, ttl)
+	}
+
+	invs, err := is.List(ctx, key)
+	if err != nil {
+		t.Fatalf("failed to list invitations: %v", err)
+	}
+	if len(invs) != 1 || !slices.Equal(invs[0].Id(), inv.Id()) {
+		t.Errorf("created invitation not listed")
+	}
+
+	return inv, code
+}
+
+func doTestInvitationRedeemRequest(code, uname string) *services.InvitationRedeemRequest {
+	return doTestInvitationRedeemRequestWithPassword(code, uname, "InvitedPassword123!")
+}
+
+func doTestInvitationRedeemRequestWithPassword(
+	code, uname, pass string,
+) *services.InvitationRedeemRequest {
+	builder := flatbuffers.NewBuilder(128)
+	codeOffset := builder.CreateString(code)
+	unameOffset := builder.CreateString(uname)
+	nameOffset := builder.CreateString("Invited Member")
+	passOffset := builder.CreateString(pass)
+	services.InvitationRedeemRequestStart(builder)
+	services.InvitationRedeemRequestAddCode(builder, codeOffset)
+	services.InvitationRedeemRequestAddUsername(builder, unameOffset)
+	services.InvitationRedeemRequestAddName(builder, nameOffset)
+	services.InvitationRedeemRequestAddPassword(builder, passOffset)
+	builder.Finish(services.InvitationRedeemRequestEnd(builder))
+
+	return services.GetRootAsInvitationRedeemRequest(builder.FinishedBytes(), 0)
+}
+
+func doTestInvitationRedeem(
+	t *testing.T, ctx context.Context, is services.InvitationService, code, uname string,
+) *model.Member {
+	m, err := is.Redeem(ctx, doTestInvitationRedeemRequest(code, uname))
+	if err != nil {
+		t.Fatalf("failed to redeem invitation: %v", err)
+	}
+	if string(m.Uname()) != uname {
+		t.Errorf("invited member username incorrect: %s != %s", m.Uname(), uname)
+	}
+
+	return m
+}
+
+func doTestInvitationJoined(
+	t *testing.T,
+	ctx context.Context,
+	cs services.ConversationService,
+	key crypto.Key,
+	c *model.Conversation,
+	m *model.Member,
+) {
+	builder := flatbuffers.NewBuilder(64)
+	idOffset := builder.CreateByteString(c.Id())
+	services.ConversationGetRequestStart(builder)
+	services.ConversationGetRequestAddId(builder, idOffset)
+	builder.Finish(services.ConversationGetRequestEnd(builder))
+
+	req := services.GetRootAsConversationGetRequest(builder.FinishedBytes(), 0)
+	updated, err := cs.Get(ctx, req, key)
+	if err != nil {
+		t.Fatalf("failed to get conversation: %v", err)
+	}
+	if !updated.IsParticipant(model.Uuid(m.Id())) {
+		t.Errorf("invited member did not join the conversation of the invitation")
+	}
+}
+
+func doTestInvitationTakenUsername(
+	t *testing.T,
+	ctx context.Context,
+	is services.InvitationService,
+	key crypto.Key,
+	creator *model.Member,
+) {
+	req := doTestInvitationCreateRequest(model.RoleGroupModerator, nil, 60)
+	_, code, err := is.Create(ctx, req, key, model.Uuid(creator.Id()))
+	if err != nil {
+		t.Fatalf("failed to create invitation: %v", err)
+	}
+
+	// The invitation isn't used up when the member can't be added
+	_, err = is.Redeem(ctx, doTestInvitationRedeemRequest(code, "invited"))
+	if err == nil {
+		t.Fatalf("redeemed invitation with a username that is taken")
+	}
+
+	m := doTestInvitationRedeem(t, ctx, is, code, "moderator")
+	if m.Role() != model.RoleGroupModerator {
+		t.Errorf("invited member role incorrect: %v != %v", m.Role(), model.RoleGroupModerator)
+	}
+}
+
+func doTestInvitationRemove(
+	t *testing.T,
+	ctx context.Context,
+	is services.InvitationService,
+	key crypto.Key,
+	creator *model.Member,
+) {
+	req := doTestInvitationCreateRequest(model.RoleUser, nil, 0)
+	inv, code, err := is.Create(ctx, req, key, model.Uuid(creator.Id()))
+	if err != nil {
+		t.Fatalf("failed to create invitation: %v", err)
+	}
+
+	builder := flatbuffers.NewBuilder(64)
+	idOffset := builder.CreateByteString(inv.Id())
+	services.InvitationRemoveRequestStart(builder)
+	services.InvitationRemoveRequestAddId(builder, idOffset)
+	builder.Finish(services.InvitationRemoveRequestEnd(builder))
+
+	rreq := services.GetRootAsInvitationRemoveRequest(builder.FinishedBytes(), 0)
+	err = is.Remove(ctx, rreq)
+	if err != nil {
+		t.Fatalf("failed to remove invitation: %v", err)
+	}
+	err = is.Remove(ctx, rreq)
+	if !errors.Is(err, store.ErrInvitationNotFound) {
+		t.Errorf("expected removed invitation to be gone, got %v", err)
+	}
+
+	_, err = is.Redeem(ctx, doTestInvitationRedeemRequest(code, "revoked"))
+	if !errors.Is(err, services.ErrInvalidInvitation) {
+		t.Errorf("expected revoked invitation to be invalid, got %v", err)
+	}
+}
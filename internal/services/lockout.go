@@ -0,0 +1,264 @@
+// ---------------------------------------------------------------------------------------------- //
+// -- Copyright (c) 2024 Braden Hitchcock - MIT License (https://opensource.org/licenses/MIT)  -- //
+// ---------------------------------------------------------------------------------------------- //
+package services
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/bradenhc/kolob/internal/crypto"
+	"github.com/bradenhc/kolob/internal/model"
+	"github.com/bradenhc/kolob/internal/store"
+)
+
+var (
+	ErrLockedOut          = errors.New("too many failed logins")
+	ErrUnknownLockoutKind = errors.New("unknown lockout kind")
+)
+
+// A lockoutPolicy decides how failed logins for a kind of subject are throttled. The first free
+// failures cost nothing. Each failure after that has to wait twice as long as the last before the
+// next login can be tried, starting at one second, and after max failures the subject is locked
+// out for LockoutDuration.
+type lockoutPolicy struct {
+	free int
+	max  int
+}
+
+// backoff returns how long a subject has to wait after its last failure before it can try again.
+func (p lockoutPolicy) backoff(failures int) time.Duration {
+	if failures >= p.max {
+		return LockoutDuration
+	}
+	if failures <= p.free {
+		return 0
+	}
+
+	// Shifting by much more than this overflows, and it is well past LockoutDuration anyway
+	if n := failures - p.free - 1; n < 20 {
+		return min(time.Second<<n, LockoutDuration)
+	}
+	return LockoutDuration
+}
+
+// Many members can share the address of a church building or a home, so addresses get far more
+// failures than usernames before they are slowed down.
+var lockoutPolicies = map[store.LockoutKind]lockoutPolicy{
+	store.AddrLockoutKind: {free: 10, max: 50},
+	store.UserLockoutKind: {free: 3, max: 10},
+}
+
+const (
+	// LockoutDuration is how long a subject can't log in after failing too many times.
+	LockoutDuration = 15 * time.Minute
+
+	// LockoutWindow is how long failed logins are remembered after the last one.
+	LockoutWindow = time.Hour
+)
+
+// A LockoutService throttles logins by counting the failures for each client address and each
+// username that was tried. Usernames are counted by their blind index whether or not a member has
+// them, so that lockouts don't reveal which usernames exist.
+type LockoutService struct {
+	store   store.LockoutStore
+	members store.MemberStore
+	ikey    crypto.IndexKey
+
+	// Counting a failure reads and then writes the lockout, and logins are let through against the
+	// ones still being tried, so both happen one at a time
+	mx       *sync.Mutex
+	attempts map[lockoutAttempt]int
+}
+
+// A lockoutAttempt names a subject with logins that were let through by Check and aren't done yet.
+type lockoutAttempt struct {
+	kind    store.LockoutKind
+	subject string
+}
+
+func NewLockoutService(
+	store store.LockoutStore, members store.MemberStore, ikey crypto.IndexKey,
+) LockoutService {
+	return LockoutService{store, members, ikey, &sync.Mutex{}, make(map[lockoutAttempt]int)}
+}
+
+// Check returns ErrLockedOut along with how long to wait before trying again if either the address
+// or the username can't log in yet. Otherwise the login is let through, and Done has to be called
+// once it has been counted with Fail or Succeed, or given up on.
+//
+// Logins that are still being tried count as failures until they are done, so that guessing many
+// passwords at once gets no more tries than guessing them one after another.
+func (s *LockoutService) Check(
+	ctx context.Context, addr string, uname []byte,
+) (time.Duration, error) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	now := time.Now()
+	subjects := s.subjects(addr, uname)
+
+	var wait time.Duration
+	for kind, subject := range subjects {
+		e, err := s.store.GetLockoutEntity(ctx, kind, subject)
+		if err != nil {
+			return 0, err
+		}
+		wait = max(wait, time.UnixMilli(e.LockedUntil).Sub(now))
+
+		if n := s.attempts[lockoutAttempt{kind, subject}]; n > 0 {
+			failures := e.Failures
+			if now.Sub(time.UnixMilli(e.FailedAt)) >= LockoutWindow {
+				failures = 0
+			}
+			wait = max(wait, lockoutPolicies[kind].backoff(failures+n))
+		}
+	}
+
+	if wait > 0 {
+		return wait, ErrLockedOut
+	}
+
+	for kind, subject := range subjects {
+		s.attempts[lockoutAttempt{kind, subject}]++
+	}
+
+	return 0, nil
+}
+
+// Done ends a login that was let through by Check for the same address and username.
+func (s *LockoutService) Done(addr string, uname []byte) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	for kind, subject := range s.subjects(addr, uname) {
+		a := lockoutAttempt{kind, subject}
+		if s.attempts[a]--; s.attempts[a] <= 0 {
+			delete(s.attempts, a)
+		}
+	}
+}
+
+// Fail counts a failed login for both the address and the username, or only for the address if the
+// username is nil.
+func (s *LockoutService) Fail(ctx context.Context, addr string, uname []byte) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+
+	now := time.Now()
+	for kind, subject := range s.subjects(addr, uname) {
+		e, err := s.store.GetLockoutEntity(ctx, kind, subject)
+		if err != nil {
+			return err
+		}
+
+		if now.Sub(time.UnixMilli(e.FailedAt)) >= LockoutWindow {
+			e.Failures = 0
+		}
+		e.Failures++
+		e.FailedAt = now.UnixMilli()
+
+		if backoff := lockoutPolicies[kind].backoff(e.Failures); backoff > 0 {
+			e.LockedUntil = now.Add(backoff).UnixMilli()
+		}
+		if e.Failures >= lockoutPolicies[kind].max {
+			slog.Warn("Locked out after too many failed logins", "kind", kind, "subject", subject)
+		}
+
+		err = s.store.PutLockoutEntity(ctx, e)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Succeed forgets the failed logins for the username. Failures for the address are kept, since
+// someone guessing passwords from it may also know a password of their own.
+func (s *LockoutService) Succeed(ctx context.Context, uname []byte) error {
+	return s.store.RemoveLockoutEntity(ctx, store.UserLockoutKind, s.userSubject(uname))
+}
+
+// List returns every subject with failed logins that haven't been forgotten yet. Usernames that
+// belong to a member include the ID of the member.
+func (s *LockoutService) List(ctx context.Context) ([]*model.Lockout, error) {
+	es, err := s.store.ListLockoutEntities(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ls := make([]*model.Lockout, 0, len(es))
+	for _, e := range es {
+		var member model.Uuid
+		if e.Kind == store.UserLockoutKind {
+			member = s.member(ctx, e.Subject)
+		}
+
+		ls = append(ls, model.NewLockout(
+			string(e.Kind), e.Subject, member, int32(e.Failures), e.FailedAt, e.LockedUntil,
+		))
+	}
+
+	return ls, nil
+}
+
+// Clear forgets the failed logins for a subject, so that it can log in again right away.
+func (s *LockoutService) Clear(ctx context.Context, kind store.LockoutKind, subject string) error {
+	if _, ok := lockoutPolicies[kind]; !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownLockoutKind, kind)
+	}
+
+	return s.store.RemoveLockoutEntity(ctx, kind, subject)
+}
+
+// Sweep removes the lockouts whose failures have been forgotten and returns how many there were.
+func (s *LockoutService) Sweep(ctx context.Context) (int, error) {
+	now := time.Now()
+	return s.store.RemoveStaleLockoutEntities(
+		ctx, now.Add(-LockoutWindow).UnixMilli(), now.UnixMilli(),
+	)
+}
+
+// StartSweeper sweeps stale lockouts from the store every interval until the context is done.
+func (s *LockoutService) StartSweeper(ctx context.Context, interval time.Duration) {
+	startSweeper(ctx, interval, "stale lockouts", s.Sweep)
+}
+
+// subjects returns the subjects that failures are counted for. Without a username, such as when a
+// code is tried instead, only the address is counted.
+func (s *LockoutService) subjects(addr string, uname []byte) map[store.LockoutKind]string {
+	subjects := map[store.LockoutKind]string{store.AddrLockoutKind: addr}
+	if uname != nil {
+		subjects[store.UserLockoutKind] = s.userSubject(uname)
+	}
+	return subjects
+}
+
+func (s *LockoutService) userSubject(uname []byte) string {
+	uhash := crypto.BlindIndex(s.ikey, uname)
+	return hex.EncodeToString(uhash[:])
+}
+
+// member returns the ID of the member with the username of the subject, or nothing if there isn't
+// one.
+func (s *LockoutService) member(ctx context.Context, subject string) model.Uuid {
+	var uhash crypto.DataHash
+	b, err := hex.DecodeString(subject)
+	if err != nil || len(b) != len(uhash) {
+		return ""
+	}
+	copy(uhash[:], b)
+
+	e, err := s.members.GetMemberEntityByUname(ctx, uhash)
+	if err != nil {
+		return ""
+	}
+
+	return e.Id
+}
@@ -0,0 +1,270 @@
+// ---------------------------------------------------------------------------------------------- //
+// -- Copyright (c) 2024 Braden Hitchcock - MIT License (https://opensource.org/licenses/MIT)  -- //
+// ---------------------------------------------------------------------------------------------- //
+package services_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/bradenhc/kolob/internal/events"
+	"github.com/bradenhc/kolob/internal/model"
+	"github.com/bradenhc/kolob/internal/services"
+	"github.com/bradenhc/kolob/internal/store"
+	"github.com/bradenhc/kolob/internal/store/sqlite"
+)
+
+func TestLockoutService(t *testing.T) {
+	// Setup the test
+	t.Parallel()
+	tempdir := t.TempDir()
+	dbpath := path.Join(tempdir, "kolob.db")
+
+	db, err := sqlite.Open(dbpath)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+
+	// Create a group with a member whose username can be locked out
+	mstore := doTestMemberCreateStore(t, db)
+	ikey := doTestIndexKey(t)
+	gs := services.NewGroupService(
+		doTestGroupCreateStore(t, db), mstore, doTestConversationCreateStore(t, db),
+		sqlite.NewInvitationStore(db), sqlite.NewSessionStore(db), sqlite.NewRekeyStore(db),
+		sqlite.NewTransactor(db), ikey,
+	)
+	doTestGroupCreate(t, ctx, gs)
+	key := doTestGroupAuth(t, ctx, gs)
+
+	ms := services.NewMemberService(
+		mstore, sqlite.NewSessionStore(db), events.NewBroker(), ikey,
+	)
+	m := doTestMemberAdd(t, ctx, ms, key, "lockeduser")
+
+	lstore := sqlite.NewLockoutStore(db)
+	ls := services.NewLockoutService(lstore, mstore, ikey)
+	doTestLockoutBackoff(t, ctx, ls, model.Uuid(m.Id()))
+	doTestLockoutBackoffLimits(t, ctx, ls, lstore)
+	doTestLockoutLocked(t, ctx, ls)
+	doTestLockoutConcurrent(t, ctx, ls)
+}
+
+func doTestLockoutBackoff(
+	t *testing.T, ctx context.Context, ls services.LockoutService, member model.Uuid,
+) {
+	addr, uname := "192.0.2.1", []byte("lockeduser")
+
+	// The first few failures cost nothing
+	for range 3 {
+		doTestLockoutFail(t, ctx, ls, addr, uname)
+	}
+	_, err := ls.Check(ctx, addr, uname)
+	if err != nil {
+		t.Fatalf("locked out before using up free failures: %v", err)
+	}
+	ls.Done(addr, uname)
+
+	// The next one has to wait
+	doTestLockoutFail(t, ctx, ls, addr, uname)
+	wait, err := ls.Check(ctx, addr, uname)
+	if !errors.Is(err, services.ErrLockedOut) {
+		t.Fatalf("expected lockout, got %v", err)
+	}
+	if wait <= 0 || wait > time.Second {
+		t.Errorf("expected to wait up to a second, got %v", wait)
+	}
+
+	// Both the address and the username of the member are listed
+	lockouts, err := ls.List(ctx)
+	if err != nil {
+		t.Fatalf("failed to list lockouts: %v", err)
+	}
+	if len(lockouts) != 2 {
+		t.Fatalf("expected 2 lockouts, got %d", len(lockouts))
+	}
+	var user *model.Lockout
+	for _, l := range lockouts {
+		if store.LockoutKind(l.Kind()) == store.UserLockoutKind {
+			user = l
+		}
+	}
+	if user == nil {
+		t.Fatalf("username lockout not listed")
+	}
+	if model.Uuid(user.Member()) != member {
+		t.Errorf("lockout member incorrect: %s != %s", user.Member(), member)
+	}
+	if user.Failures() != 4 {
+		t.Errorf("expected 4 failures, got %d", user.Failures())
+	}
+
+	// Logging in forgets the username but not the address
+	err = ls.Succeed(ctx, uname)
+	if err != nil {
+		t.Fatalf("failed to clear username failures: %v", err)
+	}
+	_, err = ls.Check(ctx, "198.51.100.1", uname)
+	if err != nil {
+		t.Errorf("username still locked out after logging in: %v", err)
+	}
+	ls.Done("198.51.100.1", uname)
+	lockouts, err = ls.List(ctx)
+	if err != nil {
+		t.Fatalf("failed to list lockouts: %v", err)
+	}
+	if len(lockouts) != 1 || store.LockoutKind(lockouts[0].Kind()) != store.AddrLockoutKind {
+		t.Errorf("expected only the address lockout to remain")
+	}
+
+	err = ls.Clear(ctx, store.AddrLockoutKind, addr)
+	if err != nil {
+		t.Fatalf("failed to clear address lockout: %v", err)
+	}
+	err = ls.Clear(ctx, "nope", addr)
+	if !errors.Is(err, services.ErrUnknownLockoutKind) {
+		t.Errorf("expected unknown lockout kind, got %v", err)
+	}
+}
+
+func doTestLockoutBackoffLimits(
+	t *testing.T, ctx context.Context, ls services.LockoutService, lstore store.LockoutStore,
+) {
+	// Addresses get 10 free failures and are locked out after 50
+	tests := []struct {
+		failures int
+		wait     time.Duration
+	}{
+		{1, 0},
+		{10, 0},
+		{11, time.Second},
+		{12, 2 * time.Second},
+		{20, 512 * time.Second},
+		{21, services.LockoutDuration},
+		{30, services.LockoutDuration},
+		{44, services.LockoutDuration},
+		{45, services.LockoutDuration},
+		{46, services.LockoutDuration},
+		{47, services.LockoutDuration},
+		{48, services.LockoutDuration},
+		{49, services.LockoutDuration},
+		{50, services.LockoutDuration},
+	}
+
+	for _, test := range tests {
+		addr := fmt.Sprintf("198.51.100.%d", test.failures)
+
+		// Count the failure on top of the ones before it
+		err := lstore.PutLockoutEntity(ctx, store.LockoutEntity{
+			Kind:     store.AddrLockoutKind,
+			Subject:  addr,
+			Failures: test.failures - 1,
+			FailedAt: time.Now().UnixMilli(),
+		})
+		if err != nil {
+			t.Fatalf("failed to store lockout: %v", err)
+		}
+		doTestLockoutFail(t, ctx, ls, addr, nil)
+
+		wait, err := ls.Check(ctx, addr, nil)
+		if test.wait == 0 {
+			if err != nil {
+				t.Errorf("failure %d: expected no wait, got %v", test.failures, err)
+			}
+			ls.Done(addr, nil)
+			continue
+		}
+		if !errors.Is(err, services.ErrLockedOut) {
+			t.Errorf("failure %d: expected lockout, got %v", test.failures, err)
+			continue
+		}
+		if wait <= test.wait-time.Second || wait > test.wait {
+			t.Errorf("failure %d: expected to wait %v, got %v", test.failures, test.wait, wait)
+		}
+
+		err = ls.Clear(ctx, store.AddrLockoutKind, addr)
+		if err != nil {
+			t.Fatalf("failed to clear address lockout: %v", err)
+		}
+	}
+}
+
+func doTestLockoutLocked(t *testing.T, ctx context.Context, ls services.LockoutService) {
+	uname := []byte("nobody")
+
+	// Usernames are locked out whether or not a member has them, and from every address
+	for i := range 10 {
+		doTestLockoutFail(t, ctx, ls, fmt.Sprintf("203.0.113.%d", i), uname)
+	}
+
+	wait, err := ls.Check(ctx, "198.51.100.1", uname)
+	if !errors.Is(err, services.ErrLockedOut) {
+		t.Fatalf("expected lockout, got %v", err)
+	}
+	if wait < services.LockoutDuration-time.Minute {
+		t.Errorf("expected to wait about %v, got %v", services.LockoutDuration, wait)
+	}
+
+	lockouts, err := ls.List(ctx)
+	if err != nil {
+		t.Fatalf("failed to list lockouts: %v", err)
+	}
+	for _, l := range lockouts {
+		if store.LockoutKind(l.Kind()) == store.UserLockoutKind && len(l.Member()) != 0 {
+			t.Errorf("lockout of unknown username has member %s", l.Member())
+		}
+	}
+
+	// Nothing is stale yet
+	n, err := ls.Sweep(ctx)
+	if err != nil {
+		t.Fatalf("failed to sweep lockouts: %v", err)
+	}
+	if n != 0 {
+		t.Errorf("swept %d lockouts that aren't stale", n)
+	}
+}
+
+func doTestLockoutConcurrent(t *testing.T, ctx context.Context, ls services.LockoutService) {
+	addr, uname := "192.0.2.2", []byte("concurrentuser")
+
+	// Logins that haven't finished yet count as failures, so only the free ones are let through
+	for i := range 4 {
+		_, err := ls.Check(ctx, addr, uname)
+		if err != nil {
+			t.Fatalf("login %d: locked out before using up free failures: %v", i, err)
+		}
+	}
+	wait, err := ls.Check(ctx, addr, uname)
+	if !errors.Is(err, services.ErrLockedOut) {
+		t.Fatalf("expected lockout with logins still being tried, got %v", err)
+	}
+	if wait <= 0 || wait > time.Second {
+		t.Errorf("expected to wait up to a second, got %v", wait)
+	}
+
+	// Once they are done, the next login is let through again
+	for range 4 {
+		ls.Done(addr, uname)
+	}
+	_, err = ls.Check(ctx, addr, uname)
+	if err != nil {
+		t.Errorf("locked out after logins were done: %v", err)
+	}
+	ls.Done(addr, uname)
+}
+
+func doTestLockoutFail(
+	t *testing.T, ctx context.Context, ls services.LockoutService, addr string, uname []byte,
+) {
+	err := ls.Fail(ctx, addr, uname)
+	if err != nil {
+		t.Fatalf("failed to count failed login: %v", err)
+	}
+}
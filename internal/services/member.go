@@ -6,18 +6,26 @@ package services
 import (
 	"context"
 	"fmt"
+	"log/slog"
 
 	"github.com/bradenhc/kolob/internal/crypto"
+	"github.com/bradenhc/kolob/internal/events"
 	"github.com/bradenhc/kolob/internal/model"
 	"github.com/bradenhc/kolob/internal/store"
 )
 
 type MemberService struct {
-	store store.MemberStore
+	store    store.MemberStore
+	sessions store.SessionStore
+	events   events.Publisher
+	ikey     crypto.IndexKey
 }
 
-func NewMemberService(store store.MemberStore) MemberService {
-	return MemberService{store}
+func NewMemberService(
+	store store.MemberStore, sessions store.SessionStore, events events.Publisher,
+	ikey crypto.IndexKey,
+) MemberService {
+	return MemberService{store, sessions, events, ikey}
 }
 
 func (s *MemberService) Create(
@@ -26,17 +34,23 @@ func (s *MemberService) Create(
 	// Verify the password
 	upass, err := crypto.NewPassword(string(req.Password()))
 	if err != nil {
-		return nil, fmt.Errorf("password validation failed: %v", err)
+		return nil, fmt.Errorf("password validation failed: %w", err)
+	}
+
+	// Only the roles that apply to the whole group can be assigned to a member
+	role := model.Role(req.Role())
+	if role != model.RoleUser && role != model.RoleGroupModerator {
+		return nil, fmt.Errorf("invalid member role: %v", role)
 	}
 
 	// Create the new member
-	m, err := model.NewMember(string(req.Username()), string(req.Name()))
+	m, err := model.NewMember(string(req.Username()), string(req.Name()), role)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create new member: %v", err)
 	}
 
 	// Create the entity we will store in the database
-	entity, err := store.NewMemberEntity(m, upass, key)
+	entity, err := store.NewMemberEntity(m, upass, key, s.ikey)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create new member entity: %v", err)
 	}
@@ -47,19 +61,25 @@ func (s *MemberService) Create(
 		return nil, fmt.Errorf("failed to store member: %v", err)
 	}
 
+	s.events.Publish(events.Event{Type: events.MemberCreated, Data: m})
+
 	return m, nil
 }
 
+// Authenticate checks the member's credentials after the group has been unlocked with the group
+// password. Members that don't have a keyslot yet are given one, so that they can Unlock the group
+// with their own password from then on.
 func (s *MemberService) Authenticate(
 	ctx context.Context, req *MemberAuthenticateRequest, key crypto.Key,
 ) (*model.Member, error) {
-	uhash := crypto.HashData(req.Username())
+	uhash := crypto.BlindIndex(s.ikey, req.Username())
 	entity, err := s.store.GetMemberEntityByUname(ctx, uhash)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get member: %v", err)
 	}
 
-	if !crypto.CheckPasswordHash(crypto.Password(req.Password()), entity.PassHash) {
+	pass := crypto.Password(req.Password())
+	if !crypto.CheckPasswordHash(pass, entity.PassHash) {
 		return nil, fmt.Errorf("password authentication failed")
 	}
 
@@ -68,11 +88,80 @@ func (s *MemberService) Authenticate(
 		return nil, fmt.Errorf("failed to decrypt member: %v", err)
 	}
 
+	if !entity.HasKeyslot() || entity.KdfParams != crypto.DefaultKdfParams {
+		s.rewrapKey(ctx, entity, pass, key)
+	}
+
+	return m, nil
+}
+
+// Unlock checks the member's credentials and returns the group data key from the member's keyslot,
+// so that the member can log in without the group password.
+func (s *MemberService) Unlock(
+	ctx context.Context, req *MemberAuthenticateRequest,
+) (crypto.Key, *model.Member, error) {
+	uhash := crypto.BlindIndex(s.ikey, req.Username())
+	entity, err := s.store.GetMemberEntityByUname(ctx, uhash)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get member: %v", err)
+	}
+
+	pass := crypto.Password(req.Password())
+	if !crypto.CheckPasswordHash(pass, entity.PassHash) {
+		return nil, nil, fmt.Errorf("password authentication failed")
+	}
+
+	key, err := entity.UnwrapKey(pass)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to unlock member keyslot: %v", err)
+	}
+
+	m, err := entity.Decrypt(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decrypt member: %v", err)
+	}
+
+	if entity.KdfParams != crypto.DefaultKdfParams {
+		s.rewrapKey(ctx, entity, pass, key)
+	}
+
+	return key, m, nil
+}
+
+// rewrapKey fills the member's keyslot using the current key derivation defaults. The member can
+// still log in if this fails, so failures are only logged.
+func (s *MemberService) rewrapKey(
+	ctx context.Context, entity store.MemberEntity, pass crypto.Password, key crypto.Key,
+) {
+	err := entity.WrapKey(pass, key)
+	if err == nil {
+		err = s.store.UpdateMemberEntity(ctx, entity)
+	}
+	if err != nil {
+		slog.Warn("Failed to update member keyslot", "member", entity.Id, "err", err)
+	}
+}
+
+func (s *MemberService) Get(
+	ctx context.Context, req *MemberGetRequest, key crypto.Key,
+) (*model.Member, error) {
+	entity, err := s.store.GetMemberEntity(ctx, model.Uuid(req.Id()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get member data: %v", err)
+	}
+
+	m, err := entity.Decrypt(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt member data: %v", err)
+	}
+
 	return m, nil
 }
 
+// ChangePassword replaces the member's password and wraps the data key in the member's keyslot with
+// the new password. If the member's password was reset, they no longer have to change it.
 func (s *MemberService) ChangePassword(
-	ctx context.Context, req *MemberChangePasswordRequest,
+	ctx context.Context, req *MemberChangePasswordRequest, key crypto.Key,
 ) error {
 	entity, err := s.store.GetMemberEntity(ctx, model.Uuid(req.Id()))
 	if err != nil {
@@ -85,7 +174,7 @@ func (s *MemberService) ChangePassword(
 
 	npass, err := crypto.NewPassword(string(req.NewPassword()))
 	if err != nil {
-		return fmt.Errorf("invalid password: %v", err)
+		return err
 	}
 
 	entity.PassHash, err = crypto.HashPassword(npass)
@@ -93,14 +182,84 @@ func (s *MemberService) ChangePassword(
 		return fmt.Errorf("failed to hash password for storage: %v", err)
 	}
 
+	err = entity.WrapKey(npass, key)
+	if err != nil {
+		return fmt.Errorf("failed to update member keyslot: %v", err)
+	}
+
+	m, err := entity.Decrypt(key)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt member data: %v", err)
+	}
+	reset := m.Reset()
+	if reset {
+		m, err = entity.SetReset(key, false)
+		if err != nil {
+			return fmt.Errorf("failed to update member entity: %v", err)
+		}
+	}
+
 	err = s.store.UpdateMemberEntity(ctx, entity)
 	if err != nil {
 		return fmt.Errorf("failed to store updated member: %v", err)
 	}
 
+	if reset {
+		s.events.Publish(events.Event{Type: events.MemberUpdated, Data: m})
+	}
+
 	return nil
 }
 
+// ResetPassword replaces the password of a member who forgot theirs with a temporary one and
+// returns it, so that a Group Moderator can pass it on. The member's keyslot is wrapped with the
+// temporary password, their sessions are ended, and they must change the password the next time
+// they log in before doing anything else.
+func (s *MemberService) ResetPassword(
+	ctx context.Context, req *MemberResetPasswordRequest, key crypto.Key,
+) (crypto.Password, error) {
+	id := model.Uuid(req.Id())
+	entity, err := s.store.GetMemberEntity(ctx, id)
+	if err != nil {
+		return "", fmt.Errorf("failed to get member data: %v", err)
+	}
+
+	pass, err := crypto.NewTemporaryPassword()
+	if err != nil {
+		return "", err
+	}
+
+	entity.PassHash, err = crypto.HashPassword(pass)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password for storage: %v", err)
+	}
+
+	err = entity.WrapKey(pass, key)
+	if err != nil {
+		return "", fmt.Errorf("failed to update member keyslot: %v", err)
+	}
+
+	m, err := entity.SetReset(key, true)
+	if err != nil {
+		return "", fmt.Errorf("failed to update member entity: %v", err)
+	}
+
+	err = s.store.UpdateMemberEntity(ctx, entity)
+	if err != nil {
+		return "", fmt.Errorf("failed to store updated member: %v", err)
+	}
+
+	// Whoever was using the old password is signed out everywhere
+	err = s.sessions.RemoveMemberSessionEntities(ctx, id)
+	if err != nil {
+		return "", fmt.Errorf("failed to end member sessions: %v", err)
+	}
+
+	s.events.Publish(events.Event{Type: events.MemberUpdated, Data: m})
+
+	return pass, nil
+}
+
 func (s *MemberService) UpdateMember(
 	ctx context.Context, req *MemberUpdateRequest, key crypto.Key,
 ) (*model.Member, error) {
@@ -109,7 +268,7 @@ func (s *MemberService) UpdateMember(
 		return nil, fmt.Errorf("failed to get member data: %v", err)
 	}
 
-	m, err := entity.Update(key, req.Username(), req.Name())
+	m, err := entity.Update(key, s.ikey, req.Username(), req.Name())
 	if err != nil {
 		return nil, fmt.Errorf("failed to update member entity: %v", err)
 	}
@@ -119,14 +278,29 @@ func (s *MemberService) UpdateMember(
 		return nil, fmt.Errorf("failed to store updated member data: %v", err)
 	}
 
+	s.events.Publish(events.Event{Type: events.MemberUpdated, Data: m})
+
 	return m, nil
 }
 
+// RemoveMember removes the member and ends every one of their sessions, so that a removed member is
+// signed out everywhere at once.
 func (s *MemberService) RemoveMember(ctx context.Context, req *MemberRemoveRequest) error {
-	err := s.store.RemoveMemberEntity(ctx, model.Uuid(req.Id()))
+	id := model.Uuid(req.Id())
+	err := s.sessions.RemoveMemberSessionEntities(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to end member sessions: %v", err)
+	}
+
+	err = s.store.RemoveMemberEntity(ctx, id)
 	if err != nil {
 		return fmt.Errorf("failed to remove member data: %v", err)
 	}
+
+	s.events.Publish(events.Event{
+		Type: events.MemberRemoved, Data: events.Removed{Id: string(req.Id())},
+	})
+
 	return nil
 }
 
@@ -151,7 +325,7 @@ func (s *MemberService) ListMembers(ctx context.Context, key crypto.Key) ([]*mod
 func (s *MemberService) FindMemberByUsername(
 	ctx context.Context, req *MemberFindByUsernameRequest, key crypto.Key,
 ) (*model.Member, error) {
-	uhash := crypto.HashData(req.Username())
+	uhash := crypto.BlindIndex(s.ikey, req.Username())
 	entity, err := s.store.GetMemberEntityByUname(ctx, uhash)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get member by username: %v", err)
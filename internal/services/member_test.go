@@ -4,15 +4,21 @@
 package services_test
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"path"
 	"testing"
+	"time"
 
 	"github.com/bradenhc/kolob/internal/crypto"
+	"github.com/bradenhc/kolob/internal/events"
 	"github.com/bradenhc/kolob/internal/model"
 	"github.com/bradenhc/kolob/internal/services"
+	"github.com/bradenhc/kolob/internal/store"
+	"github.com/bradenhc/kolob/internal/store/memory"
 	"github.com/bradenhc/kolob/internal/store/sqlite"
 	flatbuffers "github.com/google/flatbuffers/go"
 )
@@ -34,24 +40,45 @@ func TestMemberService(t *testing.T) {
 
 	// Create our group store and service
 	gstore := doTestGroupCreateStore(t, db)
-	gs := services.NewGroupService(gstore)
+	mstore := doTestMemberCreateStore(t, db)
+	cstore := doTestConversationCreateStore(t, db)
+	ikey := doTestIndexKey(t)
+	gs := services.NewGroupService(
+		gstore, mstore, cstore, sqlite.NewInvitationStore(db), sqlite.NewSessionStore(db),
+		sqlite.NewRekeyStore(db), sqlite.NewTransactor(db), ikey,
+	)
 
 	// Create and store a group to associate members with and get the key
 	doTestGroupCreate(t, ctx, gs)
 	key := doTestGroupAuth(t, ctx, gs)
 
-	// Create the member store and service
-	mstore := doTestMemberCreateStore(t, db)
-	ms := services.NewMemberService(mstore)
+	// Create the member service
+	// Sessions are kept in memory, where nothing else ends them when their member is removed
+	sessions := memory.NewSessionStore()
+	ms := services.NewMemberService(mstore, sessions, events.NewBroker(), ikey)
 
 	// Add a member
 	a := doTestMemberAdd(t, ctx, ms, key, "testuser")
 
+	// Get member by ID
+	doTestMemberGet(t, ctx, ms, key, a)
+
 	// Authenticate member
 	doTestMemberAuth(t, ctx, ms, key)
 
+	// Unlock the group with the member's keyslot
+	doTestMemberUnlock(t, ctx, ms, key, "Password12345678!", true)
+
 	// Change password
-	doTestMemberChangePassword(t, ctx, ms, a)
+	doTestMemberChangePassword(t, ctx, ms, a, key, "Password12345678!", "UpdatedPassword12345!")
+	doTestMemberUnlock(t, ctx, ms, key, "UpdatedPassword12345!", true)
+	doTestMemberUnlock(t, ctx, ms, key, "Password12345678!", false)
+
+	// Members without a keyslot get one the next time they log in with the group password
+	doTestMemberKeyslotRestored(t, ctx, ms, mstore, key)
+
+	// A Group Moderator can reset a forgotten password to a temporary one
+	a = doTestMemberResetPassword(t, ctx, ms, sessions, key, a)
 
 	// Get member
 	b := doTestMemberFindByUsername(t, ctx, ms, key, a)
@@ -63,16 +90,11 @@ func TestMemberService(t *testing.T) {
 	d := doTestMemberList(t, ctx, ms, key, c)
 
 	// Remove a member
-	doTestMemberRemove(t, ctx, ms, key, c, d)
+	doTestMemberRemove(t, ctx, ms, sessions, key, c, d)
 }
 
 func doTestMemberCreateStore(t *testing.T, db *sql.DB) sqlite.MemberStore {
-	store, err := sqlite.NewMemberStore(db)
-	if err != nil {
-		t.Fatalf("failed to create member store: %v", err)
-	}
-	return store
-
+	return sqlite.NewMemberStore(db)
 }
 
 func doTestMemberAdd(
@@ -113,6 +135,27 @@ func doTestMemberAdd(
 	return a
 }
 
+func doTestMemberGet(
+	t *testing.T, ctx context.Context, ms services.MemberService, key crypto.Key, a *model.Member,
+) {
+	builder := flatbuffers.NewBuilder(32)
+	idOffset := builder.CreateByteString(a.Id())
+	services.MemberGetRequestStart(builder)
+	services.MemberGetRequestAddId(builder, idOffset)
+	r := services.MemberGetRequestEnd(builder)
+	builder.Finish(r)
+
+	req := services.GetRootAsMemberGetRequest(builder.FinishedBytes(), 0)
+	b, err := ms.Get(ctx, req, key)
+	if err != nil {
+		t.Fatalf("failed to get member: %v", err)
+	}
+
+	if !model.MemberEqual(a, b) {
+		t.Errorf("member not the same: %+v != %+v", b, a)
+	}
+}
+
 func doTestMemberAuth(
 	t *testing.T, ctx context.Context, ms services.MemberService, key crypto.Key,
 ) {
@@ -136,11 +179,13 @@ func doTestMemberAuth(
 }
 
 func doTestMemberChangePassword(
-	t *testing.T, ctx context.Context, ms services.MemberService, a *model.Member,
+	t *testing.T,
+	ctx context.Context,
+	ms services.MemberService,
+	a *model.Member,
+	key crypto.Key,
+	oldPass, newPass string,
 ) {
-	oldPass := "Password12345678!"
-	newPass := "UpdatedPassword12345!"
-
 	builder := flatbuffers.NewBuilder(64)
 	idOffset := builder.CreateByteString(a.Id())
 	oldPassOffset := builder.CreateString(oldPass)
@@ -153,12 +198,74 @@ func doTestMemberChangePassword(
 	builder.Finish(r)
 
 	req := services.GetRootAsMemberChangePasswordRequest(builder.FinishedBytes(), 0)
-	err := ms.ChangePassword(ctx, req)
+	err := ms.ChangePassword(ctx, req, key)
 	if err != nil {
 		t.Fatalf("failed to change member password: %v", err)
 	}
 }
 
+func doTestMemberUnlockRequest(uname, upass string) *services.MemberAuthenticateRequest {
+	builder := flatbuffers.NewBuilder(64)
+	unameOffset := builder.CreateString(uname)
+	upassOffset := builder.CreateString(upass)
+	services.MemberAuthenticateRequestStart(builder)
+	services.MemberAuthenticateRequestAddUsername(builder, unameOffset)
+	services.MemberAuthenticateRequestAddPassword(builder, upassOffset)
+	builder.Finish(services.MemberAuthenticateRequestEnd(builder))
+
+	return services.GetRootAsMemberAuthenticateRequest(builder.FinishedBytes(), 0)
+}
+
+func doTestMemberUnlock(
+	t *testing.T,
+	ctx context.Context,
+	ms services.MemberService,
+	key crypto.Key,
+	upass string,
+	ok bool,
+) {
+	req := doTestMemberUnlockRequest("testuser", upass)
+	k, m, err := ms.Unlock(ctx, req)
+	if !ok {
+		if err == nil {
+			t.Errorf("member unlocked the group with the wrong password")
+		}
+		return
+	}
+	if err != nil {
+		t.Fatalf("failed to unlock group with member keyslot: %v", err)
+	}
+	if !bytes.Equal(k, key) {
+		t.Errorf("member keyslot holds the wrong key")
+	}
+	if string(m.Uname()) != "testuser" {
+		t.Errorf("unlocked the wrong member: %s", m.Uname())
+	}
+}
+
+func doTestMemberKeyslotRestored(
+	t *testing.T,
+	ctx context.Context,
+	ms services.MemberService,
+	mstore sqlite.MemberStore,
+	key crypto.Key,
+) {
+	upass := "UpdatedPassword12345!"
+
+	err := mstore.RemoveMemberKeyslots(ctx)
+	if err != nil {
+		t.Fatalf("failed to remove member keyslots: %v", err)
+	}
+	doTestMemberUnlock(t, ctx, ms, key, upass, false)
+
+	req := doTestMemberUnlockRequest("testuser", upass)
+	_, err = ms.Authenticate(ctx, req, key)
+	if err != nil {
+		t.Fatalf("member authentication failed: %v", err)
+	}
+	doTestMemberUnlock(t, ctx, ms, key, upass, true)
+}
+
 func doTestMemberFindByUsername(
 	t *testing.T, ctx context.Context, ms services.MemberService, key crypto.Key, a *model.Member,
 ) *model.Member {
@@ -243,15 +350,16 @@ func doTestMemberList(
 		t.Fatalf("failed to list members: %v", err)
 	}
 
-	if len(l) != 2 {
-		t.Fatalf("expected two members in list, got %d", len(l))
+	// The group creator is always the first member of the group
+	if len(l) != 3 {
+		t.Fatalf("expected three members in list, got %d", len(l))
 	}
 
-	if !model.MemberEqual(l[0], c) {
-		t.Errorf("first member is not what was expected: %+v != %+v", l[0], c)
+	if !model.MemberEqual(l[1], c) {
+		t.Errorf("first member is not what was expected: %+v != %+v", l[1], c)
 	}
-	if !model.MemberEqual(l[1], d) {
-		t.Errorf("second member is not what was expected: %+v != %+v", l[1], d)
+	if !model.MemberEqual(l[2], d) {
+		t.Errorf("second member is not what was expected: %+v != %+v", l[2], d)
 	}
 
 	return d
@@ -261,9 +369,13 @@ func doTestMemberRemove(
 	t *testing.T,
 	ctx context.Context,
 	ms services.MemberService,
+	sessions store.SessionStore,
 	key crypto.Key, c,
 	d *model.Member,
 ) {
+	// Give the member a session, which should end when they are removed
+	sid := doTestMemberSession(t, ctx, sessions, key, d)
+
 	builder := flatbuffers.NewBuilder(32)
 	idOffset := builder.CreateByteString(d.Id())
 	services.MemberRemoveRequestStart(builder)
@@ -277,14 +389,93 @@ func doTestMemberRemove(
 		t.Errorf("failed to remove member: %v", err)
 	}
 
+	_, err = sessions.GetSessionEntity(ctx, sid)
+	if !errors.Is(err, store.ErrSessionNotFound) {
+		t.Errorf("session of removed member was not ended: %v", err)
+	}
+
 	l, err := ms.ListMembers(ctx, key)
 	if err != nil {
 		t.Fatalf("failed to list members after removing one: %v", err)
 	}
-	if len(l) != 1 {
-		t.Fatalf("expected only one member after delete, got %d", len(l))
+	if len(l) != 2 {
+		t.Fatalf("expected only two members after delete, got %d", len(l))
+	}
+	if !model.MemberEqual(l[1], c) {
+		t.Errorf("remaining member is not what was expected after delete: %+v != %+v", l[1], c)
+	}
+}
+
+func doTestMemberResetPassword(
+	t *testing.T,
+	ctx context.Context,
+	ms services.MemberService,
+	sessions store.SessionStore,
+	key crypto.Key,
+	a *model.Member,
+) *model.Member {
+	sid := doTestMemberSession(t, ctx, sessions, key, a)
+
+	builder := flatbuffers.NewBuilder(32)
+	idOffset := builder.CreateByteString(a.Id())
+	services.MemberResetPasswordRequestStart(builder)
+	services.MemberResetPasswordRequestAddId(builder, idOffset)
+	builder.Finish(services.MemberResetPasswordRequestEnd(builder))
+
+	req := services.GetRootAsMemberResetPasswordRequest(builder.FinishedBytes(), 0)
+	tpass, err := ms.ResetPassword(ctx, req, key)
+	if err != nil {
+		t.Fatalf("failed to reset member password: %v", err)
+	}
+
+	// The member is signed out and can only log in with the temporary password
+	_, err = sessions.GetSessionEntity(ctx, sid)
+	if !errors.Is(err, store.ErrSessionNotFound) {
+		t.Errorf("session of reset member was not ended: %v", err)
+	}
+	doTestMemberUnlock(t, ctx, ms, key, "UpdatedPassword12345!", false)
+	doTestMemberUnlock(t, ctx, ms, key, string(tpass), true)
+
+	_, m, err := ms.Unlock(ctx, doTestMemberUnlockRequest("testuser", string(tpass)))
+	if err != nil {
+		t.Fatalf("failed to unlock group with temporary password: %v", err)
+	}
+	if !m.Reset() {
+		t.Errorf("member with a temporary password is not required to change it")
+	}
+
+	// Changing the temporary password lets the member do everything again
+	doTestMemberChangePassword(t, ctx, ms, a, key, string(tpass), "UpdatedPassword12345!")
+	_, m, err = ms.Unlock(ctx, doTestMemberUnlockRequest("testuser", "UpdatedPassword12345!"))
+	if err != nil {
+		t.Fatalf("failed to unlock group after changing temporary password: %v", err)
+	}
+	if m.Reset() {
+		t.Errorf("member is still required to change their password after changing it")
+	}
+
+	return m
+}
+
+// doTestMemberSession gives the member a session and returns its ID.
+func doTestMemberSession(
+	t *testing.T, ctx context.Context, sessions store.SessionStore, key crypto.Key, m *model.Member,
+) model.Uuid {
+	sid, err := model.NewUuid()
+	if err != nil {
+		t.Fatalf("failed to create session ID: %v", err)
+	}
+	now := time.Now().UnixMilli()
+	se, err := store.NewSessionEntity(
+		model.NewSession(sid, model.Uuid(m.Id()), "test", "127.0.0.1", now, now), key, key,
+	)
+	if err != nil {
+		t.Fatalf("failed to create session entity: %v", err)
 	}
-	if !model.MemberEqual(l[0], c) {
-		t.Errorf("remaining member is not what was expected after delete: %+v != %+v", l[0], c)
+	err = sessions.AddSessionEntity(ctx, se)
+	if err != nil {
+		t.Fatalf("failed to add session: %v", err)
 	}
+
+	return sid
 }
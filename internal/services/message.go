@@ -9,24 +9,54 @@ import (
 	"regexp"
 
 	"github.com/bradenhc/kolob/internal/crypto"
+	"github.com/bradenhc/kolob/internal/events"
 	"github.com/bradenhc/kolob/internal/model"
 	"github.com/bradenhc/kolob/internal/store"
 )
 
 type MessageService struct {
-	store store.MessageStore
+	store         store.MessageStore
+	conversations store.ConversationStore
+	members       store.MemberStore
+	reactions     store.ReactionStore
+	tx            store.Transactor
+	events        events.Publisher
 }
 
-func NewMessageService(store store.MessageStore) MessageService {
-	return MessageService{store}
+func NewMessageService(
+	store store.MessageStore,
+	conversations store.ConversationStore,
+	members store.MemberStore,
+	reactions store.ReactionStore,
+	tx store.Transactor,
+	events events.Publisher,
+) MessageService {
+	return MessageService{store, conversations, members, reactions, tx, events}
 }
 
 func (s *MessageService) Add(
 	ctx context.Context, req *MessageAddRequest, key crypto.Key,
 ) (*model.Message, error) {
+	// Replies always attach to the message that started the thread, even when they are made to
+	// another reply in the same thread
+	var parent model.Uuid
+	if req.Parent() != nil {
+		pe, err := s.store.GetMessageEntity(ctx, model.Uuid(req.Parent()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get parent message from store: %v", err)
+		}
+		if pe.Conversation != model.Uuid(req.Conversation()) {
+			return nil, fmt.Errorf("parent message belongs to a different conversation")
+		}
+		parent = pe.Id
+		if pe.Thread != "" {
+			parent = pe.Thread
+		}
+	}
+
 	// Create the new message object
 	m, err := model.NewMessage(
-		model.Uuid(req.Author()), model.Uuid(req.Conversation()), string(req.Content()),
+		model.Uuid(req.Author()), model.Uuid(req.Conversation()), parent, string(req.Content()),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create message object: %v", err)
@@ -42,6 +72,10 @@ func (s *MessageService) Add(
 		return nil, fmt.Errorf("failed to store message entity: %v", err)
 	}
 
+	s.events.Publish(events.Event{
+		Type: events.MessageCreated, Data: m, Conversation: string(m.Conversation()),
+	})
+
 	return m, nil
 }
 
@@ -64,35 +98,121 @@ func (s *MessageService) Get(
 func (s *MessageService) Update(
 	ctx context.Context, req *MessageUpdateRequest, key crypto.Key,
 ) (*model.Message, error) {
-	entity, err := s.store.GetMessageEntity(ctx, model.Uuid(req.Id()))
-	if err != nil {
-		return nil, fmt.Errorf("failed to get message from store: %v", err)
-	}
+	var next *model.Message
+	err := s.tx.InTransaction(ctx, func(ctx context.Context) error {
+		entity, err := s.store.GetMessageEntity(ctx, model.Uuid(req.Id()))
+		if err != nil {
+			return fmt.Errorf("failed to get message from store: %v", err)
+		}
 
-	next, err := entity.Update(key, req.Content())
-	if err != nil {
-		return nil, fmt.Errorf("failed to update message entity: %v", err)
-	}
+		next, err = entity.Update(key, req.Content())
+		if err != nil {
+			return fmt.Errorf("failed to update message entity: %v", err)
+		}
+
+		err = s.store.UpdateMessageEntity(ctx, entity)
+		if err != nil {
+			return fmt.Errorf("failed to store updated message: %v", err)
+		}
 
-	err = s.store.UpdateMessageEntity(ctx, entity)
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to store updated message: %v", err)
+		return nil, err
 	}
 
+	s.events.Publish(events.Event{
+		Type: events.MessageUpdated, Data: next, Conversation: string(next.Conversation()),
+	})
+
 	return next, nil
 }
 
 func (s *MessageService) Remove(ctx context.Context, req *MessageRemoveRequest) error {
-	err := s.store.RemoveMessageEntity(ctx, model.Uuid(req.Id()))
+	// Find the conversation the message is in so only its participants hear about the removal
+	entity, err := s.store.GetMessageEntity(ctx, model.Uuid(req.Id()))
+	if err != nil {
+		return fmt.Errorf("failed to get message from store: %v", err)
+	}
+
+	err = s.store.RemoveMessageEntity(ctx, entity.Id)
 	if err != nil {
 		return fmt.Errorf("failed to remove message from store: %v", err)
 	}
+
+	s.events.Publish(events.Event{
+		Type:         events.MessageRemoved,
+		Data:         events.Removed{Id: string(entity.Id)},
+		Conversation: string(entity.Conversation),
+	})
+
 	return nil
 }
 
+// A MessageThread is a message together with the replies made in its thread.
+type MessageThread struct {
+	Parent  *model.Message   `json:"parent"`
+	Count   int              `json:"count"`
+	Replies []*model.Message `json:"replies"`
+}
+
+// ListThread gets the message that started a thread along with all replies in the thread, ordered
+// from oldest to newest.
+func (s *MessageService) ListThread(
+	ctx context.Context, req *MessageThreadRequest, key crypto.Key,
+) (*MessageThread, error) {
+	entity, err := s.store.GetMessageEntity(ctx, model.Uuid(req.Id()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get message entity from store: %v", err)
+	}
+
+	parent, err := entity.Decrypt(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt message entity: %v", err)
+	}
+
+	query := store.ListMessageDataQuery{Thread: &entity.Id}
+	entities, err := s.store.ListMessageEntities(ctx, entity.Conversation, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get thread replies from store: %v", err)
+	}
+
+	replies := make([]*model.Message, 0, len(entities))
+	for _, e := range entities {
+		m, err := e.Decrypt(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt reply in thread: %v", err)
+		}
+
+		replies = append(replies, m)
+	}
+
+	return &MessageThread{parent, len(replies), replies}, nil
+}
+
+// A ListedMessage is a message returned from List along with the number of replies in its thread
+// and the number of members that reacted to the message with each emoji or shortcode.
+type ListedMessage struct {
+	Message   *model.Message `json:"message"`
+	Replies   int            `json:"replies"`
+	Reactions map[string]int `json:"reactions"`
+}
+
+// List lists the messages in a conversation on behalf of a member. Members can only list the
+// messages in the conversations they can see, and get ErrNotParticipant for any other.
 func (s *MessageService) List(
-	ctx context.Context, req *MessageListRequest, key crypto.Key,
-) ([]*model.Message, error) {
+	ctx context.Context, member model.Uuid, req *MessageListRequest, key crypto.Key,
+) ([]ListedMessage, error) {
+	ok, err := canSeeConversation(
+		ctx, s.conversations, s.members, model.Uuid(req.Conversation()), member, key,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotParticipant, member)
+	}
+
 	var query store.ListMessageDataQuery
 	if req.Author() != nil {
 		query.Author = new(model.Uuid)
@@ -122,22 +242,61 @@ func (s *MessageService) List(
 		mlist = append(mlist, m)
 	}
 
-	if req.Pattern() == nil {
-		return mlist, nil
+	if req.Pattern() != nil {
+		// We have a content pattern, so we need to filter the list further
+		r, err := regexp.Compile(string(req.Pattern()))
+		if err != nil {
+			return nil, fmt.Errorf("invalid content pattern: %v", err)
+		}
+
+		flist := make([]*model.Message, 0, len(mlist))
+		for _, v := range mlist {
+			if r.Match(v.Content()) {
+				flist = append(flist, v)
+			}
+		}
+		mlist = flist
+	}
+
+	return s.withCounts(ctx, model.Uuid(req.Conversation()), mlist, key)
+}
+
+// withCounts pairs each message in the list with the number of replies in its thread and the
+// aggregated reactions made to it.
+func (s *MessageService) withCounts(
+	ctx context.Context, cid model.Uuid, mlist []*model.Message, key crypto.Key,
+) ([]ListedMessage, error) {
+	replies, err := s.store.CountConversationReplies(ctx, cid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count replies in store: %v", err)
 	}
 
-	// We have a content pattern, so we need to filter the list further
-	r, err := regexp.Compile(string(req.Pattern()))
+	entities, err := s.reactions.ListConversationReactionEntities(ctx, cid)
 	if err != nil {
-		return nil, fmt.Errorf("invalid content pattern: %v", err)
+		return nil, fmt.Errorf("failed to get reaction list from store: %v", err)
+	}
+
+	counts := make(map[model.Uuid]map[string]int)
+	for _, e := range entities {
+		r, err := e.Decrypt(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt reaction in list: %v", err)
+		}
+
+		if counts[e.Message] == nil {
+			counts[e.Message] = make(map[string]int)
+		}
+		counts[e.Message][string(r.Emoji())]++
 	}
 
-	flist := make([]*model.Message, 0, len(mlist))
-	for _, v := range mlist {
-		if r.Match(v.Content()) {
-			flist = append(flist, v)
+	llist := make([]ListedMessage, 0, len(mlist))
+	for _, m := range mlist {
+		reactions := counts[model.Uuid(m.Id())]
+		if reactions == nil {
+			reactions = make(map[string]int)
 		}
+		llist = append(llist, ListedMessage{m, replies[model.Uuid(m.Id())], reactions})
 	}
 
-	return flist, nil
+	return llist, nil
 }
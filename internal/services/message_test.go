@@ -6,11 +6,13 @@ package services_test
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"path"
 	"slices"
 	"testing"
 
 	"github.com/bradenhc/kolob/internal/crypto"
+	"github.com/bradenhc/kolob/internal/events"
 	"github.com/bradenhc/kolob/internal/model"
 	"github.com/bradenhc/kolob/internal/services"
 	"github.com/bradenhc/kolob/internal/store"
@@ -31,28 +33,41 @@ func TestMessageService(t *testing.T) {
 	}
 
 	ctx := context.Background()
+	broker := events.NewBroker()
 
 	// Setup group
+	tx := sqlite.NewTransactor(db)
 	groupStore := doTestGroupCreateStore(t, db)
-	svcGroup := services.NewGroupService(groupStore)
-	doTestGroupCreate(t, ctx, svcGroup)
+	memberStore := doTestMemberCreateStore(t, db)
+	convoStore := doTestConversationCreateStore(t, db)
+	ikey := doTestIndexKey(t)
+	svcGroup := services.NewGroupService(
+		groupStore, memberStore, convoStore, sqlite.NewInvitationStore(db),
+		sqlite.NewSessionStore(db), sqlite.NewRekeyStore(db), tx, ikey,
+	)
+	group := doTestGroupCreate(t, ctx, svcGroup)
 	key := doTestGroupAuth(t, ctx, svcGroup)
 
 	// Setup members
-	memberStore := doTestMemberCreateStore(t, db)
-	svcMember := services.NewMemberService(memberStore)
+	svcMember := services.NewMemberService(memberStore, sqlite.NewSessionStore(db), broker, ikey)
 	member1 := doTestMemberAdd(t, ctx, svcMember, key, "user1")
 	member2 := doTestMemberAdd(t, ctx, svcMember, key, "user2")
 
 	// Setup conversations
-	convoStore := doTestConversationCreateStore(t, db)
-	svcConvo := services.NewConversationService(convoStore)
+	svcConvo := services.NewConversationService(convoStore, memberStore, tx, broker)
 	convo1 := doTestConversationAdd(t, ctx, svcConvo, key, member1)
 	convo2 := doTestConversationAdd(t, ctx, svcConvo, key, member2)
 
 	// Create the message store and service
 	messageStore := doTestMessageCreateStore(t, db)
-	svcMessage := services.NewMessageService(messageStore)
+	reactionStore := doTestReactionCreateStore(t, db)
+	svcMessage := services.NewMessageService(
+		messageStore, convoStore, memberStore, reactionStore, tx, broker,
+	)
+
+	// Subscribe to events so we can verify the service announces new messages
+	sub, unsubscribe := broker.Subscribe()
+	defer unsubscribe()
 
 	// Add messages to the first conversation
 	message1 := doTestMessageAdd(t, ctx, svcMessage, key, convo1, member1, "Hey there!")
@@ -64,17 +79,73 @@ func TestMessageService(t *testing.T) {
 	message5 := doTestMessageAdd(t, ctx, svcMessage, key, convo2, member1, "Okay! Another convo!")
 	message6 := doTestMessageAdd(t, ctx, svcMessage, key, convo2, member2, "Can never have enough!")
 
-	doTestMessageList(t, ctx, svcMessage, key, convo1, message1, message2, message3)
-	doTestMessageList(t, ctx, svcMessage, key, convo2, message4, message5, message6)
+	doTestMessageEvents(t, sub, message1, message2, message3, message4, message5, message6)
+
+	doTestMessageList(t, ctx, svcMessage, key, convo1, member1, message1, message2, message3)
+	doTestMessageList(t, ctx, svcMessage, key, convo2, member2, message4, message5, message6)
+	doTestMessageListNotParticipant(t, ctx, svcMessage, key, convo1, member2)
+
+	// Group Moderators can read every conversation so that they can manage it
+	doTestMessageList(t, ctx, svcMessage, key, convo1, group.Creator, message1, message2, message3)
+
+	// Reply to a message; replying to a reply should keep the reply in the original thread
+	reply1 := doTestMessageReply(t, ctx, svcMessage, key, convo1, member2, message1, message1)
+	reply2 := doTestMessageReply(t, ctx, svcMessage, key, convo1, member1, reply1, message1)
+
+	doTestMessageThread(t, ctx, svcMessage, key, message1, reply1, reply2)
+	doTestMessageList(t, ctx, svcMessage, key, convo1, member1, message1, message2, message3)
+	doTestMessageListReplies(t, ctx, svcMessage, key, convo1, member1, map[string]int{
+		string(message1.Id()): 2,
+	})
+
+	// Messages stay readable, and the data key can still be rotated, after their author is removed
+	doTestMessageAuthorRemoved(
+		t, ctx, svcMessage, svcMember, svcGroup, key, convo1, member1, member2,
+		message1, message2, message3,
+	)
 }
 
-func doTestMessageCreateStore(t *testing.T, db *sql.DB) store.MessageStore {
-	store, err := sqlite.NewMessageStore(db)
+func doTestMessageAuthorRemoved(
+	t *testing.T,
+	ctx context.Context,
+	ms services.MessageService,
+	mems services.MemberService,
+	gs services.GroupService,
+	key crypto.Key,
+	convo *model.Conversation,
+	reader, author *model.Member,
+	expected ...*model.Message,
+) {
+	builder := flatbuffers.NewBuilder(64)
+	offsetId := builder.CreateByteString(author.Id())
+	services.MemberRemoveRequestStart(builder)
+	services.MemberRemoveRequestAddId(builder, offsetId)
+	builder.Finish(services.MemberRemoveRequestEnd(builder))
+
+	err := mems.RemoveMember(ctx, services.GetRootAsMemberRemoveRequest(builder.FinishedBytes(), 0))
 	if err != nil {
-		t.Fatalf("failed to create message store: %v", err)
+		t.Fatalf("failed to remove author: %v", err)
 	}
 
-	return store
+	doTestMessageList(t, ctx, ms, key, convo, reader, expected...)
+
+	builder = flatbuffers.NewBuilder(64)
+	offsetPass := builder.CreateString("Password12345678!")
+	services.GroupRekeyRequestStart(builder)
+	services.GroupRekeyRequestAddPassword(builder, offsetPass)
+	builder.Finish(services.GroupRekeyRequestEnd(builder))
+
+	_, err = gs.Rekey(ctx, services.GetRootAsGroupRekeyRequest(builder.FinishedBytes(), 0))
+	if err != nil {
+		t.Fatalf("failed to rekey group after removing an author: %v", err)
+	}
+
+	nkey := doTestGroupAuth(t, ctx, gs)
+	doTestMessageList(t, ctx, ms, nkey, convo, reader, expected...)
+}
+
+func doTestMessageCreateStore(t *testing.T, db *sql.DB) store.MessageStore {
+	return sqlite.NewMessageStore(db)
 }
 
 func doTestMessageAdd(
@@ -118,12 +189,90 @@ func doTestMessageAdd(
 	return message
 }
 
+func doTestMessageReply(
+	t *testing.T,
+	ctx context.Context,
+	ms services.MessageService,
+	key crypto.Key,
+	convo *model.Conversation,
+	author *model.Member,
+	parent *model.Message,
+	thread *model.Message) *model.Message {
+
+	builder := flatbuffers.NewBuilder(256)
+	offsetConvo := builder.CreateByteString(convo.Id())
+	offsetAuthor := builder.CreateByteString(author.Id())
+	offsetContent := builder.CreateString("Reply!")
+	offsetParent := builder.CreateByteString(parent.Id())
+	services.MessageAddRequestStart(builder)
+	services.MessageAddRequestAddConversation(builder, offsetConvo)
+	services.MessageAddRequestAddAuthor(builder, offsetAuthor)
+	services.MessageAddRequestAddContent(builder, offsetContent)
+	services.MessageAddRequestAddParent(builder, offsetParent)
+
+	offsetAddRequest := services.MessageAddRequestEnd(builder)
+	builder.Finish(offsetAddRequest)
+
+	addRequest := services.GetRootAsMessageAddRequest(builder.FinishedBytes(), 0)
+
+	message, err := ms.Add(ctx, addRequest, key)
+	if err != nil {
+		t.Fatalf("failed to add reply: %v", err)
+	}
+
+	if !slices.Equal(thread.Id(), message.Parent()) {
+		t.Errorf("incorrect reply parent: %s != %s", thread.Id(), message.Parent())
+	}
+
+	return message
+}
+
+func doTestMessageThread(
+	t *testing.T,
+	ctx context.Context,
+	ms services.MessageService,
+	key crypto.Key,
+	parent *model.Message,
+	expected ...*model.Message,
+) {
+	builder := flatbuffers.NewBuilder(64)
+	offsetId := builder.CreateByteString(parent.Id())
+
+	services.MessageThreadRequestStart(builder)
+	services.MessageThreadRequestAddId(builder, offsetId)
+
+	offsetRequest := services.MessageThreadRequestEnd(builder)
+	builder.Finish(offsetRequest)
+
+	request := services.GetRootAsMessageThreadRequest(builder.FinishedBytes(), 0)
+	thread, err := ms.ListThread(ctx, request, key)
+	if err != nil {
+		t.Fatalf("failed to list thread: %v", err)
+	}
+
+	if !model.MessageEqual(parent, thread.Parent) {
+		t.Errorf("thread parent not the same: %+v != %+v", thread.Parent, parent)
+	}
+	if thread.Count != len(expected) {
+		t.Errorf("bad reply count: %d != %d", thread.Count, len(expected))
+	}
+	if len(thread.Replies) != len(expected) {
+		t.Fatalf("bad length: replies != expected: %d != %d", len(thread.Replies), len(expected))
+	}
+	for i, m := range expected {
+		if !model.MessageEqual(m, thread.Replies[i]) {
+			t.Errorf("reply %d not the same: %+v != %+v", i, thread.Replies[i], m)
+		}
+	}
+}
+
 func doTestMessageList(
 	t *testing.T,
 	ctx context.Context,
 	ms services.MessageService,
 	key crypto.Key,
 	convo *model.Conversation,
+	member *model.Member,
 	expected ...*model.Message,
 ) {
 	builder := flatbuffers.NewBuilder(64)
@@ -136,7 +285,7 @@ func doTestMessageList(
 	builder.Finish(offsetRequest)
 
 	request := services.GetRootAsMessageListRequest(builder.FinishedBytes(), 0)
-	messages, err := ms.List(ctx, request, key)
+	messages, err := ms.List(ctx, model.Uuid(member.Id()), request, key)
 	if err != nil {
 		t.Fatalf("failed to list messages: %v", err)
 	}
@@ -145,3 +294,76 @@ func doTestMessageList(
 		t.Fatalf("bad length: messages != expected: %d != %d", len(messages), len(expected))
 	}
 }
+
+func doTestMessageListReplies(
+	t *testing.T,
+	ctx context.Context,
+	ms services.MessageService,
+	key crypto.Key,
+	convo *model.Conversation,
+	member *model.Member,
+	expected map[string]int,
+) {
+	builder := flatbuffers.NewBuilder(64)
+	offsetId := builder.CreateByteString(convo.Id())
+
+	services.MessageListRequestStart(builder)
+	services.MessageListRequestAddConversation(builder, offsetId)
+	builder.Finish(services.MessageListRequestEnd(builder))
+
+	request := services.GetRootAsMessageListRequest(builder.FinishedBytes(), 0)
+	messages, err := ms.List(ctx, model.Uuid(member.Id()), request, key)
+	if err != nil {
+		t.Fatalf("failed to list messages: %v", err)
+	}
+
+	for _, m := range messages {
+		if n := expected[string(m.Message.Id())]; m.Replies != n {
+			t.Errorf("bad reply count for message %s: %d != %d", m.Message.Id(), m.Replies, n)
+		}
+	}
+}
+
+func doTestMessageListNotParticipant(
+	t *testing.T,
+	ctx context.Context,
+	ms services.MessageService,
+	key crypto.Key,
+	convo *model.Conversation,
+	member *model.Member,
+) {
+	builder := flatbuffers.NewBuilder(64)
+	offsetId := builder.CreateByteString(convo.Id())
+
+	services.MessageListRequestStart(builder)
+	services.MessageListRequestAddConversation(builder, offsetId)
+	builder.Finish(services.MessageListRequestEnd(builder))
+
+	request := services.GetRootAsMessageListRequest(builder.FinishedBytes(), 0)
+	_, err := ms.List(ctx, model.Uuid(member.Id()), request, key)
+	if !errors.Is(err, services.ErrNotParticipant) {
+		t.Errorf("expected member not to participate in conversation, got %v", err)
+	}
+}
+
+func doTestMessageEvents(t *testing.T, sub <-chan events.Event, expected ...*model.Message) {
+	for _, m := range expected {
+		var e events.Event
+		select {
+		case e = <-sub:
+		default:
+			t.Fatalf("missing event for message %s", m.Id())
+		}
+
+		if e.Type != events.MessageCreated {
+			t.Errorf("incorrect event type: %s != %s", e.Type, events.MessageCreated)
+		}
+		data, ok := e.Data.(*model.Message)
+		if !ok {
+			t.Fatalf("incorrect event data type: %T", e.Data)
+		}
+		if !model.MessageEqual(m, data) {
+			t.Errorf("event data not the same: %+v != %+v", data, m)
+		}
+	}
+}
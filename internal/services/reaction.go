@@ -0,0 +1,173 @@
+// ---------------------------------------------------------------------------------------------- //
+// -- Copyright (c) 2024 Braden Hitchcock - MIT License (https://opensource.org/licenses/MIT)  -- //
+// ---------------------------------------------------------------------------------------------- //
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bradenhc/kolob/internal/crypto"
+	"github.com/bradenhc/kolob/internal/events"
+	"github.com/bradenhc/kolob/internal/model"
+	"github.com/bradenhc/kolob/internal/store"
+)
+
+// MaxReactionLength is the largest number of bytes allowed in the emoji or shortcode of a reaction.
+const MaxReactionLength = 64
+
+type ReactionService struct {
+	store    store.ReactionStore
+	messages store.MessageStore
+	tx       store.Transactor
+	events   events.Publisher
+}
+
+func NewReactionService(
+	store store.ReactionStore,
+	messages store.MessageStore,
+	tx store.Transactor,
+	events events.Publisher,
+) ReactionService {
+	return ReactionService{store, messages, tx, events}
+}
+
+// Add reacts to a message on behalf of a member. If the member already reacted to the message, the
+// previous reaction is replaced and announced as removed before the new one is announced.
+func (s *ReactionService) Add(
+	ctx context.Context, req *ReactionAddRequest, key crypto.Key,
+) (*model.Reaction, error) {
+	if len(req.Emoji()) == 0 || len(req.Emoji()) > MaxReactionLength {
+		return nil, fmt.Errorf("invalid reaction: must be between 1 and %d bytes", MaxReactionLength)
+	}
+
+	r := model.NewReaction(
+		model.Uuid(req.Message()), model.Uuid(req.Author()), string(req.Emoji()),
+	)
+
+	entity, err := store.NewReactionEntity(r, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create reaction entity: %v", err)
+	}
+
+	// Look up the reaction being replaced in the same transaction that replaces it, so the removal
+	// we announce is the one that actually happened
+	var message store.MessageEntity
+	var prev *model.Reaction
+	err = s.tx.InTransaction(ctx, func(ctx context.Context) error {
+		message, err = s.messages.GetMessageEntity(ctx, model.Uuid(req.Message()))
+		if err != nil {
+			return fmt.Errorf("failed to get message from store: %v", err)
+		}
+
+		prev, err = s.find(ctx, model.Uuid(req.Message()), model.Uuid(req.Author()), key)
+		if err != nil {
+			return err
+		}
+
+		err = s.store.PutReactionEntity(ctx, entity)
+		if err != nil {
+			return fmt.Errorf("failed to store reaction entity: %v", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cid := string(message.Conversation)
+	if prev != nil {
+		s.events.Publish(events.Event{
+			Type: events.ReactionRemoved, Data: prev, Conversation: cid,
+		})
+	}
+	s.events.Publish(events.Event{Type: events.ReactionCreated, Data: r, Conversation: cid})
+
+	return r, nil
+}
+
+func (s *ReactionService) Remove(
+	ctx context.Context, req *ReactionRemoveRequest, key crypto.Key,
+) error {
+	mid, author := model.Uuid(req.Message()), model.Uuid(req.Author())
+
+	var message store.MessageEntity
+	var prev *model.Reaction
+	err := s.tx.InTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		message, err = s.messages.GetMessageEntity(ctx, mid)
+		if err != nil {
+			return fmt.Errorf("failed to get message from store: %v", err)
+		}
+
+		prev, err = s.find(ctx, mid, author, key)
+		if err != nil {
+			return err
+		}
+		if prev == nil {
+			return fmt.Errorf("member %s has not reacted to message %s", author, mid)
+		}
+
+		err = s.store.RemoveReactionEntity(ctx, mid, author)
+		if err != nil {
+			return fmt.Errorf("failed to remove reaction from store: %v", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	s.events.Publish(events.Event{
+		Type: events.ReactionRemoved, Data: prev, Conversation: string(message.Conversation),
+	})
+
+	return nil
+}
+
+func (s *ReactionService) List(
+	ctx context.Context, req *ReactionListRequest, key crypto.Key,
+) ([]*model.Reaction, error) {
+	entities, err := s.store.ListReactionEntities(ctx, model.Uuid(req.Message()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reaction list from store: %v", err)
+	}
+
+	rlist := make([]*model.Reaction, 0, len(entities))
+	for _, e := range entities {
+		r, err := e.Decrypt(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt reaction in list: %v", err)
+		}
+
+		rlist = append(rlist, r)
+	}
+
+	return rlist, nil
+}
+
+// find gets the reaction a member made to a message, or nil if they have not reacted to it.
+func (s *ReactionService) find(
+	ctx context.Context, mid, author model.Uuid, key crypto.Key,
+) (*model.Reaction, error) {
+	entities, err := s.store.ListReactionEntities(ctx, mid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reaction list from store: %v", err)
+	}
+
+	for _, e := range entities {
+		if e.Author != author {
+			continue
+		}
+
+		r, err := e.Decrypt(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt reaction: %v", err)
+		}
+		return r, nil
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,232 @@
+// ---------------------------------------------------------------------------------------------- //
+// -- Copyright (c) 2024 Braden Hitchcock - MIT License (https://opensource.org/licenses/MIT)  -- //
+// ---------------------------------------------------------------------------------------------- //
+package services_test
+
+import (
+	"context"
+	"database/sql"
+	"path"
+	"slices"
+	"testing"
+
+	"github.com/bradenhc/kolob/internal/crypto"
+	"github.com/bradenhc/kolob/internal/events"
+	"github.com/bradenhc/kolob/internal/model"
+	"github.com/bradenhc/kolob/internal/services"
+	"github.com/bradenhc/kolob/internal/store"
+	"github.com/bradenhc/kolob/internal/store/sqlite"
+	flatbuffers "github.com/google/flatbuffers/go"
+)
+
+func TestReactionService(t *testing.T) {
+	// Setup the test
+	//
+	t.Parallel()
+	tempdir := t.TempDir()
+	dbpath := path.Join(tempdir, "kolob.db")
+
+	db, err := sqlite.Open(dbpath)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+
+	ctx := context.Background()
+	broker := events.NewBroker()
+
+	// Setup group
+	tx := sqlite.NewTransactor(db)
+	groupStore := doTestGroupCreateStore(t, db)
+	memberStore := doTestMemberCreateStore(t, db)
+	convoStore := doTestConversationCreateStore(t, db)
+	ikey := doTestIndexKey(t)
+	svcGroup := services.NewGroupService(
+		groupStore, memberStore, convoStore, sqlite.NewInvitationStore(db),
+		sqlite.NewSessionStore(db), sqlite.NewRekeyStore(db), tx, ikey,
+	)
+	doTestGroupCreate(t, ctx, svcGroup)
+	key := doTestGroupAuth(t, ctx, svcGroup)
+
+	// Setup members
+	svcMember := services.NewMemberService(memberStore, sqlite.NewSessionStore(db), broker, ikey)
+	member1 := doTestMemberAdd(t, ctx, svcMember, key, "user1")
+	member2 := doTestMemberAdd(t, ctx, svcMember, key, "user2")
+
+	// Setup conversations and messages
+	svcConvo := services.NewConversationService(convoStore, memberStore, tx, broker)
+	convo := doTestConversationAdd(t, ctx, svcConvo, key, member1)
+
+	messageStore := doTestMessageCreateStore(t, db)
+	reactionStore := doTestReactionCreateStore(t, db)
+	svcMessage := services.NewMessageService(
+		messageStore, convoStore, memberStore, reactionStore, tx, broker,
+	)
+	message1 := doTestMessageAdd(t, ctx, svcMessage, key, convo, member1, "React to me!")
+	message2 := doTestMessageAdd(t, ctx, svcMessage, key, convo, member2, "Me too!")
+
+	// Create the reaction service
+	svcReaction := services.NewReactionService(reactionStore, messageStore, tx, broker)
+
+	// Subscribe to events so we can verify the service announces reactions
+	sub, unsubscribe := broker.Subscribe()
+	defer unsubscribe()
+
+	// React to the messages. Reacting twice to the same message replaces the first reaction.
+	doTestReactionAdd(t, ctx, svcReaction, key, message1, member1, ":tada:")
+	doTestReactionAdd(t, ctx, svcReaction, key, message1, member2, ":tada:")
+	doTestReactionAdd(t, ctx, svcReaction, key, message2, member1, ":smile:")
+	doTestReactionAdd(t, ctx, svcReaction, key, message2, member1, ":thumbsup:")
+
+	doTestReactionEvents(t, sub,
+		events.ReactionCreated, events.ReactionCreated, events.ReactionCreated,
+		events.ReactionRemoved, events.ReactionCreated,
+	)
+
+	doTestReactionList(t, ctx, svcReaction, key, message1, 2)
+	doTestReactionList(t, ctx, svcReaction, key, message2, 1)
+	doTestReactionCounts(t, ctx, svcMessage, key, convo, member1, map[string]map[string]int{
+		string(message1.Id()): {":tada:": 2},
+		string(message2.Id()): {":thumbsup:": 1},
+	})
+
+	doTestReactionRemove(t, ctx, svcReaction, key, message1, member2)
+	doTestReactionEvents(t, sub, events.ReactionRemoved)
+	doTestReactionList(t, ctx, svcReaction, key, message1, 1)
+}
+
+func doTestReactionCreateStore(t *testing.T, db *sql.DB) store.ReactionStore {
+	return sqlite.NewReactionStore(db)
+}
+
+func doTestReactionAdd(
+	t *testing.T,
+	ctx context.Context,
+	rs services.ReactionService,
+	key crypto.Key,
+	message *model.Message,
+	author *model.Member,
+	emoji string) {
+
+	builder := flatbuffers.NewBuilder(256)
+	offsetMessage := builder.CreateByteString(message.Id())
+	offsetAuthor := builder.CreateByteString(author.Id())
+	offsetEmoji := builder.CreateString(emoji)
+	services.ReactionAddRequestStart(builder)
+	services.ReactionAddRequestAddMessage(builder, offsetMessage)
+	services.ReactionAddRequestAddAuthor(builder, offsetAuthor)
+	services.ReactionAddRequestAddEmoji(builder, offsetEmoji)
+	builder.Finish(services.ReactionAddRequestEnd(builder))
+
+	req := services.GetRootAsReactionAddRequest(builder.FinishedBytes(), 0)
+	reaction, err := rs.Add(ctx, req, key)
+	if err != nil {
+		t.Fatalf("failed to add reaction: %v", err)
+	}
+
+	if !slices.Equal(reaction.Emoji(), []byte(emoji)) {
+		t.Errorf("incorrect reaction emoji: '%s' != '%s'", reaction.Emoji(), emoji)
+	}
+}
+
+func doTestReactionRemove(
+	t *testing.T,
+	ctx context.Context,
+	rs services.ReactionService,
+	key crypto.Key,
+	message *model.Message,
+	author *model.Member,
+) {
+	builder := flatbuffers.NewBuilder(256)
+	offsetMessage := builder.CreateByteString(message.Id())
+	offsetAuthor := builder.CreateByteString(author.Id())
+	services.ReactionRemoveRequestStart(builder)
+	services.ReactionRemoveRequestAddMessage(builder, offsetMessage)
+	services.ReactionRemoveRequestAddAuthor(builder, offsetAuthor)
+	builder.Finish(services.ReactionRemoveRequestEnd(builder))
+
+	req := services.GetRootAsReactionRemoveRequest(builder.FinishedBytes(), 0)
+	err := rs.Remove(ctx, req, key)
+	if err != nil {
+		t.Fatalf("failed to remove reaction: %v", err)
+	}
+}
+
+func doTestReactionList(
+	t *testing.T,
+	ctx context.Context,
+	rs services.ReactionService,
+	key crypto.Key,
+	message *model.Message,
+	expected int,
+) {
+	builder := flatbuffers.NewBuilder(64)
+	offsetMessage := builder.CreateByteString(message.Id())
+	services.ReactionListRequestStart(builder)
+	services.ReactionListRequestAddMessage(builder, offsetMessage)
+	builder.Finish(services.ReactionListRequestEnd(builder))
+
+	req := services.GetRootAsReactionListRequest(builder.FinishedBytes(), 0)
+	reactions, err := rs.List(ctx, req, key)
+	if err != nil {
+		t.Fatalf("failed to list reactions: %v", err)
+	}
+
+	if len(reactions) != expected {
+		t.Errorf("bad length: reactions != expected: %d != %d", len(reactions), expected)
+	}
+}
+
+func doTestReactionCounts(
+	t *testing.T,
+	ctx context.Context,
+	ms services.MessageService,
+	key crypto.Key,
+	convo *model.Conversation,
+	member *model.Member,
+	expected map[string]map[string]int,
+) {
+	builder := flatbuffers.NewBuilder(64)
+	offsetId := builder.CreateByteString(convo.Id())
+	services.MessageListRequestStart(builder)
+	services.MessageListRequestAddConversation(builder, offsetId)
+	builder.Finish(services.MessageListRequestEnd(builder))
+
+	req := services.GetRootAsMessageListRequest(builder.FinishedBytes(), 0)
+	messages, err := ms.List(ctx, model.Uuid(member.Id()), req, key)
+	if err != nil {
+		t.Fatalf("failed to list messages: %v", err)
+	}
+
+	for _, m := range messages {
+		counts := expected[string(m.Message.Id())]
+		if len(counts) != len(m.Reactions) {
+			t.Errorf(
+				"bad reaction counts for message %s: %v != %v", m.Message.Id(), m.Reactions, counts,
+			)
+			continue
+		}
+		for emoji, n := range counts {
+			if m.Reactions[emoji] != n {
+				t.Errorf("bad count for reaction %s: %d != %d", emoji, m.Reactions[emoji], n)
+			}
+		}
+	}
+}
+
+func doTestReactionEvents(t *testing.T, sub <-chan events.Event, expected ...string) {
+	for _, typ := range expected {
+		var e events.Event
+		select {
+		case e = <-sub:
+		default:
+			t.Fatalf("missing %s event", typ)
+		}
+
+		if e.Type != typ {
+			t.Errorf("incorrect event type: %s != %s", e.Type, typ)
+		}
+		if _, ok := e.Data.(*model.Reaction); !ok {
+			t.Fatalf("incorrect event data type: %T", e.Data)
+		}
+	}
+}
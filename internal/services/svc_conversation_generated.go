@@ -74,8 +74,25 @@ func (rcv *ConversationAddRequest) ModeratorsLength() int {
 	return 0
 }
 
+func (rcv *ConversationAddRequest) Participants(j int) []byte {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(10))
+	if o != 0 {
+		a := rcv._tab.Vector(o)
+		return rcv._tab.ByteVector(a + flatbuffers.UOffsetT(j*4))
+	}
+	return nil
+}
+
+func (rcv *ConversationAddRequest) ParticipantsLength() int {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(10))
+	if o != 0 {
+		return rcv._tab.VectorLen(o)
+	}
+	return 0
+}
+
 func ConversationAddRequestStart(builder *flatbuffers.Builder) {
-	builder.StartObject(3)
+	builder.StartObject(4)
 }
 func ConversationAddRequestAddName(builder *flatbuffers.Builder, name flatbuffers.UOffsetT) {
 	builder.PrependUOffsetTSlot(0, flatbuffers.UOffsetT(name), 0)
@@ -89,6 +106,12 @@ func ConversationAddRequestAddModerators(builder *flatbuffers.Builder, moderator
 func ConversationAddRequestStartModeratorsVector(builder *flatbuffers.Builder, numElems int) flatbuffers.UOffsetT {
 	return builder.StartVector(4, numElems, 4)
 }
+func ConversationAddRequestAddParticipants(builder *flatbuffers.Builder, participants flatbuffers.UOffsetT) {
+	builder.PrependUOffsetTSlot(3, flatbuffers.UOffsetT(participants), 0)
+}
+func ConversationAddRequestStartParticipantsVector(builder *flatbuffers.Builder, numElems int) flatbuffers.UOffsetT {
+	return builder.StartVector(4, numElems, 4)
+}
 func ConversationAddRequestEnd(builder *flatbuffers.Builder) flatbuffers.UOffsetT {
 	return builder.EndObject()
 }
@@ -368,6 +391,156 @@ func ConversationModsRemoveRequestStartModeratorsVector(builder *flatbuffers.Bui
 func ConversationModsRemoveRequestEnd(builder *flatbuffers.Builder) flatbuffers.UOffsetT {
 	return builder.EndObject()
 }
+type ConversationParticipantsAddRequest struct {
+	_tab flatbuffers.Table
+}
+
+func GetRootAsConversationParticipantsAddRequest(buf []byte, offset flatbuffers.UOffsetT) *ConversationParticipantsAddRequest {
+	n := flatbuffers.GetUOffsetT(buf[offset:])
+	x := &ConversationParticipantsAddRequest{}
+	x.Init(buf, n+offset)
+	return x
+}
+
+func FinishConversationParticipantsAddRequestBuffer(builder *flatbuffers.Builder, offset flatbuffers.UOffsetT) {
+	builder.Finish(offset)
+}
+
+func GetSizePrefixedRootAsConversationParticipantsAddRequest(buf []byte, offset flatbuffers.UOffsetT) *ConversationParticipantsAddRequest {
+	n := flatbuffers.GetUOffsetT(buf[offset+flatbuffers.SizeUint32:])
+	x := &ConversationParticipantsAddRequest{}
+	x.Init(buf, n+offset+flatbuffers.SizeUint32)
+	return x
+}
+
+func FinishSizePrefixedConversationParticipantsAddRequestBuffer(builder *flatbuffers.Builder, offset flatbuffers.UOffsetT) {
+	builder.FinishSizePrefixed(offset)
+}
+
+func (rcv *ConversationParticipantsAddRequest) Init(buf []byte, i flatbuffers.UOffsetT) {
+	rcv._tab.Bytes = buf
+	rcv._tab.Pos = i
+}
+
+func (rcv *ConversationParticipantsAddRequest) Table() flatbuffers.Table {
+	return rcv._tab
+}
+
+func (rcv *ConversationParticipantsAddRequest) Id() []byte {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(4))
+	if o != 0 {
+		return rcv._tab.ByteVector(o + rcv._tab.Pos)
+	}
+	return nil
+}
+
+func (rcv *ConversationParticipantsAddRequest) Participants(j int) []byte {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(6))
+	if o != 0 {
+		a := rcv._tab.Vector(o)
+		return rcv._tab.ByteVector(a + flatbuffers.UOffsetT(j*4))
+	}
+	return nil
+}
+
+func (rcv *ConversationParticipantsAddRequest) ParticipantsLength() int {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(6))
+	if o != 0 {
+		return rcv._tab.VectorLen(o)
+	}
+	return 0
+}
+
+func ConversationParticipantsAddRequestStart(builder *flatbuffers.Builder) {
+	builder.StartObject(2)
+}
+func ConversationParticipantsAddRequestAddId(builder *flatbuffers.Builder, id flatbuffers.UOffsetT) {
+	builder.PrependUOffsetTSlot(0, flatbuffers.UOffsetT(id), 0)
+}
+func ConversationParticipantsAddRequestAddParticipants(builder *flatbuffers.Builder, participants flatbuffers.UOffsetT) {
+	builder.PrependUOffsetTSlot(1, flatbuffers.UOffsetT(participants), 0)
+}
+func ConversationParticipantsAddRequestStartParticipantsVector(builder *flatbuffers.Builder, numElems int) flatbuffers.UOffsetT {
+	return builder.StartVector(4, numElems, 4)
+}
+func ConversationParticipantsAddRequestEnd(builder *flatbuffers.Builder) flatbuffers.UOffsetT {
+	return builder.EndObject()
+}
+type ConversationParticipantsRemoveRequest struct {
+	_tab flatbuffers.Table
+}
+
+func GetRootAsConversationParticipantsRemoveRequest(buf []byte, offset flatbuffers.UOffsetT) *ConversationParticipantsRemoveRequest {
+	n := flatbuffers.GetUOffsetT(buf[offset:])
+	x := &ConversationParticipantsRemoveRequest{}
+	x.Init(buf, n+offset)
+	return x
+}
+
+func FinishConversationParticipantsRemoveRequestBuffer(builder *flatbuffers.Builder, offset flatbuffers.UOffsetT) {
+	builder.Finish(offset)
+}
+
+func GetSizePrefixedRootAsConversationParticipantsRemoveRequest(buf []byte, offset flatbuffers.UOffsetT) *ConversationParticipantsRemoveRequest {
+	n := flatbuffers.GetUOffsetT(buf[offset+flatbuffers.SizeUint32:])
+	x := &ConversationParticipantsRemoveRequest{}
+	x.Init(buf, n+offset+flatbuffers.SizeUint32)
+	return x
+}
+
+func FinishSizePrefixedConversationParticipantsRemoveRequestBuffer(builder *flatbuffers.Builder, offset flatbuffers.UOffsetT) {
+	builder.FinishSizePrefixed(offset)
+}
+
+func (rcv *ConversationParticipantsRemoveRequest) Init(buf []byte, i flatbuffers.UOffsetT) {
+	rcv._tab.Bytes = buf
+	rcv._tab.Pos = i
+}
+
+func (rcv *ConversationParticipantsRemoveRequest) Table() flatbuffers.Table {
+	return rcv._tab
+}
+
+func (rcv *ConversationParticipantsRemoveRequest) Id() []byte {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(4))
+	if o != 0 {
+		return rcv._tab.ByteVector(o + rcv._tab.Pos)
+	}
+	return nil
+}
+
+func (rcv *ConversationParticipantsRemoveRequest) Participants(j int) []byte {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(6))
+	if o != 0 {
+		a := rcv._tab.Vector(o)
+		return rcv._tab.ByteVector(a + flatbuffers.UOffsetT(j*4))
+	}
+	return nil
+}
+
+func (rcv *ConversationParticipantsRemoveRequest) ParticipantsLength() int {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(6))
+	if o != 0 {
+		return rcv._tab.VectorLen(o)
+	}
+	return 0
+}
+
+func ConversationParticipantsRemoveRequestStart(builder *flatbuffers.Builder) {
+	builder.StartObject(2)
+}
+func ConversationParticipantsRemoveRequestAddId(builder *flatbuffers.Builder, id flatbuffers.UOffsetT) {
+	builder.PrependUOffsetTSlot(0, flatbuffers.UOffsetT(id), 0)
+}
+func ConversationParticipantsRemoveRequestAddParticipants(builder *flatbuffers.Builder, participants flatbuffers.UOffsetT) {
+	builder.PrependUOffsetTSlot(1, flatbuffers.UOffsetT(participants), 0)
+}
+func ConversationParticipantsRemoveRequestStartParticipantsVector(builder *flatbuffers.Builder, numElems int) flatbuffers.UOffsetT {
+	return builder.StartVector(4, numElems, 4)
+}
+func ConversationParticipantsRemoveRequestEnd(builder *flatbuffers.Builder) flatbuffers.UOffsetT {
+	return builder.EndObject()
+}
 type ConversationRemoveRequest struct {
 	_tab flatbuffers.Table
 }
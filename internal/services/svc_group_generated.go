@@ -73,8 +73,32 @@ func (rcv *GroupInitRequest) Password() []byte {
 	return nil
 }
 
+func (rcv *GroupInitRequest) CreatorUsername() []byte {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(12))
+	if o != 0 {
+		return rcv._tab.ByteVector(o + rcv._tab.Pos)
+	}
+	return nil
+}
+
+func (rcv *GroupInitRequest) CreatorName() []byte {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(14))
+	if o != 0 {
+		return rcv._tab.ByteVector(o + rcv._tab.Pos)
+	}
+	return nil
+}
+
+func (rcv *GroupInitRequest) CreatorPassword() []byte {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(16))
+	if o != 0 {
+		return rcv._tab.ByteVector(o + rcv._tab.Pos)
+	}
+	return nil
+}
+
 func GroupInitRequestStart(builder *flatbuffers.Builder) {
-	builder.StartObject(4)
+	builder.StartObject(7)
 }
 func GroupInitRequestAddGroupId(builder *flatbuffers.Builder, groupId flatbuffers.UOffsetT) {
 	builder.PrependUOffsetTSlot(0, flatbuffers.UOffsetT(groupId), 0)
@@ -88,6 +112,15 @@ func GroupInitRequestAddDescription(builder *flatbuffers.Builder, description fl
 func GroupInitRequestAddPassword(builder *flatbuffers.Builder, password flatbuffers.UOffsetT) {
 	builder.PrependUOffsetTSlot(3, flatbuffers.UOffsetT(password), 0)
 }
+func GroupInitRequestAddCreatorUsername(builder *flatbuffers.Builder, creatorUsername flatbuffers.UOffsetT) {
+	builder.PrependUOffsetTSlot(4, flatbuffers.UOffsetT(creatorUsername), 0)
+}
+func GroupInitRequestAddCreatorName(builder *flatbuffers.Builder, creatorName flatbuffers.UOffsetT) {
+	builder.PrependUOffsetTSlot(5, flatbuffers.UOffsetT(creatorName), 0)
+}
+func GroupInitRequestAddCreatorPassword(builder *flatbuffers.Builder, creatorPassword flatbuffers.UOffsetT) {
+	builder.PrependUOffsetTSlot(6, flatbuffers.UOffsetT(creatorPassword), 0)
+}
 func GroupInitRequestEnd(builder *flatbuffers.Builder) flatbuffers.UOffsetT {
 	return builder.EndObject()
 }
@@ -365,3 +398,242 @@ func GroupChangePasswordRequestAddNewPassword(builder *flatbuffers.Builder, newP
 func GroupChangePasswordRequestEnd(builder *flatbuffers.Builder) flatbuffers.UOffsetT {
 	return builder.EndObject()
 }
+type GroupRekeyRequest struct {
+	_tab flatbuffers.Table
+}
+
+func GetRootAsGroupRekeyRequest(buf []byte, offset flatbuffers.UOffsetT) *GroupRekeyRequest {
+	n := flatbuffers.GetUOffsetT(buf[offset:])
+	x := &GroupRekeyRequest{}
+	x.Init(buf, n+offset)
+	return x
+}
+
+func FinishGroupRekeyRequestBuffer(builder *flatbuffers.Builder, offset flatbuffers.UOffsetT) {
+	builder.Finish(offset)
+}
+
+func GetSizePrefixedRootAsGroupRekeyRequest(buf []byte, offset flatbuffers.UOffsetT) *GroupRekeyRequest {
+	n := flatbuffers.GetUOffsetT(buf[offset+flatbuffers.SizeUint32:])
+	x := &GroupRekeyRequest{}
+	x.Init(buf, n+offset+flatbuffers.SizeUint32)
+	return x
+}
+
+func FinishSizePrefixedGroupRekeyRequestBuffer(builder *flatbuffers.Builder, offset flatbuffers.UOffsetT) {
+	builder.FinishSizePrefixed(offset)
+}
+
+func (rcv *GroupRekeyRequest) Init(buf []byte, i flatbuffers.UOffsetT) {
+	rcv._tab.Bytes = buf
+	rcv._tab.Pos = i
+}
+
+func (rcv *GroupRekeyRequest) Table() flatbuffers.Table {
+	return rcv._tab
+}
+
+func (rcv *GroupRekeyRequest) Password() []byte {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(4))
+	if o != 0 {
+		return rcv._tab.ByteVector(o + rcv._tab.Pos)
+	}
+	return nil
+}
+
+func (rcv *GroupRekeyRequest) Shares() byte {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(6))
+	if o != 0 {
+		return rcv._tab.GetByte(o + rcv._tab.Pos)
+	}
+	return 0
+}
+
+func (rcv *GroupRekeyRequest) MutateShares(n byte) bool {
+	return rcv._tab.MutateByteSlot(6, n)
+}
+
+func (rcv *GroupRekeyRequest) Threshold() byte {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(8))
+	if o != 0 {
+		return rcv._tab.GetByte(o + rcv._tab.Pos)
+	}
+	return 0
+}
+
+func (rcv *GroupRekeyRequest) MutateThreshold(n byte) bool {
+	return rcv._tab.MutateByteSlot(8, n)
+}
+
+func GroupRekeyRequestStart(builder *flatbuffers.Builder) {
+	builder.StartObject(3)
+}
+func GroupRekeyRequestAddPassword(builder *flatbuffers.Builder, password flatbuffers.UOffsetT) {
+	builder.PrependUOffsetTSlot(0, flatbuffers.UOffsetT(password), 0)
+}
+func GroupRekeyRequestAddShares(builder *flatbuffers.Builder, shares byte) {
+	builder.PrependByteSlot(1, shares, 0)
+}
+func GroupRekeyRequestAddThreshold(builder *flatbuffers.Builder, threshold byte) {
+	builder.PrependByteSlot(2, threshold, 0)
+}
+func GroupRekeyRequestEnd(builder *flatbuffers.Builder) flatbuffers.UOffsetT {
+	return builder.EndObject()
+}
+type GroupRecoveryKitRequest struct {
+	_tab flatbuffers.Table
+}
+
+func GetRootAsGroupRecoveryKitRequest(buf []byte, offset flatbuffers.UOffsetT) *GroupRecoveryKitRequest {
+	n := flatbuffers.GetUOffsetT(buf[offset:])
+	x := &GroupRecoveryKitRequest{}
+	x.Init(buf, n+offset)
+	return x
+}
+
+func FinishGroupRecoveryKitRequestBuffer(builder *flatbuffers.Builder, offset flatbuffers.UOffsetT) {
+	builder.Finish(offset)
+}
+
+func GetSizePrefixedRootAsGroupRecoveryKitRequest(buf []byte, offset flatbuffers.UOffsetT) *GroupRecoveryKitRequest {
+	n := flatbuffers.GetUOffsetT(buf[offset+flatbuffers.SizeUint32:])
+	x := &GroupRecoveryKitRequest{}
+	x.Init(buf, n+offset+flatbuffers.SizeUint32)
+	return x
+}
+
+func FinishSizePrefixedGroupRecoveryKitRequestBuffer(builder *flatbuffers.Builder, offset flatbuffers.UOffsetT) {
+	builder.FinishSizePrefixed(offset)
+}
+
+func (rcv *GroupRecoveryKitRequest) Init(buf []byte, i flatbuffers.UOffsetT) {
+	rcv._tab.Bytes = buf
+	rcv._tab.Pos = i
+}
+
+func (rcv *GroupRecoveryKitRequest) Table() flatbuffers.Table {
+	return rcv._tab
+}
+
+func (rcv *GroupRecoveryKitRequest) Password() []byte {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(4))
+	if o != 0 {
+		return rcv._tab.ByteVector(o + rcv._tab.Pos)
+	}
+	return nil
+}
+
+func (rcv *GroupRecoveryKitRequest) Shares() byte {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(6))
+	if o != 0 {
+		return rcv._tab.GetByte(o + rcv._tab.Pos)
+	}
+	return 0
+}
+
+func (rcv *GroupRecoveryKitRequest) MutateShares(n byte) bool {
+	return rcv._tab.MutateByteSlot(6, n)
+}
+
+func (rcv *GroupRecoveryKitRequest) Threshold() byte {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(8))
+	if o != 0 {
+		return rcv._tab.GetByte(o + rcv._tab.Pos)
+	}
+	return 0
+}
+
+func (rcv *GroupRecoveryKitRequest) MutateThreshold(n byte) bool {
+	return rcv._tab.MutateByteSlot(8, n)
+}
+
+func GroupRecoveryKitRequestStart(builder *flatbuffers.Builder) {
+	builder.StartObject(3)
+}
+func GroupRecoveryKitRequestAddPassword(builder *flatbuffers.Builder, password flatbuffers.UOffsetT) {
+	builder.PrependUOffsetTSlot(0, flatbuffers.UOffsetT(password), 0)
+}
+func GroupRecoveryKitRequestAddShares(builder *flatbuffers.Builder, shares byte) {
+	builder.PrependByteSlot(1, shares, 0)
+}
+func GroupRecoveryKitRequestAddThreshold(builder *flatbuffers.Builder, threshold byte) {
+	builder.PrependByteSlot(2, threshold, 0)
+}
+func GroupRecoveryKitRequestEnd(builder *flatbuffers.Builder) flatbuffers.UOffsetT {
+	return builder.EndObject()
+}
+type GroupRecoverRequest struct {
+	_tab flatbuffers.Table
+}
+
+func GetRootAsGroupRecoverRequest(buf []byte, offset flatbuffers.UOffsetT) *GroupRecoverRequest {
+	n := flatbuffers.GetUOffsetT(buf[offset:])
+	x := &GroupRecoverRequest{}
+	x.Init(buf, n+offset)
+	return x
+}
+
+func FinishGroupRecoverRequestBuffer(builder *flatbuffers.Builder, offset flatbuffers.UOffsetT) {
+	builder.Finish(offset)
+}
+
+func GetSizePrefixedRootAsGroupRecoverRequest(buf []byte, offset flatbuffers.UOffsetT) *GroupRecoverRequest {
+	n := flatbuffers.GetUOffsetT(buf[offset+flatbuffers.SizeUint32:])
+	x := &GroupRecoverRequest{}
+	x.Init(buf, n+offset+flatbuffers.SizeUint32)
+	return x
+}
+
+func FinishSizePrefixedGroupRecoverRequestBuffer(builder *flatbuffers.Builder, offset flatbuffers.UOffsetT) {
+	builder.FinishSizePrefixed(offset)
+}
+
+func (rcv *GroupRecoverRequest) Init(buf []byte, i flatbuffers.UOffsetT) {
+	rcv._tab.Bytes = buf
+	rcv._tab.Pos = i
+}
+
+func (rcv *GroupRecoverRequest) Table() flatbuffers.Table {
+	return rcv._tab
+}
+
+func (rcv *GroupRecoverRequest) Shares(j int) []byte {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(4))
+	if o != 0 {
+		a := rcv._tab.Vector(o)
+		return rcv._tab.ByteVector(a + flatbuffers.UOffsetT(j*4))
+	}
+	return nil
+}
+
+func (rcv *GroupRecoverRequest) SharesLength() int {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(4))
+	if o != 0 {
+		return rcv._tab.VectorLen(o)
+	}
+	return 0
+}
+
+func (rcv *GroupRecoverRequest) NewPassword() []byte {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(6))
+	if o != 0 {
+		return rcv._tab.ByteVector(o + rcv._tab.Pos)
+	}
+	return nil
+}
+
+func GroupRecoverRequestStart(builder *flatbuffers.Builder) {
+	builder.StartObject(2)
+}
+func GroupRecoverRequestAddShares(builder *flatbuffers.Builder, shares flatbuffers.UOffsetT) {
+	builder.PrependUOffsetTSlot(0, flatbuffers.UOffsetT(shares), 0)
+}
+func GroupRecoverRequestStartSharesVector(builder *flatbuffers.Builder, numElems int) flatbuffers.UOffsetT {
+	return builder.StartVector(4, numElems, 4)
+}
+func GroupRecoverRequestAddNewPassword(builder *flatbuffers.Builder, newPassword flatbuffers.UOffsetT) {
+	builder.PrependUOffsetTSlot(1, flatbuffers.UOffsetT(newPassword), 0)
+}
+func GroupRecoverRequestEnd(builder *flatbuffers.Builder) flatbuffers.UOffsetT {
+	return builder.EndObject()
+}
@@ -65,8 +65,20 @@ func (rcv *MemberCreateRequest) Password() []byte {
 	return nil
 }
 
+func (rcv *MemberCreateRequest) Role() int8 {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(10))
+	if o != 0 {
+		return rcv._tab.GetInt8(o + rcv._tab.Pos)
+	}
+	return 0
+}
+
+func (rcv *MemberCreateRequest) MutateRole(n int8) bool {
+	return rcv._tab.MutateInt8Slot(10, n)
+}
+
 func MemberCreateRequestStart(builder *flatbuffers.Builder) {
-	builder.StartObject(3)
+	builder.StartObject(4)
 }
 func MemberCreateRequestAddUsername(builder *flatbuffers.Builder, username flatbuffers.UOffsetT) {
 	builder.PrependUOffsetTSlot(0, flatbuffers.UOffsetT(username), 0)
@@ -77,6 +89,9 @@ func MemberCreateRequestAddName(builder *flatbuffers.Builder, name flatbuffers.U
 func MemberCreateRequestAddPassword(builder *flatbuffers.Builder, password flatbuffers.UOffsetT) {
 	builder.PrependUOffsetTSlot(2, flatbuffers.UOffsetT(password), 0)
 }
+func MemberCreateRequestAddRole(builder *flatbuffers.Builder, role int8) {
+	builder.PrependInt8Slot(3, role, 0)
+}
 func MemberCreateRequestEnd(builder *flatbuffers.Builder) flatbuffers.UOffsetT {
 	return builder.EndObject()
 }
@@ -143,6 +158,58 @@ func MemberAuthenticateRequestAddPassword(builder *flatbuffers.Builder, password
 func MemberAuthenticateRequestEnd(builder *flatbuffers.Builder) flatbuffers.UOffsetT {
 	return builder.EndObject()
 }
+type MemberGetRequest struct {
+	_tab flatbuffers.Table
+}
+
+func GetRootAsMemberGetRequest(buf []byte, offset flatbuffers.UOffsetT) *MemberGetRequest {
+	n := flatbuffers.GetUOffsetT(buf[offset:])
+	x := &MemberGetRequest{}
+	x.Init(buf, n+offset)
+	return x
+}
+
+func FinishMemberGetRequestBuffer(builder *flatbuffers.Builder, offset flatbuffers.UOffsetT) {
+	builder.Finish(offset)
+}
+
+func GetSizePrefixedRootAsMemberGetRequest(buf []byte, offset flatbuffers.UOffsetT) *MemberGetRequest {
+	n := flatbuffers.GetUOffsetT(buf[offset+flatbuffers.SizeUint32:])
+	x := &MemberGetRequest{}
+	x.Init(buf, n+offset+flatbuffers.SizeUint32)
+	return x
+}
+
+func FinishSizePrefixedMemberGetRequestBuffer(builder *flatbuffers.Builder, offset flatbuffers.UOffsetT) {
+	builder.FinishSizePrefixed(offset)
+}
+
+func (rcv *MemberGetRequest) Init(buf []byte, i flatbuffers.UOffsetT) {
+	rcv._tab.Bytes = buf
+	rcv._tab.Pos = i
+}
+
+func (rcv *MemberGetRequest) Table() flatbuffers.Table {
+	return rcv._tab
+}
+
+func (rcv *MemberGetRequest) Id() []byte {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(4))
+	if o != 0 {
+		return rcv._tab.ByteVector(o + rcv._tab.Pos)
+	}
+	return nil
+}
+
+func MemberGetRequestStart(builder *flatbuffers.Builder) {
+	builder.StartObject(1)
+}
+func MemberGetRequestAddId(builder *flatbuffers.Builder, id flatbuffers.UOffsetT) {
+	builder.PrependUOffsetTSlot(0, flatbuffers.UOffsetT(id), 0)
+}
+func MemberGetRequestEnd(builder *flatbuffers.Builder) flatbuffers.UOffsetT {
+	return builder.EndObject()
+}
 type MemberChangePasswordRequest struct {
 	_tab flatbuffers.Table
 }
@@ -217,6 +284,58 @@ func MemberChangePasswordRequestAddNewPassword(builder *flatbuffers.Builder, new
 func MemberChangePasswordRequestEnd(builder *flatbuffers.Builder) flatbuffers.UOffsetT {
 	return builder.EndObject()
 }
+type MemberResetPasswordRequest struct {
+	_tab flatbuffers.Table
+}
+
+func GetRootAsMemberResetPasswordRequest(buf []byte, offset flatbuffers.UOffsetT) *MemberResetPasswordRequest {
+	n := flatbuffers.GetUOffsetT(buf[offset:])
+	x := &MemberResetPasswordRequest{}
+	x.Init(buf, n+offset)
+	return x
+}
+
+func FinishMemberResetPasswordRequestBuffer(builder *flatbuffers.Builder, offset flatbuffers.UOffsetT) {
+	builder.Finish(offset)
+}
+
+func GetSizePrefixedRootAsMemberResetPasswordRequest(buf []byte, offset flatbuffers.UOffsetT) *MemberResetPasswordRequest {
+	n := flatbuffers.GetUOffsetT(buf[offset+flatbuffers.SizeUint32:])
+	x := &MemberResetPasswordRequest{}
+	x.Init(buf, n+offset+flatbuffers.SizeUint32)
+	return x
+}
+
+func FinishSizePrefixedMemberResetPasswordRequestBuffer(builder *flatbuffers.Builder, offset flatbuffers.UOffsetT) {
+	builder.FinishSizePrefixed(offset)
+}
+
+func (rcv *MemberResetPasswordRequest) Init(buf []byte, i flatbuffers.UOffsetT) {
+	rcv._tab.Bytes = buf
+	rcv._tab.Pos = i
+}
+
+func (rcv *MemberResetPasswordRequest) Table() flatbuffers.Table {
+	return rcv._tab
+}
+
+func (rcv *MemberResetPasswordRequest) Id() []byte {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(4))
+	if o != 0 {
+		return rcv._tab.ByteVector(o + rcv._tab.Pos)
+	}
+	return nil
+}
+
+func MemberResetPasswordRequestStart(builder *flatbuffers.Builder) {
+	builder.StartObject(1)
+}
+func MemberResetPasswordRequestAddId(builder *flatbuffers.Builder, id flatbuffers.UOffsetT) {
+	builder.PrependUOffsetTSlot(0, flatbuffers.UOffsetT(id), 0)
+}
+func MemberResetPasswordRequestEnd(builder *flatbuffers.Builder) flatbuffers.UOffsetT {
+	return builder.EndObject()
+}
 type MemberUpdateRequest struct {
 	_tab flatbuffers.Table
 }
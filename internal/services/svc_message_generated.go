@@ -65,8 +65,16 @@ func (rcv *MessageAddRequest) Content() []byte {
 	return nil
 }
 
+func (rcv *MessageAddRequest) Parent() []byte {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(10))
+	if o != 0 {
+		return rcv._tab.ByteVector(o + rcv._tab.Pos)
+	}
+	return nil
+}
+
 func MessageAddRequestStart(builder *flatbuffers.Builder) {
-	builder.StartObject(3)
+	builder.StartObject(4)
 }
 func MessageAddRequestAddConversation(builder *flatbuffers.Builder, conversation flatbuffers.UOffsetT) {
 	builder.PrependUOffsetTSlot(0, flatbuffers.UOffsetT(conversation), 0)
@@ -77,6 +85,9 @@ func MessageAddRequestAddAuthor(builder *flatbuffers.Builder, author flatbuffers
 func MessageAddRequestAddContent(builder *flatbuffers.Builder, content flatbuffers.UOffsetT) {
 	builder.PrependUOffsetTSlot(2, flatbuffers.UOffsetT(content), 0)
 }
+func MessageAddRequestAddParent(builder *flatbuffers.Builder, parent flatbuffers.UOffsetT) {
+	builder.PrependUOffsetTSlot(3, flatbuffers.UOffsetT(parent), 0)
+}
 func MessageAddRequestEnd(builder *flatbuffers.Builder) flatbuffers.UOffsetT {
 	return builder.EndObject()
 }
@@ -351,3 +362,55 @@ func MessageListRequestAddPattern(builder *flatbuffers.Builder, pattern flatbuff
 func MessageListRequestEnd(builder *flatbuffers.Builder) flatbuffers.UOffsetT {
 	return builder.EndObject()
 }
+type MessageThreadRequest struct {
+	_tab flatbuffers.Table
+}
+
+func GetRootAsMessageThreadRequest(buf []byte, offset flatbuffers.UOffsetT) *MessageThreadRequest {
+	n := flatbuffers.GetUOffsetT(buf[offset:])
+	x := &MessageThreadRequest{}
+	x.Init(buf, n+offset)
+	return x
+}
+
+func FinishMessageThreadRequestBuffer(builder *flatbuffers.Builder, offset flatbuffers.UOffsetT) {
+	builder.Finish(offset)
+}
+
+func GetSizePrefixedRootAsMessageThreadRequest(buf []byte, offset flatbuffers.UOffsetT) *MessageThreadRequest {
+	n := flatbuffers.GetUOffsetT(buf[offset+flatbuffers.SizeUint32:])
+	x := &MessageThreadRequest{}
+	x.Init(buf, n+offset+flatbuffers.SizeUint32)
+	return x
+}
+
+func FinishSizePrefixedMessageThreadRequestBuffer(builder *flatbuffers.Builder, offset flatbuffers.UOffsetT) {
+	builder.FinishSizePrefixed(offset)
+}
+
+func (rcv *MessageThreadRequest) Init(buf []byte, i flatbuffers.UOffsetT) {
+	rcv._tab.Bytes = buf
+	rcv._tab.Pos = i
+}
+
+func (rcv *MessageThreadRequest) Table() flatbuffers.Table {
+	return rcv._tab
+}
+
+func (rcv *MessageThreadRequest) Id() []byte {
+	o := flatbuffers.UOffsetT(rcv._tab.Offset(4))
+	if o != 0 {
+		return rcv._tab.ByteVector(o + rcv._tab.Pos)
+	}
+	return nil
+}
+
+func MessageThreadRequestStart(builder *flatbuffers.Builder) {
+	builder.StartObject(1)
+}
+func MessageThreadRequestAddId(builder *flatbuffers.Builder, id flatbuffers.UOffsetT) {
+	builder.PrependUOffsetTSlot(0, flatbuffers.UOffsetT(id), 0)
+}
+func MessageThreadRequestEnd(builder *flatbuffers.Builder) flatbuffers.UOffsetT {
+	return builder.EndObject()
+}
@@ -0,0 +1,36 @@
+// ---------------------------------------------------------------------------------------------- //
+// -- Copyright (c) 2024 Braden Hitchcock - MIT License (https://opensource.org/licenses/MIT)  -- //
+// ---------------------------------------------------------------------------------------------- //
+package services
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// startSweeper calls sweep every interval until the context is done, logging how many of the named
+// things it removed.
+func startSweeper(
+	ctx context.Context, interval time.Duration, name string,
+	sweep func(context.Context) (int, error),
+) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				n, err := sweep(ctx)
+				if err != nil {
+					slog.Warn("Failed to sweep "+name, "err", err)
+				} else if n > 0 {
+					slog.Info("Swept "+name, "count", n)
+				}
+			}
+		}
+	}()
+}
@@ -8,24 +8,52 @@ import (
 	"errors"
 
 	"github.com/bradenhc/kolob/internal/crypto"
+	"github.com/bradenhc/kolob/internal/model"
 )
 
 type key int
 
-var encryptionKeyContextKey key
+var sessionContextKey key
 
 var (
 	ErrInvalidSessionContextValue = errors.New("invalid session context value")
 )
 
-func NewContext(ctx context.Context, key crypto.Key) context.Context {
-	return context.WithValue(ctx, encryptionKeyContextKey, key)
+type value struct {
+	id     model.Uuid
+	key    crypto.Key
+	member model.Uuid
 }
 
+func NewContext(
+	ctx context.Context, id model.Uuid, key crypto.Key, member model.Uuid,
+) context.Context {
+	return context.WithValue(ctx, sessionContextKey, value{id, key, member})
+}
+
+// FromContext returns the group data key stored in the session attached to the context.
 func FromContext(ctx context.Context) (crypto.Key, error) {
-	k, ok := ctx.Value(encryptionKeyContextKey).(crypto.Key)
+	v, ok := ctx.Value(sessionContextKey).(value)
+	if !ok {
+		return nil, ErrInvalidSessionContextValue
+	}
+	return v.key, nil
+}
+
+// MemberFromContext returns the ID of the member that owns the session attached to the context.
+func MemberFromContext(ctx context.Context) (model.Uuid, error) {
+	v, ok := ctx.Value(sessionContextKey).(value)
+	if !ok {
+		return "", ErrInvalidSessionContextValue
+	}
+	return v.member, nil
+}
+
+// IdFromContext returns the ID of the session attached to the context.
+func IdFromContext(ctx context.Context) (model.Uuid, error) {
+	v, ok := ctx.Value(sessionContextKey).(value)
 	if !ok {
-		return k, ErrInvalidSessionContextValue
+		return "", ErrInvalidSessionContextValue
 	}
-	return k, nil
+	return v.id, nil
 }
@@ -4,13 +4,15 @@
 package session
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"sync"
+	"log/slog"
 	"time"
 
 	"github.com/bradenhc/kolob/internal/crypto"
 	"github.com/bradenhc/kolob/internal/model"
+	"github.com/bradenhc/kolob/internal/store"
 )
 
 var (
@@ -18,55 +20,214 @@ var (
 	ErrSessionExpired  = errors.New("session expired")
 )
 
-type session struct {
-	key  crypto.Key
-	last time.Time
-}
+// touchInterval is how long a session is used before the time it was last seen is updated in the
+// store, so that busy sessions don't write to the store on every request. Idle timeouts are only
+// this precise.
+const touchInterval = time.Minute
 
+// A Manager keeps track of the sessions of logged in members. The sessions are kept in a store with
+// the data key of each one encrypted with the session key, and expire once they have been idle for
+// the idle timeout or have existed for the absolute timeout, whichever comes first.
+//
+// Clients hold a random token for their session. The session is stored and known by the hash of
+// the token, its ID, so that reading the store or listing sessions doesn't give anyone a token.
 type Manager struct {
-	sessions   map[model.Uuid]session
-	sessionsmx sync.Mutex
+	store       store.SessionStore
+	key         crypto.Key
+	idleTimeout time.Duration
+	maxAge      time.Duration
+}
+
+func NewManager(
+	s store.SessionStore, key crypto.Key, idleTimeout, maxAge time.Duration,
+) *Manager {
+	return &Manager{s, key, idleTimeout, maxAge}
 }
 
-func NewManager() *Manager {
-	return &Manager{
-		sessions: make(map[model.Uuid]session),
+// IdOf returns the ID of the session the token belongs to.
+func IdOf(token model.Uuid) model.Uuid {
+	return model.Uuid(crypto.HashData([]byte(token)).String())
+}
+
+// Add starts a session for the member that unlocks the data key and returns the token the client
+// uses it with. The user agent and address of the client are kept so the member can tell their
+// sessions apart.
+func (m *Manager) Add(
+	ctx context.Context, k crypto.Key, member model.Uuid, agent, addr string,
+) (model.Uuid, error) {
+	token, err := model.NewUuid()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate session token: %v", err)
 	}
+
+	now := time.Now().UnixMilli()
+	s := model.NewSession(IdOf(token), member, agent, addr, now, now)
+	e, err := store.NewSessionEntity(s, k, m.key)
+	if err != nil {
+		return "", err
+	}
+
+	err = m.store.AddSessionEntity(ctx, e)
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
 }
 
-func (m *Manager) Add(k crypto.Key) (model.Uuid, error) {
-	id, err := model.NewUuid()
+// Get returns the data key and the member of the session the token belongs to.
+func (m *Manager) Get(ctx context.Context, token model.Uuid) (crypto.Key, model.Uuid, error) {
+	id := IdOf(token)
+	e, err := m.store.GetSessionEntity(ctx, id)
+	if errors.Is(err, store.ErrSessionNotFound) {
+		return nil, "", ErrSessionNotFound
+	}
 	if err != nil {
-		return "", fmt.Errorf("failed to generate session ID: %v", err)
+		return nil, "", err
+	}
+
+	now := time.Now()
+	if m.expired(e, now) {
+		err := m.store.RemoveSessionEntity(ctx, id)
+		if err != nil {
+			slog.Warn("Failed to remove expired session", "err", err)
+		}
+		return nil, "", ErrSessionExpired
 	}
-	m.sessionsmx.Lock()
-	defer m.sessionsmx.Unlock()
-	m.sessions[id] = session{key: k, last: time.Now()}
-	return id, nil
+
+	key, err := e.DecryptKey(m.key)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decrypt session key: %v", err)
+	}
+
+	if now.Sub(time.UnixMilli(e.LastSeenAt)) >= touchInterval {
+		err := m.store.TouchSessionEntity(ctx, id, now.UnixMilli())
+		if err != nil {
+			slog.Warn("Failed to update session", "err", err)
+		}
+	}
+
+	return key, e.Member, nil
 }
 
-func (m *Manager) Get(id model.Uuid) (crypto.Key, error) {
-	m.sessionsmx.Lock()
-	defer m.sessionsmx.Unlock()
+// Check returns an error if the session has ended, without counting as use of the session.
+// Long-lived connections use it to notice that their session was revoked after they were opened.
+func (m *Manager) Check(ctx context.Context, id model.Uuid) error {
+	e, err := m.store.GetSessionEntity(ctx, id)
+	if errors.Is(err, store.ErrSessionNotFound) {
+		return ErrSessionNotFound
+	}
+	if err != nil {
+		return err
+	}
 
-	s, ok := m.sessions[id]
-	if !ok {
-		return nil, ErrSessionNotFound
+	if m.expired(e, time.Now()) {
+		return ErrSessionExpired
 	}
 
-	if time.Now().After(s.last.Add(15 * time.Minute)) {
-		delete(m.sessions, id)
-		return nil, ErrSessionExpired
+	return nil
+}
+
+// List returns the sessions of the member that have not expired, oldest first.
+func (m *Manager) List(ctx context.Context, member model.Uuid) ([]*model.Session, error) {
+	es, err := m.store.ListSessionEntities(ctx, member)
+	if err != nil {
+		return nil, err
 	}
 
-	s.last = time.Now()
+	now := time.Now()
+	ss := make([]*model.Session, 0, len(es))
+	for _, e := range es {
+		if m.expired(e, now) {
+			continue
+		}
+
+		s, err := e.Decrypt(m.key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt session: %v", err)
+		}
+		ss = append(ss, s)
+	}
 
-	return s.key, nil
+	return ss, nil
 }
 
-func (m *Manager) Remove(id model.Uuid) {
-	m.sessionsmx.Lock()
-	defer m.sessionsmx.Unlock()
+// Remove ends the session with the ID.
+func (m *Manager) Remove(ctx context.Context, id model.Uuid) error {
+	return m.store.RemoveSessionEntity(ctx, id)
+}
+
+// Revoke ends one of the member's sessions. ErrSessionNotFound is returned if the member has no
+// session with the ID, so that members can't end the sessions of anyone else.
+func (m *Manager) Revoke(ctx context.Context, member, id model.Uuid) error {
+	e, err := m.store.GetSessionEntity(ctx, id)
+	if errors.Is(err, store.ErrSessionNotFound) || (err == nil && e.Member != member) {
+		return ErrSessionNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	return m.store.RemoveSessionEntity(ctx, id)
+}
+
+// RevokeOthers ends every session of the member except the current one, such as when they forgot
+// to log out of a shared computer.
+func (m *Manager) RevokeOthers(ctx context.Context, member, current model.Uuid) error {
+	es, err := m.store.ListSessionEntities(ctx, member)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range es {
+		if e.Id == current {
+			continue
+		}
+		err := m.store.RemoveSessionEntity(ctx, e.Id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Clear ends every session, such as when the key they hold is no longer valid.
+func (m *Manager) Clear(ctx context.Context) error {
+	return m.store.RemoveAllSessionEntities(ctx)
+}
+
+// Sweep removes every expired session from the store and returns how many there were.
+func (m *Manager) Sweep(ctx context.Context) (int, error) {
+	now := time.Now()
+	return m.store.RemoveExpiredSessionEntities(
+		ctx, now.Add(-m.idleTimeout).UnixMilli(), now.Add(-m.maxAge).UnixMilli(),
+	)
+}
+
+// StartSweeper sweeps expired sessions from the store every interval until the context is done.
+func (m *Manager) StartSweeper(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				n, err := m.Sweep(ctx)
+				if err != nil {
+					slog.Warn("Failed to sweep expired sessions", "err", err)
+				} else if n > 0 {
+					slog.Info("Swept expired sessions", "count", n)
+				}
+			}
+		}
+	}()
+}
 
-	delete(m.sessions, id)
+func (m *Manager) expired(e store.SessionEntity, now time.Time) bool {
+	return now.Sub(time.UnixMilli(e.LastSeenAt)) >= m.idleTimeout ||
+		now.Sub(time.UnixMilli(e.CreatedAt)) >= m.maxAge
 }
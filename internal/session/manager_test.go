@@ -0,0 +1,214 @@
+// ---------------------------------------------------------------------------------------------- //
+// -- Copyright (c) 2024 Braden Hitchcock - MIT License (https://opensource.org/licenses/MIT)  -- //
+// ---------------------------------------------------------------------------------------------- //
+package session_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bradenhc/kolob/internal/crypto"
+	"github.com/bradenhc/kolob/internal/model"
+	"github.com/bradenhc/kolob/internal/session"
+	"github.com/bradenhc/kolob/internal/store"
+	"github.com/bradenhc/kolob/internal/store/memory"
+)
+
+func TestManager(t *testing.T) {
+	t.Parallel()
+
+	key, err := crypto.NewRandomKey()
+	if err != nil {
+		t.Fatalf("failed to create encryption key: %v", err)
+	}
+	skey, err := crypto.NewRandomKey()
+	if err != nil {
+		t.Fatalf("failed to create session key: %v", err)
+	}
+
+	doTestManagerGet(t, skey, key)
+	doTestManagerIdle(t, skey, key)
+	doTestManagerMaxAge(t, skey, key)
+	doTestManagerSweep(t, skey, key)
+	doTestManagerRevoke(t, skey, key)
+}
+
+func doTestManagerGet(t *testing.T, skey, key crypto.Key) {
+	ctx := context.Background()
+	s := memory.NewSessionStore()
+	m := session.NewManager(s, skey, time.Hour, time.Hour)
+
+	member := model.Uuid("member")
+	token, err := m.Add(ctx, key, member, "test-agent", "127.0.0.1")
+	if err != nil {
+		t.Fatalf("failed to add session: %v", err)
+	}
+
+	// Only the hash of the token is stored
+	id := session.IdOf(token)
+	if id == token {
+		t.Fatalf("session ID is the token")
+	}
+	if _, err := s.GetSessionEntity(ctx, token); err != store.ErrSessionNotFound {
+		t.Errorf("expected no session stored under the token: %v", err)
+	}
+	if _, err := s.GetSessionEntity(ctx, id); err != nil {
+		t.Errorf("failed to get stored session by its ID: %v", err)
+	}
+
+	k, mid, err := m.Get(ctx, token)
+	if err != nil {
+		t.Fatalf("failed to get session: %v", err)
+	}
+	if !bytes.Equal(k, key) || mid != member {
+		t.Errorf("session holds the wrong key or member")
+	}
+
+	// A manager with another session key can't use the session
+	other, err := crypto.NewRandomKey()
+	if err != nil {
+		t.Fatalf("failed to create session key: %v", err)
+	}
+	if _, _, err := session.NewManager(s, other, time.Hour, time.Hour).Get(ctx, token); err == nil {
+		t.Errorf("used a session without its session key")
+	}
+
+	err = m.Remove(ctx, id)
+	if err != nil {
+		t.Fatalf("failed to remove session: %v", err)
+	}
+	if _, _, err := m.Get(ctx, token); err != session.ErrSessionNotFound {
+		t.Errorf("expected removed session to not be found: %v", err)
+	}
+}
+
+func doTestManagerIdle(t *testing.T, skey, key crypto.Key) {
+	ctx := context.Background()
+	m := session.NewManager(memory.NewSessionStore(), skey, 50*time.Millisecond, time.Hour)
+
+	id, err := m.Add(ctx, key, "member", "test-agent", "127.0.0.1")
+	if err != nil {
+		t.Fatalf("failed to add session: %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if _, _, err := m.Get(ctx, id); err != session.ErrSessionExpired {
+		t.Errorf("expected idle session to expire: %v", err)
+	}
+
+	// Expired sessions are removed once they are found
+	if _, _, err := m.Get(ctx, id); err != session.ErrSessionNotFound {
+		t.Errorf("expected expired session to be removed: %v", err)
+	}
+}
+
+func doTestManagerMaxAge(t *testing.T, skey, key crypto.Key) {
+	ctx := context.Background()
+	m := session.NewManager(memory.NewSessionStore(), skey, time.Hour, 100*time.Millisecond)
+
+	id, err := m.Add(ctx, key, "member", "test-agent", "127.0.0.1")
+	if err != nil {
+		t.Fatalf("failed to add session: %v", err)
+	}
+
+	// Using the session doesn't keep it alive past its maximum age
+	for range 2 {
+		time.Sleep(40 * time.Millisecond)
+		if _, _, err := m.Get(ctx, id); err != nil {
+			t.Fatalf("failed to get session: %v", err)
+		}
+	}
+	time.Sleep(40 * time.Millisecond)
+	if _, _, err := m.Get(ctx, id); err != session.ErrSessionExpired {
+		t.Errorf("expected old session to expire: %v", err)
+	}
+}
+
+func doTestManagerSweep(t *testing.T, skey, key crypto.Key) {
+	ctx := context.Background()
+	m := session.NewManager(memory.NewSessionStore(), skey, 50*time.Millisecond, time.Hour)
+
+	for range 3 {
+		if _, err := m.Add(ctx, key, "member", "test-agent", "127.0.0.1"); err != nil {
+			t.Fatalf("failed to add session: %v", err)
+		}
+	}
+
+	n, err := m.Sweep(ctx)
+	if err != nil {
+		t.Fatalf("failed to sweep sessions: %v", err)
+	}
+	if n != 0 {
+		t.Errorf("swept sessions that have not expired: %d", n)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	n, err = m.Sweep(ctx)
+	if err != nil {
+		t.Fatalf("failed to sweep sessions: %v", err)
+	}
+	if n != 3 {
+		t.Errorf("wrong number of sessions swept: %d != 3", n)
+	}
+}
+
+func doTestManagerRevoke(t *testing.T, skey, key crypto.Key) {
+	ctx := context.Background()
+	m := session.NewManager(memory.NewSessionStore(), skey, time.Hour, time.Hour)
+
+	ids := make([]model.Uuid, 3)
+	for i := range ids {
+		token, err := m.Add(ctx, key, "member", "test-agent", "127.0.0.1")
+		if err != nil {
+			t.Fatalf("failed to add session: %v", err)
+		}
+		ids[i] = session.IdOf(token)
+	}
+	otherToken, err := m.Add(ctx, key, "other", "test-agent", "127.0.0.1")
+	if err != nil {
+		t.Fatalf("failed to add session: %v", err)
+	}
+	other := session.IdOf(otherToken)
+
+	ss, err := m.List(ctx, "member")
+	if err != nil {
+		t.Fatalf("failed to list sessions: %v", err)
+	}
+	if len(ss) != 3 {
+		t.Fatalf("wrong number of sessions listed: %d != 3", len(ss))
+	}
+	if string(ss[0].Agent()) != "test-agent" || string(ss[0].Addr()) != "127.0.0.1" {
+		t.Errorf("session does not remember its client: %s, %s", ss[0].Agent(), ss[0].Addr())
+	}
+
+	// Members can't end the sessions of anyone else
+	if err := m.Revoke(ctx, "member", other); err != session.ErrSessionNotFound {
+		t.Errorf("expected the session of another member to not be found: %v", err)
+	}
+	if err := m.Check(ctx, ids[0]); err != nil {
+		t.Errorf("session is not live before it is revoked: %v", err)
+	}
+	if err := m.Revoke(ctx, "member", ids[0]); err != nil {
+		t.Errorf("failed to revoke session: %v", err)
+	}
+	if err := m.Check(ctx, ids[0]); err != session.ErrSessionNotFound {
+		t.Errorf("expected the revoked session to not be found: %v", err)
+	}
+
+	// Ending the other sessions keeps the current one
+	if err := m.RevokeOthers(ctx, "member", ids[1]); err != nil {
+		t.Errorf("failed to revoke other sessions: %v", err)
+	}
+	ss, err = m.List(ctx, "member")
+	if err != nil {
+		t.Fatalf("failed to list sessions: %v", err)
+	}
+	if len(ss) != 1 || model.Uuid(ss[0].Id()) != ids[1] {
+		t.Errorf("only the current session should be left: %v", ss)
+	}
+	if _, _, err := m.Get(ctx, otherToken); err != nil {
+		t.Errorf("session of another member was ended: %v", err)
+	}
+}
@@ -4,6 +4,7 @@
 package session
 
 import (
+	"log/slog"
 	"net/http"
 
 	"github.com/bradenhc/kolob/internal/model"
@@ -26,17 +27,47 @@ func (s *Manager) Middleware(next http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
-		key, err := s.Get(model.Uuid(c.Value))
+		token := model.Uuid(c.Value)
+		key, member, err := s.Get(r.Context(), token)
 		if err != nil {
 			switch err {
 			case ErrSessionNotFound:
 				w.WriteHeader(http.StatusBadRequest)
 			case ErrSessionExpired:
 				w.WriteHeader(http.StatusUnauthorized)
+			default:
+				slog.Error("Failed to get session", "err", err)
+				w.WriteHeader(http.StatusInternalServerError)
 			}
 			return
 		}
 
-		next(w, r.WithContext(NewContext(r.Context(), key)))
+		next(w, r.WithContext(NewContext(r.Context(), IdOf(token), key, member)))
 	}
 }
+
+// SetCookie attaches the session cookie holding the session token to the response. The cookie is
+// only sent over secure connections and cannot be read by scripts in the browser.
+func SetCookie(w http.ResponseWriter, token model.Uuid) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     cookieName,
+		Value:    string(token),
+		Path:     "/",
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// ClearCookie instructs the client to discard the session cookie.
+func ClearCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     cookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
@@ -4,6 +4,8 @@
 package store
 
 import (
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"log/slog"
 
@@ -19,16 +21,26 @@ type GroupEntity struct {
 	GroupHash     crypto.DataHash
 	PassSalt      crypto.Salt
 	PassHash      crypto.PassHash
+	KdfParams     crypto.KdfParams
 	CreatedAt     int64
 	UpdatedAt     int64
 	EncryptedKey  []byte
 	EncryptedData []byte
+
+	// RecoveryDataKey is the data key encrypted with the recovery key, which is split into the
+	// shares of the group's recovery kit. It is empty until a recovery kit is created.
+	RecoveryDataKey []byte
 }
 
+// recoveryKind binds the recovery kit to the group it belongs to.
+const recoveryKind EntityKind = "group.recovery"
+
 // NewGroupEntity creates a new entity of group inforation to persist in some way. It performs the
 // obfuscation of queryable metadata about a group, encrypts the group information, and combines the
 // metadata with the encrypted group information.
-func NewGroupEntity(g *model.Group, pass crypto.Password, dkey crypto.Key) (GroupEntity, error) {
+func NewGroupEntity(
+	g *model.Group, pass crypto.Password, dkey crypto.Key, ikey crypto.IndexKey,
+) (GroupEntity, error) {
 	// Generate a second key using the user password that will encrypt the data key (pass key)
 	psalt, err := crypto.NewSalt()
 	if err != nil {
@@ -36,7 +48,11 @@ func NewGroupEntity(g *model.Group, pass crypto.Password, dkey crypto.Key) (Grou
 		return e, fmt.Errorf("failed to create salt for group: %v", err)
 	}
 	slog.Info("Deriving password key")
-	pkey := crypto.NewDerivedKey(pass, psalt)
+	pkey, err := crypto.NewDerivedKey(pass, psalt, crypto.DefaultKdfParams)
+	if err != nil {
+		var e GroupEntity
+		return e, fmt.Errorf("failed to derive password key: %v", err)
+	}
 
 	// Encyrypt the data key using the pass key before we store it in the database
 	slog.Info("Encrypting data key")
@@ -46,8 +62,8 @@ func NewGroupEntity(g *model.Group, pass crypto.Password, dkey crypto.Key) (Grou
 		return e, fmt.Errorf("failed to encrypt data key: %v", err)
 	}
 
-	// Hash the group id so we can use it for authentication without leaking information
-	ghash := crypto.HashData(g.Gid())
+	// Index the group id so we can use it for authentication without leaking information
+	ghash := crypto.BlindIndex(ikey, g.Gid())
 
 	// Hash the password so we can use it for authentication
 	phash, err := crypto.HashPassword(pass)
@@ -58,7 +74,8 @@ func NewGroupEntity(g *model.Group, pass crypto.Password, dkey crypto.Key) (Grou
 
 	// Encrypt the group information to protect privacy
 	slog.Info("Encrypting group information")
-	edata, err := crypto.Encrypt(dkey, g.Table().Bytes)
+	gid := model.Uuid(g.Id())
+	edata, err := crypto.EncryptAD(dkey, g.Table().Bytes, AssociatedData(GroupEntityKind, gid))
 	if err != nil {
 		var e GroupEntity
 		return e, fmt.Errorf("failed to encrypt group data before storing in database: %v", err)
@@ -66,10 +83,11 @@ func NewGroupEntity(g *model.Group, pass crypto.Password, dkey crypto.Key) (Grou
 
 	// Create and return the group entity that bundles all the information we persist
 	return GroupEntity{
-		Id:            model.Uuid(g.Id()),
+		Id:            gid,
 		GroupHash:     ghash,
 		PassSalt:      psalt,
 		PassHash:      phash,
+		KdfParams:     crypto.DefaultKdfParams,
 		CreatedAt:     g.Created(),
 		UpdatedAt:     g.Updated(),
 		EncryptedKey:  ekey,
@@ -79,7 +97,7 @@ func NewGroupEntity(g *model.Group, pass crypto.Password, dkey crypto.Key) (Grou
 
 // Decrypt decrypts the entity's data buffer and returns a Group object using the underlying buffer.
 func (e *GroupEntity) Decrypt(k crypto.Key) (*model.Group, error) {
-	data, err := crypto.Decrypt(k, e.EncryptedData)
+	data, err := crypto.DecryptAD(k, e.EncryptedData, e.AssociatedData())
 	if err != nil {
 		return nil, err
 	}
@@ -87,7 +105,9 @@ func (e *GroupEntity) Decrypt(k crypto.Key) (*model.Group, error) {
 	return model.GetRootAsGroup(data, 0), nil
 }
 
-func (e *GroupEntity) Update(key crypto.Key, gid, name, desc []byte) (*model.Group, error) {
+func (e *GroupEntity) Update(
+	key crypto.Key, ikey crypto.IndexKey, gid, name, desc []byte,
+) (*model.Group, error) {
 	prev, err := e.Decrypt(key)
 	if err != nil {
 		return nil, fmt.Errorf("failed to decrypt group prior to update: %v", err)
@@ -95,10 +115,10 @@ func (e *GroupEntity) Update(key crypto.Key, gid, name, desc []byte) (*model.Gro
 
 	next := model.GroupCloneWithUpdates(prev, gid, name, desc)
 
-	e.GroupHash = crypto.HashData(next.Gid())
+	e.GroupHash = crypto.BlindIndex(ikey, next.Gid())
 	e.UpdatedAt = next.Updated()
 
-	edata, err := crypto.Encrypt(key, next.Table().Bytes)
+	edata, err := crypto.EncryptAD(key, next.Table().Bytes, e.AssociatedData())
 	if err != nil {
 		return nil, fmt.Errorf("failed to encrypt updated entity: %v", err)
 	}
@@ -107,19 +127,70 @@ func (e *GroupEntity) Update(key crypto.Key, gid, name, desc []byte) (*model.Gro
 	return next, nil
 }
 
+// AssociatedData returns the data the encrypted group information is bound to.
+func (e *GroupEntity) AssociatedData() []byte {
+	return AssociatedData(GroupEntityKind, e.Id)
+}
+
+// WrapRecoveryKey stores the data key encrypted with the recovery key. The recovery key itself is
+// never stored, so a new recovery kit is needed whenever the data key is replaced.
+func (e *GroupEntity) WrapRecoveryKey(dkey, rkey crypto.Key) error {
+	rdkey, err := crypto.EncryptAD(rkey, dkey, AssociatedData(recoveryKind, e.Id))
+	if err != nil {
+		return fmt.Errorf("failed to encrypt data key with recovery key: %v", err)
+	}
+
+	e.RecoveryDataKey = rdkey
+
+	return nil
+}
+
+// RecoverDataKey returns the data key using the recovery key combined from the recovery shares.
+func (e *GroupEntity) RecoverDataKey(rkey crypto.Key) (crypto.Key, error) {
+	if !e.HasRecoveryKit() {
+		return nil, fmt.Errorf("group has no recovery kit")
+	}
+
+	dkey, err := crypto.DecryptAD(rkey, e.RecoveryDataKey, AssociatedData(recoveryKind, e.Id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt data key with recovery key: %v", err)
+	}
+
+	return dkey, nil
+}
+
+// HasRecoveryKit reports whether a recovery kit has been created for the group.
+func (e *GroupEntity) HasRecoveryKit() bool {
+	return len(e.RecoveryDataKey) != 0
+}
+
+// A MemberEntity holds a keyslot for the member: a copy of the group data key encrypted with a key
+// derived from the member's password. The keyslot lets the member unlock the group data without the
+// group password. Members that existed before keyslots were introduced have an empty keyslot.
 type MemberEntity struct {
 	Id            model.Uuid
 	UsernameHash  crypto.DataHash
 	PassHash      crypto.PassHash
+	KeySalt       crypto.Salt
+	KdfParams     crypto.KdfParams
+	EncryptedKey  []byte
 	CreatedAt     int64
 	UpdatedAt     int64
 	EncryptedData []byte
 }
 
-func NewMemberEntity(m *model.Member, pass crypto.Password, key crypto.Key) (MemberEntity, error) {
-	// Hash the username so that we can store it in the DB without leaking information and use it
+// ErrNoKeyslot is returned when unlocking the keyslot of a member that doesn't have one.
+var ErrNoKeyslot = errors.New("member has no keyslot")
+
+// keyslotKind binds a keyslot to the member it belongs to.
+const keyslotKind EntityKind = "member.keyslot"
+
+func NewMemberEntity(
+	m *model.Member, pass crypto.Password, key crypto.Key, ikey crypto.IndexKey,
+) (MemberEntity, error) {
+	// Index the username so that we can store it in the DB without leaking information and use it
 	// for fast lookup later
-	uhash := crypto.HashData(m.Uname())
+	uhash := crypto.BlindIndex(ikey, m.Uname())
 
 	// Hash the password so we can use it for authentication
 	phash, err := crypto.HashPassword(pass)
@@ -129,25 +200,35 @@ func NewMemberEntity(m *model.Member, pass crypto.Password, key crypto.Key) (Mem
 	}
 
 	// Encrypt the member edata prior to storing it in the DB
-	edata, err := crypto.Encrypt(key, m.Table().Bytes)
+	id := model.Uuid(m.Id())
+	edata, err := crypto.EncryptAD(key, m.Table().Bytes, AssociatedData(MemberEntityKind, id))
 	if err != nil {
 		var e MemberEntity
 		return e, fmt.Errorf("failed to encrypt member data before storing: %v", err)
 	}
 
 	// Create and return the member entity that bundles all persisted information
-	return MemberEntity{
-		Id:            model.Uuid(m.Id()),
+	e := MemberEntity{
+		Id:            id,
 		UsernameHash:  uhash,
 		PassHash:      phash,
 		CreatedAt:     m.Created(),
 		UpdatedAt:     m.Updated(),
 		EncryptedData: edata,
-	}, nil
+	}
+
+	// Give the member their own copy of the data key
+	err = e.WrapKey(pass, key)
+	if err != nil {
+		var e MemberEntity
+		return e, err
+	}
+
+	return e, nil
 }
 
 func (e *MemberEntity) Decrypt(k crypto.Key) (*model.Member, error) {
-	data, err := crypto.Decrypt(k, e.EncryptedData)
+	data, err := crypto.DecryptAD(k, e.EncryptedData, e.AssociatedData())
 	if err != nil {
 		return nil, err
 	}
@@ -155,7 +236,9 @@ func (e *MemberEntity) Decrypt(k crypto.Key) (*model.Member, error) {
 	return model.GetRootAsMember(data, 0), nil
 }
 
-func (e *MemberEntity) Update(k crypto.Key, uname, name []byte) (*model.Member, error) {
+func (e *MemberEntity) Update(
+	k crypto.Key, ikey crypto.IndexKey, uname, name []byte,
+) (*model.Member, error) {
 	prev, err := e.Decrypt(k)
 	if err != nil {
 		return nil, err
@@ -163,9 +246,28 @@ func (e *MemberEntity) Update(k crypto.Key, uname, name []byte) (*model.Member,
 
 	next := model.CloneMemberWithUpdates(prev, uname, name)
 
-	e.UsernameHash = crypto.HashData(uname)
+	e.UsernameHash = crypto.BlindIndex(ikey, next.Uname())
+	e.UpdatedAt = next.Updated()
+	edata, err := crypto.EncryptAD(k, next.Table().Bytes, e.AssociatedData())
+	if err != nil {
+		return nil, err
+	}
+	e.EncryptedData = edata
+
+	return next, nil
+}
+
+// SetReset marks whether the member must change their password before doing anything else.
+func (e *MemberEntity) SetReset(k crypto.Key, reset bool) (*model.Member, error) {
+	prev, err := e.Decrypt(k)
+	if err != nil {
+		return nil, err
+	}
+
+	next := model.CloneMemberWithReset(prev, reset)
+
 	e.UpdatedAt = next.Updated()
-	edata, err := crypto.Encrypt(k, next.Table().Bytes)
+	edata, err := crypto.EncryptAD(k, next.Table().Bytes, e.AssociatedData())
 	if err != nil {
 		return nil, err
 	}
@@ -174,30 +276,91 @@ func (e *MemberEntity) Update(k crypto.Key, uname, name []byte) (*model.Member,
 	return next, nil
 }
 
+// AssociatedData returns the data the encrypted member information is bound to.
+func (e *MemberEntity) AssociatedData() []byte {
+	return AssociatedData(MemberEntityKind, e.Id)
+}
+
+// WrapKey fills the member's keyslot with the data key, encrypted with a key derived from the
+// password using a new salt and the current default key derivation parameters.
+func (e *MemberEntity) WrapKey(pass crypto.Password, dkey crypto.Key) error {
+	ksalt, err := crypto.NewSalt()
+	if err != nil {
+		return fmt.Errorf("failed to create salt for member keyslot: %v", err)
+	}
+
+	pkey, err := crypto.NewDerivedKey(pass, ksalt, crypto.DefaultKdfParams)
+	if err != nil {
+		return fmt.Errorf("failed to derive member password key: %v", err)
+	}
+
+	ekey, err := crypto.EncryptAD(pkey, dkey, AssociatedData(keyslotKind, e.Id))
+	if err != nil {
+		return fmt.Errorf("failed to encrypt data key for member keyslot: %v", err)
+	}
+
+	e.KeySalt = ksalt
+	e.KdfParams = crypto.DefaultKdfParams
+	e.EncryptedKey = ekey
+
+	return nil
+}
+
+// UnwrapKey returns the data key in the member's keyslot using the member's password. The password
+// should already have been checked against the member's password hash.
+func (e *MemberEntity) UnwrapKey(pass crypto.Password) (crypto.Key, error) {
+	if !e.HasKeyslot() {
+		return nil, ErrNoKeyslot
+	}
+
+	pkey, err := crypto.NewDerivedKey(pass, e.KeySalt, e.KdfParams)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive member password key: %v", err)
+	}
+
+	dkey, err := crypto.DecryptAD(pkey, e.EncryptedKey, AssociatedData(keyslotKind, e.Id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt data key in member keyslot: %v", err)
+	}
+
+	return dkey, nil
+}
+
+// HasKeyslot reports whether the member's keyslot holds a copy of the data key.
+func (e *MemberEntity) HasKeyslot() bool {
+	return len(e.EncryptedKey) != 0
+}
+
+// A ConversationEntity keeps a plaintext copy of the conversation participants so that stores can
+// find the conversations a member participates in without decrypting them.
 type ConversationEntity struct {
 	Id            model.Uuid
+	Participants  []model.Uuid
 	CreatedAt     int64
 	UpdatedAt     int64
 	EncryptedData []byte
 }
 
 func NewConversationEntity(c *model.Conversation, k crypto.Key) (ConversationEntity, error) {
-	edata, err := crypto.Encrypt(k, c.Table().Bytes)
+	e := ConversationEntity{
+		Id:           model.Uuid(c.Id()),
+		Participants: conversationParticipants(c),
+		CreatedAt:    c.Created(),
+		UpdatedAt:    c.Updated(),
+	}
+
+	edata, err := crypto.EncryptAD(k, c.Table().Bytes, e.AssociatedData())
 	if err != nil {
 		var e ConversationEntity
 		return e, fmt.Errorf("failed to encrypt conversation info: %v", err)
 	}
+	e.EncryptedData = edata
 
-	return ConversationEntity{
-		Id:            model.Uuid(c.Id()),
-		CreatedAt:     c.Created(),
-		UpdatedAt:     c.Updated(),
-		EncryptedData: edata,
-	}, nil
+	return e, nil
 }
 
 func (e *ConversationEntity) Decrypt(k crypto.Key) (*model.Conversation, error) {
-	data, err := crypto.Decrypt(k, e.EncryptedData)
+	data, err := crypto.DecryptAD(k, e.EncryptedData, e.AssociatedData())
 	if err != nil {
 		return nil, err
 	}
@@ -206,54 +369,73 @@ func (e *ConversationEntity) Decrypt(k crypto.Key) (*model.Conversation, error)
 }
 
 func (e *ConversationEntity) Update(
-	k crypto.Key, name, desc []byte, mods [][]byte,
+	k crypto.Key, name, desc []byte, mods, participants [][]byte,
 ) (*model.Conversation, error) {
 	prev, err := e.Decrypt(k)
 	if err != nil {
 		return nil, err
 	}
 
-	next := model.CloneConversationWithUpdates(prev, name, desc, mods)
+	next := model.CloneConversationWithUpdates(prev, name, desc, mods, participants)
 
-	edata, err := crypto.Encrypt(k, next.Table().Bytes)
+	edata, err := crypto.EncryptAD(k, next.Table().Bytes, e.AssociatedData())
 	if err != nil {
 		return nil, err
 	}
 
+	e.Participants = conversationParticipants(next)
 	e.UpdatedAt = next.Updated()
 	e.EncryptedData = edata
 
 	return next, nil
 }
 
+// AssociatedData returns the data the encrypted conversation information is bound to. The
+// participants are kept out of it since they are removed along with the members they refer to.
+func (e *ConversationEntity) AssociatedData() []byte {
+	return AssociatedData(ConversationEntityKind, e.Id)
+}
+
+func conversationParticipants(c *model.Conversation) []model.Uuid {
+	participants := make([]model.Uuid, 0, c.ParticipantsLength())
+	for i := range c.ParticipantsLength() {
+		participants = append(participants, model.Uuid(c.Participants(i)))
+	}
+	return participants
+}
+
 type MessageEntity struct {
 	Id            model.Uuid
 	Author        model.Uuid
 	Conversation  model.Uuid
+	Thread        model.Uuid
 	CreatedAt     int64
 	UpdatedAt     int64
 	EncryptedData []byte
 }
 
 func NewMessageEntity(m *model.Message, k crypto.Key) (MessageEntity, error) {
-	edata, err := crypto.Encrypt(k, m.Table().Bytes)
+	e := MessageEntity{
+		Id:           model.Uuid(m.Id()),
+		Author:       model.Uuid(m.Author()),
+		Conversation: model.Uuid(m.Conversation()),
+		Thread:       model.Uuid(m.Parent()),
+		CreatedAt:    m.Created(),
+		UpdatedAt:    m.Updated(),
+	}
+
+	edata, err := crypto.EncryptAD(k, m.Table().Bytes, e.AssociatedData())
 	if err != nil {
 		var e MessageEntity
 		return e, fmt.Errorf("failed to encrypt message data: %v", err)
 	}
+	e.EncryptedData = edata
 
-	return MessageEntity{
-		Id:            model.Uuid(m.Id()),
-		Author:        model.Uuid(m.Author()),
-		Conversation:  model.Uuid(m.Conversation()),
-		CreatedAt:     m.Created(),
-		UpdatedAt:     m.Updated(),
-		EncryptedData: edata,
-	}, nil
+	return e, nil
 }
 
 func (e *MessageEntity) Decrypt(k crypto.Key) (*model.Message, error) {
-	data, err := crypto.Decrypt(k, e.EncryptedData)
+	data, err := crypto.DecryptAD(k, e.EncryptedData, e.AssociatedData())
 	if err != nil {
 		return nil, err
 	}
@@ -269,7 +451,7 @@ func (e *MessageEntity) Update(k crypto.Key, content []byte) (*model.Message, er
 
 	next := model.CloneMessageWithUpdates(prev, content)
 
-	edata, err := crypto.Encrypt(k, next.Table().Bytes)
+	edata, err := crypto.EncryptAD(k, next.Table().Bytes, e.AssociatedData())
 	if err != nil {
 		return nil, fmt.Errorf("failed to encrypt: %v", err)
 	}
@@ -279,3 +461,253 @@ func (e *MessageEntity) Update(k crypto.Key, content []byte) (*model.Message, er
 
 	return next, nil
 }
+
+// AssociatedData returns the data the encrypted message is bound to, which includes the
+// conversation it is stored with. The author isn't bound, since it is cleared when the author is
+// removed from the group.
+func (e *MessageEntity) AssociatedData() []byte {
+	return AssociatedData(MessageEntityKind, e.Id, e.Conversation)
+}
+
+type ReactionEntity struct {
+	Message       model.Uuid
+	Author        model.Uuid
+	CreatedAt     int64
+	EncryptedData []byte
+}
+
+func NewReactionEntity(r *model.Reaction, k crypto.Key) (ReactionEntity, error) {
+	e := ReactionEntity{
+		Message:   model.Uuid(r.Message()),
+		Author:    model.Uuid(r.Author()),
+		CreatedAt: r.Created(),
+	}
+
+	edata, err := crypto.EncryptAD(k, r.Table().Bytes, e.AssociatedData())
+	if err != nil {
+		var e ReactionEntity
+		return e, fmt.Errorf("failed to encrypt reaction data: %v", err)
+	}
+	e.EncryptedData = edata
+
+	return e, nil
+}
+
+func (e *ReactionEntity) Decrypt(k crypto.Key) (*model.Reaction, error) {
+	data, err := crypto.DecryptAD(k, e.EncryptedData, e.AssociatedData())
+	if err != nil {
+		return nil, err
+	}
+
+	return model.GetRootAsReaction(data, 0), nil
+}
+
+// AssociatedData returns the data the encrypted reaction is bound to.
+func (e *ReactionEntity) AssociatedData() []byte {
+	return AssociatedData(ReactionEntityKind, e.Message, e.Author)
+}
+
+// AssociatedData binds the encrypted data of an entity to its kind and to the identifiers stored in
+// plaintext alongside it, so that the data can't be moved to another entity without decryption
+// failing. Each identifier is prefixed with its length so that different identifiers can't produce
+// the same associated data.
+func AssociatedData(kind EntityKind, ids ...model.Uuid) []byte {
+	ad := binary.AppendUvarint(nil, uint64(len(kind)))
+	ad = append(ad, kind...)
+	for _, id := range ids {
+		ad = binary.AppendUvarint(ad, uint64(len(id)))
+		ad = append(ad, id...)
+	}
+	return ad
+}
+
+// A SessionEntity is the session of a logged in member. The group data key the session unlocks and
+// the rest of the session information are encrypted with the session key held by the server, so
+// stored sessions can't be used or linked to a client without it. The ID is the hash of the token
+// the client holds, never the token itself.
+type SessionEntity struct {
+	Id            model.Uuid
+	Member        model.Uuid
+	EncryptedKey  []byte
+	CreatedAt     int64
+	LastSeenAt    int64
+	EncryptedData []byte
+}
+
+// sessionKind and sessionKeyKind bind the encrypted information and key of a session to the
+// session and its member.
+const (
+	sessionKind    EntityKind = "session"
+	sessionKeyKind EntityKind = "session.key"
+)
+
+func NewSessionEntity(s *model.Session, dkey, skey crypto.Key) (SessionEntity, error) {
+	e := SessionEntity{
+		Id:         model.Uuid(s.Id()),
+		Member:     model.Uuid(s.Member()),
+		CreatedAt:  s.Created(),
+		LastSeenAt: s.Seen(),
+	}
+
+	ekey, err := crypto.EncryptAD(skey, dkey, AssociatedData(sessionKeyKind, e.Id, e.Member))
+	if err != nil {
+		return e, fmt.Errorf("failed to encrypt session key: %v", err)
+	}
+	e.EncryptedKey = ekey
+
+	edata, err := crypto.EncryptAD(skey, s.Table().Bytes, e.AssociatedData())
+	if err != nil {
+		return e, fmt.Errorf("failed to encrypt session data before storing: %v", err)
+	}
+	e.EncryptedData = edata
+
+	return e, nil
+}
+
+// Decrypt returns the session information, with the time the session was last seen taken from the
+// entity since it changes without the encrypted data being rewritten.
+func (e *SessionEntity) Decrypt(skey crypto.Key) (*model.Session, error) {
+	data, err := crypto.DecryptAD(skey, e.EncryptedData, e.AssociatedData())
+	if err != nil {
+		return nil, err
+	}
+
+	s := model.GetRootAsSession(data, 0)
+	return model.NewSession(
+		e.Id, e.Member, string(s.Agent()), string(s.Addr()), e.CreatedAt, e.LastSeenAt,
+	), nil
+}
+
+// DecryptKey returns the group data key held by the session.
+func (e *SessionEntity) DecryptKey(skey crypto.Key) (crypto.Key, error) {
+	return crypto.DecryptAD(skey, e.EncryptedKey, AssociatedData(sessionKeyKind, e.Id, e.Member))
+}
+
+// AssociatedData returns the data the encrypted session information is bound to.
+func (e *SessionEntity) AssociatedData() []byte {
+	return AssociatedData(sessionKind, e.Id, e.Member)
+}
+
+// An InvitationEntity holds a copy of the group data key encrypted with a key derived from the code
+// of the invitation, like the keyslot of a member, so that whoever redeems the code can be added to
+// the group without anyone else unlocking it. The code is looked up by its blind index.
+type InvitationEntity struct {
+	Id            model.Uuid
+	CodeHash      crypto.DataHash
+	KeySalt       crypto.Salt
+	KdfParams     crypto.KdfParams
+	EncryptedKey  []byte
+	CreatedAt     int64
+	ExpiresAt     int64
+	EncryptedData []byte
+}
+
+// invitationKind and invitationKeyKind bind the encrypted information and key of an invitation to
+// the invitation.
+const (
+	invitationKind    EntityKind = "invitation"
+	invitationKeyKind EntityKind = "invitation.key"
+)
+
+func NewInvitationEntity(
+	i *model.Invitation, code string, dkey crypto.Key, ikey crypto.IndexKey,
+) (InvitationEntity, error) {
+	e := InvitationEntity{
+		Id:        model.Uuid(i.Id()),
+		CodeHash:  crypto.BlindIndex(ikey, []byte(code)),
+		KdfParams: crypto.DefaultKdfParams,
+		CreatedAt: i.Created(),
+		ExpiresAt: i.Expires(),
+	}
+
+	salt, err := crypto.NewSalt()
+	if err != nil {
+		return e, fmt.Errorf("failed to create salt for invitation key: %v", err)
+	}
+	e.KeySalt = salt
+
+	ckey, err := crypto.NewDerivedKey(crypto.Password(code), e.KeySalt, e.KdfParams)
+	if err != nil {
+		return e, fmt.Errorf("failed to derive invitation code key: %v", err)
+	}
+
+	ekey, err := crypto.EncryptAD(ckey, dkey, AssociatedData(invitationKeyKind, e.Id))
+	if err != nil {
+		return e, fmt.Errorf("failed to encrypt data key for invitation: %v", err)
+	}
+	e.EncryptedKey = ekey
+
+	edata, err := crypto.EncryptAD(dkey, i.Table().Bytes, e.AssociatedData())
+	if err != nil {
+		return e, fmt.Errorf("failed to encrypt invitation data before storing: %v", err)
+	}
+	e.EncryptedData = edata
+
+	return e, nil
+}
+
+func (e *InvitationEntity) Decrypt(k crypto.Key) (*model.Invitation, error) {
+	data, err := crypto.DecryptAD(k, e.EncryptedData, e.AssociatedData())
+	if err != nil {
+		return nil, err
+	}
+
+	return model.GetRootAsInvitation(data, 0), nil
+}
+
+// UnwrapKey returns the group data key held by the invitation using its code.
+func (e *InvitationEntity) UnwrapKey(code string) (crypto.Key, error) {
+	ckey, err := crypto.NewDerivedKey(crypto.Password(code), e.KeySalt, e.KdfParams)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive invitation code key: %v", err)
+	}
+
+	dkey, err := crypto.DecryptAD(ckey, e.EncryptedKey, AssociatedData(invitationKeyKind, e.Id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt data key in invitation: %v", err)
+	}
+
+	return dkey, nil
+}
+
+// AssociatedData returns the data the encrypted invitation information is bound to.
+func (e *InvitationEntity) AssociatedData() []byte {
+	return AssociatedData(invitationKind, e.Id)
+}
+
+// A LockoutKind names what a lockout counts the failed logins of.
+type LockoutKind string
+
+const (
+	// AddrLockoutKind lockouts are kept for the IP address of a client.
+	AddrLockoutKind LockoutKind = "addr"
+
+	// UserLockoutKind lockouts are kept for the hexadecimal blind index of a username, so that the
+	// usernames people tried aren't stored.
+	UserLockoutKind LockoutKind = "user"
+)
+
+// A LockoutEntity counts the failed logins for a subject of a kind. The subject can't log in again
+// until the time in LockedUntil. Times are in milliseconds since the Unix epoch.
+type LockoutEntity struct {
+	Kind        LockoutKind
+	Subject     string
+	Failures    int
+	FailedAt    int64
+	LockedUntil int64
+}
+
+// A RekeyEntity records a data key rotation that has started but not yet finished. The new data key
+// is encrypted with the key derived from the group password.
+type RekeyEntity struct {
+	EncryptedKey []byte
+	StartedAt    int64
+}
+
+// EncryptedData is the encrypted data of a single entity, identified by its position in the store
+// so that entities of any kind can be visited in order. The data is bound to AssociatedData.
+type EncryptedData struct {
+	Row            int64
+	Data           []byte
+	AssociatedData []byte
+}
@@ -0,0 +1,153 @@
+// ---------------------------------------------------------------------------------------------- //
+// -- Copyright (c) 2024 Braden Hitchcock - MIT License (https://opensource.org/licenses/MIT)  -- //
+// ---------------------------------------------------------------------------------------------- //
+package memory
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/bradenhc/kolob/internal/crypto"
+	"github.com/bradenhc/kolob/internal/model"
+	"github.com/bradenhc/kolob/internal/store"
+)
+
+// A SessionStore keeps sessions in memory, so they end when the program does. The encrypted key of
+// each session is zeroed when the session is removed.
+type SessionStore struct {
+	sessions map[model.Uuid]store.SessionEntity
+	mu       sync.Mutex
+}
+
+func NewSessionStore() *SessionStore {
+	return &SessionStore{
+		sessions: make(map[model.Uuid]store.SessionEntity),
+	}
+}
+
+func (s *SessionStore) AddSessionEntity(ctx context.Context, e store.SessionEntity) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.sessions[e.Id]; ok {
+		return fmt.Errorf("session %s already exists", e.Id)
+	}
+
+	// Keep a copy so the caller can't change what is stored
+	s.sessions[e.Id] = copySessionEntity(e)
+
+	return nil
+}
+
+func (s *SessionStore) GetSessionEntity(
+	ctx context.Context, id model.Uuid,
+) (store.SessionEntity, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.sessions[id]
+	if !ok {
+		return e, store.ErrSessionNotFound
+	}
+
+	return copySessionEntity(e), nil
+}
+
+func (s *SessionStore) ListSessionEntities(
+	ctx context.Context, member model.Uuid,
+) ([]store.SessionEntity, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	es := make([]store.SessionEntity, 0)
+	for _, e := range s.sessions {
+		if e.Member == member {
+			es = append(es, copySessionEntity(e))
+		}
+	}
+	slices.SortFunc(es, func(a, b store.SessionEntity) int {
+		return cmp.Compare(a.CreatedAt, b.CreatedAt)
+	})
+
+	return es, nil
+}
+
+func (s *SessionStore) TouchSessionEntity(
+	ctx context.Context, id model.Uuid, lastSeen int64,
+) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.sessions[id]; ok {
+		e.LastSeenAt = lastSeen
+		s.sessions[id] = e
+	}
+
+	return nil
+}
+
+func (s *SessionStore) RemoveSessionEntity(ctx context.Context, id model.Uuid) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.remove(id)
+	return nil
+}
+
+func (s *SessionStore) RemoveMemberSessionEntities(ctx context.Context, member model.Uuid) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, e := range s.sessions {
+		if e.Member == member {
+			s.remove(id)
+		}
+	}
+	return nil
+}
+
+func (s *SessionStore) RemoveAllSessionEntities(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id := range s.sessions {
+		s.remove(id)
+	}
+	return nil
+}
+
+func (s *SessionStore) RemoveExpiredSessionEntities(
+	ctx context.Context, idleSince, createdSince int64,
+) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for id, e := range s.sessions {
+		if e.LastSeenAt < idleSince || e.CreatedAt < createdSince {
+			s.remove(id)
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+// remove zeroes the encrypted key and data of the session before forgetting it. The lock must be
+// held.
+func (s *SessionStore) remove(id model.Uuid) {
+	if e, ok := s.sessions[id]; ok {
+		crypto.Zero(e.EncryptedKey)
+		crypto.Zero(e.EncryptedData)
+		delete(s.sessions, id)
+	}
+}
+
+func copySessionEntity(e store.SessionEntity) store.SessionEntity {
+	e.EncryptedKey = slices.Clone(e.EncryptedKey)
+	e.EncryptedData = slices.Clone(e.EncryptedData)
+	return e
+}
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"log/slog"
 
+	"github.com/bradenhc/kolob/internal/crypto"
 	"github.com/bradenhc/kolob/internal/model"
 	"github.com/bradenhc/kolob/internal/store"
 )
@@ -17,47 +18,33 @@ type ConversationStore struct {
 	db *sql.DB
 }
 
-func NewConversationStore(db *sql.DB) (ConversationStore, error) {
-	slog.Info("Setting up table: conversation")
-	_, err := db.Exec(`
-		CREATE TABLE IF NOT EXISTS conversation (
-			id			TEXT,
-			created		INTEGER,
-			updated		INTEGER,
-			data		BLOB,
-
-			PRIMARY KEY (id)
-		)
-	`)
-	if err != nil {
-		var store ConversationStore
-		return store, fmt.Errorf("failed to create conversation table: %v", err)
-	}
-
-	return ConversationStore{db}, nil
+func NewConversationStore(db *sql.DB) ConversationStore {
+	return ConversationStore{db}
 }
 
 func (s ConversationStore) AddConversationEntity(
 	ctx context.Context, e store.ConversationEntity,
 ) error {
 	slog.Info("Adding conversation information to sqlite database")
-	_, err := s.db.ExecContext(
-		ctx,
-		"INSERT INTO [conversation] VALUES (?, ?, ?, ?)",
-		e.Id, e.CreatedAt, e.UpdatedAt, e.EncryptedData,
-	)
-	if err != nil {
-		return fmt.Errorf("failed to store new conversation in database: %v", err)
-	}
+	return NewTransactor(s.db).InTransaction(ctx, func(ctx context.Context) error {
+		_, err := executor(ctx, s.db).ExecContext(
+			ctx,
+			"INSERT INTO [conversation] VALUES (?, ?, ?, ?)",
+			e.Id, e.CreatedAt, e.UpdatedAt, e.EncryptedData,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to store new conversation in database: %v", err)
+		}
 
-	return nil
+		return s.putParticipants(ctx, e)
+	})
 }
 
 func (s ConversationStore) GetConversationEntity(
 	ctx context.Context, id model.Uuid,
 ) (store.ConversationEntity, error) {
 	var e store.ConversationEntity
-	err := s.db.QueryRowContext(
+	err := executor(ctx, s.db).QueryRowContext(
 		ctx, "SELECT id, created, updated, data FROM [conversation] WHERE id = ?", id,
 	).Scan(
 		&e.Id, &e.CreatedAt, &e.UpdatedAt, &e.EncryptedData,
@@ -73,18 +60,20 @@ func (s ConversationStore) GetConversationEntity(
 func (s ConversationStore) UpdateConversationEntity(
 	ctx context.Context, e store.ConversationEntity,
 ) error {
-	query := "UPDATE [conversation] SET updated = ?, data = ? WHERE id = ?"
-	_, err := s.db.ExecContext(ctx, query, e.UpdatedAt, e.EncryptedData, e.Id)
-	if err != nil {
-		return fmt.Errorf("failed to store updated conversation entity in sqlite db: %v", err)
-	}
+	return NewTransactor(s.db).InTransaction(ctx, func(ctx context.Context) error {
+		query := "UPDATE [conversation] SET updated = ?, data = ? WHERE id = ?"
+		_, err := executor(ctx, s.db).ExecContext(ctx, query, e.UpdatedAt, e.EncryptedData, e.Id)
+		if err != nil {
+			return fmt.Errorf("failed to store updated conversation entity in sqlite db: %v", err)
+		}
 
-	return nil
+		return s.putParticipants(ctx, e)
+	})
 }
 
 func (s ConversationStore) RemoveConversationEntity(ctx context.Context, id model.Uuid) error {
 	query := "DELETE FROM [conversation] WHERE id = ?"
-	_, err := s.db.ExecContext(ctx, query, id)
+	_, err := executor(ctx, s.db).ExecContext(ctx, query, id)
 	if err != nil {
 		return fmt.Errorf("failed to remove conversation with id %s from sqlite db: %v", id, err)
 	}
@@ -96,7 +85,71 @@ func (s ConversationStore) ListConversationEntities(
 	ctx context.Context,
 ) ([]store.ConversationEntity, error) {
 	query := "SELECT * FROM [conversation]"
-	rows, err := s.db.QueryContext(ctx, query)
+	return s.listConversationEntities(ctx, query)
+}
+
+func (s ConversationStore) CountConversationEntities(ctx context.Context) (int, error) {
+	var count int
+	query := "SELECT COUNT(*) FROM [conversation]"
+	err := executor(ctx, s.db).QueryRowContext(ctx, query).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count conversations in sqlite db: %v", err)
+	}
+
+	return count, nil
+}
+
+func (s ConversationStore) ListParticipatingConversationEntities(
+	ctx context.Context, member model.Uuid,
+) ([]store.ConversationEntity, error) {
+	query := `
+		SELECT conversation.* FROM [conversation]
+		JOIN participant ON participant.conversation = conversation.id
+		WHERE participant.member = ?
+	`
+	return s.listConversationEntities(ctx, query, member)
+}
+
+func (s ConversationStore) IsConversationParticipant(
+	ctx context.Context, id, member model.Uuid,
+) (bool, error) {
+	var count int
+	query := "SELECT COUNT(*) FROM participant WHERE conversation = ? AND member = ?"
+	err := executor(ctx, s.db).QueryRowContext(ctx, query, id, member).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to find conversation participant in sqlite db: %v", err)
+	}
+
+	return count > 0, nil
+}
+
+func (s ConversationStore) ListConversationParticipants(
+	ctx context.Context, id model.Uuid,
+) ([]model.Uuid, error) {
+	query := "SELECT member FROM participant WHERE conversation = ?"
+	rows, err := executor(ctx, s.db).QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get conversation participants from sqlite db: %v", err)
+	}
+	defer rows.Close()
+
+	ms := make([]model.Uuid, 0)
+	for rows.Next() {
+		var m model.Uuid
+		if err := rows.Scan(&m); err != nil {
+			return nil, fmt.Errorf("failed to scan participant row: %v", err)
+		}
+
+		ms = append(ms, m)
+	}
+
+	return ms, nil
+}
+
+func (s ConversationStore) listConversationEntities(
+	ctx context.Context, query string, args ...any,
+) ([]store.ConversationEntity, error) {
+	rows, err := executor(ctx, s.db).QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get conversation list from sqlite db: %v", err)
 	}
@@ -114,3 +167,84 @@ func (s ConversationStore) ListConversationEntities(
 
 	return cs, nil
 }
+
+// putParticipants replaces the participants stored for the conversation with the participants of
+// the entity.
+func (s ConversationStore) putParticipants(ctx context.Context, e store.ConversationEntity) error {
+	tx := executor(ctx, s.db)
+	_, err := tx.ExecContext(ctx, "DELETE FROM participant WHERE conversation = ?", e.Id)
+	if err != nil {
+		return fmt.Errorf("failed to clear conversation participants in sqlite db: %v", err)
+	}
+
+	for _, m := range e.Participants {
+		_, err := tx.ExecContext(ctx, "INSERT INTO participant VALUES (?, ?)", e.Id, m)
+		if err != nil {
+			return fmt.Errorf("failed to store conversation participant in sqlite db: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// rewriteConversationParticipants gives the conversations created before participants were stored
+// their moderators as participants. Moderators that are no longer members are left out of the
+// participant table.
+func rewriteConversationParticipants(
+	ctx context.Context, db QueryExecutor, key crypto.Key, _ crypto.IndexKey,
+) error {
+	rows, err := db.QueryContext(ctx, "SELECT id, created, updated, data FROM [conversation]")
+	if err != nil {
+		return fmt.Errorf("failed to get conversation list from sqlite db: %v", err)
+	}
+	defer rows.Close()
+
+	es := make([]store.ConversationEntity, 0)
+	for rows.Next() {
+		var e store.ConversationEntity
+		if err := rows.Scan(&e.Id, &e.CreatedAt, &e.UpdatedAt, &e.EncryptedData); err != nil {
+			return fmt.Errorf("failed to scan conversation row: %v", err)
+		}
+		es = append(es, e)
+	}
+	rows.Close()
+
+	if len(es) == 0 {
+		return nil
+	}
+	if key == nil {
+		return ErrKeyRequired
+	}
+
+	for _, e := range es {
+		// The data was encrypted before it was bound to the conversation, so it is decrypted as is
+		data, err := crypto.Decrypt(key, e.EncryptedData)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt conversation %s: %v", e.Id, err)
+		}
+		prev := model.GetRootAsConversation(data, 0)
+
+		// Cloning the conversation adds its moderators to its participants
+		c := model.CloneConversationWithUpdates(prev, nil, nil, nil, nil)
+		next, err := store.NewConversationEntity(c, key)
+		if err != nil {
+			return fmt.Errorf("failed to create conversation entity: %v", err)
+		}
+
+		query := "UPDATE [conversation] SET updated = ?, data = ? WHERE id = ?"
+		_, err = db.ExecContext(ctx, query, next.UpdatedAt, next.EncryptedData, next.Id)
+		if err != nil {
+			return fmt.Errorf("failed to store rewritten conversation in sqlite db: %v", err)
+		}
+
+		for _, m := range next.Participants {
+			query := "INSERT OR IGNORE INTO participant SELECT ?, id FROM member WHERE id = ?"
+			_, err := db.ExecContext(ctx, query, next.Id, m)
+			if err != nil {
+				return fmt.Errorf("failed to store conversation participant in sqlite db: %v", err)
+			}
+		}
+	}
+
+	return nil
+}
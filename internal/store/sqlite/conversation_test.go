@@ -7,6 +7,7 @@ import (
 	"context"
 	"database/sql"
 	"path"
+	"slices"
 	"testing"
 
 	"github.com/bradenhc/kolob/internal/crypto"
@@ -33,11 +34,10 @@ func TestConversationSqliteStore(t *testing.T) {
 		t.Fatalf("failed to create encryption key: %v", err)
 	}
 
-	// Create a user id to use for a moderator
-	moderator, err := model.NewUuid()
-	if err != nil {
-		t.Fatalf("failed to create member UUID: %v", err)
-	}
+	// Create a member to use for a moderator. Moderators participate in the conversations they
+	// moderate, so they must exist in the member table.
+	memberStore := doTestMemberStoreSqliteCreate(t, db)
+	moderator := doTestMemberStoreSqliteInsert(t, memberStore, key, doTestIndexKey(t))
 
 	// Run the tests
 	store := doTestConversationStoreSqliteCreate(t, db)
@@ -45,22 +45,18 @@ func TestConversationSqliteStore(t *testing.T) {
 	doTestConversationStoreSqliteGet(t, store, key, entity)
 	entity = doTestConversationStoreSqliteUpdate(t, store, key, entity)
 	doTestConversationStoreSqliteList(t, store, key, moderator)
+	doTestConversationStoreSqliteParticipants(t, store, key, moderator, entity)
 	doTestConversationStoreSqliteRemove(t, store, entity)
 }
 
 func doTestConversationStoreSqliteCreate(t *testing.T, db *sql.DB) store.ConversationStore {
-	s, err := sqlite.NewConversationStore(db)
-	if err != nil {
-		t.Fatalf("failed to create sqlite conversation store: %v", err)
-	}
-
-	return s
+	return sqlite.NewConversationStore(db)
 }
 
 func doTestConversationStoreSqliteInsert(
 	t *testing.T, s store.ConversationStore, k crypto.Key, mod model.Uuid,
 ) store.ConversationEntity {
-	c, err := model.NewConversation("TestName", "TestDesc", []model.Uuid{mod})
+	c, err := model.NewConversation("TestName", "TestDesc", []model.Uuid{mod}, nil)
 	if err != nil {
 		t.Fatalf("failed to create new conversation: %v", err)
 	}
@@ -112,7 +108,7 @@ func doTestConversationStoreSqliteGet(
 func doTestConversationStoreSqliteUpdate(
 	t *testing.T, s store.ConversationStore, k crypto.Key, e store.ConversationEntity,
 ) store.ConversationEntity {
-	expected, err := e.Update(k, []byte("UpdatedName"), []byte("UpdatedDescription"), nil)
+	expected, err := e.Update(k, []byte("UpdatedName"), []byte("UpdatedDescription"), nil, nil)
 	if err != nil {
 		t.Fatalf("failed to update conversation entity: %v", err)
 	}
@@ -156,6 +152,53 @@ func doTestConversationStoreSqliteList(
 	}
 }
 
+func doTestConversationStoreSqliteParticipants(
+	t *testing.T, s store.ConversationStore, k crypto.Key, m model.Uuid, e store.ConversationEntity,
+) {
+	ok, err := s.IsConversationParticipant(context.Background(), e.Id, m)
+	if err != nil {
+		t.Fatalf("failed to check conversation participant: %v", err)
+	}
+	if !ok {
+		t.Errorf("moderator is not a participant in the conversation")
+	}
+
+	ms, err := s.ListConversationParticipants(context.Background(), e.Id)
+	if err != nil {
+		t.Fatalf("failed to list conversation participants: %v", err)
+	}
+	if !slices.Equal(ms, []model.Uuid{m}) {
+		t.Errorf("conversation participants incorrect: %v != %v", ms, []model.Uuid{m})
+	}
+
+	entities, err := s.ListParticipatingConversationEntities(context.Background(), m)
+	if err != nil {
+		t.Fatalf("failed to list participating conversation entities: %v", err)
+	}
+	if len(entities) != 4 {
+		t.Errorf("expected 4 participating conversation entities: got %d", len(entities))
+	}
+
+	// Removing the moderator as a participant should remove the conversation from their list
+	_, err = e.Update(k, nil, nil, [][]byte{}, [][]byte{})
+	if err != nil {
+		t.Fatalf("failed to update conversation participants: %v", err)
+	}
+
+	err = s.UpdateConversationEntity(context.Background(), e)
+	if err != nil {
+		t.Fatalf("failed to store updated conversation participants: %v", err)
+	}
+
+	entities, err = s.ListParticipatingConversationEntities(context.Background(), m)
+	if err != nil {
+		t.Fatalf("failed to list participating conversation entities after update: %v", err)
+	}
+	if len(entities) != 3 {
+		t.Errorf("expected 3 participating conversation entities: got %d", len(entities))
+	}
+}
+
 func doTestConversationStoreSqliteRemove(
 	t *testing.T, s store.ConversationStore, e store.ConversationEntity,
 ) {
@@ -9,45 +9,30 @@ import (
 	"fmt"
 	"log/slog"
 
+	"github.com/bradenhc/kolob/internal/crypto"
 	"github.com/bradenhc/kolob/internal/store"
 )
 
+// groupColumns names the columns of the group table in the order GetGroupEntity scans them. The
+// columns are named because the kdf and recovery columns were added to existing tables by
+// migrations.
+const groupColumns = "id, ghash, psalt, phash, kdf, ekey, rdkey, created, updated, data"
+
 type GroupStore struct {
 	db *sql.DB
 }
 
-func NewGroupStore(db *sql.DB) (GroupStore, error) {
-	slog.Info("Setting up table: group")
-	_, err := db.Exec(`
-		CREATE TABLE IF NOT EXISTS [group] (
-			id 		TEXT,
-			ghash	BLOB,
-			psalt	BLOB,
-			phash	BLOB,
-			ekey 	BLOB,
-			created INTEGER,
-			updated INTEGER,
-			data	BLOB,
-	
-			PRIMARY KEY (id),
-			UNIQUE (ghash)
-		)
-	`)
-	if err != nil {
-		var s GroupStore
-		return s, fmt.Errorf("failed to create group table: %v", err)
-	}
-
-	return GroupStore{db}, nil
+func NewGroupStore(db *sql.DB) GroupStore {
+	return GroupStore{db}
 }
 
 func (s GroupStore) AddGroupEntity(ctx context.Context, e store.GroupEntity) error {
 	slog.Info("Adding group information to sqlite database")
-	_, err := s.db.ExecContext(
+	_, err := executor(ctx, s.db).ExecContext(
 		ctx,
-		"INSERT INTO [group] VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
-		e.Id, e.GroupHash[:], e.PassSalt, e.PassHash, e.EncryptedKey, e.CreatedAt,
-		e.UpdatedAt, e.EncryptedData,
+		"INSERT INTO [group] ("+groupColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		e.Id, e.GroupHash[:], e.PassSalt, e.PassHash, e.KdfParams.String(), e.EncryptedKey,
+		e.RecoveryDataKey, e.CreatedAt, e.UpdatedAt, e.EncryptedData,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to store group entity in sqlite database: %v", err)
@@ -58,7 +43,7 @@ func (s GroupStore) AddGroupEntity(ctx context.Context, e store.GroupEntity) err
 
 func (s GroupStore) IsGroupDataSet(ctx context.Context) (bool, error) {
 	var count int
-	err := s.db.QueryRow("SELECT COUNT(*) FROM [group]").Scan(&count)
+	err := executor(ctx, s.db).QueryRowContext(ctx, "SELECT COUNT(*) FROM [group]").Scan(&count)
 	if err != nil {
 		return false, fmt.Errorf("failed to check for existing group information: %v", err)
 	}
@@ -69,24 +54,33 @@ func (s GroupStore) IsGroupDataSet(ctx context.Context) (bool, error) {
 func (s GroupStore) GetGroupEntity(ctx context.Context) (store.GroupEntity, error) {
 	var e store.GroupEntity
 	var ghash []byte
-	err := s.db.QueryRowContext(ctx, "SELECT * FROM [group]").Scan(
-		&e.Id, &ghash, &e.PassSalt, &e.PassHash, &e.EncryptedKey, &e.CreatedAt, &e.UpdatedAt,
-		&e.EncryptedData,
+	var kdf string
+	query := "SELECT " + groupColumns + " FROM [group]"
+	err := executor(ctx, s.db).QueryRowContext(ctx, query).Scan(
+		&e.Id, &ghash, &e.PassSalt, &e.PassHash, &kdf, &e.EncryptedKey, &e.RecoveryDataKey,
+		&e.CreatedAt, &e.UpdatedAt, &e.EncryptedData,
 	)
 	if err != nil {
 		return e, fmt.Errorf("failed to get group entity from sqlite database: %v", err)
 	}
 
 	copy(e.GroupHash[:], ghash)
+	e.KdfParams, err = crypto.ParseKdfParams(kdf)
+	if err != nil {
+		return e, fmt.Errorf("failed to read group key derivation parameters: %v", err)
+	}
 
 	return e, nil
 }
 
 func (s GroupStore) UpdateGroupEntity(ctx context.Context, e store.GroupEntity) error {
-	query := "UPDATE [group] SET ghash = ?, psalt = ?, phash = ?, ekey = ?, updated = ?, data = ?"
-	_, err := s.db.ExecContext(
-		ctx, query, e.GroupHash[:], e.PassSalt, e.PassHash, e.EncryptedKey, e.UpdatedAt,
-		e.EncryptedData,
+	query := `
+		UPDATE [group] SET
+			ghash = ?, psalt = ?, phash = ?, kdf = ?, ekey = ?, rdkey = ?, updated = ?, data = ?
+	`
+	_, err := executor(ctx, s.db).ExecContext(
+		ctx, query, e.GroupHash[:], e.PassSalt, e.PassHash, e.KdfParams.String(), e.EncryptedKey,
+		e.RecoveryDataKey, e.UpdatedAt, e.EncryptedData,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to update group entity in sqlite database: %v", err)
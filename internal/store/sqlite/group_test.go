@@ -30,18 +30,25 @@ func TestGroupSqliteStore(t *testing.T) {
 
 	// Run the tests
 	store := doTestGroupStoreSqliteCreate(t, db)
+	doTestGroupStoreSqliteIndexKeyInUse(t, db, false)
 	key := doTestGroupStoreSqliteInsert(t, store)
+	doTestGroupStoreSqliteIndexKeyInUse(t, db, true)
 	entity, group := doTestGroupStoreSqliteGet(t, store, key)
 	doTestGroupStoreSqliteUpdate(t, store, key, entity, group)
 }
 
 func doTestGroupStoreSqliteCreate(t *testing.T, db *sql.DB) sqlite.GroupStore {
-	store, err := sqlite.NewGroupStore(db)
+	return sqlite.NewGroupStore(db)
+}
+
+func doTestGroupStoreSqliteIndexKeyInUse(t *testing.T, db *sql.DB, want bool) {
+	inUse, err := sqlite.IndexKeyInUse(context.Background(), db)
 	if err != nil {
-		t.Fatalf("failed to create group store: %v", err)
+		t.Fatalf("failed to check whether the index key is in use: %v", err)
+	}
+	if inUse != want {
+		t.Errorf("expected index key in use to be %t, got %t", want, inUse)
 	}
-
-	return store
 }
 
 func doTestGroupStoreSqliteInsert(t *testing.T, s sqlite.GroupStore) crypto.Key {
@@ -57,7 +64,7 @@ func doTestGroupStoreSqliteInsert(t *testing.T, s sqlite.GroupStore) crypto.Key
 		t.Fatalf("failed to create random key: %v", err)
 	}
 
-	entity, err := store.NewGroupEntity(group, pass, key)
+	entity, err := store.NewGroupEntity(group, pass, key, doTestIndexKey(t))
 	if err != nil {
 		t.Fatalf("failed to create new group entity: %v", err)
 	}
@@ -76,11 +83,10 @@ func doTestGroupStoreSqliteGet(t *testing.T, s sqlite.GroupStore, key crypto.Key
 		t.Fatalf("failed to get group data: %v", err)
 	}
 
-	data, err := crypto.Decrypt(key, entity.EncryptedData)
+	group, err := entity.Decrypt(key)
 	if err != nil {
 		t.Fatalf("failed to decrypt group: %v", err)
 	}
-	group := model.GetRootAsGroup(data, 0)
 
 	if !slices.Equal(group.Gid(), []byte("Group123")) {
 		t.Errorf("group ids are not equal")
@@ -109,10 +115,10 @@ func doTestGroupStoreSqliteUpdate(
 	ndesc := []byte("New Description")
 	ngroup := model.GroupCloneWithUpdates(g, ngid, nname, ndesc)
 
-	e.GroupHash = crypto.HashData(ngroup.Gid())
+	e.GroupHash = crypto.BlindIndex(doTestIndexKey(t), ngroup.Gid())
 	e.UpdatedAt = ngroup.Updated()
 
-	edata, err := crypto.Encrypt(k, ngroup.Table().Bytes)
+	edata, err := crypto.EncryptAD(k, ngroup.Table().Bytes, e.AssociatedData())
 	if err != nil {
 		t.Fatalf("failed to encrypt updated group data: %v", err)
 	}
@@ -128,13 +134,11 @@ func doTestGroupStoreSqliteUpdate(
 		t.Fatalf("failed to get updated group entity from store: %v", err)
 	}
 
-	data, err := crypto.Decrypt(k, e.EncryptedData)
+	g, err = e.Decrypt(k)
 	if err != nil {
 		t.Fatalf("failed to decrypt group data: %v", err)
 	}
 
-	g = model.GetRootAsGroup(data, 0)
-
 	if !slices.Equal(g.Gid(), ngid) {
 		t.Errorf("updated group id does not match")
 	}
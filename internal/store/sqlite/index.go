@@ -0,0 +1,95 @@
+// ---------------------------------------------------------------------------------------------- //
+// -- Copyright (c) 2024 Braden Hitchcock - MIT License (https://opensource.org/licenses/MIT)  -- //
+// ---------------------------------------------------------------------------------------------- //
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/bradenhc/kolob/internal/crypto"
+	"github.com/bradenhc/kolob/internal/model"
+	"github.com/bradenhc/kolob/internal/store"
+)
+
+// An indexedTable is the table of a kind of entity that is found by a blind index, along with the
+// column holding the index and the value in the entity's data that is indexed.
+type indexedTable struct {
+	column string
+	value  func(data []byte) []byte
+}
+
+// indexedTables maps each kind of entity with a blind index to the table that holds it.
+var indexedTables = map[store.EntityKind]indexedTable{
+	store.GroupEntityKind: {"ghash", func(data []byte) []byte {
+		return model.GetRootAsGroup(data, 0).Gid()
+	}},
+	store.MemberEntityKind: {"uhash", func(data []byte) []byte {
+		return model.GetRootAsMember(data, 0).Uname()
+	}},
+}
+
+// IndexKeyInUse reports whether the database holds a group whose blind indexes have been computed
+// with an index key, so that it can only be used with that key. Databases without a group, or that
+// haven't been migrated to blind indexes yet, can be given a new one.
+func IndexKeyInUse(ctx context.Context, db *sql.DB) (bool, error) {
+	applied, err := appliedMigrations(ctx, db)
+	if err != nil {
+		return false, err
+	}
+	// Blind indexes replaced the plain hashes in migration 9
+	if _, ok := applied[9]; !ok {
+		return false, nil
+	}
+
+	return NewGroupStore(db).IsGroupDataSet(ctx)
+}
+
+// rewriteBlindIndexes recomputes the username and group ID hashes, which were plain SHA-256 hashes
+// before blind indexes were introduced, using the index key.
+func rewriteBlindIndexes(
+	ctx context.Context, db QueryExecutor, key crypto.Key, ikey crypto.IndexKey,
+) error {
+	for _, kind := range []store.EntityKind{store.GroupEntityKind, store.MemberEntityKind} {
+		table := indexedTables[kind]
+
+		var after int64
+		for {
+			ds, err := listEncryptedData(ctx, db, kind, after, 100)
+			if err != nil {
+				return err
+			}
+			if len(ds) == 0 {
+				break
+			}
+			if key == nil {
+				return ErrKeyRequired
+			}
+			if ikey == nil {
+				return ErrIndexKeyRequired
+			}
+
+			for _, d := range ds {
+				data, err := crypto.DecryptAD(key, d.Data, d.AssociatedData)
+				if err != nil {
+					return fmt.Errorf("failed to decrypt %s data: %v", kind, err)
+				}
+
+				h := crypto.BlindIndex(ikey, table.value(data))
+				query := fmt.Sprintf(
+					"UPDATE [%s] SET %s = ? WHERE rowid = ?",
+					encryptedTables[kind].name, table.column,
+				)
+				_, err = db.ExecContext(ctx, query, h[:], d.Row)
+				if err != nil {
+					return fmt.Errorf("failed to update %s index in sqlite db: %v", kind, err)
+				}
+			}
+
+			after = ds[len(ds)-1].Row
+		}
+	}
+
+	return nil
+}
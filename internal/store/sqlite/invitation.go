@@ -0,0 +1,146 @@
+// ---------------------------------------------------------------------------------------------- //
+// -- Copyright (c) 2024 Braden Hitchcock - MIT License (https://opensource.org/licenses/MIT)  -- //
+// ---------------------------------------------------------------------------------------------- //
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/bradenhc/kolob/internal/crypto"
+	"github.com/bradenhc/kolob/internal/model"
+	"github.com/bradenhc/kolob/internal/store"
+)
+
+type InvitationStore struct {
+	db *sql.DB
+}
+
+func NewInvitationStore(db *sql.DB) InvitationStore {
+	return InvitationStore{db}
+}
+
+func (s InvitationStore) AddInvitationEntity(ctx context.Context, e store.InvitationEntity) error {
+	_, err := executor(ctx, s.db).ExecContext(
+		ctx, "INSERT INTO invitation VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		e.Id, e.CodeHash[:], e.KeySalt, e.KdfParams.String(), e.EncryptedKey, e.CreatedAt,
+		e.ExpiresAt, e.EncryptedData,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to store invitation in sqlite db: %v", err)
+	}
+
+	return nil
+}
+
+func (s InvitationStore) GetInvitationEntityByCode(
+	ctx context.Context, chash crypto.DataHash,
+) (store.InvitationEntity, error) {
+	e, err := scanInvitationEntity(executor(ctx, s.db).QueryRowContext(
+		ctx, "SELECT * FROM invitation WHERE chash = ?", chash[:],
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return e, store.ErrInvitationNotFound
+	}
+	if err != nil {
+		return e, fmt.Errorf("failed to get invitation from sqlite db: %v", err)
+	}
+
+	return e, nil
+}
+
+func (s InvitationStore) ListInvitationEntities(
+	ctx context.Context,
+) ([]store.InvitationEntity, error) {
+	rows, err := executor(ctx, s.db).QueryContext(
+		ctx, "SELECT * FROM invitation ORDER BY created",
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list invitations in sqlite db: %v", err)
+	}
+	defer rows.Close()
+
+	es := make([]store.InvitationEntity, 0)
+	for rows.Next() {
+		e, err := scanInvitationEntity(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan invitation from sqlite db: %v", err)
+		}
+		es = append(es, e)
+	}
+
+	return es, nil
+}
+
+func (s InvitationStore) RemoveInvitationEntity(ctx context.Context, id model.Uuid) error {
+	res, err := executor(ctx, s.db).ExecContext(ctx, "DELETE FROM invitation WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to remove invitation from sqlite db: %v", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to count removed invitations in sqlite db: %v", err)
+	}
+	if n == 0 {
+		return store.ErrInvitationNotFound
+	}
+
+	return nil
+}
+
+// RemoveInvitationEntities removes every invitation.
+func (s InvitationStore) RemoveInvitationEntities(ctx context.Context) error {
+	_, err := executor(ctx, s.db).ExecContext(ctx, "DELETE FROM invitation")
+	if err != nil {
+		return fmt.Errorf("failed to remove invitations from sqlite db: %v", err)
+	}
+
+	return nil
+}
+
+// RemoveExpiredInvitationEntities removes the invitations that expired before now and returns how
+// many there were.
+func (s InvitationStore) RemoveExpiredInvitationEntities(
+	ctx context.Context, now int64,
+) (int, error) {
+	res, err := executor(ctx, s.db).ExecContext(
+		ctx, "DELETE FROM invitation WHERE expires <= ?", now,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to remove expired invitations from sqlite db: %v", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count expired invitations in sqlite db: %v", err)
+	}
+
+	return int(n), nil
+}
+
+func scanInvitationEntity(row scanner) (store.InvitationEntity, error) {
+	var e store.InvitationEntity
+	var chash, ksalt []byte
+	var kdf string
+	err := row.Scan(
+		&e.Id, &chash, &ksalt, &kdf, &e.EncryptedKey, &e.CreatedAt, &e.ExpiresAt,
+		&e.EncryptedData,
+	)
+	if err != nil {
+		var e store.InvitationEntity
+		return e, err
+	}
+
+	copy(e.CodeHash[:], chash)
+	e.KeySalt = ksalt
+	e.KdfParams, err = crypto.ParseKdfParams(kdf)
+	if err != nil {
+		var e store.InvitationEntity
+		return e, fmt.Errorf("failed to read invitation key derivation parameters: %v", err)
+	}
+
+	return e, nil
+}
@@ -0,0 +1,151 @@
+// ---------------------------------------------------------------------------------------------- //
+// -- Copyright (c) 2024 Braden Hitchcock - MIT License (https://opensource.org/licenses/MIT)  -- //
+// ---------------------------------------------------------------------------------------------- //
+package sqlite_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/bradenhc/kolob/internal/crypto"
+	"github.com/bradenhc/kolob/internal/model"
+	"github.com/bradenhc/kolob/internal/store"
+	"github.com/bradenhc/kolob/internal/store/sqlite"
+)
+
+func TestInvitationStoreSqlite(t *testing.T) {
+	// Setup the test
+	t.Parallel()
+	tempdir := t.TempDir()
+	dbpath := path.Join(tempdir, "kolob.db")
+
+	db, err := sqlite.Open(dbpath)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	key, err := crypto.NewRandomKey()
+	if err != nil {
+		t.Fatalf("failed to create encryption key: %v", err)
+	}
+	ikey := doTestIndexKey(t)
+
+	s := sqlite.NewInvitationStore(db)
+	doTestInvitationStoreSqliteGet(t, s, key, ikey)
+	doTestInvitationStoreSqliteExpire(t, s, key, ikey)
+}
+
+func doTestInvitationStoreSqliteInsert(
+	t *testing.T, s sqlite.InvitationStore, key crypto.Key, ikey crypto.IndexKey,
+	ttl time.Duration,
+) (*model.Invitation, string) {
+	code, err := crypto.NewInvitationCode()
+	if err != nil {
+		t.Fatalf("failed to create invitation code: %v", err)
+	}
+
+	inv, err := model.NewInvitation("creator", model.RoleUser, []model.Uuid{"general"}, ttl)
+	if err != nil {
+		t.Fatalf("failed to create invitation: %v", err)
+	}
+
+	e, err := store.NewInvitationEntity(inv, code, key, ikey)
+	if err != nil {
+		t.Fatalf("failed to create invitation entity: %v", err)
+	}
+
+	err = s.AddInvitationEntity(context.Background(), e)
+	if err != nil {
+		t.Fatalf("failed to add invitation entity: %v", err)
+	}
+
+	return inv, code
+}
+
+func doTestInvitationStoreSqliteGet(
+	t *testing.T, s sqlite.InvitationStore, key crypto.Key, ikey crypto.IndexKey,
+) {
+	ctx := context.Background()
+	inv, code := doTestInvitationStoreSqliteInsert(t, s, key, ikey, time.Hour)
+
+	e, err := s.GetInvitationEntityByCode(ctx, crypto.BlindIndex(ikey, []byte(code)))
+	if err != nil {
+		t.Fatalf("failed to get invitation by code: %v", err)
+	}
+
+	// The code unlocks the data key, which unlocks the rest of the invitation
+	k, err := e.UnwrapKey(code)
+	if err != nil {
+		t.Fatalf("failed to unwrap invitation key: %v", err)
+	}
+	if !bytes.Equal(k, key) {
+		t.Errorf("invitation holds the wrong key")
+	}
+	_, err = e.UnwrapKey("AAAAA-AAAAA")
+	if err == nil {
+		t.Errorf("unwrapped invitation key with the wrong code")
+	}
+
+	got, err := e.Decrypt(key)
+	if err != nil {
+		t.Fatalf("failed to decrypt invitation: %v", err)
+	}
+	if string(got.Id()) != string(inv.Id()) || got.Expires() != inv.Expires() {
+		t.Errorf("invitation incorrect: %s != %s", got.Id(), inv.Id())
+	}
+
+	_, err = s.GetInvitationEntityByCode(ctx, crypto.BlindIndex(ikey, []byte("AAAAA-AAAAA")))
+	if !errors.Is(err, store.ErrInvitationNotFound) {
+		t.Errorf("expected unknown code not to be found, got %v", err)
+	}
+
+	err = s.RemoveInvitationEntity(ctx, e.Id)
+	if err != nil {
+		t.Fatalf("failed to remove invitation: %v", err)
+	}
+	err = s.RemoveInvitationEntity(ctx, e.Id)
+	if !errors.Is(err, store.ErrInvitationNotFound) {
+		t.Errorf("removed an invitation twice: %v", err)
+	}
+}
+
+func doTestInvitationStoreSqliteExpire(
+	t *testing.T, s sqlite.InvitationStore, key crypto.Key, ikey crypto.IndexKey,
+) {
+	ctx := context.Background()
+	expired, _ := doTestInvitationStoreSqliteInsert(t, s, key, ikey, -time.Minute)
+	doTestInvitationStoreSqliteInsert(t, s, key, ikey, time.Hour)
+
+	n, err := s.RemoveExpiredInvitationEntities(ctx, time.Now().UnixMilli())
+	if err != nil {
+		t.Fatalf("failed to remove expired invitations: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("expected 1 expired invitation removed, got %d", n)
+	}
+
+	es, err := s.ListInvitationEntities(ctx)
+	if err != nil {
+		t.Fatalf("failed to list invitations: %v", err)
+	}
+	if len(es) != 1 || es[0].Id == model.Uuid(expired.Id()) {
+		t.Errorf("expected only the unexpired invitation to remain")
+	}
+
+	err = s.RemoveInvitationEntities(ctx)
+	if err != nil {
+		t.Fatalf("failed to remove invitations: %v", err)
+	}
+	es, err = s.ListInvitationEntities(ctx)
+	if err != nil {
+		t.Fatalf("failed to list invitations: %v", err)
+	}
+	if len(es) != 0 {
+		t.Errorf("expected no invitations to remain, got %d", len(es))
+	}
+}
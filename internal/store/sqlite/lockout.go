@@ -0,0 +1,107 @@
+// ---------------------------------------------------------------------------------------------- //
+// -- Copyright (c) 2024 Braden Hitchcock - MIT License (https://opensource.org/licenses/MIT)  -- //
+// ---------------------------------------------------------------------------------------------- //
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/bradenhc/kolob/internal/store"
+)
+
+type LockoutStore struct {
+	db *sql.DB
+}
+
+func NewLockoutStore(db *sql.DB) LockoutStore {
+	return LockoutStore{db}
+}
+
+func (s LockoutStore) GetLockoutEntity(
+	ctx context.Context, kind store.LockoutKind, subject string,
+) (store.LockoutEntity, error) {
+	e, err := scanLockoutEntity(executor(ctx, s.db).QueryRowContext(
+		ctx, "SELECT * FROM lockout WHERE kind = ? AND subject = ?", kind, subject,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return store.LockoutEntity{Kind: kind, Subject: subject}, nil
+	}
+	if err != nil {
+		return e, fmt.Errorf("failed to get lockout from sqlite db: %v", err)
+	}
+
+	return e, nil
+}
+
+func (s LockoutStore) PutLockoutEntity(ctx context.Context, e store.LockoutEntity) error {
+	_, err := executor(ctx, s.db).ExecContext(
+		ctx, "INSERT OR REPLACE INTO lockout VALUES (?, ?, ?, ?, ?)",
+		e.Kind, e.Subject, e.Failures, e.FailedAt, e.LockedUntil,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to store lockout in sqlite db: %v", err)
+	}
+
+	return nil
+}
+
+func (s LockoutStore) RemoveLockoutEntity(
+	ctx context.Context, kind store.LockoutKind, subject string,
+) error {
+	_, err := executor(ctx, s.db).ExecContext(
+		ctx, "DELETE FROM lockout WHERE kind = ? AND subject = ?", kind, subject,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to remove lockout from sqlite db: %v", err)
+	}
+
+	return nil
+}
+
+func (s LockoutStore) ListLockoutEntities(ctx context.Context) ([]store.LockoutEntity, error) {
+	rows, err := executor(ctx, s.db).QueryContext(ctx, "SELECT * FROM lockout ORDER BY failed")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list lockouts in sqlite db: %v", err)
+	}
+	defer rows.Close()
+
+	es := make([]store.LockoutEntity, 0)
+	for rows.Next() {
+		e, err := scanLockoutEntity(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan lockout from sqlite db: %v", err)
+		}
+		es = append(es, e)
+	}
+
+	return es, nil
+}
+
+// RemoveStaleLockoutEntities removes the lockouts whose last failure was before failedBefore and
+// that are no longer locked at now.
+func (s LockoutStore) RemoveStaleLockoutEntities(
+	ctx context.Context, failedBefore, now int64,
+) (int, error) {
+	res, err := executor(ctx, s.db).ExecContext(
+		ctx, "DELETE FROM lockout WHERE failed < ? AND until <= ?", failedBefore, now,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to remove stale lockouts from sqlite db: %v", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count stale lockouts in sqlite db: %v", err)
+	}
+
+	return int(n), nil
+}
+
+func scanLockoutEntity(row scanner) (store.LockoutEntity, error) {
+	var e store.LockoutEntity
+	err := row.Scan(&e.Kind, &e.Subject, &e.Failures, &e.FailedAt, &e.LockedUntil)
+	return e, err
+}
@@ -0,0 +1,121 @@
+// ---------------------------------------------------------------------------------------------- //
+// -- Copyright (c) 2024 Braden Hitchcock - MIT License (https://opensource.org/licenses/MIT)  -- //
+// ---------------------------------------------------------------------------------------------- //
+package sqlite_test
+
+import (
+	"context"
+	"path"
+	"testing"
+
+	"github.com/bradenhc/kolob/internal/store"
+	"github.com/bradenhc/kolob/internal/store/sqlite"
+)
+
+func TestLockoutStoreSqlite(t *testing.T) {
+	// Setup the test
+	t.Parallel()
+	tempdir := t.TempDir()
+	dbpath := path.Join(tempdir, "kolob.db")
+
+	db, err := sqlite.Open(dbpath)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	s := sqlite.NewLockoutStore(db)
+	doTestLockoutStoreSqlitePut(t, s)
+	doTestLockoutStoreSqliteStale(t, s)
+}
+
+func doTestLockoutStoreSqlitePut(t *testing.T, s store.LockoutStore) {
+	ctx := context.Background()
+
+	// Subjects without failures don't need to be stored
+	e, err := s.GetLockoutEntity(ctx, store.AddrLockoutKind, "192.0.2.1")
+	if err != nil {
+		t.Fatalf("failed to get lockout: %v", err)
+	}
+	if e.Kind != store.AddrLockoutKind || e.Subject != "192.0.2.1" || e.Failures != 0 {
+		t.Errorf("unstored lockout incorrect: %+v", e)
+	}
+
+	e.Failures = 2
+	e.FailedAt = 1000
+	err = s.PutLockoutEntity(ctx, e)
+	if err != nil {
+		t.Fatalf("failed to put lockout: %v", err)
+	}
+
+	e.Failures = 3
+	e.LockedUntil = 2000
+	err = s.PutLockoutEntity(ctx, e)
+	if err != nil {
+		t.Fatalf("failed to replace lockout: %v", err)
+	}
+
+	got, err := s.GetLockoutEntity(ctx, store.AddrLockoutKind, "192.0.2.1")
+	if err != nil {
+		t.Fatalf("failed to get lockout: %v", err)
+	}
+	if got != e {
+		t.Errorf("lockout incorrect: %+v != %+v", got, e)
+	}
+
+	// The same subject can be locked out for a different kind
+	u := store.LockoutEntity{Kind: store.UserLockoutKind, Subject: "192.0.2.1", Failures: 1}
+	err = s.PutLockoutEntity(ctx, u)
+	if err != nil {
+		t.Fatalf("failed to put lockout: %v", err)
+	}
+
+	es, err := s.ListLockoutEntities(ctx)
+	if err != nil {
+		t.Fatalf("failed to list lockouts: %v", err)
+	}
+	if len(es) != 2 {
+		t.Fatalf("expected 2 lockouts, got %d", len(es))
+	}
+
+	err = s.RemoveLockoutEntity(ctx, store.UserLockoutKind, "192.0.2.1")
+	if err != nil {
+		t.Fatalf("failed to remove lockout: %v", err)
+	}
+	got, err = s.GetLockoutEntity(ctx, store.AddrLockoutKind, "192.0.2.1")
+	if err != nil {
+		t.Fatalf("failed to get lockout: %v", err)
+	}
+	if got.Failures != 3 {
+		t.Errorf("removing a lockout removed another kind")
+	}
+}
+
+func doTestLockoutStoreSqliteStale(t *testing.T, s store.LockoutStore) {
+	ctx := context.Background()
+
+	// The lockout from the previous step failed at 1000 and is locked until 2000
+	n, err := s.RemoveStaleLockoutEntities(ctx, 1500, 1500)
+	if err != nil {
+		t.Fatalf("failed to remove stale lockouts: %v", err)
+	}
+	if n != 0 {
+		t.Errorf("removed %d lockouts that are still locked", n)
+	}
+
+	n, err = s.RemoveStaleLockoutEntities(ctx, 1500, 2000)
+	if err != nil {
+		t.Fatalf("failed to remove stale lockouts: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("expected 1 stale lockout removed, got %d", n)
+	}
+
+	es, err := s.ListLockoutEntities(ctx)
+	if err != nil {
+		t.Fatalf("failed to list lockouts: %v", err)
+	}
+	if len(es) != 0 {
+		t.Errorf("expected no lockouts, got %d", len(es))
+	}
+}
@@ -14,40 +14,25 @@ import (
 	"github.com/bradenhc/kolob/internal/store"
 )
 
+// memberColumns names the columns of the member table in the order scanMemberEntity expects. The
+// columns are named because the keyslot columns were added to existing tables by a migration.
+const memberColumns = "id, uhash, phash, ksalt, kdf, ekey, created, updated, data"
+
 type MemberStore struct {
 	db *sql.DB
 }
 
-func NewMemberStore(db *sql.DB) (MemberStore, error) {
-
-	slog.Info("Setting up table: member")
-	_, err := db.Exec(`
-		CREATE TABLE IF NOT EXISTS member (
-			id 			TEXT,
-			uhash		BLOB,
-			phash		BLOB,
-			created		INTEGER,
-			updated		INTEGER,
-			data 		BLOB,
-			
-			PRIMARY KEY (id),
-			UNIQUE (uhash)
-		)
-	`)
-	if err != nil {
-		var ms MemberStore
-		return ms, fmt.Errorf("failed to create member table: %v", err)
-	}
-
-	return MemberStore{db}, nil
+func NewMemberStore(db *sql.DB) MemberStore {
+	return MemberStore{db}
 }
 
 func (s MemberStore) AddMemberEntity(ctx context.Context, e store.MemberEntity) error {
 	slog.Info("Adding member information to sqlite database")
-	_, err := s.db.ExecContext(
+	_, err := executor(ctx, s.db).ExecContext(
 		ctx,
-		"INSERT INTO member VALUES (?, ?, ?, ?, ?, ?)",
-		e.Id, e.UsernameHash[:], e.PassHash, e.CreatedAt, e.UpdatedAt, e.EncryptedData,
+		"INSERT INTO member ("+memberColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		e.Id, e.UsernameHash[:], e.PassHash, e.KeySalt, keyslotKdf(e), e.EncryptedKey,
+		e.CreatedAt, e.UpdatedAt, e.EncryptedData,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to store new member in database: %v", err)
@@ -59,48 +44,39 @@ func (s MemberStore) AddMemberEntity(ctx context.Context, e store.MemberEntity)
 func (s MemberStore) GetMemberEntity(
 	ctx context.Context, id model.Uuid,
 ) (store.MemberEntity, error) {
-	var e store.MemberEntity
-	var uhash []byte
-	err := s.db.QueryRowContext(
-		ctx, "SELECT * FROM [member] WHERE id = ?", id,
-	).Scan(
-		&e.Id, &uhash, &e.PassHash, &e.CreatedAt, &e.UpdatedAt, &e.EncryptedData,
-	)
+	e, err := scanMemberEntity(executor(ctx, s.db).QueryRowContext(
+		ctx, "SELECT "+memberColumns+" FROM [member] WHERE id = ?", id,
+	))
 	if err != nil {
 		var e store.MemberEntity
 		return e, fmt.Errorf("failed to get member data from sqlite database: %v", err)
 	}
 
-	copy(e.UsernameHash[:], uhash)
-
 	return e, nil
 }
 
 func (s MemberStore) GetMemberEntityByUname(
 	ctx context.Context, uhash crypto.DataHash,
 ) (store.MemberEntity, error) {
-	var e store.MemberEntity
-	var uh []byte
-	err := s.db.QueryRowContext(
-		ctx, "SELECT * FROM [member] WHERE uhash = ?",
-		uhash[:],
-	).Scan(
-		&e.Id, &uh, &e.PassHash, &e.CreatedAt, &e.UpdatedAt, &e.EncryptedData,
-	)
+	e, err := scanMemberEntity(executor(ctx, s.db).QueryRowContext(
+		ctx, "SELECT "+memberColumns+" FROM [member] WHERE uhash = ?", uhash[:],
+	))
 	if err != nil {
 		var e store.MemberEntity
 		return e, fmt.Errorf("failed to get member data by uname from sqlite database: %v", err)
 	}
 
-	copy(e.UsernameHash[:], uh)
-
 	return e, nil
 }
 
 func (s MemberStore) UpdateMemberEntity(ctx context.Context, e store.MemberEntity) error {
-	query := "UPDATE [member] SET uhash = ?, phash = ?, updated = ?, data = ? WHERE id = ?"
-	_, err := s.db.ExecContext(
-		ctx, query, e.UsernameHash[:], e.PassHash, e.UpdatedAt, e.EncryptedData, e.Id[:],
+	query := `
+		UPDATE [member] SET uhash = ?, phash = ?, ksalt = ?, kdf = ?, ekey = ?, updated = ?, data = ?
+		WHERE id = ?
+	`
+	_, err := executor(ctx, s.db).ExecContext(
+		ctx, query, e.UsernameHash[:], e.PassHash, e.KeySalt, keyslotKdf(e), e.EncryptedKey,
+		e.UpdatedAt, e.EncryptedData, e.Id[:],
 	)
 	if err != nil {
 		return fmt.Errorf("failed to store updated member data in database: %v", err)
@@ -111,7 +87,7 @@ func (s MemberStore) UpdateMemberEntity(ctx context.Context, e store.MemberEntit
 
 func (s MemberStore) RemoveMemberEntity(ctx context.Context, id model.Uuid) error {
 	query := "DELETE FROM [member] WHERE id = ?"
-	_, err := s.db.ExecContext(ctx, query, id)
+	_, err := executor(ctx, s.db).ExecContext(ctx, query, id)
 	if err != nil {
 		return fmt.Errorf("failed to remove member with id %s: %v", id, err)
 	}
@@ -119,8 +95,8 @@ func (s MemberStore) RemoveMemberEntity(ctx context.Context, id model.Uuid) erro
 }
 
 func (s MemberStore) ListMemberEntities(ctx context.Context) ([]store.MemberEntity, error) {
-	query := "SELECT * FROM [member]"
-	rows, err := s.db.QueryContext(ctx, query)
+	query := "SELECT " + memberColumns + " FROM [member]"
+	rows, err := executor(ctx, s.db).QueryContext(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get member list from database: %v", err)
 	}
@@ -128,19 +104,57 @@ func (s MemberStore) ListMemberEntities(ctx context.Context) ([]store.MemberEnti
 
 	ms := make([]store.MemberEntity, 0)
 	for rows.Next() {
-		var e store.MemberEntity
-		var uhash []byte
-		err := rows.Scan(
-			&e.Id, &uhash, &e.PassHash, &e.CreatedAt, &e.UpdatedAt, &e.EncryptedData,
-		)
+		e, err := scanMemberEntity(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan member row: %v", err)
 		}
 
-		copy(e.UsernameHash[:], uhash)
-
 		ms = append(ms, e)
 	}
 
 	return ms, nil
 }
+
+// RemoveMemberKeyslots empties the keyslot of every member.
+func (s MemberStore) RemoveMemberKeyslots(ctx context.Context) error {
+	query := "UPDATE [member] SET ksalt = NULL, kdf = NULL, ekey = NULL"
+	_, err := executor(ctx, s.db).ExecContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to remove member keyslots from database: %v", err)
+	}
+	return nil
+}
+
+func scanMemberEntity(row scanner) (store.MemberEntity, error) {
+	var e store.MemberEntity
+	var uhash, ksalt []byte
+	var kdf sql.NullString
+	err := row.Scan(
+		&e.Id, &uhash, &e.PassHash, &ksalt, &kdf, &e.EncryptedKey, &e.CreatedAt, &e.UpdatedAt,
+		&e.EncryptedData,
+	)
+	if err != nil {
+		var e store.MemberEntity
+		return e, err
+	}
+
+	copy(e.UsernameHash[:], uhash)
+	e.KeySalt = ksalt
+	if kdf.Valid {
+		e.KdfParams, err = crypto.ParseKdfParams(kdf.String)
+		if err != nil {
+			var e store.MemberEntity
+			return e, fmt.Errorf("failed to read member key derivation parameters: %v", err)
+		}
+	}
+
+	return e, nil
+}
+
+// keyslotKdf stores the key derivation parameters of an empty keyslot as NULL.
+func keyslotKdf(e store.MemberEntity) any {
+	if !e.HasKeyslot() {
+		return nil
+	}
+	return e.KdfParams.String()
+}
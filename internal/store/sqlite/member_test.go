@@ -35,33 +35,42 @@ func TestMemberSqliteStore(t *testing.T) {
 		t.Fatalf("failed to create encryption key: %v", err)
 	}
 
+	// And one to index usernames with
+	ikey := doTestIndexKey(t)
+
 	// Run the tests
 	store := doTestMemberStoreSqliteCreate(t, db)
-	id := doTestMemberStoreSqliteInsert(t, store, key)
+	id := doTestMemberStoreSqliteInsert(t, store, key, ikey)
 	entity := doTestMemberStoreSqliteGet(t, store, key, id)
-	doTestMemberStoreSqliteUpdate(t, store, key, entity)
-	doTestMemberStoreSqliteList(t, store, key)
+	doTestMemberStoreSqliteUpdate(t, store, key, ikey, entity)
+	doTestMemberStoreSqliteList(t, store, key, ikey)
 	doTestMemberStoreSqliteRemove(t, store, id)
 }
 
-func doTestMemberStoreSqliteCreate(t *testing.T, db *sql.DB) sqlite.MemberStore {
-	store, err := sqlite.NewMemberStore(db)
+func doTestIndexKey(t *testing.T) crypto.IndexKey {
+	ikey, err := crypto.NewIndexKey()
 	if err != nil {
-		t.Fatalf("failed to create member store: %v", err)
+		t.Fatalf("failed to create index key: %v", err)
 	}
 
-	return store
+	return ikey
+}
+
+func doTestMemberStoreSqliteCreate(t *testing.T, db *sql.DB) sqlite.MemberStore {
+	return sqlite.NewMemberStore(db)
 }
 
-func doTestMemberStoreSqliteInsert(t *testing.T, s sqlite.MemberStore, key crypto.Key) model.Uuid {
-	member, err := model.NewMember("TestUser", "Name")
+func doTestMemberStoreSqliteInsert(
+	t *testing.T, s sqlite.MemberStore, key crypto.Key, ikey crypto.IndexKey,
+) model.Uuid {
+	member, err := model.NewMember("TestUser", "Name", model.RoleUser)
 	if err != nil {
 		t.Fatalf("failed to create new group: %v", err)
 	}
 
 	pass, _ := crypto.NewPassword("Password123!")
 
-	entity, err := store.NewMemberEntity(member, pass, key)
+	entity, err := store.NewMemberEntity(member, pass, key, ikey)
 	if err != nil {
 		t.Fatalf("failed to create new member entity: %v", err)
 	}
@@ -103,12 +112,16 @@ func doTestMemberStoreSqliteGet(
 }
 
 func doTestMemberStoreSqliteUpdate(
-	t *testing.T, store sqlite.MemberStore, key crypto.Key, entity store.MemberEntity,
+	t *testing.T,
+	store sqlite.MemberStore,
+	key crypto.Key,
+	ikey crypto.IndexKey,
+	entity store.MemberEntity,
 ) {
 	uname := []byte("UpdatedUname")
 	name := []byte("New Name")
 
-	_, err := entity.Update(key, uname, name)
+	_, err := entity.Update(key, ikey, uname, name)
 	if err != nil {
 		t.Fatalf("failed to update member entity: %v", err)
 	}
@@ -141,17 +154,19 @@ func doTestMemberStoreSqliteUpdate(
 	}
 }
 
-func doTestMemberStoreSqliteList(t *testing.T, s store.MemberStore, key crypto.Key) {
+func doTestMemberStoreSqliteList(
+	t *testing.T, s store.MemberStore, key crypto.Key, ikey crypto.IndexKey,
+) {
 	for i := range 3 {
 		uname := fmt.Sprintf("TestUser%02d", i)
-		member, err := model.NewMember(uname, "Name")
+		member, err := model.NewMember(uname, "Name", model.RoleUser)
 		if err != nil {
 			t.Fatalf("failed to create new member: %v", err)
 		}
 
 		pass, _ := crypto.NewPassword("Password123!")
 
-		entity, err := store.NewMemberEntity(member, pass, key)
+		entity, err := store.NewMemberEntity(member, pass, key, ikey)
 		if err != nil {
 			t.Fatalf("failed to create new member entity: %v", err)
 		}
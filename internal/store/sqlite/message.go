@@ -7,7 +7,6 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
-	"log/slog"
 	"strings"
 
 	"github.com/bradenhc/kolob/internal/model"
@@ -18,35 +17,16 @@ type MessageStore struct {
 	db *sql.DB
 }
 
-func NewMessageStore(db *sql.DB) (MessageStore, error) {
-	slog.Info("Setting up table: message")
-	_, err := db.Exec(`
-		CREATE TABLE IF NOT EXISTS message (
-			id				TEXT,
-			conversation	TEXT,
-			author			TEXT,
-			created			INTEGER,
-			updated			INTEGER,
-			data			BLOB,
-
-			PRIMARY KEY (id),
-			FOREIGN KEY (conversation) 	REFERENCES conversation(id) ON DELETE CASCADE,
-			FOREIGN KEY (author) 		REFERENCES member(id) 		ON DELETE SET NULL
-		)
-	`)
-	if err != nil {
-		var s MessageStore
-		return s, fmt.Errorf("failed to create message table: %v", err)
-	}
-
-	return MessageStore{db}, nil
+func NewMessageStore(db *sql.DB) MessageStore {
+	return MessageStore{db}
 }
 
 func (s MessageStore) AddMessageEntity(ctx context.Context, e store.MessageEntity) error {
-	_, err := s.db.ExecContext(
+	_, err := executor(ctx, s.db).ExecContext(
 		ctx,
-		"INSERT INTO message VALUES (?, ?, ?, ?, ?, ?)",
-		e.Id, e.Conversation, e.Author, e.CreatedAt, e.UpdatedAt, e.EncryptedData,
+		"INSERT INTO message ("+messageColumns+") VALUES (?, ?, ?, ?, ?, ?, ?)",
+		e.Id, e.Conversation, nullUuid(e.Author), nullUuid(e.Thread), e.CreatedAt, e.UpdatedAt,
+		e.EncryptedData,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to store new message in database: %v", err)
@@ -58,13 +38,9 @@ func (s MessageStore) AddMessageEntity(ctx context.Context, e store.MessageEntit
 func (s MessageStore) GetMessageEntity(
 	ctx context.Context, id model.Uuid,
 ) (store.MessageEntity, error) {
-	var e store.MessageEntity
-	query := "SELECT * FROM message WHERE id = ?"
-	err := s.db.QueryRowContext(ctx, query, id).Scan(
-		&e.Id, &e.Author, &e.Conversation, &e.CreatedAt, &e.UpdatedAt, &e.EncryptedData,
-	)
+	query := "SELECT " + messageColumns + " FROM message WHERE id = ?"
+	e, err := scanMessageEntity(executor(ctx, s.db).QueryRowContext(ctx, query, id))
 	if err != nil {
-		var e store.MessageEntity
 		return e, fmt.Errorf("failed to get message from database: %v", err)
 	}
 
@@ -75,7 +51,7 @@ func (s MessageStore) UpdateMessageEntity(
 	ctx context.Context, e store.MessageEntity,
 ) error {
 	query := "UPDATE message SET updated = ?, data = ? WHERE id = ?"
-	_, err := s.db.ExecContext(ctx, query, e.UpdatedAt, e.EncryptedData, e.Id)
+	_, err := executor(ctx, s.db).ExecContext(ctx, query, e.UpdatedAt, e.EncryptedData, e.Id)
 	if err != nil {
 		return fmt.Errorf("failed to update message in database: %v", err)
 	}
@@ -84,7 +60,7 @@ func (s MessageStore) UpdateMessageEntity(
 
 func (s MessageStore) RemoveMessageEntity(ctx context.Context, id model.Uuid) error {
 	query := "DELETE FROM message WHERE id = ?"
-	_, err := s.db.ExecContext(ctx, query, id)
+	_, err := executor(ctx, s.db).ExecContext(ctx, query, id)
 	if err != nil {
 		return fmt.Errorf("failed to remove message from database: %v", err)
 	}
@@ -98,6 +74,12 @@ func (s MessageStore) ListMessageEntities(
 	params := make([]any, 0)
 	where = append(where, "conversation = ?")
 	params = append(params, cid)
+	if q.Thread != nil {
+		where = append(where, "thread = ?")
+		params = append(params, *q.Thread)
+	} else {
+		where = append(where, "thread IS NULL")
+	}
 	if q.Author != nil {
 		where = append(where, "author = ?")
 		params = append(params, *q.Author)
@@ -110,18 +92,19 @@ func (s MessageStore) ListMessageEntities(
 		where = append(where, "created <= ?")
 		params = append(params, *q.CreatedBefore)
 	}
-	query := fmt.Sprintf("SELECT * from [message] WHERE %s", strings.Join(where, " AND "))
-	rows, err := s.db.QueryContext(ctx, query, params...)
+	query := fmt.Sprintf(
+		"SELECT %s FROM [message] WHERE %s ORDER BY created, rowid",
+		messageColumns, strings.Join(where, " AND "),
+	)
+	rows, err := executor(ctx, s.db).QueryContext(ctx, query, params...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch messages from database: %v", err)
 	}
+	defer rows.Close()
 
 	entities := make([]store.MessageEntity, 0)
 	for rows.Next() {
-		var e store.MessageEntity
-		err := rows.Scan(
-			&e.Id, &e.Author, &e.Conversation, &e.CreatedAt, &e.UpdatedAt, &e.EncryptedData,
-		)
+		e, err := scanMessageEntity(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan message row: %v", err)
 		}
@@ -131,3 +114,67 @@ func (s MessageStore) ListMessageEntities(
 
 	return entities, nil
 }
+
+// CountConversationReplies counts the replies in each thread of the conversation, keyed by the
+// message that started the thread. Messages without replies are left out.
+func (s MessageStore) CountConversationReplies(
+	ctx context.Context, cid model.Uuid,
+) (map[model.Uuid]int, error) {
+	query := `
+		SELECT thread, COUNT(*) FROM [message]
+		WHERE conversation = ? AND thread IS NOT NULL
+		GROUP BY thread
+	`
+	rows, err := executor(ctx, s.db).QueryContext(ctx, query, cid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count replies in database: %v", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[model.Uuid]int)
+	for rows.Next() {
+		var thread model.Uuid
+		var count int
+		if err := rows.Scan(&thread, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan reply count row: %v", err)
+		}
+
+		counts[thread] = count
+	}
+
+	return counts, nil
+}
+
+// scanner is implemented by both *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// messageColumns names the columns of the message table in the order scanMessageEntity expects.
+// The columns are named because the thread column was added to existing tables by a migration.
+const messageColumns = "id, conversation, author, thread, created, updated, data"
+
+func scanMessageEntity(row scanner) (store.MessageEntity, error) {
+	var e store.MessageEntity
+	var author, thread sql.NullString
+	err := row.Scan(
+		&e.Id, &e.Conversation, &author, &thread, &e.CreatedAt, &e.UpdatedAt, &e.EncryptedData,
+	)
+	if err != nil {
+		var e store.MessageEntity
+		return e, err
+	}
+
+	// The author is cleared when they are removed from the group
+	e.Author = model.Uuid(author.String)
+	e.Thread = model.Uuid(thread.String)
+	return e, nil
+}
+
+// nullUuid stores an empty id as NULL so that foreign key constraints on optional references hold.
+func nullUuid(id model.Uuid) any {
+	if id == "" {
+		return nil
+	}
+	return id
+}
@@ -36,19 +36,13 @@ func TestMessageStoreSqlite(t *testing.T) {
 		t.Fatalf("failed to create encryption key: %v", err)
 	}
 
-	// Create a user id to use for a moderator
-	moderator, err := model.NewUuid()
-	if err != nil {
-		t.Fatalf("failed to create member UUID: %v", err)
-	}
-
 	// Create a member
 	memberStore := doTestMemberStoreSqliteCreate(t, db)
-	memberId := doTestMemberStoreSqliteInsert(t, memberStore, key)
+	memberId := doTestMemberStoreSqliteInsert(t, memberStore, key, doTestIndexKey(t))
 
 	// Create a conversation
 	conversationStore := doTestConversationStoreSqliteCreate(t, db)
-	conversationEntity := doTestConversationStoreSqliteInsert(t, conversationStore, key, moderator)
+	conversationEntity := doTestConversationStoreSqliteInsert(t, conversationStore, key, memberId)
 
 	// Run tests on the message store
 	messageStore := doTestMessageStoreSqliteCreate(t, db)
@@ -56,22 +50,22 @@ func TestMessageStoreSqlite(t *testing.T) {
 	messageEntity := doTestMessageStoreSqliteGet(t, messageStore, key, memberId, conversationEntity.Id, messageId)
 	doTestMessageStoreSqliteUpdate(t, messageStore, key, messageEntity)
 	doTestMessageStoreSqliteList(t, messageStore, key, memberId, conversationEntity.Id)
+	doTestMessageStoreSqliteThread(t, messageStore, key, memberId, conversationEntity.Id, messageId)
 	doTestMessageStoreSqliteRemove(t, messageStore, conversationEntity.Id, messageId)
+
+	// Encrypted data moved to another conversation behind the store's back should be rejected
+	other := doTestConversationStoreSqliteInsert(t, conversationStore, key, memberId)
+	doTestMessageStoreSqliteMoved(t, db, messageStore, key, memberId, conversationEntity.Id, other.Id)
 }
 
 func doTestMessageStoreSqliteCreate(t *testing.T, db *sql.DB) store.MessageStore {
-	store, err := sqlite.NewMessageStore(db)
-	if err != nil {
-		t.Fatalf("failed to create message store: %v", err)
-	}
-
-	return store
+	return sqlite.NewMessageStore(db)
 }
 
 func doTestMessageStoreSqliteInsert(
 	t *testing.T, s store.MessageStore, k crypto.Key, memberId, conversationId model.Uuid,
 ) model.Uuid {
-	m, err := model.NewMessage(memberId, conversationId, "Hello, world!")
+	m, err := model.NewMessage(memberId, conversationId, "", "Hello, world!")
 	if err != nil {
 		t.Fatalf("failed to create new message: %v", err)
 	}
@@ -96,6 +90,10 @@ func doTestMessageStoreSqliteGet(
 		t.Fatalf("failed to get message entity: %v", err)
 	}
 
+	if entity.Author != memberId || entity.Conversation != conversationId {
+		t.Errorf("message entity author and conversation do not match")
+	}
+
 	m, err := entity.Decrypt(k)
 	if err != nil {
 		t.Fatalf("failed to decrypt message: %v", err)
@@ -154,7 +152,7 @@ func doTestMessageStoreSqliteList(
 	time.Sleep(1 * time.Second)
 
 	for i := range 3 {
-		m, err := model.NewMessage(memberId, conversationId, fmt.Sprintf("TestMessage%d", i))
+		m, err := model.NewMessage(memberId, conversationId, "", fmt.Sprintf("TestMessage%d", i))
 		if err != nil {
 			t.Fatalf("failed to create message list number %d: %v", i, err)
 		}
@@ -193,6 +191,72 @@ func doTestMessageStoreSqliteList(
 	}
 }
 
+func doTestMessageStoreSqliteThread(
+	t *testing.T, s store.MessageStore, k crypto.Key, memberId, conversationId, messageId model.Uuid,
+) {
+	for i := range 2 {
+		m, err := model.NewMessage(memberId, conversationId, messageId, fmt.Sprintf("Reply%d", i))
+		if err != nil {
+			t.Fatalf("failed to create reply number %d: %v", i, err)
+		}
+
+		e, err := store.NewMessageEntity(m, k)
+		if err != nil {
+			t.Fatalf("failed to create reply entity number %d: %v", i, err)
+		}
+
+		err = s.AddMessageEntity(context.Background(), e)
+		if err != nil {
+			t.Fatalf("failed to store reply entity number %d: %v", i, err)
+		}
+	}
+
+	query := store.ListMessageDataQuery{Thread: &messageId}
+	entities, err := s.ListMessageEntities(context.Background(), conversationId, query)
+	if err != nil {
+		t.Fatalf("failed to list replies in thread: %v", err)
+	}
+
+	if len(entities) != 2 {
+		t.Fatalf("expected a total of 2 replies: got %d", len(entities))
+	}
+
+	for i, e := range entities {
+		if e.Thread != messageId {
+			t.Errorf("reply %d is not in the thread of the parent message", i)
+		}
+
+		m, err := e.Decrypt(k)
+		if err != nil {
+			t.Fatalf("failed to decrypt reply %d: %v", i, err)
+		}
+
+		if string(m.Content()) != fmt.Sprintf("Reply%d", i) {
+			t.Errorf("replies are out of order: got %s at %d", m.Content(), i)
+		}
+	}
+
+	// Replies should not be listed with the other messages in the conversation
+	var all store.ListMessageDataQuery
+	entities, err = s.ListMessageEntities(context.Background(), conversationId, all)
+	if err != nil {
+		t.Fatalf("failed to list messages after adding replies: %v", err)
+	}
+
+	if len(entities) != 4 {
+		t.Errorf("expected a total of 4 messages: got %d", len(entities))
+	}
+
+	// Only the parent message has replies to count
+	counts, err := s.CountConversationReplies(context.Background(), conversationId)
+	if err != nil {
+		t.Fatalf("failed to count replies: %v", err)
+	}
+	if len(counts) != 1 || counts[messageId] != 2 {
+		t.Errorf("expected 2 replies to the parent message only: got %v", counts)
+	}
+}
+
 func doTestMessageStoreSqliteRemove(
 	t *testing.T, s store.MessageStore, conversationId, messageId model.Uuid,
 ) {
@@ -211,3 +275,28 @@ func doTestMessageStoreSqliteRemove(
 		t.Errorf("expected a total of 3 messages: got %d", len(entities))
 	}
 }
+
+func doTestMessageStoreSqliteMoved(
+	t *testing.T,
+	db *sql.DB,
+	s store.MessageStore,
+	k crypto.Key,
+	memberId, conversationId, otherId model.Uuid,
+) {
+	messageId := doTestMessageStoreSqliteInsert(t, s, k, memberId, conversationId)
+
+	_, err := db.Exec("UPDATE message SET conversation = ? WHERE id = ?", otherId, messageId)
+	if err != nil {
+		t.Fatalf("failed to move message: %v", err)
+	}
+
+	entity, err := s.GetMessageEntity(context.Background(), messageId)
+	if err != nil {
+		t.Fatalf("failed to get message entity: %v", err)
+	}
+
+	_, err = entity.Decrypt(k)
+	if err == nil {
+		t.Errorf("expected decrypting a message moved to another conversation to fail")
+	}
+}
@@ -0,0 +1,315 @@
+// ---------------------------------------------------------------------------------------------- //
+// -- Copyright (c) 2024 Braden Hitchcock - MIT License (https://opensource.org/licenses/MIT)  -- //
+// ---------------------------------------------------------------------------------------------- //
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bradenhc/kolob/internal/crypto"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// ErrKeyRequired is returned by a Rewrite that finds encrypted data to rewrite but was not given
+// the group data key.
+var ErrKeyRequired = errors.New("migration requires the group data key")
+
+// ErrIndexKeyRequired is returned by a Rewrite that finds blind indexes to rewrite but was not
+// given the index key.
+var ErrIndexKeyRequired = errors.New("migration requires the index key")
+
+// A Rewrite changes the encrypted data stored in the database as part of a migration. It runs in
+// the same transaction as the SQL of its migration, after the SQL has been applied.
+//
+// The key is nil when the database is migrated without the group being unlocked. A Rewrite that has
+// nothing to rewrite should succeed anyway, so that new databases can always be migrated; if it has
+// data to rewrite, it must return ErrKeyRequired. The same goes for the index key, which is nil when
+// the database is opened by a program that doesn't compute blind indexes.
+type Rewrite func(ctx context.Context, db QueryExecutor, key crypto.Key, ikey crypto.IndexKey) error
+
+// rewrites maps a migration version to the Rewrite that runs with it.
+var rewrites = map[int]Rewrite{
+	4: rewriteConversationParticipants,
+	6: rewriteBoundEntities,
+	9: rewriteBlindIndexes,
+}
+
+// A Migration is a single step that changes the database schema from one version to the next.
+// Migrations are embedded in the program as SQL files named <version>_<name>.sql and applied in
+// order of their version.
+type Migration struct {
+	Version int
+	Name    string
+
+	sql     string
+	rewrite Rewrite
+}
+
+// RewritesData reports whether the migration rewrites encrypted data and may need the group key.
+func (m Migration) RewritesData() bool {
+	return m.rewrite != nil
+}
+
+// A MigrationStatus is a migration together with when it was applied to the database, if ever.
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// Migrations returns all of the migrations known to the program, ordered by version.
+func Migrations() ([]Migration, error) {
+	entries, err := migrationFiles.ReadDir("migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read embedded migrations: %v", err)
+	}
+
+	ms := make([]Migration, 0, len(entries))
+	for _, e := range entries {
+		base := strings.TrimSuffix(e.Name(), ".sql")
+		v, name, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("invalid migration file name: %s", e.Name())
+		}
+		version, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %v", e.Name(), err)
+		}
+
+		data, err := migrationFiles.ReadFile(path.Join("migrations", e.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %v", e.Name(), err)
+		}
+
+		ms = append(ms, Migration{version, name, string(data), rewrites[version]})
+	}
+
+	slices.SortFunc(ms, func(a, b Migration) int { return a.Version - b.Version })
+	for i, m := range ms {
+		if m.Version != i+1 {
+			return nil, fmt.Errorf("migration %d (%s) is out of sequence", m.Version, m.Name)
+		}
+	}
+
+	return ms, nil
+}
+
+// MigrationStatuses lists every known migration along with when it was applied to the database.
+func MigrationStatuses(ctx context.Context, db *sql.DB) ([]MigrationStatus, error) {
+	ms, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := appliedMigrations(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(ms))
+	for _, m := range ms {
+		s := MigrationStatus{Migration: m}
+		if at, ok := applied[m.Version]; ok {
+			s.AppliedAt = &at
+		}
+		statuses = append(statuses, s)
+	}
+
+	return statuses, nil
+}
+
+// errDryRun rolls back the transaction of a dry run once all of the migrations have been applied.
+var errDryRun = errors.New("dry run")
+
+// Migrate applies the migrations that have not yet been applied to the database, in order. Each
+// migration runs in its own transaction and is recorded in the schema_version table, so a failed
+// migration leaves the database at the version of the last one that succeeded.
+//
+// The key and index key are passed to the migrations that rewrite encrypted data and may be nil;
+// see Rewrite.
+//
+// If dryRun is set, all of the pending migrations are applied in a single transaction that is then
+// rolled back, which shows whether they would succeed without changing the database.
+//
+// The migrations that were applied, or would have been applied in a dry run, are returned.
+func Migrate(
+	ctx context.Context, db *sql.DB, key crypto.Key, ikey crypto.IndexKey, dryRun bool,
+) ([]Migration, error) {
+	statuses, err := MigrationStatuses(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := make([]Migration, 0)
+	for _, s := range statuses {
+		if s.AppliedAt == nil {
+			pending = append(pending, s.Migration)
+		}
+	}
+
+	tx := NewTransactor(db)
+	if dryRun {
+		err := tx.InTransaction(ctx, func(ctx context.Context) error {
+			for _, m := range pending {
+				if err := applyMigration(ctx, executor(ctx, db), m, key, ikey); err != nil {
+					return err
+				}
+			}
+			return errDryRun
+		})
+		if err != errDryRun {
+			return nil, err
+		}
+		return pending, nil
+	}
+
+	applied := make([]Migration, 0, len(pending))
+	for _, m := range pending {
+		slog.Info("Applying database migration", "version", m.Version, "name", m.Name)
+		err := tx.InTransaction(ctx, func(ctx context.Context) error {
+			return applyMigration(ctx, executor(ctx, db), m, key, ikey)
+		})
+		if err != nil {
+			return applied, err
+		}
+		applied = append(applied, m)
+	}
+
+	return applied, nil
+}
+
+func applyMigration(
+	ctx context.Context, db QueryExecutor, m Migration, key crypto.Key, ikey crypto.IndexKey,
+) error {
+	_, err := db.ExecContext(ctx, m.sql)
+	if err != nil {
+		return fmt.Errorf("failed to apply migration %d (%s): %v", m.Version, m.Name, err)
+	}
+
+	if m.rewrite != nil {
+		err := m.rewrite(ctx, db, key, ikey)
+		if errors.Is(err, ErrKeyRequired) {
+			return fmt.Errorf(
+				"migration %d (%s) rewrites encrypted data and requires the group password: %w",
+				m.Version, m.Name, err,
+			)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to rewrite data in migration %d (%s): %v", m.Version, m.Name, err)
+		}
+	}
+
+	_, err = db.ExecContext(
+		ctx, "INSERT INTO schema_version VALUES (?, ?, ?)", m.Version, m.Name, time.Now().UnixMilli(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record migration %d (%s): %v", m.Version, m.Name, err)
+	}
+
+	return nil
+}
+
+// UnlockGroupKey unlocks the group data key with the group ID and password, for the migrations that
+// rewrite encrypted data. The stores expect the database to be fully migrated, so this reads only
+// the columns of the group table that every version of the schema has, along with the key
+// derivation parameters once a migration has recorded them.
+func UnlockGroupKey(
+	ctx context.Context, db *sql.DB, gid string, pass crypto.Password, ikey crypto.IndexKey,
+) (crypto.Key, error) {
+	var ghash, psalt, phash, ekey []byte
+	err := db.QueryRowContext(ctx, "SELECT ghash, psalt, phash, ekey FROM [group]").Scan(
+		&ghash, &psalt, &phash, &ekey,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("the group has not been created")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group from sqlite db: %v", err)
+	}
+
+	// The group ID is hashed without a key until blind indexes are introduced
+	var h crypto.DataHash
+	copy(h[:], ghash)
+	if !crypto.CheckBlindIndex(ikey, []byte(gid), h) && !crypto.CheckDataHash([]byte(gid), h) {
+		return nil, fmt.Errorf("incorrect credentials")
+	}
+	if !crypto.CheckPasswordHash(pass, phash) {
+		return nil, fmt.Errorf("incorrect credentials")
+	}
+
+	params := crypto.LegacyKdfParams
+	var count int
+	err = db.QueryRowContext(
+		ctx, "SELECT COUNT(*) FROM pragma_table_info('group') WHERE name = 'kdf'",
+	).Scan(&count)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look for group key derivation parameters: %v", err)
+	}
+	if count != 0 {
+		var kdf string
+		err := db.QueryRowContext(ctx, "SELECT kdf FROM [group]").Scan(&kdf)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get group key derivation parameters: %v", err)
+		}
+		params, err = crypto.ParseKdfParams(kdf)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read group key derivation parameters: %v", err)
+		}
+	}
+
+	pkey, err := crypto.NewDerivedKey(pass, psalt, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive password key: %v", err)
+	}
+	key, err := crypto.Decrypt(pkey, ekey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt group data key: %v", err)
+	}
+
+	return key, nil
+}
+
+// appliedMigrations returns when each applied migration was applied, keyed by version.
+func appliedMigrations(ctx context.Context, db *sql.DB) (map[int]time.Time, error) {
+	_, err := db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_version (
+			version	INTEGER,
+			name	TEXT,
+			applied	INTEGER,
+
+			PRIMARY KEY (version)
+		)
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create schema_version table: %v", err)
+	}
+
+	rows, err := db.QueryContext(ctx, "SELECT version, applied FROM schema_version")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get applied migrations: %v", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var at int64
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, fmt.Errorf("failed to scan schema_version row: %v", err)
+		}
+		applied[version] = time.UnixMilli(at)
+	}
+
+	return applied, nil
+}
@@ -0,0 +1,462 @@
+// ---------------------------------------------------------------------------------------------- //
+// -- Copyright (c) 2024 Braden Hitchcock - MIT License (https://opensource.org/licenses/MIT)  -- //
+// ---------------------------------------------------------------------------------------------- //
+package sqlite_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path"
+	"testing"
+
+	"github.com/bradenhc/kolob/internal/crypto"
+	"github.com/bradenhc/kolob/internal/model"
+	"github.com/bradenhc/kolob/internal/services"
+	"github.com/bradenhc/kolob/internal/store"
+	"github.com/bradenhc/kolob/internal/store/sqlite"
+	flatbuffers "github.com/google/flatbuffers/go"
+)
+
+func TestMigrateSqlite(t *testing.T) {
+	// Setup the test
+	t.Parallel()
+	tempdir := t.TempDir()
+
+	ctx := context.Background()
+
+	// Migrate a new database
+	db, err := sqlite.Connect(path.Join(tempdir, "kolob-TestMigrateSqlite.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	doTestMigrateSqliteDryRun(t, ctx, db)
+	doTestMigrateSqliteNew(t, ctx, db)
+
+	// Migrate a database created before migrations existed
+	legacy, err := sqlite.Connect(path.Join(tempdir, "kolob-TestMigrateSqlite-legacy.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer legacy.Close()
+
+	doTestMigrateSqliteLegacy(t, ctx, legacy)
+
+	// Migrate a database created by the first release, unlocking it while partly migrated
+	baseline, err := sqlite.Connect(path.Join(tempdir, "kolob-TestMigrateSqlite-baseline.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer baseline.Close()
+
+	doTestMigrateSqliteBaseline(t, ctx, baseline)
+}
+
+func doTestMigrateSqlitePending(t *testing.T, ctx context.Context, db *sql.DB) int {
+	statuses, err := sqlite.MigrationStatuses(ctx, db)
+	if err != nil {
+		t.Fatalf("failed to get migration statuses: %v", err)
+	}
+
+	pending := 0
+	for _, s := range statuses {
+		if s.AppliedAt == nil {
+			pending++
+		}
+	}
+
+	return pending
+}
+
+func doTestMigrateSqliteDryRun(t *testing.T, ctx context.Context, db *sql.DB) {
+	ms, err := sqlite.Migrations()
+	if err != nil {
+		t.Fatalf("failed to get migrations: %v", err)
+	}
+
+	applied, err := sqlite.Migrate(ctx, db, nil, nil, true)
+	if err != nil {
+		t.Fatalf("failed to dry run migrations: %v", err)
+	}
+	if len(applied) != len(ms) {
+		t.Errorf("wrong number of migrations in dry run: %d != %d", len(applied), len(ms))
+	}
+
+	// Nothing should have changed
+	if pending := doTestMigrateSqlitePending(t, ctx, db); pending != len(ms) {
+		t.Errorf("wrong number of pending migrations after dry run: %d != %d", pending, len(ms))
+	}
+	var count int
+	err = db.QueryRowContext(
+		ctx, "SELECT COUNT(*) FROM sqlite_master WHERE name = 'conversation'",
+	).Scan(&count)
+	if err != nil {
+		t.Fatalf("failed to look for conversation table: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("dry run created the conversation table")
+	}
+}
+
+func doTestMigrateSqliteNew(t *testing.T, ctx context.Context, db *sql.DB) {
+	ms, err := sqlite.Migrations()
+	if err != nil {
+		t.Fatalf("failed to get migrations: %v", err)
+	}
+
+	applied, err := sqlite.Migrate(ctx, db, nil, nil, false)
+	if err != nil {
+		t.Fatalf("failed to migrate new database: %v", err)
+	}
+	if len(applied) != len(ms) {
+		t.Errorf("wrong number of migrations applied: %d != %d", len(applied), len(ms))
+	}
+	if pending := doTestMigrateSqlitePending(t, ctx, db); pending != 0 {
+		t.Errorf("migrations still pending after migrate: %d", pending)
+	}
+
+	// Migrating again should do nothing
+	applied, err = sqlite.Migrate(ctx, db, nil, nil, false)
+	if err != nil {
+		t.Fatalf("failed to migrate up to date database: %v", err)
+	}
+	if len(applied) != 0 {
+		t.Errorf("migrations applied to up to date database: %d", len(applied))
+	}
+}
+
+func doTestMigrateSqliteLegacy(t *testing.T, ctx context.Context, db *sql.DB) {
+	// Create the tables the way the stores did before migrations existed
+	_, err := db.ExecContext(ctx, `
+		CREATE TABLE member (
+			id TEXT, uhash BLOB, phash BLOB, created INTEGER, updated INTEGER, data BLOB,
+			PRIMARY KEY (id), UNIQUE (uhash)
+		);
+		CREATE TABLE conversation (
+			id TEXT, created INTEGER, updated INTEGER, data BLOB,
+			PRIMARY KEY (id)
+		);
+		CREATE TABLE message (
+			id TEXT, conversation TEXT, author TEXT, created INTEGER, updated INTEGER, data BLOB,
+			PRIMARY KEY (id),
+			FOREIGN KEY (conversation) REFERENCES conversation(id) ON DELETE CASCADE,
+			FOREIGN KEY (author) REFERENCES member(id) ON DELETE SET NULL
+		);
+	`)
+	if err != nil {
+		t.Fatalf("failed to create legacy tables: %v", err)
+	}
+
+	key, err := crypto.NewRandomKey()
+	if err != nil {
+		t.Fatalf("failed to create random key: %v", err)
+	}
+	ikey := doTestIndexKey(t)
+
+	// Store a member and a conversation they moderate that has no participants
+	m, err := model.NewMember("moderator", "Moderator", model.RoleUser)
+	if err != nil {
+		t.Fatalf("failed to create member: %v", err)
+	}
+	pass, _ := crypto.NewPassword("Password123!")
+	me, err := store.NewMemberEntity(m, pass, key, ikey)
+	if err != nil {
+		t.Fatalf("failed to create member entity: %v", err)
+	}
+
+	// Usernames were hashed without a key before migration 9
+	me.UsernameHash = crypto.HashData(m.Uname())
+
+	// Data was not bound to its row before migration 6
+	me.EncryptedData, err = crypto.Encrypt(key, m.Table().Bytes)
+	if err != nil {
+		t.Fatalf("failed to encrypt member: %v", err)
+	}
+	_, err = db.ExecContext(
+		ctx, "INSERT INTO member VALUES (?, ?, ?, ?, ?, ?)",
+		me.Id, me.UsernameHash[:], me.PassHash, me.CreatedAt, me.UpdatedAt, me.EncryptedData,
+	)
+	if err != nil {
+		t.Fatalf("failed to store legacy member: %v", err)
+	}
+
+	c := doTestMigrateSqliteLegacyConversation(t, model.Uuid(m.Id()))
+	ce, err := store.NewConversationEntity(c, key)
+	if err != nil {
+		t.Fatalf("failed to create conversation entity: %v", err)
+	}
+	ce.EncryptedData, err = crypto.Encrypt(key, c.Table().Bytes)
+	if err != nil {
+		t.Fatalf("failed to encrypt conversation: %v", err)
+	}
+	_, err = db.ExecContext(
+		ctx, "INSERT INTO conversation VALUES (?, ?, ?, ?)",
+		ce.Id, ce.CreatedAt, ce.UpdatedAt, ce.EncryptedData,
+	)
+	if err != nil {
+		t.Fatalf("failed to store legacy conversation: %v", err)
+	}
+
+	// The conversation needs to be rewritten, which can't happen without the key
+	_, err = sqlite.Migrate(ctx, db, nil, nil, false)
+	if !errors.Is(err, sqlite.ErrKeyRequired) {
+		t.Fatalf("expected migration without key to require key: %v", err)
+	}
+	ms, err := sqlite.Migrations()
+	if err != nil {
+		t.Fatalf("failed to get migrations: %v", err)
+	}
+	if pending := doTestMigrateSqlitePending(t, ctx, db); pending != len(ms)-3 {
+		t.Errorf("wrong number of pending migrations: %d != %d", pending, len(ms)-3)
+	}
+
+	_, err = sqlite.Migrate(ctx, db, key, ikey, false)
+	if err != nil {
+		t.Fatalf("failed to migrate legacy database: %v", err)
+	}
+
+	// The moderator should now participate in the conversation
+	cs := sqlite.NewConversationStore(db)
+	ok, err := cs.IsConversationParticipant(ctx, model.Uuid(c.Id()), model.Uuid(m.Id()))
+	if err != nil {
+		t.Fatalf("failed to check conversation participant: %v", err)
+	}
+	if !ok {
+		t.Errorf("moderator is not a participant after migration")
+	}
+
+	e, err := cs.GetConversationEntity(ctx, model.Uuid(c.Id()))
+	if err != nil {
+		t.Fatalf("failed to get migrated conversation: %v", err)
+	}
+	migrated, err := e.Decrypt(key)
+	if err != nil {
+		t.Fatalf("failed to decrypt migrated conversation: %v", err)
+	}
+	if !migrated.IsParticipant(model.Uuid(m.Id())) {
+		t.Errorf("moderator is not a participant in the migrated conversation data")
+	}
+
+	// The member data should now be bound to the member, and the member found by a blind index
+	me, err = sqlite.NewMemberStore(db).GetMemberEntityByUname(
+		ctx, crypto.BlindIndex(ikey, m.Uname()),
+	)
+	if err != nil {
+		t.Fatalf("failed to get migrated member: %v", err)
+	}
+	if _, err := me.Decrypt(key); err != nil {
+		t.Errorf("failed to decrypt migrated member: %v", err)
+	}
+
+	// Messages can be stored in the migrated message table, which gained its thread column last
+	msg, err := model.NewMessage(model.Uuid(m.Id()), model.Uuid(c.Id()), "", "Hello")
+	if err != nil {
+		t.Fatalf("failed to create message: %v", err)
+	}
+	msge, err := store.NewMessageEntity(msg, key)
+	if err != nil {
+		t.Fatalf("failed to create message entity: %v", err)
+	}
+	err = sqlite.NewMessageStore(db).AddMessageEntity(ctx, msge)
+	if err != nil {
+		t.Fatalf("failed to store message in migrated database: %v", err)
+	}
+}
+
+// doTestMigrateSqliteLegacyConversation builds a conversation the way it was stored before
+// conversations had participants.
+func doTestMigrateSqliteLegacyConversation(t *testing.T, mod model.Uuid) *model.Conversation {
+	id, err := model.NewUuid()
+	if err != nil {
+		t.Fatalf("failed to create uuid: %v", err)
+	}
+
+	builder := flatbuffers.NewBuilder(256)
+	idOffset := builder.CreateString(string(id))
+	nameOffset := builder.CreateString("Legacy")
+	descOffset := builder.CreateString("A conversation from before participants")
+	modOffset := builder.CreateString(string(mod))
+	model.ConversationStartModsVector(builder, 1)
+	builder.PrependUOffsetT(modOffset)
+	modsOffset := builder.EndVector(1)
+
+	model.ConversationStart(builder)
+	model.ConversationAddId(builder, idOffset)
+	model.ConversationAddName(builder, nameOffset)
+	model.ConversationAddDesc(builder, descOffset)
+	model.ConversationAddMods(builder, modsOffset)
+	builder.Finish(model.ConversationEnd(builder))
+
+	return model.GetRootAsConversation(builder.FinishedBytes(), 0)
+}
+
+func doTestMigrateSqliteBaseline(t *testing.T, ctx context.Context, db *sql.DB) {
+	// Create the tables the way the first release did
+	_, err := db.ExecContext(ctx, `
+		CREATE TABLE [group] (
+			id TEXT, ghash BLOB, psalt BLOB, phash BLOB, ekey BLOB, created INTEGER,
+			updated INTEGER, data BLOB,
+			PRIMARY KEY (id), UNIQUE (ghash)
+		);
+		CREATE TABLE member (
+			id TEXT, uhash BLOB, phash BLOB, created INTEGER, updated INTEGER, data BLOB,
+			PRIMARY KEY (id), UNIQUE (uhash)
+		);
+		CREATE TABLE conversation (
+			id TEXT, created INTEGER, updated INTEGER, data BLOB,
+			PRIMARY KEY (id)
+		);
+		CREATE TABLE message (
+			id TEXT, conversation TEXT, author TEXT, created INTEGER, updated INTEGER, data BLOB,
+			PRIMARY KEY (id),
+			FOREIGN KEY (conversation) REFERENCES conversation(id) ON DELETE CASCADE,
+			FOREIGN KEY (author) REFERENCES member(id) ON DELETE SET NULL
+		);
+	`)
+	if err != nil {
+		t.Fatalf("failed to create baseline tables: %v", err)
+	}
+
+	key, err := crypto.NewRandomKey()
+	if err != nil {
+		t.Fatalf("failed to create random key: %v", err)
+	}
+	ikey := doTestIndexKey(t)
+	pass := crypto.Password("Password12345678!")
+
+	// Store the group with a plain hash of its ID and a key derived with the legacy parameters
+	g, err := model.NewGroup("BaselineGroup", "Baseline", "A group from the first release")
+	if err != nil {
+		t.Fatalf("failed to create group: %v", err)
+	}
+	psalt, err := crypto.NewSalt()
+	if err != nil {
+		t.Fatalf("failed to create salt: %v", err)
+	}
+	pkey, err := crypto.NewDerivedKey(pass, psalt, crypto.LegacyKdfParams)
+	if err != nil {
+		t.Fatalf("failed to derive password key: %v", err)
+	}
+	ekey, err := crypto.Encrypt(pkey, key)
+	if err != nil {
+		t.Fatalf("failed to encrypt data key: %v", err)
+	}
+	phash, err := crypto.HashPassword(pass)
+	if err != nil {
+		t.Fatalf("failed to hash password: %v", err)
+	}
+	gdata, err := crypto.Encrypt(key, g.Table().Bytes)
+	if err != nil {
+		t.Fatalf("failed to encrypt group: %v", err)
+	}
+	ghash := crypto.HashData(g.Gid())
+	_, err = db.ExecContext(
+		ctx, "INSERT INTO [group] VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		string(g.Id()), ghash[:], psalt, phash, ekey, g.Created(), g.Updated(), gdata,
+	)
+	if err != nil {
+		t.Fatalf("failed to store baseline group: %v", err)
+	}
+
+	// Store a member who wrote a message in a conversation they moderate
+	m, err := model.NewMember("baseline", "Baseline", model.RoleUser)
+	if err != nil {
+		t.Fatalf("failed to create member: %v", err)
+	}
+	mdata, err := crypto.Encrypt(key, m.Table().Bytes)
+	if err != nil {
+		t.Fatalf("failed to encrypt member: %v", err)
+	}
+	uhash := crypto.HashData(m.Uname())
+	_, err = db.ExecContext(
+		ctx, "INSERT INTO member VALUES (?, ?, ?, ?, ?, ?)",
+		string(m.Id()), uhash[:], phash, m.Created(), m.Updated(), mdata,
+	)
+	if err != nil {
+		t.Fatalf("failed to store baseline member: %v", err)
+	}
+
+	c := doTestMigrateSqliteLegacyConversation(t, model.Uuid(m.Id()))
+	cdata, err := crypto.Encrypt(key, c.Table().Bytes)
+	if err != nil {
+		t.Fatalf("failed to encrypt conversation: %v", err)
+	}
+	_, err = db.ExecContext(
+		ctx, "INSERT INTO conversation VALUES (?, ?, ?, ?)", string(c.Id()), 0, 0, cdata,
+	)
+	if err != nil {
+		t.Fatalf("failed to store baseline conversation: %v", err)
+	}
+
+	msg, err := model.NewMessage(model.Uuid(m.Id()), model.Uuid(c.Id()), "", "Hello")
+	if err != nil {
+		t.Fatalf("failed to create message: %v", err)
+	}
+	msgdata, err := crypto.Encrypt(key, msg.Table().Bytes)
+	if err != nil {
+		t.Fatalf("failed to encrypt message: %v", err)
+	}
+	_, err = db.ExecContext(
+		ctx, "INSERT INTO message VALUES (?, ?, ?, ?, ?, ?)",
+		string(msg.Id()), string(c.Id()), string(m.Id()), msg.Created(), msg.Updated(), msgdata,
+	)
+	if err != nil {
+		t.Fatalf("failed to store baseline message: %v", err)
+	}
+
+	// Migrate the way the migrate command does, unlocking the group once the key is needed
+	_, err = sqlite.Migrate(ctx, db, nil, ikey, false)
+	if !errors.Is(err, sqlite.ErrKeyRequired) {
+		t.Fatalf("expected migration without key to require key: %v", err)
+	}
+	if _, err := sqlite.UnlockGroupKey(ctx, db, "BaselineGroup", "WrongPassword1234!", ikey); err == nil {
+		t.Errorf("partly migrated group unlocked with the wrong password")
+	}
+	ukey, err := sqlite.UnlockGroupKey(ctx, db, "BaselineGroup", pass, ikey)
+	if err != nil {
+		t.Fatalf("failed to unlock partly migrated group: %v", err)
+	}
+	_, err = sqlite.Migrate(ctx, db, ukey, ikey, false)
+	if err != nil {
+		t.Fatalf("failed to migrate baseline database: %v", err)
+	}
+	if pending := doTestMigrateSqlitePending(t, ctx, db); pending != 0 {
+		t.Errorf("migrations still pending after migrate: %d", pending)
+	}
+
+	// The migrated group unlocks through the group service, and its data is readable
+	gs := services.NewGroupService(
+		sqlite.NewGroupStore(db), sqlite.NewMemberStore(db), sqlite.NewConversationStore(db),
+		sqlite.NewInvitationStore(db), sqlite.NewSessionStore(db), sqlite.NewRekeyStore(db),
+		sqlite.NewTransactor(db), ikey,
+	)
+	builder := flatbuffers.NewBuilder(64)
+	gidOffset := builder.CreateString("BaselineGroup")
+	passOffset := builder.CreateString(string(pass))
+	services.GroupAuthenticateRequestStart(builder)
+	services.GroupAuthenticateRequestAddGroupId(builder, gidOffset)
+	services.GroupAuthenticateRequestAddPassword(builder, passOffset)
+	builder.Finish(services.GroupAuthenticateRequestEnd(builder))
+
+	akey, err := gs.Authenticate(
+		ctx, services.GetRootAsGroupAuthenticateRequest(builder.FinishedBytes(), 0),
+	)
+	if err != nil {
+		t.Fatalf("failed to unlock migrated group: %v", err)
+	}
+	if _, err := gs.Get(ctx, akey); err != nil {
+		t.Errorf("failed to get migrated group: %v", err)
+	}
+
+	es, err := sqlite.NewMessageStore(db).ListMessageEntities(
+		ctx, model.Uuid(c.Id()), store.ListMessageDataQuery{},
+	)
+	if err != nil || len(es) != 1 {
+		t.Fatalf("failed to list migrated messages: %v", err)
+	}
+	if _, err := es[0].Decrypt(akey); err != nil {
+		t.Errorf("failed to decrypt migrated message: %v", err)
+	}
+}
@@ -0,0 +1,50 @@
+-- The tables created by Kolob before schema migrations were introduced. Existing databases already
+-- have these tables, so they are only created when missing.
+
+CREATE TABLE IF NOT EXISTS [group] (
+    id      TEXT,
+    ghash   BLOB,
+    psalt   BLOB,
+    phash   BLOB,
+    ekey    BLOB,
+    created INTEGER,
+    updated INTEGER,
+    data    BLOB,
+
+    PRIMARY KEY (id),
+    UNIQUE (ghash)
+);
+
+CREATE TABLE IF NOT EXISTS member (
+    id      TEXT,
+    uhash   BLOB,
+    phash   BLOB,
+    created INTEGER,
+    updated INTEGER,
+    data    BLOB,
+
+    PRIMARY KEY (id),
+    UNIQUE (uhash)
+);
+
+CREATE TABLE IF NOT EXISTS conversation (
+    id      TEXT,
+    created INTEGER,
+    updated INTEGER,
+    data    BLOB,
+
+    PRIMARY KEY (id)
+);
+
+CREATE TABLE IF NOT EXISTS message (
+    id              TEXT,
+    conversation    TEXT,
+    author          TEXT,
+    created         INTEGER,
+    updated         INTEGER,
+    data            BLOB,
+
+    PRIMARY KEY (id),
+    FOREIGN KEY (conversation)  REFERENCES conversation(id) ON DELETE CASCADE,
+    FOREIGN KEY (author)        REFERENCES member(id)       ON DELETE SET NULL
+);
@@ -0,0 +1,5 @@
+-- Replies to a message reference the message that started the thread.
+
+ALTER TABLE message ADD COLUMN thread TEXT REFERENCES message(id) ON DELETE CASCADE;
+
+CREATE INDEX message_thread ON message (thread, created);
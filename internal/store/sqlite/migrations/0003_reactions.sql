@@ -0,0 +1,12 @@
+-- Each member can have one reaction to a message.
+
+CREATE TABLE reaction (
+    message TEXT,
+    author  TEXT,
+    created INTEGER,
+    data    BLOB,
+
+    PRIMARY KEY (message, author),
+    FOREIGN KEY (message)   REFERENCES message(id)  ON DELETE CASCADE,
+    FOREIGN KEY (author)    REFERENCES member(id)   ON DELETE CASCADE
+);
@@ -0,0 +1,14 @@
+-- The members that participate in a conversation, kept in plaintext alongside the encrypted
+-- conversation so that conversations can be filtered by participant. Existing conversations are
+-- given their moderators as participants by a rewrite of their encrypted data.
+
+CREATE TABLE participant (
+    conversation    TEXT,
+    member          TEXT,
+
+    PRIMARY KEY (conversation, member),
+    FOREIGN KEY (conversation)  REFERENCES conversation(id) ON DELETE CASCADE,
+    FOREIGN KEY (member)        REFERENCES member(id)       ON DELETE CASCADE
+);
+
+CREATE INDEX participant_member ON participant (member);
@@ -0,0 +1,10 @@
+-- The state of a data key rotation that has not finished yet. The new data key is encrypted with
+-- the key derived from the group password, so the rotation can be resumed after a crash.
+
+CREATE TABLE rekey (
+    id      INTEGER CHECK (id = 1),
+    ekey    BLOB,
+    started INTEGER,
+
+    PRIMARY KEY (id)
+);
@@ -0,0 +1,5 @@
+-- Encrypted data is bound to the identifiers stored alongside it in plaintext, such as the id and
+-- conversation of a message, so that it can't be moved to another row. The schema is
+-- unchanged; existing data is re-sealed by a rewrite.
+
+SELECT 1;
@@ -0,0 +1,4 @@
+-- The algorithm and parameters used to derive the key that encrypts the group data key from the
+-- group password. Groups created before they were recorded used PBKDF2 with 1,000,000 iterations.
+
+ALTER TABLE [group] ADD COLUMN kdf TEXT NOT NULL DEFAULT 'pbkdf2-sha256$i=1000000';
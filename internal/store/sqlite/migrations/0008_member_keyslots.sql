@@ -0,0 +1,7 @@
+-- Each member keeps a copy of the group data key encrypted with a key derived from their own
+-- password, so they can log in without the group password. Existing members get one the next
+-- time they log in with the group password.
+
+ALTER TABLE member ADD COLUMN ksalt BLOB;
+ALTER TABLE member ADD COLUMN kdf TEXT;
+ALTER TABLE member ADD COLUMN ekey BLOB;
@@ -0,0 +1,6 @@
+-- The username and group ID hashes used to look up members and authenticate the group are keyed
+-- with the server's index key instead of being plain SHA-256 hashes, so that they can't be checked
+-- against guessed values with only the database. The schema is unchanged; existing hashes are
+-- recomputed by a rewrite.
+
+SELECT 1;
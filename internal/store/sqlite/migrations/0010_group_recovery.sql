@@ -0,0 +1,6 @@
+-- The recovery kit of the group: the group data key encrypted with a recovery key that is split into
+-- shares, and the recovery key encrypted with the data key so that the first copy can be replaced
+-- when the data key is rotated. Both are NULL until a recovery kit is created.
+
+ALTER TABLE [group] ADD COLUMN rdkey BLOB;
+ALTER TABLE [group] ADD COLUMN rkey BLOB;
@@ -0,0 +1,16 @@
+-- Sessions of logged in members, kept in the database so that they survive a restart. The data key
+-- each session unlocks is encrypted with the session key held by the server. Sessions end with the
+-- member they belong to.
+
+CREATE TABLE session (
+    id      TEXT,
+    member  TEXT,
+    ekey    BLOB,
+    created INTEGER,
+    seen    INTEGER,
+
+    PRIMARY KEY (id),
+    FOREIGN KEY (member) REFERENCES member(id) ON DELETE CASCADE
+);
+
+CREATE INDEX session_seen ON session (seen);
@@ -0,0 +1,8 @@
+-- Sessions remember the client that started them, encrypted with the session key. Sessions started
+-- before this are ended, since there is nothing to show for them; members log in again.
+
+DELETE FROM session;
+
+ALTER TABLE session ADD COLUMN data BLOB;
+
+CREATE INDEX session_member ON session (member);
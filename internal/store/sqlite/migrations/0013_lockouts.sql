@@ -0,0 +1,12 @@
+-- Failed logins for each client address and username, so that password guessing is slowed down and
+-- eventually locked out even across restarts. Usernames are stored as their blind index.
+
+CREATE TABLE lockout (
+    kind        TEXT,
+    subject     TEXT,
+    failures    INTEGER,
+    failed      INTEGER,
+    until       INTEGER,
+
+    PRIMARY KEY (kind, subject)
+);
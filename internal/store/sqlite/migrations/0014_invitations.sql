@@ -0,0 +1,17 @@
+-- Invitations that let someone join the group by redeeming a single use code. Codes are stored as
+-- their blind index. Each invitation holds the data key encrypted with a key derived from its code.
+
+CREATE TABLE invitation (
+    id      TEXT,
+    chash   BLOB UNIQUE,
+    ksalt   BLOB,
+    kdf     TEXT,
+    ekey    BLOB,
+    created INTEGER,
+    expires INTEGER,
+    data    BLOB,
+
+    PRIMARY KEY (id)
+);
+
+CREATE INDEX invitation_expires ON invitation (expires);
@@ -0,0 +1,5 @@
+-- The recovery key was stored encrypted with the data key so that the recovery kit could be moved
+-- to a new data key, but that let anyone holding an old data key recover the new one. Rotating the
+-- data key now replaces the recovery kit instead, so the recovery key is no longer stored.
+
+ALTER TABLE [group] DROP COLUMN rkey;
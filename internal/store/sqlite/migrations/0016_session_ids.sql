@@ -0,0 +1,5 @@
+-- Sessions are stored by the SHA-256 hash of the token the client holds rather than by the token
+-- itself, so that reading the database doesn't give anyone a session to use. Sessions started
+-- before this are ended, since their tokens can't be hashed here; members log in again.
+
+DELETE FROM session;
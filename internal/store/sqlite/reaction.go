@@ -0,0 +1,85 @@
+// ---------------------------------------------------------------------------------------------- //
+// -- Copyright (c) 2024 Braden Hitchcock - MIT License (https://opensource.org/licenses/MIT)  -- //
+// ---------------------------------------------------------------------------------------------- //
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/bradenhc/kolob/internal/model"
+	"github.com/bradenhc/kolob/internal/store"
+)
+
+type ReactionStore struct {
+	db *sql.DB
+}
+
+func NewReactionStore(db *sql.DB) ReactionStore {
+	return ReactionStore{db}
+}
+
+func (s ReactionStore) PutReactionEntity(ctx context.Context, e store.ReactionEntity) error {
+	_, err := executor(ctx, s.db).ExecContext(
+		ctx,
+		"INSERT OR REPLACE INTO reaction VALUES (?, ?, ?, ?)",
+		e.Message, e.Author, e.CreatedAt, e.EncryptedData,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to store reaction in database: %v", err)
+	}
+
+	return nil
+}
+
+func (s ReactionStore) RemoveReactionEntity(ctx context.Context, mid, author model.Uuid) error {
+	query := "DELETE FROM reaction WHERE message = ? AND author = ?"
+	_, err := executor(ctx, s.db).ExecContext(ctx, query, mid, author)
+	if err != nil {
+		return fmt.Errorf("failed to remove reaction from database: %v", err)
+	}
+	return nil
+}
+
+func (s ReactionStore) ListReactionEntities(
+	ctx context.Context, mid model.Uuid,
+) ([]store.ReactionEntity, error) {
+	query := "SELECT * FROM reaction WHERE message = ? ORDER BY created"
+	return s.listReactionEntities(ctx, query, mid)
+}
+
+func (s ReactionStore) ListConversationReactionEntities(
+	ctx context.Context, cid model.Uuid,
+) ([]store.ReactionEntity, error) {
+	query := `
+		SELECT reaction.* FROM reaction
+		JOIN message ON reaction.message = message.id
+		WHERE message.conversation = ?
+		ORDER BY reaction.created
+	`
+	return s.listReactionEntities(ctx, query, cid)
+}
+
+func (s ReactionStore) listReactionEntities(
+	ctx context.Context, query string, args ...any,
+) ([]store.ReactionEntity, error) {
+	rows, err := executor(ctx, s.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch reactions from database: %v", err)
+	}
+	defer rows.Close()
+
+	entities := make([]store.ReactionEntity, 0)
+	for rows.Next() {
+		var e store.ReactionEntity
+		err := rows.Scan(&e.Message, &e.Author, &e.CreatedAt, &e.EncryptedData)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan reaction row: %v", err)
+		}
+
+		entities = append(entities, e)
+	}
+
+	return entities, nil
+}
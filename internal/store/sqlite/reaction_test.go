@@ -0,0 +1,124 @@
+// ---------------------------------------------------------------------------------------------- //
+// -- Copyright (c) 2024 Braden Hitchcock - MIT License (https://opensource.org/licenses/MIT)  -- //
+// ---------------------------------------------------------------------------------------------- //
+package sqlite_test
+
+import (
+	"context"
+	"database/sql"
+	"path"
+	"slices"
+	"testing"
+
+	"github.com/bradenhc/kolob/internal/crypto"
+	"github.com/bradenhc/kolob/internal/model"
+	"github.com/bradenhc/kolob/internal/store"
+	"github.com/bradenhc/kolob/internal/store/sqlite"
+)
+
+func TestReactionStoreSqlite(t *testing.T) {
+	// Setup the test
+	t.Parallel()
+	tempdir := t.TempDir()
+	dbpath := path.Join(tempdir, "kolob.db")
+
+	db, err := sqlite.Open(dbpath)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	// Create a random key we will use for encryption/decryption
+	key, err := crypto.NewRandomKey()
+	if err != nil {
+		t.Fatalf("failed to create encryption key: %v", err)
+	}
+
+	// Create a member, conversation, and message to react to
+	memberStore := doTestMemberStoreSqliteCreate(t, db)
+	memberId := doTestMemberStoreSqliteInsert(t, memberStore, key, doTestIndexKey(t))
+	conversationStore := doTestConversationStoreSqliteCreate(t, db)
+	conversationEntity := doTestConversationStoreSqliteInsert(t, conversationStore, key, memberId)
+	messageStore := doTestMessageStoreSqliteCreate(t, db)
+	messageId := doTestMessageStoreSqliteInsert(t, messageStore, key, memberId, conversationEntity.Id)
+
+	// Run tests on the reaction store
+	reactionStore := doTestReactionStoreSqliteCreate(t, db)
+	doTestReactionStoreSqlitePut(t, reactionStore, key, messageId, memberId, ":smile:")
+	doTestReactionStoreSqlitePut(t, reactionStore, key, messageId, memberId, ":tada:")
+	doTestReactionStoreSqliteList(t, reactionStore, key, conversationEntity.Id, messageId, ":tada:")
+	doTestReactionStoreSqliteRemove(t, reactionStore, messageId, memberId)
+}
+
+func doTestReactionStoreSqliteCreate(t *testing.T, db *sql.DB) store.ReactionStore {
+	return sqlite.NewReactionStore(db)
+}
+
+func doTestReactionStoreSqlitePut(
+	t *testing.T, s store.ReactionStore, k crypto.Key, messageId, memberId model.Uuid, emoji string,
+) {
+	r := model.NewReaction(messageId, memberId, emoji)
+	entity, err := store.NewReactionEntity(r, k)
+	if err != nil {
+		t.Fatalf("failed to create reaction entity: %v", err)
+	}
+
+	err = s.PutReactionEntity(context.Background(), entity)
+	if err != nil {
+		t.Fatalf("failed to put reaction entity: %v", err)
+	}
+}
+
+func doTestReactionStoreSqliteList(
+	t *testing.T,
+	s store.ReactionStore,
+	k crypto.Key,
+	conversationId, messageId model.Uuid,
+	emoji string,
+) {
+	entities, err := s.ListReactionEntities(context.Background(), messageId)
+	if err != nil {
+		t.Fatalf("failed to list message reactions: %v", err)
+	}
+
+	// The second reaction from the member should have replaced the first
+	if len(entities) != 1 {
+		t.Fatalf("expected a total of 1 reaction: got %d", len(entities))
+	}
+
+	r, err := entities[0].Decrypt(k)
+	if err != nil {
+		t.Fatalf("failed to decrypt reaction: %v", err)
+	}
+
+	if !slices.Equal(r.Emoji(), []byte(emoji)) {
+		t.Errorf("reaction emoji does not match: %s != %s", r.Emoji(), emoji)
+	}
+
+	entities, err = s.ListConversationReactionEntities(context.Background(), conversationId)
+	if err != nil {
+		t.Fatalf("failed to list conversation reactions: %v", err)
+	}
+
+	if len(entities) != 1 {
+		t.Errorf("expected a total of 1 conversation reaction: got %d", len(entities))
+	}
+}
+
+func doTestReactionStoreSqliteRemove(
+	t *testing.T, s store.ReactionStore, messageId, memberId model.Uuid,
+) {
+	err := s.RemoveReactionEntity(context.Background(), messageId, memberId)
+	if err != nil {
+		t.Fatalf("failed to remove reaction from store: %v", err)
+	}
+
+	entities, err := s.ListReactionEntities(context.Background(), messageId)
+	if err != nil {
+		t.Fatalf("failed to list reactions after remove: %v", err)
+	}
+
+	if len(entities) != 0 {
+		t.Errorf("expected no reactions after remove: got %d", len(entities))
+	}
+}
@@ -0,0 +1,205 @@
+// ---------------------------------------------------------------------------------------------- //
+// -- Copyright (c) 2024 Braden Hitchcock - MIT License (https://opensource.org/licenses/MIT)  -- //
+// ---------------------------------------------------------------------------------------------- //
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/bradenhc/kolob/internal/crypto"
+	"github.com/bradenhc/kolob/internal/model"
+	"github.com/bradenhc/kolob/internal/store"
+)
+
+type RekeyStore struct {
+	db *sql.DB
+}
+
+func NewRekeyStore(db *sql.DB) RekeyStore {
+	return RekeyStore{db}
+}
+
+// An encryptedTable is the table that holds a kind of encrypted entity, along with the columns
+// whose values the encrypted data is bound to, in the order the entity binds them.
+type encryptedTable struct {
+	name    string
+	columns []string
+}
+
+// encryptedTables maps each kind of encrypted entity to the table that holds it.
+var encryptedTables = map[store.EntityKind]encryptedTable{
+	store.GroupEntityKind:        {"group", []string{"id"}},
+	store.MemberEntityKind:       {"member", []string{"id"}},
+	store.ConversationEntityKind: {"conversation", []string{"id"}},
+	store.MessageEntityKind:      {"message", []string{"id", "conversation"}},
+	store.ReactionEntityKind:     {"reaction", []string{"message", "author"}},
+}
+
+func (s RekeyStore) IsRekeyInProgress(ctx context.Context) (bool, error) {
+	var count int
+	err := executor(ctx, s.db).QueryRowContext(ctx, "SELECT COUNT(*) FROM rekey").Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to check for data key rotation in sqlite db: %v", err)
+	}
+
+	return count != 0, nil
+}
+
+func (s RekeyStore) GetRekeyEntity(ctx context.Context) (store.RekeyEntity, error) {
+	var e store.RekeyEntity
+	err := executor(ctx, s.db).QueryRowContext(ctx, "SELECT ekey, started FROM rekey").Scan(
+		&e.EncryptedKey, &e.StartedAt,
+	)
+	if err != nil {
+		return e, fmt.Errorf("failed to get data key rotation from sqlite db: %v", err)
+	}
+
+	return e, nil
+}
+
+func (s RekeyStore) PutRekeyEntity(ctx context.Context, e store.RekeyEntity) error {
+	_, err := executor(ctx, s.db).ExecContext(
+		ctx, "INSERT OR REPLACE INTO rekey VALUES (1, ?, ?)", e.EncryptedKey, e.StartedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to store data key rotation in sqlite db: %v", err)
+	}
+
+	return nil
+}
+
+func (s RekeyStore) RemoveRekeyEntity(ctx context.Context) error {
+	_, err := executor(ctx, s.db).ExecContext(ctx, "DELETE FROM rekey")
+	if err != nil {
+		return fmt.Errorf("failed to remove data key rotation from sqlite db: %v", err)
+	}
+
+	return nil
+}
+
+// ListEncryptedData lists the encrypted data of up to limit entities of the given kind, in order of
+// their rowid and starting after the given rowid. The data is listed along with the associated data
+// it is bound to.
+func (s RekeyStore) ListEncryptedData(
+	ctx context.Context, kind store.EntityKind, after int64, limit int,
+) ([]store.EncryptedData, error) {
+	return listEncryptedData(ctx, executor(ctx, s.db), kind, after, limit)
+}
+
+func (s RekeyStore) UpdateEncryptedData(
+	ctx context.Context, kind store.EntityKind, row int64, data []byte,
+) error {
+	return updateEncryptedData(ctx, executor(ctx, s.db), kind, row, data)
+}
+
+func listEncryptedData(
+	ctx context.Context, db QueryExecutor, kind store.EntityKind, after int64, limit int,
+) ([]store.EncryptedData, error) {
+	table, ok := encryptedTables[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown entity kind: %s", kind)
+	}
+
+	query := fmt.Sprintf(
+		"SELECT rowid, data, %s FROM [%s] WHERE rowid > ? ORDER BY rowid LIMIT ?",
+		strings.Join(table.columns, ", "), table.name,
+	)
+	rows, err := db.QueryContext(ctx, query, after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s data from sqlite db: %v", table.name, err)
+	}
+	defer rows.Close()
+
+	ds := make([]store.EncryptedData, 0, limit)
+	for rows.Next() {
+		var d store.EncryptedData
+		ids := make([]sql.NullString, len(table.columns))
+		dest := []any{&d.Row, &d.Data}
+		for i := range ids {
+			dest = append(dest, &ids[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("failed to scan %s row: %v", table.name, err)
+		}
+
+		// Optional references like the thread of a message are stored as NULL but bound as empty
+		uuids := make([]model.Uuid, len(ids))
+		for i, id := range ids {
+			uuids[i] = model.Uuid(id.String)
+		}
+		d.AssociatedData = store.AssociatedData(kind, uuids...)
+
+		ds = append(ds, d)
+	}
+
+	return ds, nil
+}
+
+func updateEncryptedData(
+	ctx context.Context, db QueryExecutor, kind store.EntityKind, row int64, data []byte,
+) error {
+	table, ok := encryptedTables[kind]
+	if !ok {
+		return fmt.Errorf("unknown entity kind: %s", kind)
+	}
+
+	query := fmt.Sprintf("UPDATE [%s] SET data = ? WHERE rowid = ?", table.name)
+	_, err := db.ExecContext(ctx, query, data, row)
+	if err != nil {
+		return fmt.Errorf("failed to store %s data in sqlite db: %v", table.name, err)
+	}
+
+	return nil
+}
+
+// rewriteBoundEntities binds the encrypted data of every entity to the identifiers stored alongside
+// it (see store.AssociatedData). Data that is already bound, such as data rewritten by an earlier
+// migration, is left alone.
+func rewriteBoundEntities(
+	ctx context.Context, db QueryExecutor, key crypto.Key, _ crypto.IndexKey,
+) error {
+	kinds := append([]store.EntityKind{store.GroupEntityKind}, store.EncryptedEntityKinds...)
+	for _, kind := range kinds {
+		var after int64
+		for {
+			ds, err := listEncryptedData(ctx, db, kind, after, 100)
+			if err != nil {
+				return err
+			}
+			if len(ds) == 0 {
+				break
+			}
+			if key == nil {
+				return ErrKeyRequired
+			}
+
+			for _, d := range ds {
+				if _, err := crypto.DecryptAD(key, d.Data, d.AssociatedData); err == nil {
+					continue
+				}
+
+				data, err := crypto.Decrypt(key, d.Data)
+				if err != nil {
+					return fmt.Errorf("failed to decrypt %s data: %v", kind, err)
+				}
+
+				edata, err := crypto.EncryptAD(key, data, d.AssociatedData)
+				if err != nil {
+					return fmt.Errorf("failed to encrypt %s data: %v", kind, err)
+				}
+
+				err = updateEncryptedData(ctx, db, kind, d.Row, edata)
+				if err != nil {
+					return err
+				}
+			}
+
+			after = ds[len(ds)-1].Row
+		}
+	}
+
+	return nil
+}
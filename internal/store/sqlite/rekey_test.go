@@ -0,0 +1,133 @@
+// ---------------------------------------------------------------------------------------------- //
+// -- Copyright (c) 2024 Braden Hitchcock - MIT License (https://opensource.org/licenses/MIT)  -- //
+// ---------------------------------------------------------------------------------------------- //
+package sqlite_test
+
+import (
+	"context"
+	"path"
+	"slices"
+	"testing"
+
+	"github.com/bradenhc/kolob/internal/crypto"
+	"github.com/bradenhc/kolob/internal/store"
+	"github.com/bradenhc/kolob/internal/store/sqlite"
+)
+
+func TestRekeyStoreSqlite(t *testing.T) {
+	// Setup the test
+	t.Parallel()
+	tempdir := t.TempDir()
+	dbpath := path.Join(tempdir, "kolob.db")
+
+	db, err := sqlite.Open(dbpath)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	key, err := crypto.NewRandomKey()
+	if err != nil {
+		t.Fatalf("failed to create encryption key: %v", err)
+	}
+
+	// Store a few conversations whose data we can walk through
+	memberStore := doTestMemberStoreSqliteCreate(t, db)
+	memberId := doTestMemberStoreSqliteInsert(t, memberStore, key, doTestIndexKey(t))
+	conversationStore := doTestConversationStoreSqliteCreate(t, db)
+	for range 3 {
+		doTestConversationStoreSqliteInsert(t, conversationStore, key, memberId)
+	}
+
+	s := sqlite.NewRekeyStore(db)
+	doTestRekeyStoreSqliteProgress(t, s)
+	doTestRekeyStoreSqliteData(t, s)
+}
+
+func doTestRekeyStoreSqliteProgress(t *testing.T, s store.RekeyStore) {
+	ctx := context.Background()
+
+	ok, err := s.IsRekeyInProgress(ctx)
+	if err != nil {
+		t.Fatalf("failed to check rekey progress: %v", err)
+	}
+	if ok {
+		t.Fatalf("rekey in progress before one was started")
+	}
+
+	e := store.RekeyEntity{EncryptedKey: []byte("encrypted key"), StartedAt: 42}
+	err = s.PutRekeyEntity(ctx, e)
+	if err != nil {
+		t.Fatalf("failed to put rekey entity: %v", err)
+	}
+
+	ok, err = s.IsRekeyInProgress(ctx)
+	if err != nil {
+		t.Fatalf("failed to check rekey progress: %v", err)
+	}
+	if !ok {
+		t.Fatalf("rekey not in progress after one was started")
+	}
+
+	got, err := s.GetRekeyEntity(ctx)
+	if err != nil {
+		t.Fatalf("failed to get rekey entity: %v", err)
+	}
+	if !slices.Equal(got.EncryptedKey, e.EncryptedKey) || got.StartedAt != e.StartedAt {
+		t.Errorf("%+v != %+v", got, e)
+	}
+
+	err = s.RemoveRekeyEntity(ctx)
+	if err != nil {
+		t.Fatalf("failed to remove rekey entity: %v", err)
+	}
+
+	ok, err = s.IsRekeyInProgress(ctx)
+	if err != nil {
+		t.Fatalf("failed to check rekey progress: %v", err)
+	}
+	if ok {
+		t.Errorf("rekey still in progress after it was removed")
+	}
+}
+
+func doTestRekeyStoreSqliteData(t *testing.T, s store.RekeyStore) {
+	ctx := context.Background()
+
+	// Walk through the conversations two at a time
+	first, err := s.ListEncryptedData(ctx, store.ConversationEntityKind, 0, 2)
+	if err != nil {
+		t.Fatalf("failed to list conversation data: %v", err)
+	}
+	if len(first) != 2 {
+		t.Fatalf("wrong number of conversations in first batch: %d != %d", len(first), 2)
+	}
+
+	rest, err := s.ListEncryptedData(ctx, store.ConversationEntityKind, first[1].Row, 2)
+	if err != nil {
+		t.Fatalf("failed to list conversation data: %v", err)
+	}
+	if len(rest) != 1 {
+		t.Fatalf("wrong number of conversations in second batch: %d != %d", len(rest), 1)
+	}
+
+	// Replace the data of the last conversation
+	data := []byte("re-encrypted data")
+	err = s.UpdateEncryptedData(ctx, store.ConversationEntityKind, rest[0].Row, data)
+	if err != nil {
+		t.Fatalf("failed to update conversation data: %v", err)
+	}
+
+	rest, err = s.ListEncryptedData(ctx, store.ConversationEntityKind, first[1].Row, 2)
+	if err != nil {
+		t.Fatalf("failed to list conversation data: %v", err)
+	}
+	if len(rest) != 1 || !slices.Equal(rest[0].Data, data) {
+		t.Errorf("conversation data was not updated")
+	}
+
+	_, err = s.ListEncryptedData(ctx, store.EntityKind("unknown"), 0, 2)
+	if err == nil {
+		t.Errorf("expected listing an unknown kind of entity to fail")
+	}
+}
@@ -0,0 +1,139 @@
+// ---------------------------------------------------------------------------------------------- //
+// -- Copyright (c) 2024 Braden Hitchcock - MIT License (https://opensource.org/licenses/MIT)  -- //
+// ---------------------------------------------------------------------------------------------- //
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/bradenhc/kolob/internal/model"
+	"github.com/bradenhc/kolob/internal/store"
+)
+
+// sessionColumns names the columns of the session table in the order scanSessionEntity expects. The
+// columns are named because the data column was added to the table by a migration.
+const sessionColumns = "id, member, ekey, created, seen, data"
+
+type SessionStore struct {
+	db *sql.DB
+}
+
+func NewSessionStore(db *sql.DB) SessionStore {
+	return SessionStore{db}
+}
+
+func (s SessionStore) AddSessionEntity(ctx context.Context, e store.SessionEntity) error {
+	_, err := executor(ctx, s.db).ExecContext(
+		ctx, "INSERT INTO session ("+sessionColumns+") VALUES (?, ?, ?, ?, ?, ?)",
+		e.Id, e.Member, e.EncryptedKey, e.CreatedAt, e.LastSeenAt, e.EncryptedData,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to store session in sqlite db: %v", err)
+	}
+
+	return nil
+}
+
+func (s SessionStore) GetSessionEntity(
+	ctx context.Context, id model.Uuid,
+) (store.SessionEntity, error) {
+	e, err := scanSessionEntity(executor(ctx, s.db).QueryRowContext(
+		ctx, "SELECT "+sessionColumns+" FROM session WHERE id = ?", id,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return e, store.ErrSessionNotFound
+	}
+	if err != nil {
+		return e, fmt.Errorf("failed to get session from sqlite db: %v", err)
+	}
+
+	return e, nil
+}
+
+func (s SessionStore) ListSessionEntities(
+	ctx context.Context, member model.Uuid,
+) ([]store.SessionEntity, error) {
+	rows, err := executor(ctx, s.db).QueryContext(
+		ctx, "SELECT "+sessionColumns+" FROM session WHERE member = ? ORDER BY created", member,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions in sqlite db: %v", err)
+	}
+	defer rows.Close()
+
+	es := make([]store.SessionEntity, 0)
+	for rows.Next() {
+		e, err := scanSessionEntity(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan session from sqlite db: %v", err)
+		}
+		es = append(es, e)
+	}
+
+	return es, nil
+}
+
+func (s SessionStore) TouchSessionEntity(ctx context.Context, id model.Uuid, lastSeen int64) error {
+	_, err := executor(ctx, s.db).ExecContext(
+		ctx, "UPDATE session SET seen = ? WHERE id = ?", lastSeen, id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update session in sqlite db: %v", err)
+	}
+
+	return nil
+}
+
+func (s SessionStore) RemoveSessionEntity(ctx context.Context, id model.Uuid) error {
+	_, err := executor(ctx, s.db).ExecContext(ctx, "DELETE FROM session WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to remove session from sqlite db: %v", err)
+	}
+
+	return nil
+}
+
+func (s SessionStore) RemoveMemberSessionEntities(ctx context.Context, member model.Uuid) error {
+	_, err := executor(ctx, s.db).ExecContext(ctx, "DELETE FROM session WHERE member = ?", member)
+	if err != nil {
+		return fmt.Errorf("failed to remove member sessions from sqlite db: %v", err)
+	}
+
+	return nil
+}
+
+func (s SessionStore) RemoveAllSessionEntities(ctx context.Context) error {
+	_, err := executor(ctx, s.db).ExecContext(ctx, "DELETE FROM session")
+	if err != nil {
+		return fmt.Errorf("failed to remove sessions from sqlite db: %v", err)
+	}
+
+	return nil
+}
+
+func (s SessionStore) RemoveExpiredSessionEntities(
+	ctx context.Context, idleSince, createdSince int64,
+) (int, error) {
+	res, err := executor(ctx, s.db).ExecContext(
+		ctx, "DELETE FROM session WHERE seen < ? OR created < ?", idleSince, createdSince,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to remove expired sessions from sqlite db: %v", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count expired sessions in sqlite db: %v", err)
+	}
+
+	return int(n), nil
+}
+
+func scanSessionEntity(row scanner) (store.SessionEntity, error) {
+	var e store.SessionEntity
+	err := row.Scan(&e.Id, &e.Member, &e.EncryptedKey, &e.CreatedAt, &e.LastSeenAt, &e.EncryptedData)
+	return e, err
+}
@@ -0,0 +1,188 @@
+// ---------------------------------------------------------------------------------------------- //
+// -- Copyright (c) 2024 Braden Hitchcock - MIT License (https://opensource.org/licenses/MIT)  -- //
+// ---------------------------------------------------------------------------------------------- //
+package sqlite_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path"
+	"testing"
+
+	"github.com/bradenhc/kolob/internal/crypto"
+	"github.com/bradenhc/kolob/internal/model"
+	"github.com/bradenhc/kolob/internal/store"
+	"github.com/bradenhc/kolob/internal/store/sqlite"
+)
+
+func TestSessionStoreSqlite(t *testing.T) {
+	// Setup the test
+	t.Parallel()
+	tempdir := t.TempDir()
+	dbpath := path.Join(tempdir, "kolob-TestSessionStoreSqlite.db")
+
+	db, err := sqlite.Open(dbpath)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	key, err := crypto.NewRandomKey()
+	if err != nil {
+		t.Fatalf("failed to create encryption key: %v", err)
+	}
+	skey, err := crypto.NewRandomKey()
+	if err != nil {
+		t.Fatalf("failed to create session key: %v", err)
+	}
+
+	// Sessions belong to members
+	memberStore := doTestMemberStoreSqliteCreate(t, db)
+	memberId := doTestMemberStoreSqliteInsert(t, memberStore, key, doTestIndexKey(t))
+
+	s := sqlite.NewSessionStore(db)
+	id := doTestSessionStoreSqliteInsert(t, s, memberId, key, skey, 1000)
+	doTestSessionStoreSqliteGet(t, s, id, key, skey)
+	doTestSessionStoreSqliteExpire(t, s, memberId, key, skey)
+
+	// Sessions end with their member
+	id = doTestSessionStoreSqliteInsert(t, s, memberId, key, skey, 5000)
+	err = memberStore.RemoveMemberEntity(context.Background(), memberId)
+	if err != nil {
+		t.Fatalf("failed to remove member: %v", err)
+	}
+	_, err = s.GetSessionEntity(context.Background(), id)
+	if !errors.Is(err, store.ErrSessionNotFound) {
+		t.Errorf("session outlived its member: %v", err)
+	}
+}
+
+func doTestSessionStoreSqliteInsert(
+	t *testing.T, s sqlite.SessionStore, member model.Uuid, key, skey crypto.Key, now int64,
+) model.Uuid {
+	id, err := model.NewUuid()
+	if err != nil {
+		t.Fatalf("failed to create session ID: %v", err)
+	}
+
+	e, err := store.NewSessionEntity(
+		model.NewSession(id, member, "test-agent", "127.0.0.1", now, now), key, skey,
+	)
+	if err != nil {
+		t.Fatalf("failed to create session entity: %v", err)
+	}
+
+	err = s.AddSessionEntity(context.Background(), e)
+	if err != nil {
+		t.Fatalf("failed to add session entity: %v", err)
+	}
+
+	return id
+}
+
+func doTestSessionStoreSqliteGet(
+	t *testing.T, s sqlite.SessionStore, id model.Uuid, key, skey crypto.Key,
+) {
+	ctx := context.Background()
+
+	err := s.TouchSessionEntity(ctx, id, 2000)
+	if err != nil {
+		t.Fatalf("failed to touch session: %v", err)
+	}
+
+	e, err := s.GetSessionEntity(ctx, id)
+	if err != nil {
+		t.Fatalf("failed to get session: %v", err)
+	}
+	if e.CreatedAt != 1000 || e.LastSeenAt != 2000 {
+		t.Errorf("wrong session times: %d, %d", e.CreatedAt, e.LastSeenAt)
+	}
+
+	dkey, err := e.DecryptKey(skey)
+	if err != nil {
+		t.Fatalf("failed to decrypt session key: %v", err)
+	}
+	if !bytes.Equal(dkey, key) {
+		t.Errorf("session holds the wrong key")
+	}
+
+	m, err := e.Decrypt(skey)
+	if err != nil {
+		t.Fatalf("failed to decrypt session: %v", err)
+	}
+	if string(m.Agent()) != "test-agent" || string(m.Addr()) != "127.0.0.1" || m.Seen() != 2000 {
+		t.Errorf("wrong session information: %s, %s, %d", m.Agent(), m.Addr(), m.Seen())
+	}
+
+	// The key can't be moved to another session
+	e.Id = "another"
+	if _, err := e.DecryptKey(skey); err == nil {
+		t.Errorf("decrypted session key bound to another session")
+	}
+
+	_, err = s.GetSessionEntity(ctx, "missing")
+	if !errors.Is(err, store.ErrSessionNotFound) {
+		t.Errorf("expected missing session to not be found: %v", err)
+	}
+}
+
+func doTestSessionStoreSqliteExpire(
+	t *testing.T, s sqlite.SessionStore, member model.Uuid, key, skey crypto.Key,
+) {
+	ctx := context.Background()
+
+	idle := doTestSessionStoreSqliteInsert(t, s, member, key, skey, 1500)
+	old := doTestSessionStoreSqliteInsert(t, s, member, key, skey, 500)
+	err := s.TouchSessionEntity(ctx, old, 3000)
+	if err != nil {
+		t.Fatalf("failed to touch session: %v", err)
+	}
+
+	// The idle session was last seen too long ago and the old one was created too long ago
+	n, err := s.RemoveExpiredSessionEntities(ctx, 1800, 800)
+	if err != nil {
+		t.Fatalf("failed to remove expired sessions: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("wrong number of expired sessions removed: %d != 2", n)
+	}
+	for _, id := range []model.Uuid{idle, old} {
+		if _, err := s.GetSessionEntity(ctx, id); !errors.Is(err, store.ErrSessionNotFound) {
+			t.Errorf("expired session %s was not removed: %v", id, err)
+		}
+	}
+
+	// Sessions are listed by member and can be ended together
+	first := doTestSessionStoreSqliteInsert(t, s, member, key, skey, 3000)
+	second := doTestSessionStoreSqliteInsert(t, s, member, key, skey, 3500)
+	es, err := s.ListSessionEntities(ctx, member)
+	if err != nil {
+		t.Fatalf("failed to list sessions: %v", err)
+	}
+	// The first session added by the test is still there too
+	if len(es) != 3 || es[1].Id != first || es[2].Id != second {
+		t.Errorf("wrong number or order of sessions listed: %d", len(es))
+	}
+	err = s.RemoveMemberSessionEntities(ctx, member)
+	if err != nil {
+		t.Fatalf("failed to remove member sessions: %v", err)
+	}
+	es, err = s.ListSessionEntities(ctx, member)
+	if err != nil {
+		t.Fatalf("failed to list sessions: %v", err)
+	}
+	if len(es) != 0 {
+		t.Errorf("member sessions were not removed: %d", len(es))
+	}
+
+	// Removing every session leaves none behind
+	other := doTestSessionStoreSqliteInsert(t, s, member, key, skey, 4000)
+	err = s.RemoveAllSessionEntities(ctx)
+	if err != nil {
+		t.Fatalf("failed to remove sessions: %v", err)
+	}
+	if _, err := s.GetSessionEntity(ctx, other); !errors.Is(err, store.ErrSessionNotFound) {
+		t.Errorf("session was not removed: %v", err)
+	}
+}
@@ -4,26 +4,54 @@
 package sqlite
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 
 	_ "modernc.org/sqlite"
 )
 
+// connectionParams are applied to every connection the driver opens, not just the first one:
+//
+//   - foreign key constraints are enforced;
+//   - a connection waits for a while on a locked database before failing with SQLITE_BUSY;
+//   - transactions take the write lock when they begin, so that two transactions that read before
+//     they write cannot deadlock each other.
+const connectionParams = "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_txlock=immediate"
+
 // Open creates a new connection to an SQLite database on the filesystem at the provided path and
-// initializes it with settings needed to support the various stores.
+// initializes it with settings needed to support the various stores. Any pending schema migrations
+// are applied before the database is returned; see Migrate.
 //
 // The returned DB handle is safe to use throughout the lifetime of the program and by multiple
 // goroutines; therefore, Open should only be called once when the program starts.
 func Open(path string) (*sql.DB, error) {
-	db, err := sql.Open("sqlite", path)
+	db, err := Connect(path)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = Migrate(context.Background(), db, nil, nil, false)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate database: %v", err)
+	}
+
+	return db, nil
+}
+
+// Connect is like Open, but leaves the database schema as it is. It is meant for tools that manage
+// the database themselves, such as the migrate command.
+func Connect(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", path+"?"+connectionParams)
 	if err != nil {
 		return nil, err
 	}
 
-	_, err = db.Exec("PRAGMA foreign_keys = ON")
+	err = db.Ping()
 	if err != nil {
-		return nil, fmt.Errorf("failed to enable foreign keys: %v", err)
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to database: %v", err)
 	}
 
 	return db, nil
@@ -0,0 +1,107 @@
+// ---------------------------------------------------------------------------------------------- //
+// -- Copyright (c) 2024 Braden Hitchcock - MIT License (https://opensource.org/licenses/MIT)  -- //
+// ---------------------------------------------------------------------------------------------- //
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// txKey is the context key used to carry the transaction of a unit of work to the stores.
+type txKey struct{}
+
+// A Transactor runs units of work against an SQLite database in a single transaction.
+type Transactor struct {
+	db *sql.DB
+}
+
+func NewTransactor(db *sql.DB) Transactor {
+	return Transactor{db}
+}
+
+// maxTxAttempts is the number of times a unit of work is tried before giving up on a busy database.
+const maxTxAttempts = 5
+
+// txRetryDelay is how long to wait before the first retry of a unit of work. The delay doubles
+// with every retry after that.
+const txRetryDelay = 10 * time.Millisecond
+
+// InTransaction calls f with a context that carries a new transaction. Store calls made with that
+// context run in the transaction, which is committed if f succeeds and rolled back otherwise. If
+// the context already carries a transaction, f joins it instead of starting a new one.
+//
+// If the database is busy because another connection holds a conflicting lock, the whole unit of
+// work is rolled back and tried again a few times before the error is returned. Since f may run
+// more than once, it must not have side effects outside of the database.
+func (t Transactor) InTransaction(ctx context.Context, f func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return f(ctx)
+	}
+
+	delay := txRetryDelay
+	for attempt := 1; ; attempt++ {
+		err := t.run(ctx, f)
+		if err == nil || !isBusy(err) || attempt == maxTxAttempts {
+			return err
+		}
+
+		slog.Warn("Database is busy, retrying transaction", "attempt", attempt)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("transaction cancelled while database is busy: %v", err)
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+func (t Transactor) run(ctx context.Context, f func(ctx context.Context) error) error {
+	tx, err := t.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	err = f(context.WithValue(ctx, txKey{}, tx))
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return nil
+}
+
+// isBusy reports whether the error was caused by the database being locked by another connection.
+// Errors from the stores are wrapped as text, so the message is checked when the original SQLite
+// error is no longer available.
+func isBusy(err error) bool {
+	var serr *sqlite.Error
+	if errors.As(err, &serr) {
+		return serr.Code()&0xff == sqlite3.SQLITE_BUSY
+	}
+
+	msg := err.Error()
+	return strings.Contains(msg, "(SQLITE_BUSY)") || strings.Contains(msg, "database is locked")
+}
+
+// executor returns the transaction carried by the context if there is one. Otherwise the database
+// is returned so that the query runs on its own.
+func executor(ctx context.Context, db *sql.DB) QueryExecutor {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return tx
+	}
+	return db
+}
@@ -0,0 +1,208 @@
+// ---------------------------------------------------------------------------------------------- //
+// -- Copyright (c) 2024 Braden Hitchcock - MIT License (https://opensource.org/licenses/MIT)  -- //
+// ---------------------------------------------------------------------------------------------- //
+package sqlite_test
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sync"
+	"testing"
+
+	"github.com/bradenhc/kolob/internal/crypto"
+	"github.com/bradenhc/kolob/internal/model"
+	"github.com/bradenhc/kolob/internal/store"
+	"github.com/bradenhc/kolob/internal/store/sqlite"
+)
+
+func TestTransactorSqlite(t *testing.T) {
+	// Setup the test
+	t.Parallel()
+	tempdir := t.TempDir()
+	dbpath := path.Join(tempdir, "kolob-TestTransactorSqlite.db")
+
+	db, err := sqlite.Open(dbpath)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	doTestMemberStoreSqliteCreate(t, db)
+	s := doTestConversationStoreSqliteCreate(t, db)
+	tx := sqlite.NewTransactor(db)
+
+	key, err := crypto.NewRandomKey()
+	if err != nil {
+		t.Fatalf("failed to create random key: %v", err)
+	}
+
+	// Run the tests
+	ctx := context.Background()
+	doTestTransactorSqliteCommit(t, ctx, tx, s, key)
+	doTestTransactorSqliteRollback(t, ctx, tx, s, key)
+	doTestTransactorSqliteNested(t, ctx, tx, s, key)
+	doTestTransactorSqliteForeignKeys(t, ctx, tx, s, key)
+	doTestTransactorSqliteConcurrent(t, ctx, tx, s, key)
+}
+
+func doTestTransactorSqliteConversation(
+	t *testing.T, key crypto.Key, participants ...model.Uuid,
+) store.ConversationEntity {
+	c, err := model.NewConversation("Name", "Description", nil, participants)
+	if err != nil {
+		t.Fatalf("failed to create conversation: %v", err)
+	}
+
+	e, err := store.NewConversationEntity(c, key)
+	if err != nil {
+		t.Fatalf("failed to create conversation entity: %v", err)
+	}
+
+	return e
+}
+
+func doTestTransactorSqliteCount(
+	t *testing.T, ctx context.Context, s store.ConversationStore, expected int,
+) {
+	count, err := s.CountConversationEntities(ctx)
+	if err != nil {
+		t.Fatalf("failed to count conversations: %v", err)
+	}
+	if count != expected {
+		t.Errorf("wrong number of conversations: %d != %d", count, expected)
+	}
+}
+
+func doTestTransactorSqliteCommit(
+	t *testing.T,
+	ctx context.Context,
+	tx sqlite.Transactor,
+	s store.ConversationStore,
+	key crypto.Key,
+) {
+	err := tx.InTransaction(ctx, func(ctx context.Context) error {
+		for range 2 {
+			err := s.AddConversationEntity(ctx, doTestTransactorSqliteConversation(t, key))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to run transaction: %v", err)
+	}
+
+	doTestTransactorSqliteCount(t, ctx, s, 2)
+}
+
+func doTestTransactorSqliteRollback(
+	t *testing.T,
+	ctx context.Context,
+	tx sqlite.Transactor,
+	s store.ConversationStore,
+	key crypto.Key,
+) {
+	err := tx.InTransaction(ctx, func(ctx context.Context) error {
+		err := s.AddConversationEntity(ctx, doTestTransactorSqliteConversation(t, key))
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("failed on purpose")
+	})
+	if err == nil {
+		t.Fatalf("expected transaction to fail")
+	}
+
+	doTestTransactorSqliteCount(t, ctx, s, 2)
+}
+
+func doTestTransactorSqliteNested(
+	t *testing.T,
+	ctx context.Context,
+	tx sqlite.Transactor,
+	s store.ConversationStore,
+	key crypto.Key,
+) {
+	// The inner unit of work joins the outer one, so its changes are lost when the outer one fails
+	err := tx.InTransaction(ctx, func(ctx context.Context) error {
+		err := tx.InTransaction(ctx, func(ctx context.Context) error {
+			return s.AddConversationEntity(ctx, doTestTransactorSqliteConversation(t, key))
+		})
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("failed on purpose")
+	})
+	if err == nil {
+		t.Fatalf("expected transaction to fail")
+	}
+
+	doTestTransactorSqliteCount(t, ctx, s, 2)
+}
+
+func doTestTransactorSqliteForeignKeys(
+	t *testing.T,
+	ctx context.Context,
+	tx sqlite.Transactor,
+	s store.ConversationStore,
+	key crypto.Key,
+) {
+	missing, err := model.NewUuid()
+	if err != nil {
+		t.Fatalf("failed to create uuid: %v", err)
+	}
+
+	// Foreign keys must be enforced on whichever connection the transaction ends up using
+	for range 4 {
+		err := tx.InTransaction(ctx, func(ctx context.Context) error {
+			e := doTestTransactorSqliteConversation(t, key, missing)
+			return s.AddConversationEntity(ctx, e)
+		})
+		if err == nil {
+			t.Fatalf("expected participant without a member to be refused")
+		}
+	}
+
+	doTestTransactorSqliteCount(t, ctx, s, 2)
+}
+
+func doTestTransactorSqliteConcurrent(
+	t *testing.T,
+	ctx context.Context,
+	tx sqlite.Transactor,
+	s store.ConversationStore,
+	key crypto.Key,
+) {
+	entities := make([]store.ConversationEntity, 8)
+	for i := range entities {
+		entities[i] = doTestTransactorSqliteConversation(t, key)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(entities))
+	for _, e := range entities {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- tx.InTransaction(ctx, func(ctx context.Context) error {
+				_, err := s.CountConversationEntities(ctx)
+				if err != nil {
+					return err
+				}
+				return s.AddConversationEntity(ctx, e)
+			})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("concurrent transaction failed: %v", err)
+		}
+	}
+
+	doTestTransactorSqliteCount(t, ctx, s, 10)
+}
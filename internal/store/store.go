@@ -5,11 +5,19 @@ package store
 
 import (
 	"context"
+	"errors"
 
 	"github.com/bradenhc/kolob/internal/crypto"
 	"github.com/bradenhc/kolob/internal/model"
 )
 
+// A Transactor runs a unit of work made up of several store calls in a single transaction. The
+// stores take part in the transaction when they are called with the context given to the unit of
+// work; if the unit of work returns an error, none of its changes are kept.
+type Transactor interface {
+	InTransaction(ctx context.Context, f func(ctx context.Context) error) error
+}
+
 type GroupStore interface {
 	AddGroupEntity(ctx context.Context, e GroupEntity) error
 	IsGroupDataSet(ctx context.Context) (bool, error)
@@ -24,14 +32,22 @@ type MemberStore interface {
 	UpdateMemberEntity(ctx context.Context, e MemberEntity) error
 	RemoveMemberEntity(ctx context.Context, id model.Uuid) error
 	ListMemberEntities(ctx context.Context) ([]MemberEntity, error)
+	RemoveMemberKeyslots(ctx context.Context) error
 }
 
+// A ConversationStore keeps track of the members that participate in each conversation using the
+// participants of the entities it is given. Entities returned from the store do not have their
+// participants set; they are found in the decrypted conversation instead.
 type ConversationStore interface {
 	AddConversationEntity(ctx context.Context, e ConversationEntity) error
 	GetConversationEntity(ctx context.Context, id model.Uuid) (ConversationEntity, error)
 	UpdateConversationEntity(ctx context.Context, e ConversationEntity) error
 	RemoveConversationEntity(ctx context.Context, id model.Uuid) error
 	ListConversationEntities(ctx context.Context) ([]ConversationEntity, error)
+	CountConversationEntities(ctx context.Context) (int, error)
+	ListParticipatingConversationEntities(ctx context.Context, member model.Uuid) ([]ConversationEntity, error)
+	IsConversationParticipant(ctx context.Context, id, member model.Uuid) (bool, error)
+	ListConversationParticipants(ctx context.Context, id model.Uuid) ([]model.Uuid, error)
 }
 
 type MessageStore interface {
@@ -40,9 +56,92 @@ type MessageStore interface {
 	UpdateMessageEntity(ctx context.Context, m MessageEntity) error
 	RemoveMessageEntity(ctx context.Context, id model.Uuid) error
 	ListMessageEntities(ctx context.Context, cid model.Uuid, q ListMessageDataQuery) ([]MessageEntity, error)
+	CountConversationReplies(ctx context.Context, cid model.Uuid) (map[model.Uuid]int, error)
+}
+
+// A ReactionStore holds at most one reaction per member per message. Putting a reaction for a
+// member that has already reacted to the message replaces their previous reaction.
+type ReactionStore interface {
+	PutReactionEntity(ctx context.Context, e ReactionEntity) error
+	RemoveReactionEntity(ctx context.Context, mid, author model.Uuid) error
+	ListReactionEntities(ctx context.Context, mid model.Uuid) ([]ReactionEntity, error)
+	ListConversationReactionEntities(ctx context.Context, cid model.Uuid) ([]ReactionEntity, error)
+}
+
+// A RekeyStore keeps the state of a data key rotation and gives access to every piece of data
+// encrypted with the group data key, so that all of it can be re-encrypted with a new key.
+type RekeyStore interface {
+	IsRekeyInProgress(ctx context.Context) (bool, error)
+	GetRekeyEntity(ctx context.Context) (RekeyEntity, error)
+	PutRekeyEntity(ctx context.Context, e RekeyEntity) error
+	RemoveRekeyEntity(ctx context.Context) error
+	ListEncryptedData(ctx context.Context, kind EntityKind, after int64, limit int) ([]EncryptedData, error)
+	UpdateEncryptedData(ctx context.Context, kind EntityKind, row int64, data []byte) error
+}
+
+// ErrSessionNotFound is returned by a SessionStore asked for a session it doesn't have.
+var ErrSessionNotFound = errors.New("session not found")
+
+// A SessionStore keeps the sessions of logged in members. Times are in milliseconds since the Unix
+// epoch. Stores that hold sessions in memory zero the encrypted keys of the sessions they remove.
+type SessionStore interface {
+	AddSessionEntity(ctx context.Context, e SessionEntity) error
+	GetSessionEntity(ctx context.Context, id model.Uuid) (SessionEntity, error)
+	ListSessionEntities(ctx context.Context, member model.Uuid) ([]SessionEntity, error)
+	TouchSessionEntity(ctx context.Context, id model.Uuid, lastSeen int64) error
+	RemoveSessionEntity(ctx context.Context, id model.Uuid) error
+	RemoveMemberSessionEntities(ctx context.Context, member model.Uuid) error
+	RemoveAllSessionEntities(ctx context.Context) error
+	RemoveExpiredSessionEntities(ctx context.Context, idleSince, createdSince int64) (int, error)
+}
+
+// ErrInvitationNotFound is returned by an InvitationStore asked for an invitation it doesn't have.
+var ErrInvitationNotFound = errors.New("invitation not found")
+
+// An InvitationStore keeps the invitations that haven't been redeemed yet. Times are in milliseconds
+// since the Unix epoch. Removing an invitation that isn't stored returns ErrInvitationNotFound, so
+// that only one of several attempts to redeem the same invitation succeeds.
+type InvitationStore interface {
+	AddInvitationEntity(ctx context.Context, e InvitationEntity) error
+	GetInvitationEntityByCode(ctx context.Context, chash crypto.DataHash) (InvitationEntity, error)
+	ListInvitationEntities(ctx context.Context) ([]InvitationEntity, error)
+	RemoveInvitationEntity(ctx context.Context, id model.Uuid) error
+	RemoveInvitationEntities(ctx context.Context) error
+	RemoveExpiredInvitationEntities(ctx context.Context, now int64) (int, error)
+}
+
+// A LockoutStore counts failed logins for each client address and username. Getting a lockout that
+// isn't stored returns one without any failures rather than an error.
+type LockoutStore interface {
+	GetLockoutEntity(ctx context.Context, kind LockoutKind, subject string) (LockoutEntity, error)
+	PutLockoutEntity(ctx context.Context, e LockoutEntity) error
+	RemoveLockoutEntity(ctx context.Context, kind LockoutKind, subject string) error
+	ListLockoutEntities(ctx context.Context) ([]LockoutEntity, error)
+	RemoveStaleLockoutEntities(ctx context.Context, failedBefore, now int64) (int, error)
+}
+
+// An EntityKind names a kind of entity whose data is encrypted with the group data key.
+type EntityKind string
+
+const (
+	GroupEntityKind        EntityKind = "group"
+	MemberEntityKind       EntityKind = "member"
+	ConversationEntityKind EntityKind = "conversation"
+	MessageEntityKind      EntityKind = "message"
+	ReactionEntityKind     EntityKind = "reaction"
+)
+
+// EncryptedEntityKinds lists every kind of entity encrypted with the group data key that a
+// RekeyStore gives access to. The group entity itself is not listed since its data is rewritten
+// together with its encrypted key.
+var EncryptedEntityKinds = []EntityKind{
+	MemberEntityKind, ConversationEntityKind, MessageEntityKind, ReactionEntityKind,
 }
 
+// ListMessageDataQuery filters the messages listed from a conversation. Replies are only listed
+// when Thread is set, in which case only the replies in the thread of that message are listed.
 type ListMessageDataQuery struct {
+	Thread        *model.Uuid
 	Author        *model.Uuid
 	CreatedAfter  *int64
 	CreatedBefore *int64
@@ -7,3 +7,6 @@ flatc --go --gen-onefile --go-namespace services -o "$here/../internal/services"
 flatc --go --gen-onefile --go-namespace services -o "$here/../internal/services" "$here/svc_member.fbs"
 flatc --go --gen-onefile --go-namespace services -o "$here/../internal/services" "$here/svc_conversation.fbs"
 flatc --go --gen-onefile --go-namespace services -o "$here/../internal/services" "$here/svc_message.fbs"
+flatc --go --gen-onefile --go-namespace services -o "$here/../internal/services" "$here/svc_session.fbs"
+flatc --go --gen-onefile --go-namespace services -o "$here/../internal/services" "$here/svc_reaction.fbs"
+flatc --go --gen-onefile --go-namespace services -o "$here/../internal/services" "$here/svc_invitation.fbs"